@@ -0,0 +1,90 @@
+// Package main is the entry point for the administration of Wits from the command line
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+)
+
+const usage = `Usage: admin bootstrap <email>
+
+Commands:
+  bootstrap <email>  Grants the admin role to the registered user with the email, as long as there is no admin yet`
+
+func main() {
+	slog.Info("💬 👑 (cmd/admin/main.go) 🥦 Welcome to Wits Administration!")
+	if len(os.Args) != 3 || os.Args[1] != "bootstrap" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	db, err := storage.NewBun(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keyring, err := storage.NewKeyringFromConfig(storage.NewBunDataKeyRepository(db), cfg.Encryption)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := bootstrap(ctx, storage.NewBunRepositories(db, keyring), os.Args[2]); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("✅ 👑 (cmd/admin/main.go) 🥦 Wits Administration finished!")
+}
+
+// bootstrap grants the admin role to the first admin, who can then manage all other users from the admin area.
+func bootstrap(ctx context.Context, repos *storage.Repositories, email string) error {
+	slog.Info("💬 👑 (cmd/admin/main.go) bootstrap()", "email", email)
+	admins, err := repos.Accounts.CountAccountsByRole(ctx, types.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return errors.New("an admin already exists, further admins are appointed in the admin area")
+	}
+	user, err := repos.Users.GetAuthenticatedUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user registered with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+	account, err := repos.Accounts.GetAccountByUserID(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		account = types.Account{ID: uuid.New(), UserID: user.ID}
+	} else if err != nil {
+		return err
+	}
+	account.Role = types.RoleAdmin
+	account.DisabledAt = time.Time{}
+	if err := repos.Accounts.SaveAccount(ctx, &account); err != nil {
+		return err
+	}
+	if err := repos.AuditEvents.CreateAuditEvent(ctx, &types.AuditEvent{
+		Action:    types.AuditActionRoleChange,
+		AccountID: user.ID,
+		Details:   user.Email + " " + string(types.RoleAdmin) + " by bootstrap",
+	}); err != nil {
+		slog.Error("🚨 👑 (cmd/admin/main.go) ❓❓❓❓ 🗒️  Recording bootstrap failed with", "error", err)
+	}
+	slog.Info("🆗 👑 (cmd/admin/main.go)  👑 User has been made the first admin with", "email", user.Email)
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package main is the entry point for managing the encryption keys of Wits from the command line
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+)
+
+const usage = `Usage: keys <command>
+
+Commands:
+  generate  Prints a new random master key for ENCRYPTION_MASTER_KEY
+  rewrap    Wraps all data keys with ENCRYPTION_MASTER_KEY, which are still wrapped with one of the
+            ENCRYPTION_PREVIOUS_MASTER_KEYS. Afterwards the previous master keys can be removed.
+  rotate    Replaces the data keys of all accounts and re-encrypts the sensitive data with the new ones, which
+            encrypts data stored in plain text before the master key had been set as well
+
+Rotating the master key: set the new key as ENCRYPTION_MASTER_KEY, move the old one to
+ENCRYPTION_PREVIOUS_MASTER_KEYS, run rewrap and then remove the old key.`
+
+func main() {
+	slog.Info("💬 🔐 (cmd/keys/main.go) 🥦 Welcome to Wits Key Management!")
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(context.Background(), os.Args[1]); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("✅ 🔐 (cmd/keys/main.go) 🥦 Wits Key Management finished!")
+}
+
+// run runs the command.
+func run(ctx context.Context, cmd string) error {
+	slog.Info("💬 🔐 (cmd/keys/main.go) run()", "command", cmd)
+	switch cmd {
+	case "generate":
+		key, err := storage.GenerateMasterKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case "rewrap", "rotate":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	db, err := storage.NewBun(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	keyring, err := storage.NewKeyringFromConfig(storage.NewBunDataKeyRepository(db), cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errors.New("ENCRYPTION_MASTER_KEY is not set, generate one first")
+	}
+
+	if cmd == "rewrap" {
+		n, err := keyring.Rewrap(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("🆗 🔐 (cmd/keys/main.go)  🔑 Data keys have been wrapped with the current master key", "count", n)
+		return nil
+	}
+	n, err := keyring.RotateDataKeys(ctx, db)
+	if err != nil {
+		return err
+	}
+	slog.Info("🆗 🔐 (cmd/keys/main.go)  🔑 Data keys have been rotated, re-encrypting", "rows", n)
+	return nil
+}
@@ -2,71 +2,343 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+const usage = `Usage: migrate [flags] <command> [args]
+
+Commands:
+  status          Shows the current version, whether it is dirty and the pending migrations
+  up [N]          Applies the next N pending migrations, or all of them
+  down [N]        Rolls back the last N applied migrations, or all of them, after a confirmation
+  goto V          Applies or rolls back the migrations up to version V
+  force V         Sets the version to V without running migrations, e.g. to clean up a dirty version
+  create NAME     Creates empty up and down migrations NAME in the migrations directories of Postgres and SQLite
+  reset           Drops all tables of the database after a confirmation, replacing all migrations
 
-	"github.com/joho/godotenv"
+The database is the one of DB_DRIVER, either postgres (the default) or sqlite.
 
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+Flags:`
+
+var (
+	dryRun = flag.Bool("dry-run", false, "print the SQL of the migrations instead of running them")
+	yes    = flag.Bool("yes", false, "skip the confirmation of down, goto and reset")
+	dir    = flag.String("dir", "pkg/storage/migrations", "the directory create writes new migrations to, and those of SQLite to its sqlite directory")
 )
 
 func main() {
 	slog.Info("💬 💾 (cmd/migrate/main.go) 🥦 Welcome to Wits Database Migrator!")
-	db, err := createDB()
-	if err != nil {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
 		log.Fatal(err)
 	}
+	slog.Info("✅ 💾 (cmd/migrate/main.go) 🥦 Wits Database Migrator finished!")
+}
+
+// run runs the command with its arguments.
+func run(cmd string, args []string) error {
+	slog.Info("💬 💾 (cmd/migrate/main.go) run()", "command", cmd, "args", args, "dryRun", *dryRun)
+	if cmd == "create" {
+		if len(args) != 1 {
+			return errors.New("create needs the NAME of the migration")
+		}
+		// Both drivers get the same version, so their schemas do not drift apart
+		now := time.Now()
+		for _, d := range []string{*dir, filepath.Join(*dir, migrations.Dir(storage.DBDriverSQLite))} {
+			files, err := createMigration(d, args[0], now)
+			for _, file := range files {
+				fmt.Println(file)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	db, err := createDB(cfg.Database)
+	if err != nil {
+		return err
+	}
 	defer db.Close()
+	if cmd == "reset" {
+		return reset(db, cfg.Database)
+	}
 
-	// Create migration instance
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	m, src, err := newMigrate(db, cfg.Database.Driver)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	all, err := loadMigrations(src)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := m.Version()
+	applied := !errors.Is(err, migrate.ErrNilVersion)
+	if err != nil && applied {
+		return err
+	}
+	current, err := indexOf(all, version, applied)
+	if err != nil {
+		return err
 	}
 
-	// Point to your migration files. Here we're using local files, but it could be other sources.
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://cmd/migrate/migrations", // source URL
-		"postgres",                      // driver name
-		driver,                          // instance
-	)
+	var steps []step
+	var target uint
+	switch cmd {
+	case "status":
+		return status(version, dirty, applied, all[current+1:])
+	case "up":
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		steps, err = planUp(all, current, n)
+		if err != nil {
+			return err
+		}
+	case "down":
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		steps, err = planDown(all, current, n)
+		if err != nil {
+			return err
+		}
+	case "goto":
+		if target, err = versionArg(args); err != nil {
+			return err
+		}
+		steps, err = planGoto(all, current, target)
+		if err != nil {
+			return err
+		}
+	case "force":
+		if target, err = versionArg(args); err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Printf("-- force version %d\n", target)
+			return nil
+		}
+		return m.Force(int(target))
+	default:
+		return fmt.Errorf("unknown command %q, see migrate -help", cmd)
+	}
+
+	if len(steps) == 0 {
+		slog.Info("🆗 💾 (cmd/migrate/main.go)  💾 No change, the database is at the requested version")
+		return nil
+	}
+	if *dryRun {
+		return printSteps(os.Stdout, src, steps)
+	}
+	if !steps[0].Up && !confirm(fmt.Sprintf("Roll back %d migration(s) of database %s, down to before version %d?", len(steps), cfg.Database.Name, steps[len(steps)-1].Version), "y") {
+		return errors.New("rolling back has been cancelled")
+	}
+	if cmd == "goto" {
+		err = m.Migrate(target)
+	} else if steps[0].Up {
+		err = m.Steps(len(steps))
+	} else {
+		err = m.Steps(-len(steps))
+	}
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	slog.Info("🆗 💾 (cmd/migrate/main.go)  💾 Migrations ran!", "count", len(steps), "up", steps[0].Up)
+	return nil
+}
+
+// status prints the current version, whether it is dirty and the pending migrations.
+func status(version uint, dirty bool, applied bool, pending []migration) error {
+	if applied {
+		fmt.Printf("Version: %d (dirty: %t)\n", version, dirty)
+	} else {
+		fmt.Println("Version: none")
+	}
+	fmt.Printf("Pending: %d\n", len(pending))
+	for _, m := range pending {
+		fmt.Printf("  %d_%s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+// reset drops all tables of the current schema, including the migration version, and the auth schema of a local
+// database. It requires typing the name of the database as confirmation.
+func reset(db *sql.DB, cfg config.Database) error {
+	slog.Info("💬 💾 (cmd/migrate/main.go) reset()")
+	query := "select tablename from pg_tables where schemaname = current_schema()"
+	if cfg.Driver == storage.DBDriverSQLite {
+		query = "select name from sqlite_master where type = 'table' and name not like 'sqlite_%'"
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	var statements []string
+	if cfg.Driver == storage.DBDriverSQLite {
+		// SQLite has no cascade, so the foreign keys are not enforced while dropping the tables in any order
+		statements = append(statements, "pragma foreign_keys = off")
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		if cfg.Driver == storage.DBDriverSQLite {
+			statements = append(statements, fmt.Sprintf("drop table if exists %q", table))
+		} else {
+			statements = append(statements, fmt.Sprintf("drop table if exists %q cascade", table))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// Supabase manages the auth schema of a remote database
+	if cfg.Type == storage.DBTypeLocal && cfg.Driver == storage.DBDriverPostgres {
+		statements = append(statements, "drop schema if exists auth cascade")
 	}
 
-	cmd := os.Args[len(os.Args)-1]
-	if cmd == "up" {
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+	if *dryRun {
+		for _, statement := range statements {
+			fmt.Println(statement + ";")
 		}
-		slog.Info("🆗 💾 (cmd/migrate/main.go)  💾 Up migrations ran!")
+		return nil
+	}
+	dbname := cfg.Name
+	if cfg.Driver == storage.DBDriverSQLite {
+		dbname = cfg.Path
 	}
-	if cmd == "down" {
-		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+	if !confirm(fmt.Sprintf("This drops all %d table(s) and their data. Type the name of the database %s to continue:", len(statements), dbname), dbname) {
+		return errors.New("reset has been cancelled")
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
 		}
-		slog.Info("🆗 💾 (cmd/migrate/main.go)  💾 Down migrations ran!")
+		slog.Info("🆗 💾 (cmd/migrate/main.go)  🫳 Dropped with", "statement", statement)
 	}
-	slog.Info("✅ 💾 (cmd/migrate/main.go) 🥦 Wits Database Migrator finished!")
+	slog.Info("✅ 💾 (cmd/migrate/main.go) reset() -> 🫳 Database has been reset")
+	return nil
 }
 
-func createDB() (*sql.DB, error) {
-	slog.Info("💬 💾 (cmd/migrate/main.go) createDB()")
-	if err := godotenv.Load(); err != nil {
-		return nil, err
+// confirm asks the question on stdin and reports whether the answer matches, unless --yes is given.
+func confirm(question string, answer string) bool {
+	if *yes {
+		return true
+	}
+	fmt.Printf("%s [%s] ", question, answer)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(line), answer)
+}
+
+// optionalCount parses the optional count N of up and down, which is 0 for all migrations.
+func optionalCount(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("N must be a positive number, got %q", args[0])
 	}
-	var (
-		host   = os.Getenv("DB_HOST")
-		user   = os.Getenv("DB_USER")
-		pass   = os.Getenv("DB_PASSWORD")
-		dbname = os.Getenv("DB_NAME")
+	return n, nil
+}
+
+// versionArg parses the version V of goto and force.
+func versionArg(args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errors.New("the version V is missing")
+	}
+	v, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("V must be a version, got %q", args[0])
+	}
+	return uint(v), nil
+}
+
+// newMigrate creates the migration instance for the database with the embedded migrations, returning the source
+// for reading the migrations as well.
+func newMigrate(db *sql.DB, driver string) (*migrate.Migrate, source.Driver, error) {
+	var instance database.Driver
+	var err error
+	if driver == storage.DBDriverSQLite {
+		instance, err = sqlite.WithInstance(db, &sqlite.Config{})
+	} else {
+		instance, err = postgres.WithInstance(db, &postgres.Config{})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// The migrations are embedded into the binary, so it runs from any working directory
+	src, err := migrations.NewSource(driver)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := migrate.NewWithInstance(
+		"iofs",   // source name
+		src,      // source instance
+		driver,   // driver name
+		instance, // instance
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+	readSrc, err := migrations.NewSource(driver)
+	return m, readSrc, err
+}
 
-	return storage.CreatePostgresDB(dbname, user, pass, host)
+func createDB(cfg config.Database) (*sql.DB, error) {
+	slog.Info("💬 💾 (cmd/migrate/main.go) createDB()")
+	if cfg.Driver == storage.DBDriverSQLite {
+		return storage.CreateSQLiteDB(cfg.Path)
+	}
+	db, err := storage.CreatePostgresDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.PingWithRetry(context.Background(), db, cfg.ConnectAttempts); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
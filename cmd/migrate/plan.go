@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// versionLayout is the layout of the timestamps, which are used as migration versions.
+const versionLayout = "20060102150405"
+
+// migrationName matches the names of new migrations, which become part of the file names.
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// migration is a single version of the embedded migrations.
+type migration struct {
+	Version uint
+	Name    string
+}
+
+// step applies (up) or rolls back (down) a single migration.
+type step struct {
+	migration
+	Up bool
+}
+
+// loadMigrations returns all migrations of the source, ordered by version.
+func loadMigrations(src source.Driver) ([]migration, error) {
+	var migrations []migration
+	version, err := src.First()
+	for err == nil {
+		r, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		r.Close()
+		migrations = append(migrations, migration{Version: version, Name: name})
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// indexOf returns the index of the version in the migrations, or -1 if no version has been applied yet.
+func indexOf(migrations []migration, version uint, applied bool) (int, error) {
+	if !applied {
+		return -1, nil
+	}
+	for i, m := range migrations {
+		if m.Version == version {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("current version %d is not part of the embedded migrations", version)
+}
+
+// planUp returns the next n migrations after the current index, or all pending migrations if n is 0.
+func planUp(migrations []migration, current int, n int) ([]step, error) {
+	pending := migrations[current+1:]
+	if n == 0 {
+		n = len(pending)
+	}
+	if n > len(pending) {
+		return nil, fmt.Errorf("cannot apply %d migrations, only %d are pending", n, len(pending))
+	}
+	steps := make([]step, n)
+	for i := range n {
+		steps[i] = step{migration: pending[i], Up: true}
+	}
+	return steps, nil
+}
+
+// planDown returns the last n applied migrations up to the current index in reverse order, or all applied
+// migrations if n is 0.
+func planDown(migrations []migration, current int, n int) ([]step, error) {
+	applied := current + 1
+	if n == 0 {
+		n = applied
+	}
+	if n > applied {
+		return nil, fmt.Errorf("cannot roll back %d migrations, only %d are applied", n, applied)
+	}
+	steps := make([]step, n)
+	for i := range n {
+		steps[i] = step{migration: migrations[current-i]}
+	}
+	return steps, nil
+}
+
+// planGoto returns the migrations to apply or roll back to get from the current index to the target version.
+func planGoto(migrations []migration, current int, target uint) ([]step, error) {
+	index, err := indexOf(migrations, target, true)
+	if err != nil {
+		return nil, fmt.Errorf("version %d is not part of the embedded migrations", target)
+	}
+	if index == current {
+		return nil, nil
+	}
+	if index > current {
+		return planUp(migrations, current, index-current)
+	}
+	return planDown(migrations, current, current-index)
+}
+
+// printSteps prints the SQL of every step, which is what --dry-run shows instead of running the migrations.
+func printSteps(w io.Writer, src source.Driver, steps []step) error {
+	for _, s := range steps {
+		direction, read := "down", src.ReadDown
+		if s.Up {
+			direction, read = "up", src.ReadUp
+		}
+		r, _, err := read(s.Version)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "-- %d_%s.%s.sql\n%s\n", s.Version, s.Name, direction, body)
+	}
+	return nil
+}
+
+// createMigration writes empty up and down migrations with the name to the directory, versioned by the time.
+func createMigration(dir string, name string, now time.Time) ([]string, error) {
+	slog.Info("💬 💾 (cmd/migrate/plan.go) createMigration()", "dir", dir, "name", name)
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("name %q may only contain lowercase letters, digits and underscores", name)
+	}
+	version := now.UTC().Format(versionLayout)
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			return files, fmt.Errorf("migration %s already exists", path)
+		}
+		if err != nil {
+			return files, err
+		}
+		f.Close()
+		files = append(files, path)
+	}
+	slog.Info("✅ 💾 (cmd/migrate/plan.go) createMigration() -> 📄 Migration has been created with", "version", version)
+	return files, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testMigrations = []migration{{1, "first"}, {2, "second"}, {3, "third"}}
+
+// versions returns the versions of the steps, negated for down steps.
+func versions(steps []step) []int {
+	vs := []int{}
+	for _, s := range steps {
+		if s.Up {
+			vs = append(vs, int(s.Version))
+		} else {
+			vs = append(vs, -int(s.Version))
+		}
+	}
+	return vs
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    func() ([]step, error)
+		want    []int
+		wantErr bool
+	}{
+		{"Up without N should apply all pending", func() ([]step, error) { return planUp(testMigrations, -1, 0) }, []int{1, 2, 3}, false},
+		{"Up N should apply the next N", func() ([]step, error) { return planUp(testMigrations, 0, 1) }, []int{2}, false},
+		{"Up beyond the last should error", func() ([]step, error) { return planUp(testMigrations, 1, 2) }, nil, true},
+		{"Down without N should roll back all applied", func() ([]step, error) { return planDown(testMigrations, 2, 0) }, []int{-3, -2, -1}, false},
+		{"Down N should roll back the last N", func() ([]step, error) { return planDown(testMigrations, 2, 1) }, []int{-3}, false},
+		{"Down beyond the first should error", func() ([]step, error) { return planDown(testMigrations, 0, 2) }, nil, true},
+		{"Goto a later version should apply", func() ([]step, error) { return planGoto(testMigrations, 0, 3) }, []int{2, 3}, false},
+		{"Goto an earlier version should roll back", func() ([]step, error) { return planGoto(testMigrations, 2, 1) }, []int{-3, -2}, false},
+		{"Goto the current version should do nothing", func() ([]step, error) { return planGoto(testMigrations, 1, 2) }, []int{}, false},
+		{"Goto an unknown version should error", func() ([]step, error) { return planGoto(testMigrations, 1, 4) }, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := tt.plan()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("plan error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(versions(steps), tt.want) {
+				t.Errorf("plan = %v, want %v", versions(steps), tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)
+	files, err := createMigration(dir, "create_products_table", now)
+	if err != nil {
+		t.Fatalf("createMigration() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "20261019190000_create_products_table.up.sql"),
+		filepath.Join(dir, "20261019190000_create_products_table.down.sql"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("createMigration() = %v, want %v", files, want)
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("createMigration() did not create %v", file)
+		}
+	}
+	if _, err := createMigration(dir, "create_products_table", now); err == nil {
+		t.Error("createMigration() should not overwrite an existing migration")
+	}
+	if _, err := createMigration(dir, "Create Products", now); err == nil {
+		t.Error("createMigration() should reject names with spaces")
+	}
+}
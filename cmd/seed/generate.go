@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+)
+
+// demoDomain is the domain of the emails of all demo users, which is reserved and never receives mail.
+const demoDomain = "wits.example"
+
+// Demo usernames are made of the same kind of words as the generated usernames of pseudonymous users.
+var (
+	demoAdjectives = []string{"amber", "brave", "calm", "clever", "gentle", "golden", "mellow", "misty", "quiet", "sunny"}
+	demoNouns      = []string{"badger", "falcon", "gecko", "heron", "koala", "lynx", "otter", "puffin", "raven", "wombat"}
+	demoUserAgents = []string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+	}
+	demoDoctors  = []string{"Dr. Bauer", "Dr. Green", "Dr. Okafor", "Dr. Rossi", "Dr. Tanaka"}
+	demoSymptoms = []string{"Pain", "Sleeplessness", "Nausea", "Anxiety", "Spasms", "Loss of appetite"}
+	demoNotes    = []string{"Helped quickly", "Felt tired afterwards", "Barely noticed it", "Better than yesterday", "Took it before sleeping"}
+)
+
+// demoProduct is a product of the catalog of the demo patients, with its usual dose and the monthly amount of its
+// prescriptions, both in the unit of its kind.
+type demoProduct struct {
+	name    string
+	kind    types.ProductKind
+	thc     float64
+	cbd     float64
+	dose    float64
+	monthly float64
+	methods []types.ConsumptionMethod
+}
+
+// demoProducts is the catalog of the products of the demo patients.
+var demoProducts = []demoProduct{
+	{"Bedrocan", types.ProductKindFlower, 22, 1, 0.1, 30, []types.ConsumptionMethod{types.ConsumptionMethodVaporized, types.ConsumptionMethodSmoked}},
+	{"Bediol", types.ProductKindFlower, 6.3, 8, 0.15, 20, []types.ConsumptionMethod{types.ConsumptionMethodVaporized}},
+	{"Pedanios 22/1", types.ProductKindFlower, 22, 1, 0.1, 30, []types.ConsumptionMethod{types.ConsumptionMethodVaporized, types.ConsumptionMethodSmoked}},
+	{"Tilray Extract THC 25", types.ProductKindExtract, 25, 0.5, 0.05, 5, []types.ConsumptionMethod{types.ConsumptionMethodVaporized}},
+	{"Sativex Spray", types.ProductKindOil, 2.7, 2.5, 0.3, 30, []types.ConsumptionMethod{types.ConsumptionMethodSublingual}},
+	{"CBD Oil 10%", types.ProductKindOil, 0.2, 10, 0.5, 30, []types.ConsumptionMethod{types.ConsumptionMethodOral, types.ConsumptionMethodSublingual}},
+	{"Dronabinol Capsules", types.ProductKindEdible, 2.5, 0, 1, 60, []types.ConsumptionMethod{types.ConsumptionMethodOral}},
+}
+
+// options configure the generated demo data.
+type options struct {
+	Users  int
+	Seed   uint64
+	Months int
+	End    time.Time
+}
+
+// dataset is the generated demo data, in the order in which it has to be inserted.
+type dataset struct {
+	Users          []types.AuthenticatedUser
+	Accounts       []types.Account
+	Delegations    []types.Delegation
+	AuditEvents    []types.AuditEvent
+	Products       []types.Product
+	Prescriptions  []types.Prescription
+	InventoryItems []types.InventoryItem
+	Consumptions   []types.Consumption
+	SymptomRatings []types.SymptomRating
+}
+
+// generator creates demo data from a seeded random source, so the same options always result in the same data.
+type generator struct {
+	rnd   *rand.Rand
+	start time.Time
+	end   time.Time
+}
+
+// generate returns the demo data for the options. The first user is an admin, every fifth user a caregiver and every
+// seventh user a pseudonymous patient without an email. All users but the caregivers track their products,
+// prescriptions, inventory, consumptions and symptoms from their registration on.
+func generate(opts options) dataset {
+	g := generator{
+		rnd:   rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x5eed)),
+		start: opts.End.AddDate(0, -opts.Months, 0),
+		end:   opts.End,
+	}
+	var data dataset
+	for i := range opts.Users {
+		user, account := g.user(i)
+		data.Users = append(data.Users, user)
+		data.Accounts = append(data.Accounts, account)
+		data.AuditEvents = append(data.AuditEvents, g.auditEvents(user)...)
+		if account.Role != types.RoleCaregiver {
+			g.trackedRecords(&data, user)
+		}
+	}
+	// Caregivers look after one to three of the patients, which have been generated before them
+	for i, account := range data.Accounts {
+		if account.Role != types.RoleCaregiver {
+			continue
+		}
+		patients := 1 + g.rnd.IntN(3)
+		for _, j := range g.rnd.Perm(i) {
+			if patients == 0 {
+				break
+			}
+			if data.Accounts[j].Role != types.RolePatient || data.Users[j].Email == "" {
+				continue
+			}
+			data.Delegations = append(data.Delegations, g.delegation(data.Users[j], data.Users[i]))
+			patients--
+		}
+	}
+	return data
+}
+
+// user returns the i-th demo user and their account.
+func (g *generator) user(i int) (types.AuthenticatedUser, types.Account) {
+	createdAt := g.between(g.start, g.end)
+	user := types.AuthenticatedUser{
+		ID:        g.uuid(),
+		Email:     fmt.Sprintf("demo-%04d@%s", i+1, demoDomain),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	account := types.Account{
+		ID:        g.uuid(),
+		UserID:    user.ID,
+		Role:      types.RolePatient,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	switch {
+	case i == 0:
+		account.Role = types.RoleAdmin
+	case i%5 == 0:
+		account.Role = types.RoleCaregiver
+	case i%7 == 0:
+		user.Email = ""
+		account.Username = fmt.Sprintf("%s-%s-%04d",
+			demoAdjectives[g.rnd.IntN(len(demoAdjectives))], demoNouns[g.rnd.IntN(len(demoNouns))], i+1)
+	}
+	if i > 0 && g.rnd.IntN(20) == 0 {
+		account.DisabledAt = g.between(createdAt, g.end)
+	}
+	return user, account
+}
+
+// auditEvents returns the registration of the user followed by logins and logouts until the end.
+func (g *generator) auditEvents(user types.AuthenticatedUser) []types.AuditEvent {
+	ip := fmt.Sprintf("192.0.2.%d", 1+g.rnd.IntN(254))
+	userAgent := demoUserAgents[g.rnd.IntN(len(demoUserAgents))]
+	event := func(action string, at time.Time) types.AuditEvent {
+		return types.AuditEvent{
+			ID:        g.uuid(),
+			Action:    action,
+			ActorID:   user.ID,
+			AccountID: user.ID,
+			Email:     user.Email,
+			IPAddress: ip,
+			UserAgent: userAgent,
+			CreatedAt: at,
+		}
+	}
+	events := []types.AuditEvent{event(types.AuditActionRegister, user.CreatedAt)}
+	// Users log in every few days, some more often than others
+	days := 1 + g.rnd.IntN(7)
+	for at := user.CreatedAt.Add(time.Hour + g.duration(24*time.Hour)); at.Before(g.end); at = at.AddDate(0, 0, days).Add(g.duration(12 * time.Hour)) {
+		if g.rnd.IntN(10) == 0 {
+			events = append(events, event(types.AuditActionLoginFailure, at.Add(-time.Minute)))
+		}
+		events = append(events, event(types.AuditActionLogin, at))
+		if logout := at.Add(g.duration(2 * time.Hour)); logout.Before(g.end) && g.rnd.IntN(3) == 0 {
+			events = append(events, event(types.AuditActionLogout, logout))
+		}
+	}
+	return events
+}
+
+// trackedRecords adds the products of the user to the data, together with their monthly prescriptions, the last
+// fill of each in the inventory, a few daily consumptions and the ratings of one or two symptoms every few days.
+func (g *generator) trackedRecords(data *dataset, user types.AuthenticatedUser) {
+	// The products are added within a day after the registration, unless the data ends before
+	createdAt := user.CreatedAt.Add(24 * time.Hour)
+	if createdAt.After(g.end) {
+		createdAt = g.end
+	}
+	createdAt = g.between(user.CreatedAt, createdAt)
+	var products []types.Product
+	var catalog []demoProduct
+	for _, i := range g.rnd.Perm(len(demoProducts))[:1+g.rnd.IntN(3)] {
+		demo := demoProducts[i]
+		products = append(products, types.Product{
+			ID:        g.uuid(),
+			OwnerID:   user.ID,
+			Name:      demo.name,
+			Kind:      demo.kind,
+			THC:       demo.thc,
+			CBD:       demo.cbd,
+			CreatedAt: createdAt,
+		})
+		catalog = append(catalog, demo)
+	}
+	data.Products = append(data.Products, products...)
+
+	doctor := demoDoctors[g.rnd.IntN(len(demoDoctors))]
+	for i, product := range products {
+		var last types.Prescription
+		for issuedAt := product.CreatedAt; issuedAt.Before(g.end); issuedAt = issuedAt.AddDate(0, 1, 0) {
+			last = types.Prescription{
+				ID:        g.uuid(),
+				OwnerID:   user.ID,
+				ProductID: product.ID,
+				Doctor:    doctor,
+				Amount:    catalog[i].monthly,
+				IssuedAt:  issuedAt,
+				ExpiresAt: issuedAt.AddDate(0, 1, 0),
+				CreatedAt: issuedAt,
+			}
+			data.Prescriptions = append(data.Prescriptions, last)
+		}
+		acquiredAt := g.between(last.IssuedAt, last.IssuedAt.Add(72*time.Hour))
+		if !acquiredAt.Before(g.end) {
+			continue
+		}
+		data.InventoryItems = append(data.InventoryItems, types.InventoryItem{
+			ID:         g.uuid(),
+			OwnerID:    user.ID,
+			ProductID:  product.ID,
+			Amount:     roundAmount(last.Amount * g.rnd.Float64()),
+			AcquiredAt: acquiredAt,
+			CreatedAt:  acquiredAt,
+		})
+	}
+
+	for day := createdAt.Truncate(24 * time.Hour); day.Before(g.end); day = day.AddDate(0, 0, 1) {
+		for range 1 + g.rnd.IntN(3) {
+			consumedAt := day.Add(8*time.Hour + g.duration(14*time.Hour)).Truncate(time.Second)
+			if consumedAt.Before(createdAt) || !consumedAt.Before(g.end) {
+				continue
+			}
+			i := g.rnd.IntN(len(products))
+			consumption := types.Consumption{
+				ID:         g.uuid(),
+				OwnerID:    user.ID,
+				ProductID:  products[i].ID,
+				Amount:     roundAmount(catalog[i].dose * (0.5 + g.rnd.Float64())),
+				Method:     catalog[i].methods[g.rnd.IntN(len(catalog[i].methods))],
+				ConsumedAt: consumedAt,
+				CreatedAt:  consumedAt,
+			}
+			if g.rnd.IntN(8) == 0 {
+				consumption.Notes = demoNotes[g.rnd.IntN(len(demoNotes))]
+			}
+			data.Consumptions = append(data.Consumptions, consumption)
+		}
+	}
+
+	// The severity of a symptom varies around its own baseline
+	symptoms := g.rnd.Perm(len(demoSymptoms))[:1+g.rnd.IntN(2)]
+	baselines := make([]int, len(symptoms))
+	for i := range baselines {
+		baselines[i] = 3 + g.rnd.IntN(6)
+	}
+	days := 1 + g.rnd.IntN(3)
+	for at := g.between(createdAt, createdAt.Add(24*time.Hour)); at.Before(g.end); at = at.AddDate(0, 0, days).Add(g.duration(6 * time.Hour)).Truncate(time.Second) {
+		for i, symptom := range symptoms {
+			severity := min(max(baselines[i]+g.rnd.IntN(5)-2, 0), types.MaxSeverity)
+			data.SymptomRatings = append(data.SymptomRatings, types.SymptomRating{
+				ID:        g.uuid(),
+				OwnerID:   user.ID,
+				Symptom:   demoSymptoms[symptom],
+				Severity:  severity,
+				RatedAt:   at,
+				CreatedAt: at,
+			})
+		}
+	}
+}
+
+// roundAmount rounds an amount to the two decimals shown to the users.
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// delegation returns an accepted delegation of the owner to the caregiver.
+func (g *generator) delegation(owner types.AuthenticatedUser, caregiver types.AuthenticatedUser) types.Delegation {
+	createdAt := g.between(caregiver.CreatedAt, g.end)
+	access := types.AccessRead
+	if g.rnd.IntN(3) == 0 {
+		access = types.AccessReadWrite
+	}
+	return types.Delegation{
+		ID:         g.uuid(),
+		OwnerID:    owner.ID,
+		DelegateID: caregiver.ID,
+		Email:      caregiver.Email,
+		Access:     access,
+		// Accepted delegations are never looked up by their token, it only has to be unique
+		TokenHash:  g.uuid().String(),
+		AcceptedAt: g.between(createdAt, g.end),
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+}
+
+// uuid returns a random version 4 UUID from the seeded source.
+func (g *generator) uuid() uuid.UUID {
+	var id uuid.UUID
+	for i := 0; i < len(id); i += 8 {
+		v := g.rnd.Uint64()
+		for j := range 8 {
+			id[i+j] = byte(v >> (8 * j))
+		}
+	}
+	id[6] = (id[6] & 0x0f) | 0x40 // version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // variant 10
+	return id
+}
+
+// between returns a random time in [from, to), truncated to seconds.
+func (g *generator) between(from time.Time, to time.Time) time.Time {
+	if !to.After(from) {
+		return from
+	}
+	return from.Add(g.duration(to.Sub(from))).Truncate(time.Second)
+}
+
+// duration returns a random duration in [0, max).
+func (g *generator) duration(max time.Duration) time.Duration {
+	return time.Duration(g.rnd.Int64N(int64(max)))
+}
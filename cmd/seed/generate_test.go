@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+)
+
+func TestGenerate(t *testing.T) {
+	opts := options{Users: 30, Seed: 42, Months: 3, End: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	data := generate(opts)
+
+	if !reflect.DeepEqual(data, generate(opts)) {
+		t.Error("generate() with the same options returned different data")
+	}
+	opts.Seed = 43
+	if reflect.DeepEqual(data, generate(opts)) {
+		t.Error("generate() with a different seed returned the same data")
+	}
+
+	if len(data.Users) != 30 || len(data.Accounts) != 30 {
+		t.Fatalf("generate() returned %d users and %d accounts, want 30", len(data.Users), len(data.Accounts))
+	}
+	if data.Accounts[0].Role != types.RoleAdmin {
+		t.Errorf("first account has role %s, want %s", data.Accounts[0].Role, types.RoleAdmin)
+	}
+	if len(data.Delegations) == 0 {
+		t.Error("generate() returned no delegations")
+	}
+	start := opts.End.AddDate(0, -opts.Months, 0)
+	for _, event := range data.AuditEvents {
+		if event.CreatedAt.Before(start) || !event.CreatedAt.Before(opts.End) {
+			t.Errorf("audit event %s at %s is not between %s and %s", event.Action, event.CreatedAt, start, opts.End)
+		}
+	}
+	if len(data.Products) == 0 || len(data.Prescriptions) == 0 || len(data.InventoryItems) == 0 || len(data.Consumptions) == 0 || len(data.SymptomRatings) == 0 {
+		t.Fatalf("generate() returned %d products, %d prescriptions, %d inventory items, %d consumptions and %d symptom ratings, want some of each",
+			len(data.Products), len(data.Prescriptions), len(data.InventoryItems), len(data.Consumptions), len(data.SymptomRatings))
+	}
+	trackers := map[string]bool{}
+	for i, account := range data.Accounts {
+		if account.Role != types.RoleCaregiver {
+			trackers[data.Users[i].ID.String()] = true
+		}
+	}
+	products := map[string]bool{}
+	for _, product := range data.Products {
+		if !trackers[product.OwnerID.String()] {
+			t.Errorf("product %s belongs to %s, which is a caregiver or unknown", product.ID, product.OwnerID)
+		}
+		products[product.ID.String()] = true
+	}
+	for _, consumption := range data.Consumptions {
+		if !products[consumption.ProductID.String()] {
+			t.Errorf("consumption %s is of the unknown product %s", consumption.ID, consumption.ProductID)
+		}
+		if consumption.ConsumedAt.Before(start) || !consumption.ConsumedAt.Before(opts.End) {
+			t.Errorf("consumption %s at %s is not between %s and %s", consumption.ID, consumption.ConsumedAt, start, opts.End)
+		}
+	}
+	for _, rating := range data.SymptomRatings {
+		if rating.Severity < 0 || rating.Severity > types.MaxSeverity {
+			t.Errorf("symptom rating %s has the severity %d, want 0 to %d", rating.ID, rating.Severity, types.MaxSeverity)
+		}
+		if rating.RatedAt.Before(start) || !rating.RatedAt.Before(opts.End) {
+			t.Errorf("symptom rating %s at %s is not between %s and %s", rating.ID, rating.RatedAt, start, opts.End)
+		}
+	}
+	for _, prescription := range data.Prescriptions {
+		if !products[prescription.ProductID.String()] || !prescription.ExpiresAt.After(prescription.IssuedAt) {
+			t.Errorf("prescription %+v is of an unknown product or expires before it is issued", prescription)
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, user := range data.Users {
+		if ids[user.ID.String()] {
+			t.Errorf("user %d has the duplicate id %s", i, user.ID)
+		}
+		ids[user.ID.String()] = true
+		if user.ID.Version() != 4 {
+			t.Errorf("user %d has id %s of version %d, want 4", i, user.ID, user.ID.Version())
+		}
+		if data.Accounts[i].UserID != user.ID {
+			t.Errorf("account %d belongs to %s, want %s", i, data.Accounts[i].UserID, user.ID)
+		}
+		if user.Email == "" && data.Accounts[i].Username == "" {
+			t.Errorf("user %d has neither an email nor a username", i)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package main is the entry point for seeding the database with demo data
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const usage = `Usage: seed [flags]
+
+Creates demo users with accounts, delegations and an audit log spread over the last months, and for all but the
+caregivers products, prescriptions, inventory, a consumption history and symptom ratings. The same flags always
+create the same data, so screenshots, load tests and UI development work with reproducible data. All demo users log
+in with the same password, pseudonymous ones with their username.
+
+Flags:`
+
+var (
+	users    = flag.Int("users", 25, "the number of demo users")
+	seed     = flag.Uint64("seed", 1, "the seed of the random data, a different seed creates different data")
+	months   = flag.Int("months", 6, "the number of months the data is spread over")
+	end      = flag.String("end", "", "the date (YYYY-MM-DD) the data ends at, defaults to today")
+	password = flag.String("password", "wits-demo", "the password of all demo users")
+)
+
+func main() {
+	slog.Info("💬 🌱 (cmd/seed/main.go) 🥦 Welcome to Wits Database Seeder!")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	opts, err := parseOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.Type != storage.DBTypeLocal {
+		log.Fatalf("seeding needs DB_TYPE=%s, Supabase manages the users of a remote database", storage.DBTypeLocal)
+	}
+	ctx := context.Background()
+	db, err := storage.NewBun(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	keyring, err := storage.NewKeyringFromConfig(storage.NewBunDataKeyRepository(db), cfg.Encryption)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(ctx, storage.NewBunRepositories(db, keyring), generate(opts)); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("✅ 🌱 (cmd/seed/main.go) 🥦 Wits Database Seeder finished!")
+}
+
+// parseOptions validates the flags. The end defaults to the start of today, so the data only changes once a day.
+func parseOptions() (options, error) {
+	opts := options{Users: *users, Seed: *seed, Months: *months, End: time.Now().UTC().Truncate(24 * time.Hour)}
+	if opts.Users < 1 {
+		return opts, errors.New("-users must be at least 1")
+	}
+	if opts.Months < 1 {
+		return opts, errors.New("-months must be at least 1")
+	}
+	if *end != "" {
+		t, err := time.Parse(time.DateOnly, *end)
+		if err != nil {
+			return opts, fmt.Errorf("-end must be a date like 2024-03-01, got %q", *end)
+		}
+		opts.End = t
+	}
+	return opts, nil
+}
+
+// run inserts the demo data, unless the first demo user already exists.
+func run(ctx context.Context, repos *storage.Repositories, data dataset) error {
+	slog.Info("💬 🌱 (cmd/seed/main.go) run()", "users", len(data.Users), "delegations", len(data.Delegations), "auditEvents", len(data.AuditEvents),
+		"products", len(data.Products), "prescriptions", len(data.Prescriptions), "inventoryItems", len(data.InventoryItems),
+		"consumptions", len(data.Consumptions), "symptomRatings", len(data.SymptomRatings))
+	_, err := repos.Users.GetAuthenticatedUserByEmail(ctx, data.Users[0].Email)
+	if err == nil {
+		return fmt.Errorf("demo user %s already exists, reset the database before seeding it again", data.Users[0].Email)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	// Hashing is slow on purpose, so all demo users share the same hash
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*password), 8)
+	if err != nil {
+		return err
+	}
+	// All or nothing, so a failed run can simply be repeated
+	err = repos.Tx.RunInTx(ctx, func(ctx context.Context, repos *storage.Repositories) error {
+		for i := range data.Users {
+			data.Users[i].Password = string(hashedPassword)
+			if err := repos.Users.CreateAuthenticatedUser(ctx, &data.Users[i]); err != nil {
+				return err
+			}
+			if err := repos.Accounts.CreateAccount(ctx, &data.Accounts[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.Delegations {
+			if err := repos.Delegations.CreateDelegation(ctx, &data.Delegations[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.AuditEvents {
+			if err := repos.AuditEvents.CreateAuditEvent(ctx, &data.AuditEvents[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.Products {
+			if err := repos.Products.CreateProduct(ctx, &data.Products[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.Prescriptions {
+			if err := repos.Prescriptions.CreatePrescription(ctx, &data.Prescriptions[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.InventoryItems {
+			if err := repos.Inventory.CreateInventoryItem(ctx, &data.InventoryItems[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.Consumptions {
+			if err := repos.Consumptions.CreateConsumption(ctx, &data.Consumptions[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.SymptomRatings {
+			if err := repos.Symptoms.CreateSymptomRating(ctx, &data.SymptomRatings[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("🆗 🌱 (cmd/seed/main.go)  🌱 Demo data has been seeded, log in with", "email", data.Users[0].Email, "password", *password)
+	return nil
+}
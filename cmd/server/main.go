@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -9,26 +10,39 @@ import (
 	"os"
 
 	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/TheDonDope/wits-server/pkg/handler"
+	"github.com/TheDonDope/wits-server/pkg/mail"
 	"github.com/TheDonDope/wits-server/pkg/storage"
-	"github.com/joho/godotenv"
+	"github.com/TheDonDope/wits-server/pkg/types"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	gommonlog "github.com/labstack/gommon/log"
+	"github.com/nedpals/supabase-go"
 )
 
 func main() {
 	slog.Info("💬 🖥️  (cmd/server.go) 🥦 Welcome to Wits!")
 
-	if err := initEverything(); err != nil {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	ctx := context.Background()
+	repos, svc, supabaseClient, err := initEverything(ctx, cfg)
+	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Echo instance
 	e := echo.New()
 
-	if err := configureLogging(e); err != nil {
+	if err := configureLogging(e, cfg.Log); err != nil {
 		log.Fatal(err)
 	}
 
@@ -39,32 +53,32 @@ func main() {
 	e.Static("/public", "public")
 	e.File("/favicon.ico", "public/img/favicon.ico")
 
-	configureRoutes(e)
+	configureRoutes(ctx, e, repos, cfg, svc, supabaseClient)
 
 	// Start server
-	addr := os.Getenv("HTTP_LISTEN_ADDR")
+	addr := cfg.HTTP.ListenAddr
 	slog.Info("🚀 🖥️  (cmd/server.go) 🛜 Wits server is running at", "addr", addr)
 	e.Logger.Fatal(e.Start(addr))
 }
 
 // configureLogging configures the logging for the server, adding logging and recovery middlewares as well as
-// setting the log level from the environment. Finally, it sets the log output to a stdout and file.
-func configureLogging(e *echo.Echo) error {
+// setting the log level from the configuration. Finally, it sets the log output to a stdout and file.
+func configureLogging(e *echo.Echo, cfg config.Log) error {
 	slog.Info("💬 🖥️  (cmd/server.go) configureLogging()")
 
-	// Set log level from environment variable
-	e.Logger.SetLevel(parseLogLevel())
+	// Set log level from the configuration
+	e.Logger.SetLevel(parseLogLevel(cfg.Level))
 
 	// Check if log directory exists and create if neccessary
-	if _, err := os.Stat(os.Getenv("LOG_DIR")); os.IsNotExist(err) {
-		if err := os.Mkdir(os.Getenv("LOG_DIR"), 0755); err != nil {
+	if _, err := os.Stat(cfg.Dir); os.IsNotExist(err) {
+		if err := os.Mkdir(cfg.Dir, 0755); err != nil {
 			slog.Error("🚨 🖥️  (cmd/server.go) ❓❓❓❓ 🗒️  Failed to create log directory", "error", err)
 			return err
 		}
 	}
 
 	// Create a log file for the server logs
-	logPath := fmt.Sprintf("%s/%s", os.Getenv("LOG_DIR"), os.Getenv("LOG_FILE"))
+	logPath := fmt.Sprintf("%s/%s", cfg.Dir, cfg.File)
 	echoLog, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
 	if err != nil {
 		slog.Error("🚨 🖥️  (cmd/server.go) ❓❓❓❓ 🗒️  Failed to open log file", "error", err)
@@ -74,7 +88,7 @@ func configureLogging(e *echo.Echo) error {
 	e.Logger.SetOutput(io.MultiWriter(os.Stdout, echoLog))
 
 	// Create an access log
-	accessLogPath := fmt.Sprintf("%s/%s", os.Getenv("LOG_DIR"), os.Getenv("ACCESS_LOG_FILE"))
+	accessLogPath := fmt.Sprintf("%s/%s", cfg.Dir, cfg.AccessFile)
 	accessLog, err := os.OpenFile(accessLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
 	if err != nil {
 		slog.Error("🚨 🖥️  (cmd/server.go) ❓❓❓❓ 🗒️  Failed to open access log file", "error", err)
@@ -86,65 +100,186 @@ func configureLogging(e *echo.Echo) error {
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
-	slog.Info("✅ 🖥️  (cmd/server.go) configureLogging() -> 🗒️  OK with", "logLevel", os.Getenv("LOG_LEVEL"), "logFilePath", logPath, "accessLogPath", accessLogPath)
+	slog.Info("✅ 🖥️  (cmd/server.go) configureLogging() -> 🗒️  OK with", "logLevel", cfg.Level, "logFilePath", logPath, "accessLogPath", accessLogPath)
 	return nil
 }
 
-// configureRoutes configures the routes for the server, adding both unprotected and protected routes.
-func configureRoutes(e *echo.Echo) {
+// configureRoutes configures the routes for the server, adding both unprotected and protected routes. The handlers
+// and middlewares get the repositories, the configuration, the services and, if Supabase is used, the Supabase client
+// injected.
+func configureRoutes(ctx context.Context, e *echo.Echo, repos *storage.Repositories, cfg *config.Config, svc handler.Services, supabaseClient *supabase.Client) {
+	e.IPExtractor = handler.NewIPExtractor(cfg.HTTP)
+
 	// Home Route
 	home := handler.HomeHandler{}
 	e.GET("/", home.HandleGetHome)
 
+	// Health routes, which the probes of Kubernetes call
+	health := handler.NewHealthHandler(repos, cfg, svc)
+	e.GET(handler.LivenessPath, health.HandleGetHealthz)
+	e.GET(handler.ReadinessPath, health.HandleGetReadyz)
+	e.GET(handler.VersionPath, health.HandleGetVersion)
+
 	// Auth routes
-	aut := handler.NewAuthHandler()
-	e.Use(handler.WithUser())
+	identity := handler.NewIdentityRegistry(ctx, repos, cfg, svc, supabaseClient)
+	aut := handler.NewAuthHandler(repos, cfg, svc, identity)
+	mw := handler.NewMiddleware(repos, cfg, svc)
+	e.Use(mw.WithUser())
 	e.GET("/login", aut.HandleGetLogin)
-	e.GET("/login/provider/google", aut.HandleGetLoginWithGoogle)
+	e.GET("/login/provider/:provider", aut.HandleGetLoginWithProvider)
 	e.POST("/login", aut.HandlePostLogin)
 	e.POST("/logout", aut.HandlePostLogout)
 	e.GET("/register", aut.HandleGetRegister)
 	e.POST("/register", aut.HandlePostRegister)
 	e.GET("/auth/callback", aut.HandleGetAuthCallback)
+	e.GET("/auth/:provider/callback", aut.HandleGetProviderCallback)
+
+	// Password recovery with recovery codes, which pseudonymous users get in place of a recovery email
+	recovery := handler.NewRecoveryHandler(repos, cfg, svc)
+	if svc.Identity.Enabled(auth.ProviderLocal) {
+		e.GET("/recover-password", recovery.HandleGetRecoverPassword)
+		e.POST("/recover-password", recovery.HandlePostRecoverPassword)
+	}
 
 	// Authenticated routes
 	indexGroup := e.Group("") // Start with root path
-	// Configure middleware with the custom claims type, but only when using local DB
-	if os.Getenv("DB_TYPE") == storage.DBTypeLocal {
-		indexGroup.Use(echojwt.WithConfig(auth.EchoJWTConfig()))
+	// Configure middleware with the custom claims type, but only when the sessions carry self-signed tokens
+	if !svc.Identity.UsesSupabase() {
+		indexGroup.Use(echojwt.WithConfig(auth.EchoJWTConfig(cfg.Auth.JWTSecretKey, svc.Sessions)))
 	}
 
 	indexGroup.Use(handler.WithAuth())
 
 	// Dashboard routes
 	dashboard := handler.DashboardHandler{}
-	indexGroup.GET("/dashboard", dashboard.HandleGetDashboard)
+	indexGroup.GET("/dashboard", dashboard.HandleGetDashboard, handler.WithScope(types.ResourceDashboard), handler.RequirePermission(types.PermissionViewDashboard), mw.WithDelegation())
 
 	// User settings routes
-	settings := handler.SettingsHandler{}
-	indexGroup.GET("/settings", settings.HandleGetSettings)
+	settings := handler.NewSettingsHandler(repos, cfg, svc, identity)
+	e.GET("/email/confirm", settings.HandleGetConfirmEmail)
+	settingsGroup := indexGroup.Group("/settings", handler.WithSession(), handler.RequirePermission(types.PermissionManageAccount))
+	settingsGroup.GET("", settings.HandleGetSettings)
+	settingsGroup.POST("/password", settings.HandlePostPassword)
+	settingsGroup.POST("/email", settings.HandlePostEmail)
+	settingsGroup.POST("/sessions/logout", settings.HandlePostSessionsLogout)
+	settingsGroup.POST("/sessions/:id/logout", settings.HandlePostSessionLogout)
+	settingsGroup.POST("/tokens", settings.HandlePostAPIToken)
+	settingsGroup.POST("/tokens/:id/revoke", settings.HandlePostAPITokenRevoke)
+	settingsGroup.POST("/recovery-codes", recovery.HandlePostRecoveryCodes)
+
+	// Delegation routes
+	delegation := handler.NewDelegationHandler(repos, cfg, svc)
+	settingsGroup.POST("/delegations", delegation.HandlePostDelegation)
+	settingsGroup.POST("/delegations/:id/revoke", delegation.HandlePostDelegationRevoke)
+	delegationGroup := indexGroup.Group("/delegations", handler.WithSession())
+	delegationGroup.GET("/accept", delegation.HandleGetDelegationAccept)
+	delegationGroup.POST("/accept", delegation.HandlePostDelegationAccept)
+	delegationGroup.POST("/switch", delegation.HandlePostDelegationSwitch, handler.RequirePermission(types.PermissionViewDelegated))
+
+	// Report share routes
+	reportShare := handler.NewReportShareHandler(repos, cfg, svc)
+	e.GET("/share/:token", reportShare.HandleGetSharedReport)
+	settingsGroup.POST("/shares", reportShare.HandlePostReportShare)
+	settingsGroup.POST("/shares/:id/revoke", reportShare.HandlePostReportShareRevoke)
+	settingsGroup.POST("/shares/:id/delete", reportShare.HandlePostReportShareDelete)
+	settingsGroup.POST("/shares/:id/restore", reportShare.HandlePostReportShareRestore)
+
+	// Admin routes
+	admin := handler.NewAdminHandler(repos, cfg, svc)
+	adminGroup := indexGroup.Group("/admin", handler.WithSession(), handler.RequirePermission(types.PermissionManageUsers))
+	adminGroup.GET("", admin.HandleGetAdmin)
+	adminGroup.POST("/users/:id/role", admin.HandlePostUserRole)
+	adminGroup.POST("/users/:id/disable", admin.HandlePostUserDisable)
+	adminGroup.POST("/users/:id/enable", admin.HandlePostUserEnable)
+	adminGroup.GET("/audit", admin.HandleGetAudit, handler.RequirePermission(types.PermissionViewAudit))
+
+	// JSON API routes, which authenticate with bearer tokens instead of the session
+	api := handler.NewAPIHandler(repos, cfg, svc)
+	e.GET(handler.APIPrefix+"/openapi.json", api.HandleGetOpenAPI)
+	e.GET(handler.APIPrefix+"/docs", api.HandleGetAPIDocs)
+	apiGroup := e.Group(handler.APIPrefix+"/v1", handler.WithProblems())
+	if svc.Identity.Enabled(auth.ProviderLocal) && !svc.Identity.UsesSupabase() {
+		apiGroup.POST("/auth/token", api.HandlePostToken)
+	}
+	apiAuthGroup := apiGroup.Group("", mw.WithBearer())
+	apiAuthGroup.GET("/account", api.HandleGetAccount, handler.APIAccess(types.ResourceAccount, types.PermissionManageAccount))
+
+	// JSON API routes of the share links and the audit log, which the settings page offers as well
+	reportSharesGroup := apiAuthGroup.Group("/report-shares", handler.APIAccess(types.ResourceReportShares, types.PermissionManageAccount))
+	reportSharesGroup.GET("", api.HandleGetReportShares)
+	reportSharesGroup.POST("", api.HandlePostReportShare)
+	reportSharesGroup.GET("/:id", api.HandleGetReportShare)
+	reportSharesGroup.PATCH("/:id", api.HandlePatchReportShare)
+	reportSharesGroup.DELETE("/:id", api.HandleDeleteReportShare)
+	apiAuthGroup.GET("/audit-events", api.HandleGetAuditEvents, handler.APIAccess(types.ResourceAuditEvents, types.PermissionManageAccount))
+
+	// JSON API routes of the tracked records, which only the patients themselves can access
+	productsGroup := apiAuthGroup.Group("/products", handler.APIAccess(types.ResourceProducts, types.PermissionTrackData))
+	productsGroup.GET("", api.HandleGetProducts)
+	productsGroup.POST("", api.HandlePostProduct)
+	productsGroup.GET("/:id", api.HandleGetProduct)
+	productsGroup.PUT("/:id", api.HandlePutProduct)
+	productsGroup.DELETE("/:id", api.HandleDeleteProduct)
+	inventoryGroup := apiAuthGroup.Group("/inventory", handler.APIAccess(types.ResourceInventory, types.PermissionTrackData))
+	inventoryGroup.GET("", api.HandleGetInventoryItems)
+	inventoryGroup.POST("", api.HandlePostInventoryItem)
+	inventoryGroup.GET("/:id", api.HandleGetInventoryItem)
+	inventoryGroup.PUT("/:id", api.HandlePutInventoryItem)
+	inventoryGroup.DELETE("/:id", api.HandleDeleteInventoryItem)
+	consumptionsGroup := apiAuthGroup.Group("/consumptions", handler.APIAccess(types.ResourceConsumptions, types.PermissionTrackData))
+	consumptionsGroup.GET("", api.HandleGetConsumptions)
+	consumptionsGroup.POST("", api.HandlePostConsumption)
+	consumptionsGroup.GET("/:id", api.HandleGetConsumption)
+	consumptionsGroup.PUT("/:id", api.HandlePutConsumption)
+	consumptionsGroup.DELETE("/:id", api.HandleDeleteConsumption)
+	symptomsGroup := apiAuthGroup.Group("/symptoms", handler.APIAccess(types.ResourceSymptoms, types.PermissionTrackData))
+	symptomsGroup.GET("", api.HandleGetSymptomRatings)
+	symptomsGroup.POST("", api.HandlePostSymptomRating)
+	symptomsGroup.GET("/:id", api.HandleGetSymptomRating)
+	symptomsGroup.PUT("/:id", api.HandlePutSymptomRating)
+	symptomsGroup.DELETE("/:id", api.HandleDeleteSymptomRating)
 }
 
-// initEverything initializes everything needed for the server to run
-func initEverything() error {
-	if err := godotenv.Load(); err != nil {
-		return err
+// initEverything initializes everything needed for the server to run with the configuration, returning the
+// repositories, the services and, if Supabase is used, the Supabase client
+func initEverything(ctx context.Context, cfg *config.Config) (*storage.Repositories, handler.Services, *supabase.Client, error) {
+	var svc handler.Services
+	db, err := storage.NewBun(ctx, cfg.Database)
+	if err != nil {
+		return nil, svc, nil, err
 	}
+	keyring, err := storage.NewKeyringFromConfig(storage.NewBunDataKeyRepository(db), cfg.Encryption)
+	if err != nil {
+		return nil, svc, nil, err
+	}
+	repos := storage.NewBunRepositories(db, keyring)
+	go storage.RunTrashPurge(ctx, db, storage.TrashPurgeInterval)
 
-	if err := storage.InitBunWithPostgres(); err != nil {
-		return err
+	if svc.Sessions, err = storage.InitSessionStore(ctx, repos.Sessions, cfg.Auth, handler.NewIPExtractor(cfg.HTTP)); err != nil {
+		return nil, svc, nil, err
 	}
 
-	dbType := os.Getenv("DB_TYPE")
-	if dbType == storage.DBTypeRemote {
-		return storage.InitSupabaseClient()
+	if svc.Identity, err = auth.InitIdentityConfig(cfg); err != nil {
+		return nil, svc, nil, err
 	}
-	return nil
+
+	if svc.Throttler, err = auth.InitLoginThrottler(db, cfg.Auth.LoginThrottleStore); err != nil {
+		return nil, svc, nil, err
+	}
+
+	if svc.Mailer, err = mail.InitMailer(cfg.SMTP); err != nil {
+		return nil, svc, nil, err
+	}
+
+	if svc.Identity.UsesSupabase() {
+		return repos, svc, storage.NewSupabaseClient(cfg.Supabase), nil
+	}
+	return repos, svc, nil, nil
 }
 
-// parseLogLevel returns the log level from the environment, as a log.Lvl
-func parseLogLevel() gommonlog.Lvl {
-	switch os.Getenv("LOG_LEVEL") {
+// parseLogLevel returns the log level of the configuration, as a log.Lvl
+func parseLogLevel(level string) gommonlog.Lvl {
+	switch level {
 	case "DEBUG":
 		return gommonlog.DEBUG
 	case "INFO":
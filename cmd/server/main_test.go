@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/handler"
+	"github.com/TheDonDope/wits-server/pkg/mail"
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiStep is a request of the contract test to a route of the API.
+type apiStep struct {
+	method     string
+	route      string
+	path       string
+	body       string
+	headers    map[string]string
+	wantStatus int
+}
+
+// TestAPIContract checks the OpenAPI document of the API against the server: Every route of the API registered with
+// Echo has to be documented and vice versa, and the responses to requests covering every route have to validate
+// against the document.
+func TestAPIContract(t *testing.T) {
+	e, password := newAPIServer(t)
+	doc := handler.APIDocument()
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	registered := map[string]bool{}
+	for _, r := range e.Routes() {
+		if r.Method == echo.RouteNotFound || !strings.HasPrefix(r.Path, handler.APIPrefix+"/v1/") {
+			continue
+		}
+		route := r.Method + " " + documentedPath(doc, r.Path)
+		registered[route] = true
+		if !documented[route] {
+			t.Errorf("Route %s is registered, but missing in the OpenAPI document", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("Route %s is in the OpenAPI document, but not registered", route)
+		}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	specJSON, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	spec, err := jsonschema.UnmarshalJSON(bytes.NewReader(specJSON))
+	if err != nil {
+		t.Fatalf("jsonschema.UnmarshalJSON() error = %v", err)
+	}
+	if err := compiler.AddResource("openapi.json", spec); err != nil {
+		t.Fatalf("AddResource() error = %v", err)
+	}
+
+	var bearer string
+	exercised := map[string]bool{}
+	do := func(t *testing.T, step apiStep) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(step.method, handler.APIPrefix+"/v1"+step.path, strings.NewReader(step.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if bearer != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+		}
+		for name, value := range step.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s %s status = %v, want %v with body %s", step.method, step.path, rec.Code, step.wantStatus, rec.Body)
+		}
+		route := step.method + " " + documentedPath(doc, handler.APIPrefix+"/v1"+step.route)
+		exercised[route] = true
+		validateResponse(t, compiler, doc, step, rec)
+		return rec
+	}
+
+	// The access tokens and the account
+	t.Run("account", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodPost, route: "/auth/token", path: "/auth/token", body: `{"login": "contract@wits.example", "password": "wrong"}`, wantStatus: http.StatusUnauthorized})
+		rec := do(t, apiStep{method: http.MethodPost, route: "/auth/token", path: "/auth/token", body: `{"login": "contract@wits.example", "password": "` + password + `"}`, wantStatus: http.StatusOK})
+		var token struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &token); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		do(t, apiStep{method: http.MethodGet, route: "/account", path: "/account", wantStatus: http.StatusUnauthorized})
+		bearer = token.AccessToken
+
+		rec = do(t, apiStep{method: http.MethodGet, route: "/account", path: "/account", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/account", path: "/account", headers: map[string]string{"If-None-Match": rec.Header().Get("ETag")}, wantStatus: http.StatusNotModified})
+	})
+
+	// The share links and the audit log, which the settings page offers as well
+	t.Run("report shares", func(t *testing.T) {
+		today := time.Now().Format(time.DateOnly)
+		lastWeek := time.Now().AddDate(0, 0, -7).Format(time.DateOnly)
+		do(t, apiStep{method: http.MethodPost, route: "/report-shares", path: "/report-shares", body: `{"name": "", "from": "` + today + `", "to": "` + lastWeek + `", "expires_in_days": 7}`, wantStatus: http.StatusUnprocessableEntity})
+		var rec *httptest.ResponseRecorder
+		for range 2 {
+			rec = do(t, apiStep{method: http.MethodPost, route: "/report-shares", path: "/report-shares", body: `{"name": "Dr. Smith", "from": "` + lastWeek + `", "to": "` + today + `", "expires_in_days": 7}`, wantStatus: http.StatusCreated})
+		}
+		var share struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &share); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		do(t, apiStep{method: http.MethodGet, route: "/report-shares", path: "/report-shares?limit=1&sort=-expires_at&status=active", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/report-shares", path: "/report-shares?limit=1000", wantStatus: http.StatusUnprocessableEntity})
+		rec = do(t, apiStep{method: http.MethodGet, route: "/report-shares/:id", path: "/report-shares/" + share.ID, wantStatus: http.StatusOK})
+		shareETag := rec.Header().Get("ETag")
+		do(t, apiStep{method: http.MethodGet, route: "/report-shares/:id", path: "/report-shares/" + uuid.NewString(), wantStatus: http.StatusNotFound})
+		do(t, apiStep{method: http.MethodPatch, route: "/report-shares/:id", path: "/report-shares/" + share.ID, body: `{"revoked": true}`, wantStatus: http.StatusPreconditionRequired})
+		rec = do(t, apiStep{method: http.MethodPatch, route: "/report-shares/:id", path: "/report-shares/" + share.ID, body: `{"revoked": true}`, headers: map[string]string{"If-Match": shareETag}, wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodDelete, route: "/report-shares/:id", path: "/report-shares/" + share.ID, headers: map[string]string{"If-Match": shareETag}, wantStatus: http.StatusPreconditionFailed})
+		do(t, apiStep{method: http.MethodDelete, route: "/report-shares/:id", path: "/report-shares/" + share.ID, headers: map[string]string{"If-Match": rec.Header().Get("ETag")}, wantStatus: http.StatusNoContent})
+	})
+	t.Run("audit events", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodGet, route: "/audit-events", path: "/audit-events?action=report_share", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/audit-events", path: "/audit-events?created_after=yesterday", wantStatus: http.StatusUnprocessableEntity})
+	})
+
+	// The tracked records, which are all created, listed, replaced and moved to the trash alike
+	var productID string
+	t.Run("products", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodPost, route: "/products", path: "/products", body: `{"name": "", "kind": "resin"}`, wantStatus: http.StatusUnprocessableEntity})
+		rec := do(t, apiStep{method: http.MethodPost, route: "/products", path: "/products", body: `{"name": "Bedrocan", "kind": "flower", "thc": 22, "cbd": 1}`, wantStatus: http.StatusCreated})
+		productID = decodeID(t, rec)
+		do(t, apiStep{method: http.MethodGet, route: "/products", path: "/products?kind=flower&sort=-name", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/products", path: "/products?kind=resin", wantStatus: http.StatusUnprocessableEntity})
+		exerciseRecord(t, do, "/products", productID, `{"name": "Bedrocan", "kind": "flower", "thc": 21.5}`)
+		rec = do(t, apiStep{method: http.MethodPost, route: "/products", path: "/products", body: `{"name": "Bediol", "kind": "oil"}`, wantStatus: http.StatusCreated})
+		productID = decodeID(t, rec)
+	})
+	t.Run("inventory", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodPost, route: "/inventory", path: "/inventory", body: `{"product_id": "` + uuid.NewString() + `", "amount": -1}`, wantStatus: http.StatusUnprocessableEntity})
+		rec := do(t, apiStep{method: http.MethodPost, route: "/inventory", path: "/inventory", body: `{"product_id": "` + productID + `", "amount": 10, "acquired_at": "2026-01-31T00:00:00Z"}`, wantStatus: http.StatusCreated})
+		do(t, apiStep{method: http.MethodGet, route: "/inventory", path: "/inventory?product_id=" + productID + "&sort=amount", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/inventory", path: "/inventory?product_id=bedrocan", wantStatus: http.StatusUnprocessableEntity})
+		exerciseRecord(t, do, "/inventory", decodeID(t, rec), `{"product_id": "`+productID+`", "amount": 5, "acquired_at": "2026-01-31T00:00:00Z"}`)
+	})
+	t.Run("consumptions", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodPost, route: "/consumptions", path: "/consumptions", body: `{"product_id": "` + productID + `", "amount": 0, "method": "injected"}`, wantStatus: http.StatusUnprocessableEntity})
+		rec := do(t, apiStep{method: http.MethodPost, route: "/consumptions", path: "/consumptions", body: `{"product_id": "` + productID + `", "amount": 0.5, "method": "oral", "consumed_at": "2026-02-01T20:00:00Z", "notes": "Before sleeping"}`, wantStatus: http.StatusCreated})
+		do(t, apiStep{method: http.MethodGet, route: "/consumptions", path: "/consumptions?method=oral&consumed_after=2026-02-01T00:00:00Z&consumed_before=2026-02-02T00:00:00Z", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/consumptions", path: "/consumptions?consumed_after=yesterday", wantStatus: http.StatusUnprocessableEntity})
+		exerciseRecord(t, do, "/consumptions", decodeID(t, rec), `{"product_id": "`+productID+`", "amount": 1, "method": "sublingual", "consumed_at": "2026-02-01T20:00:00Z"}`)
+	})
+	t.Run("symptoms", func(t *testing.T) {
+		do(t, apiStep{method: http.MethodPost, route: "/symptoms", path: "/symptoms", body: `{"symptom": "Pain", "severity": 11}`, wantStatus: http.StatusUnprocessableEntity})
+		rec := do(t, apiStep{method: http.MethodPost, route: "/symptoms", path: "/symptoms", body: `{"symptom": "Pain", "severity": 6, "rated_at": "2026-02-01T08:00:00Z"}`, wantStatus: http.StatusCreated})
+		do(t, apiStep{method: http.MethodGet, route: "/symptoms", path: "/symptoms?min_severity=5&rated_after=2026-02-01T00:00:00Z&sort=-severity", wantStatus: http.StatusOK})
+		do(t, apiStep{method: http.MethodGet, route: "/symptoms", path: "/symptoms?min_severity=11", wantStatus: http.StatusUnprocessableEntity})
+		exerciseRecord(t, do, "/symptoms", decodeID(t, rec), `{"symptom": "Pain", "severity": 3, "rated_at": "2026-02-01T08:00:00Z", "notes": "Better after the oil"}`)
+	})
+
+	for route := range documented {
+		if !exercised[route] {
+			t.Errorf("Route %s is not exercised by the contract test", route)
+		}
+	}
+}
+
+// exerciseRecord reads, replaces and deletes the tracked record with the id below the path, checking the 404 of an
+// unknown id and the preconditions of the ETag along the way.
+func exerciseRecord(t *testing.T, do func(*testing.T, apiStep) *httptest.ResponseRecorder, path, id, replacement string) {
+	t.Helper()
+	route := path + "/:id"
+	rec := do(t, apiStep{method: http.MethodGet, route: route, path: path + "/" + id, wantStatus: http.StatusOK})
+	readETag := rec.Header().Get("ETag")
+	do(t, apiStep{method: http.MethodGet, route: route, path: path + "/" + id, headers: map[string]string{"If-None-Match": readETag}, wantStatus: http.StatusNotModified})
+	do(t, apiStep{method: http.MethodGet, route: route, path: path + "/" + uuid.NewString(), wantStatus: http.StatusNotFound})
+	do(t, apiStep{method: http.MethodPut, route: route, path: path + "/" + id, body: replacement, wantStatus: http.StatusPreconditionRequired})
+	rec = do(t, apiStep{method: http.MethodPut, route: route, path: path + "/" + id, body: replacement, headers: map[string]string{"If-Match": readETag}, wantStatus: http.StatusOK})
+	do(t, apiStep{method: http.MethodDelete, route: route, path: path + "/" + id, headers: map[string]string{"If-Match": readETag}, wantStatus: http.StatusPreconditionFailed})
+	do(t, apiStep{method: http.MethodDelete, route: route, path: path + "/" + id, headers: map[string]string{"If-Match": rec.Header().Get("ETag")}, wantStatus: http.StatusNoContent})
+	do(t, apiStep{method: http.MethodGet, route: route, path: path + "/" + id, wantStatus: http.StatusNotFound})
+}
+
+// decodeID returns the id of the resource in the body of the response.
+func decodeID(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resource struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resource); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return resource.ID
+}
+
+// validateResponse checks the response of the step against the response of its operation in the OpenAPI document,
+// falling back to the default response for undocumented statuses.
+func validateResponse(t *testing.T, compiler *jsonschema.Compiler, doc *openapi.Document, step apiStep, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	op := doc.Operation(step.method, handler.APIPrefix+"/v1"+step.route)
+	if op == nil {
+		t.Fatalf("%s %s is missing in the OpenAPI document", step.method, step.route)
+	}
+	status := strconv.Itoa(rec.Code)
+	response, ok := op.Responses[status]
+	if !ok {
+		status = "default"
+		response = op.Responses[status]
+	}
+	for name := range response.Headers {
+		if name != "Link" && rec.Header().Get(name) == "" {
+			t.Errorf("%s %s response %v misses the documented header %s", step.method, step.path, rec.Code, name)
+		}
+	}
+	if len(response.Content) == 0 {
+		if rec.Body.Len() > 0 {
+			t.Errorf("%s %s response %v has a body %s, which is not documented", step.method, step.path, rec.Code, rec.Body)
+		}
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(rec.Header().Get(echo.HeaderContentType))
+	if _, ok := response.Content[contentType]; !ok {
+		t.Fatalf("%s %s response %v has the content type %q, which is not documented", step.method, step.path, rec.Code, contentType)
+	}
+	pointer := "/paths/" + escapePointer(documentedPath(doc, handler.APIPrefix+"/v1"+step.route)) + "/" + strings.ToLower(step.method) +
+		"/responses/" + status + "/content/" + escapePointer(contentType) + "/schema"
+	schema, err := compiler.Compile("openapi.json#" + pointer)
+	if err != nil {
+		t.Fatalf("Compile(%s) error = %v", pointer, err)
+	}
+	body, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("%s %s response %v is no JSON: %s", step.method, step.path, rec.Code, rec.Body)
+	}
+	if err := schema.Validate(body); err != nil {
+		t.Errorf("%s %s response %v does not validate against the OpenAPI document: %v", step.method, step.path, rec.Code, err)
+	}
+}
+
+// documentedPath returns the path of the OpenAPI document for a route path of Echo, e.g. /report-shares/{id} for
+// /api/v1/report-shares/:id.
+func documentedPath(doc *openapi.Document, echoPath string) string {
+	segments := strings.Split(strings.TrimPrefix(echoPath, doc.Servers[0].URL), "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapePointer escapes a key of a JSON pointer, see RFC 6901.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// newAPIServer returns the server with its routes on a migrated SQLite database, which has a local user. The
+// password of the user is returned as well.
+func newAPIServer(t *testing.T) (*echo.Echo, string) {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "wits.db")
+	sqlDB, err := storage.CreateSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("CreateSQLiteDB() error = %v", err)
+	}
+	driver, err := sqlite.WithInstance(sqlDB, &sqlite.Config{})
+	if err != nil {
+		t.Fatalf("sqlite.WithInstance() error = %v", err)
+	}
+	src, err := migrations.NewSource(storage.DBDriverSQLite)
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, storage.DBDriverSQLite, driver)
+	if err != nil {
+		t.Fatalf("migrate.NewWithInstance() error = %v", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	sqlDB.Close()
+
+	cfg := config.Default()
+	cfg.Database.Driver = storage.DBDriverSQLite
+	cfg.Database.Path = path
+	cfg.Auth.JWTSecretKey = config.Secret(strings.Repeat("j", 32))
+	cfg.Auth.SessionSecret = config.Secret(strings.Repeat("s", 32))
+	db, err := storage.NewBun(ctx, cfg.Database)
+	if err != nil {
+		t.Fatalf("NewBun() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repos := storage.NewBunRepositories(db, nil)
+	var svc handler.Services
+	if svc.Sessions, err = storage.InitSessionStore(ctx, repos.Sessions, cfg.Auth, handler.NewIPExtractor(cfg.HTTP)); err != nil {
+		t.Fatalf("InitSessionStore() error = %v", err)
+	}
+	if svc.Identity, err = auth.InitIdentityConfig(cfg); err != nil {
+		t.Fatalf("InitIdentityConfig() error = %v", err)
+	}
+	if svc.Throttler, err = auth.InitLoginThrottler(db, cfg.Auth.LoginThrottleStore); err != nil {
+		t.Fatalf("InitLoginThrottler() error = %v", err)
+	}
+	svc.Mailer = mail.LogMailer{}
+
+	password := "correct horse battery staple"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	user := types.AuthenticatedUser{ID: uuid.New(), Email: "contract@wits.example", Password: string(hash)}
+	user.Account = types.Account{ID: uuid.New(), UserID: user.ID}
+	if err := repos.Users.CreateAuthenticatedUser(ctx, &user); err != nil {
+		t.Fatalf("CreateAuthenticatedUser() error = %v", err)
+	}
+	if err := repos.Accounts.CreateAccount(ctx, &user.Account); err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+
+	e := echo.New()
+	configureRoutes(ctx, e, repos, cfg, svc, nil)
+	return e, password
+}
+
+func TestAPIDocs(t *testing.T) {
+	e, _ := newAPIServer(t)
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{handler.APIPrefix + "/openapi.json", `"openapi":"3.1.0"`},
+		{handler.APIPrefix + "/docs", `id="listReportShares"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %v, want 200 with %s", tt.path, rec.Code, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProbes(t *testing.T) {
+	e, _ := newAPIServer(t)
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{handler.LivenessPath, `"status":"ok"`},
+		{handler.ReadinessPath, `"migrations":"ok"`},
+		{handler.VersionPath, `"go_version":"go`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %v %s, want 200 with %s", tt.path, rec.Code, rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
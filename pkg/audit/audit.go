@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Recorder appends the events of the requests to the audit log.
+type Recorder struct {
+	events storage.AuditRepository
+}
+
+// NewRecorder returns a new Recorder, storing the events in the repository.
+func NewRecorder(events storage.AuditRepository) *Recorder {
+	return &Recorder{events: events}
+}
+
+// Record appends the event to the audit log, completing it with the details of the request: the IP address, the
+// user agent and, unless given, the logged in user as actor and the account the user is acting on. Failing to
+// record an event is logged, but never fails the request.
+func (r *Recorder) Record(c echo.Context, event types.AuditEvent) {
+	slog.Info("💬 🗒️  (pkg/audit/audit.go) Record()", "action", event.Action)
+	if event.IPAddress == "" {
+		event.IPAddress = c.RealIP()
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = c.Request().UserAgent()
+	}
+	if user, ok := c.Get(types.UserContextKey).(types.AuthenticatedUser); ok && user.LoggedIn {
+		if event.ActorID == uuid.Nil {
+			event.ActorID = user.ID
+			if event.Email == "" {
+				event.Email = user.LoginName()
+			}
+		}
+		if event.AccountID == uuid.Nil {
+			event.AccountID = user.OwnerID()
+		}
+	}
+	if event.AccountID == uuid.Nil {
+		event.AccountID = event.ActorID
+	}
+	if err := r.events.CreateAuditEvent(c.Request().Context(), &event); err != nil {
+		slog.Error("🚨 🗒️  (pkg/audit/audit.go) ❓❓❓❓ 🗒️  Recording audit event failed with", "action", event.Action, "error", err)
+		return
+	}
+	slog.Info("✅ 🗒️  (pkg/audit/audit.go) Record() -> 🗒️  Audit event has been recorded", "action", event.Action)
+}
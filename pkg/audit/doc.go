@@ -0,0 +1,3 @@
+// Package audit records security relevant events, like logins, password changes and changes of account data, in
+// the append-only audit log.
+package audit // import "github.com/TheDonDope/wits-server/pkg/audit"
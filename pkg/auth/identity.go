@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+)
+
+const (
+	// ProviderLocal logs in users with their email or username and a password stored in the local database.
+	ProviderLocal = "local"
+	// ProviderSupabase logs in users with their email and password at Supabase.
+	ProviderSupabase = "supabase"
+	// ProviderGoogle logs in users with their Google account through Supabase.
+	ProviderGoogle = "google"
+	// ProviderOIDC logs in users with a generic OpenID Connect provider, configured with the OIDC_* variables.
+	ProviderOIDC = "oidc"
+)
+
+// Providers are all identity providers which can be enabled.
+var Providers = []string{ProviderLocal, ProviderSupabase, ProviderGoogle, ProviderOIDC}
+
+// IdentityConfig is the configuration of the identity providers, with which users can log in. Providers can be
+// enabled independently of the database, and combined, e.g. local passwords together with OpenID Connect.
+type IdentityConfig struct {
+	// Providers are the enabled identity providers, in the order they are shown on the login page
+	Providers []string
+}
+
+// InitIdentityConfig returns the identity configuration from the configuration.
+func InitIdentityConfig(c *config.Config) (IdentityConfig, error) {
+	slog.Info("💬 🪪 (pkg/auth/identity.go) InitIdentityConfig()")
+	cfg, err := NewIdentityConfig(c)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/auth/identity.go) ❓❓❓❓ 🪪 Configuring identity providers failed with", "error", err)
+		return IdentityConfig{}, err
+	}
+	slog.Info("✅ 🪪 (pkg/auth/identity.go) InitIdentityConfig() -> 🪪 Using identity providers", "providers", cfg.Providers)
+	return cfg, nil
+}
+
+// NewIdentityConfig returns the IdentityConfig with the identity providers of the configuration, see
+// config.Config.IdentityProviders.
+func NewIdentityConfig(c *config.Config) (IdentityConfig, error) {
+	cfg := IdentityConfig{Providers: c.IdentityProviders()}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that at least one known provider is enabled, and at most one of the providers logging in with
+// the password form.
+func (c IdentityConfig) Validate() error {
+	if len(c.Providers) == 0 {
+		return fmt.Errorf("no identity provider enabled, set AUTH_PROVIDERS to any of %s", strings.Join(Providers, ","))
+	}
+	for _, p := range c.Providers {
+		if !slices.Contains(Providers, p) {
+			return fmt.Errorf("unknown identity provider %q, choose any of %s", p, strings.Join(Providers, ","))
+		}
+	}
+	if c.Enabled(ProviderLocal) && c.Enabled(ProviderSupabase) {
+		return fmt.Errorf("identity providers %q and %q both log in with the password form, enable only one of them", ProviderLocal, ProviderSupabase)
+	}
+	return nil
+}
+
+// Enabled reports whether the identity provider is enabled.
+func (c IdentityConfig) Enabled(provider string) bool {
+	return slices.Contains(c.Providers, provider)
+}
+
+// UsesSupabase reports whether an enabled provider logs in users at Supabase, which requires the Supabase client.
+// Sessions of Supabase carry access tokens signed by Supabase instead of self-signed ones.
+func (c IdentityConfig) UsesSupabase() bool {
+	return c.Enabled(ProviderSupabase) || c.Enabled(ProviderGoogle)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+)
+
+func TestNewIdentityConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		authProviders string
+		dbType        string
+		oidcIssuer    string
+		want          []string
+		wantErr       bool
+	}{
+		{"Local database should default to local passwords", "", "local", "", []string{ProviderLocal}, false},
+		{"Local database with issuer should add OpenID Connect", "", "local", "https://sso.example.org", []string{ProviderLocal, ProviderOIDC}, false},
+		{"Remote database should default to Supabase and Google", "", "remote", "", []string{ProviderSupabase, ProviderGoogle}, false},
+		{"Providers should be independent of the database", "oidc, Google", "local", "", []string{ProviderOIDC, ProviderGoogle}, false},
+		{"Unknown provider should fail", "local,ldap", "local", "", nil, true},
+		{"Two password providers should fail", "local,supabase", "", "", nil, true},
+		{"No provider should fail", "", "", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.Default()
+			c.Auth.Providers = strings.FieldsFunc(tt.authProviders, func(r rune) bool { return r == ',' || r == ' ' })
+			c.Database.Type = tt.dbType
+			c.OIDC.IssuerURL = tt.oidcIssuer
+			cfg, err := NewIdentityConfig(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewIdentityConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(cfg.Providers, tt.want) {
+				t.Errorf("NewIdentityConfig() providers = %v, want %v", cfg.Providers, tt.want)
+			}
+		})
+	}
+}
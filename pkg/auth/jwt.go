@@ -3,12 +3,12 @@ package auth
 import (
 	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/gorilla/sessions"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -21,6 +21,8 @@ const (
 	RefreshTokenCookieName = "wits-refresh-token"
 	// WitsSessionName is the name of the session cookie.
 	WitsSessionName = "wits-session"
+	// TokenLifetime is the time after which a signed token expires.
+	TokenLifetime = time.Hour
 )
 
 // WitsCustomClaims are custom claims extending default ones.
@@ -30,12 +32,14 @@ type WitsCustomClaims struct {
 	jwt.RegisteredClaims
 }
 
-// EchoJWTConfig returns the configuration for the echo-jwt middleware.
-func EchoJWTConfig() echojwt.Config {
+// EchoJWTConfig returns the configuration for the echo-jwt middleware, verifying the tokens with the secret key. The
+// access token is read from the session of the session store.
+func EchoJWTConfig(secretKey config.Secret, sessions *storage.PostgresStore) echojwt.Config {
 	return echojwt.Config{
-		BeforeFunc:   echoBeforeFunc,
+		Skipper:      echoSkipper,
+		BeforeFunc:   echoBeforeFunc(sessions),
 		ErrorHandler: echoJWTErrorHandler,
-		SigningKey:   []byte(os.Getenv("JWT_SECRET_KEY")),
+		SigningKey:   []byte(secretKey.Value()),
 		TokenLookupFuncs: []middleware.ValuesExtractor{
 			echoContextExtractor,
 		},
@@ -51,7 +55,9 @@ func SignToken(user types.AuthenticatedUser, secret []byte) (string, error) {
 	claims := &WitsCustomClaims{
 		Email: user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenLifetime)),
 		},
 	}
 	// Declare the token with the algorithm used for signing, and the claims
@@ -62,21 +68,39 @@ func SignToken(user types.AuthenticatedUser, secret []byte) (string, error) {
 	return token.SignedString(secret)
 }
 
-// echoBeforeFunc sets the access token in the echo.Context.
-func echoBeforeFunc(c echo.Context) {
-	slog.Info("💬 🏠 (pkg/auth/jwt.go) echoBeforeFunc()")
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), WitsSessionName)
-	accessToken, ok := session.Values[AccessTokenCookieName]
-	if !ok {
-		slog.Error("🚨 🏠 (pkg/auth/jwt.go) ❓❓❓❓ 🔑 Access token not found in session")
-		return
+// ParseToken verifies a JWT token signed with the specified secret and returns its claims, e.g. of a bearer token.
+func ParseToken(token string, secret []byte) (*WitsCustomClaims, error) {
+	claims := new(WitsCustomClaims)
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// echoSkipper skips the validation of the access token for requests authenticated with a personal API token.
+func echoSkipper(c echo.Context) bool {
+	_, ok := c.Get(types.APITokenContextKey).(types.APIToken)
+	return ok
+}
+
+// echoBeforeFunc returns the function, which sets the access token of the session in the echo.Context.
+func echoBeforeFunc(sessions *storage.PostgresStore) middleware.BeforeFunc {
+	return func(c echo.Context) {
+		slog.Info("💬 🏠 (pkg/auth/jwt.go) echoBeforeFunc()")
+		session, _ := sessions.Get(c.Request(), WitsSessionName)
+		accessToken, ok := session.Values[AccessTokenCookieName]
+		if !ok {
+			slog.Error("🚨 🏠 (pkg/auth/jwt.go) ❓❓❓❓ 🔑 Access token not found in session")
+			return
+		}
+		token := accessToken.(string)
+		c.Set(AccessTokenCookieName, token)
+		slog.Info("🆗 🏠 (pkg/auth/jwt.go)  🔓 Token found and set with", "token", token[:5]+"...")
+		slog.Info("✅ 🏠 (pkg/auth/jwt.go) echoBeforeFunc() -> 📦 Access token has been set in echo.Context")
 	}
-	token := accessToken.(string)
-	c.Set(AccessTokenCookieName, token)
-	slog.Info("🆗 🏠 (pkg/auth/jwt.go)  🔓 Token found and set with", "token", token[:5]+"...")
-	slog.Info("✅ 🏠 (pkg/auth/jwt.go) echoBeforeFunc() -> 📦 Access token has been set in echo.Context")
-	return
 }
 
 // echoContextExtractor extracts the token from the echo.Context.
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// usernameAdjectives and usernameNouns are combined to generated usernames, which are easy to remember but do not
+// reveal anything about the user.
+var (
+	usernameAdjectives = []string{
+		"amber", "brave", "calm", "clever", "cosmic", "curious", "dusty", "eager", "gentle", "golden",
+		"happy", "hazy", "jolly", "lucky", "mellow", "misty", "quiet", "rapid", "silent", "sunny",
+	}
+	usernameNouns = []string{
+		"badger", "beetle", "falcon", "ferret", "gecko", "heron", "koala", "lynx", "marmot", "otter",
+		"owl", "panda", "puffin", "raven", "salmon", "sloth", "sparrow", "tapir", "walrus", "wombat",
+	}
+)
+
+// recoveryCodeEncoding encodes recovery codes in lower case without padding, so they are easy to type.
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateUsername returns a random username of the form adjective-noun-number, e.g. calm-otter-4821.
+func GenerateUsername() (string, error) {
+	adjective, err := randomInt(len(usernameAdjectives))
+	if err != nil {
+		return "", err
+	}
+	noun, err := randomInt(len(usernameNouns))
+	if err != nil {
+		return "", err
+	}
+	number, err := randomInt(10000)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%04d", usernameAdjectives[adjective], usernameNouns[noun], number), nil
+}
+
+// GenerateRecoveryCodes returns n random single-use recovery codes of the form xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns the recovery code as it has been generated, ignoring case and surrounding spaces.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// randomInt returns a uniform random number in [0, max).
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUsername(t *testing.T) {
+	username, err := GenerateUsername()
+	if err != nil {
+		t.Fatalf("GenerateUsername() error = %v", err)
+	}
+	if !regexp.MustCompile(`^[a-z]+-[a-z]+-\d{4}$`).MatchString(username) {
+		t.Errorf("GenerateUsername() = %v, want adjective-noun-number", username)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want 10", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`).MatchString(code) {
+			t.Errorf("GenerateRecoveryCodes() code = %v, want xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("GenerateRecoveryCodes() returned %v twice", code)
+		}
+		seen[code] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(code)+" ") != code {
+			t.Errorf("NormalizeRecoveryCode() did not restore %v", code)
+		}
+	}
+}
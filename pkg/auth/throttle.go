@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// ThrottleStoreMemory keeps the failed login counters in memory, which is sufficient for a single instance.
+	ThrottleStoreMemory = "memory"
+	// ThrottleStorePostgres keeps the failed login counters in Postgres, so they are shared by multiple replicas.
+	ThrottleStorePostgres = "postgres"
+)
+
+// LoginAttemptStore is the interface for the storage of the failed login counters.
+type LoginAttemptStore interface {
+	// GetLoginAttempt returns the login attempt for the key, or an empty attempt if there is none
+	GetLoginAttempt(ctx context.Context, key string) (types.LoginAttempt, error)
+	// RecordLoginFailure counts the failure atomically and returns the attempt after it
+	RecordLoginFailure(ctx context.Context, failure types.LoginFailure) (types.LoginAttempt, error)
+	// DeleteLoginAttempt deletes the login attempt for the key
+	DeleteLoginAttempt(ctx context.Context, key string) error
+}
+
+// ThrottlePolicy defines how failed logins for a single key are slowed down and locked out.
+type ThrottlePolicy struct {
+	// FreeAttempts is the number of failures before any delay is enforced
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure beyond the free attempts, doubling with every further failure
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential delay
+	MaxDelay time.Duration
+	// LockoutThreshold is the number of failures after which the key is locked out
+	LockoutThreshold int
+	// LockoutDuration is how long a lockout lasts
+	LockoutDuration time.Duration
+	// ResetAfter is the time after the last failure after which the counter starts over
+	ResetAfter time.Duration
+}
+
+// EmailThrottlePolicy is the policy for failed logins to a single account.
+var EmailThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       24 * time.Hour,
+}
+
+// IPThrottlePolicy is the policy for failed logins from a single IP address, which may be shared by many users.
+var IPThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:     10,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 50,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       24 * time.Hour,
+}
+
+// ThrottleResult describes the state of the throttled keys after a failed login.
+type ThrottleResult struct {
+	// Wait is how long the client has to wait before the next login attempt
+	Wait time.Duration
+	// LockedOut lists the keys which have been locked out by this failure
+	LockedOut []string
+}
+
+// LoginThrottler slows down and locks out repeated failed logins, keyed by IP address and by email.
+type LoginThrottler struct {
+	store       LoginAttemptStore
+	ipPolicy    ThrottlePolicy
+	emailPolicy ThrottlePolicy
+	now         func() time.Time
+}
+
+// NewLoginThrottler returns a new LoginThrottler using the given store and the default policies.
+func NewLoginThrottler(store LoginAttemptStore) *LoginThrottler {
+	return &LoginThrottler{store: store, ipPolicy: IPThrottlePolicy, emailPolicy: EmailThrottlePolicy, now: time.Now}
+}
+
+// InitLoginThrottler returns the login throttler with the store of the type, memory or postgres. The Postgres store
+// uses the database connection.
+func InitLoginThrottler(db bun.IDB, storeType string) (*LoginThrottler, error) {
+	slog.Info("💬 🏠 (pkg/auth/throttle.go) InitLoginThrottler()")
+	var throttler *LoginThrottler
+	switch storeType {
+	case "", ThrottleStoreMemory:
+		throttler = NewLoginThrottler(NewMemoryLoginAttemptStore())
+	case ThrottleStorePostgres:
+		throttler = NewLoginThrottler(storage.NewPostgresLoginAttemptStore(db))
+	default:
+		return nil, fmt.Errorf("LOGIN_THROTTLE_STORE is invalid: %s", storeType)
+	}
+	slog.Info("✅ 🏠 (pkg/auth/throttle.go) InitLoginThrottler() -> 🐢 Using login throttle store", "store", storeType)
+	return throttler, nil
+}
+
+// Check returns how long the client has to wait before a login attempt for the ip and email is allowed.
+func (t *LoginThrottler) Check(ctx context.Context, ip string, email string) (time.Duration, error) {
+	now := t.now()
+	var wait time.Duration
+	for key, policy := range t.policies(ip, email) {
+		attempt, err := t.store.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, policy.wait(attempt, now))
+	}
+	return wait, nil
+}
+
+// Fail records a failed login attempt for the ip and email.
+func (t *LoginThrottler) Fail(ctx context.Context, ip string, email string) (ThrottleResult, error) {
+	now := t.now()
+	result := ThrottleResult{}
+	for key, policy := range t.policies(ip, email) {
+		attempt, err := t.store.RecordLoginFailure(ctx, types.LoginFailure{
+			Key:              key,
+			At:               now,
+			ResetBefore:      now.Add(-policy.ResetAfter),
+			LockoutThreshold: policy.LockoutThreshold,
+			LockUntil:        now.Add(policy.LockoutDuration),
+		})
+		if err != nil {
+			return result, err
+		}
+		// The counter only reaches the threshold once until it starts over, so exactly one failure reports the lockout
+		if attempt.Failures == policy.LockoutThreshold {
+			result.LockedOut = append(result.LockedOut, key)
+			slog.Warn("🚨 🏠 (pkg/auth/throttle.go) ❓❓❓❓ 🐢 Login has been locked out for", "key", key, "until", attempt.LockedUntil)
+		}
+		result.Wait = max(result.Wait, policy.wait(attempt, now))
+	}
+	return result, nil
+}
+
+// Succeed resets the failed login counter of the email after a successful login. The counter of the IP address is
+// kept, so a valid account can not be used to reset it.
+func (t *LoginThrottler) Succeed(ctx context.Context, email string) error {
+	return t.store.DeleteLoginAttempt(ctx, emailThrottleKey(email))
+}
+
+// policies returns the throttling keys for the ip and email with their policies.
+func (t *LoginThrottler) policies(ip string, email string) map[string]ThrottlePolicy {
+	return map[string]ThrottlePolicy{
+		"ip:" + ip:              t.ipPolicy,
+		emailThrottleKey(email): t.emailPolicy,
+	}
+}
+
+// wait returns how long the attempt has to wait at the given time under the policy.
+func (p ThrottlePolicy) wait(attempt types.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil.After(now) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if !attempt.LockedUntil.IsZero() || attempt.Failures < p.FreeAttempts || now.Sub(attempt.LastFailureAt) > p.ResetAfter {
+		return 0
+	}
+	delay := p.MaxDelay
+	if exp := attempt.Failures - p.FreeAttempts; exp < 32 {
+		delay = min(p.BaseDelay<<exp, p.MaxDelay)
+	}
+	if until := attempt.LastFailureAt.Add(delay); until.After(now) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// emailThrottleKey returns the throttling key for the email.
+func emailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// MemoryLoginAttemptStore keeps the failed login counters in memory.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]types.LoginAttempt
+}
+
+// NewMemoryLoginAttemptStore returns a new, empty MemoryLoginAttemptStore.
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: map[string]types.LoginAttempt{}}
+}
+
+// GetLoginAttempt returns the login attempt for the key, or an empty attempt if there is none.
+func (m *MemoryLoginAttemptStore) GetLoginAttempt(ctx context.Context, key string) (types.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if attempt, ok := m.attempts[key]; ok {
+		return attempt, nil
+	}
+	return types.LoginAttempt{Key: key}, nil
+}
+
+// RecordLoginFailure counts the failure under the lock of the store and returns the attempt after it.
+func (m *MemoryLoginAttemptStore) RecordLoginFailure(ctx context.Context, failure types.LoginFailure) (types.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt := m.attempts[failure.Key]
+	attempt.Key = failure.Key
+	expiredLockout := !attempt.LockedUntil.IsZero() && !attempt.LockedUntil.After(failure.At)
+	if expiredLockout || attempt.LastFailureAt.Before(failure.ResetBefore) {
+		attempt.Failures = 0
+		attempt.LockedUntil = time.Time{}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = failure.At
+	if attempt.Failures >= failure.LockoutThreshold && attempt.LockedUntil.IsZero() {
+		attempt.LockedUntil = failure.LockUntil
+	}
+	m.attempts[failure.Key] = attempt
+	return attempt, nil
+}
+
+// DeleteLoginAttempt deletes the login attempt for the key.
+func (m *MemoryLoginAttemptStore) DeleteLoginAttempt(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
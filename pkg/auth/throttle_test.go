@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottler(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	newThrottler := func() *LoginThrottler {
+		throttler := NewLoginThrottler(NewMemoryLoginAttemptStore())
+		throttler.now = func() time.Time { return now }
+		return throttler
+	}
+
+	tests := []struct {
+		name          string
+		failures      int
+		advance       time.Duration
+		wantWait      time.Duration
+		wantLockedOut bool
+	}{
+		{"Free attempts should not be throttled", EmailThrottlePolicy.FreeAttempts - 1, 0, 0, false},
+		{"First failure beyond the free attempts should wait the base delay", EmailThrottlePolicy.FreeAttempts, 0, time.Second, false},
+		{"Further failures should double the delay", EmailThrottlePolicy.FreeAttempts + 2, 0, 4 * time.Second, false},
+		{"Delay should be capped", EmailThrottlePolicy.LockoutThreshold - 1, 0, time.Minute, false},
+		{"Elapsed delay should allow the next attempt", EmailThrottlePolicy.FreeAttempts + 2, 5 * time.Second, 0, false},
+		{"Reaching the threshold should lock out", EmailThrottlePolicy.LockoutThreshold, 0, EmailThrottlePolicy.LockoutDuration, true},
+		{"Expired lockout should allow the next attempt", EmailThrottlePolicy.LockoutThreshold, EmailThrottlePolicy.LockoutDuration, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler := newThrottler()
+			lockedOut := false
+			for i := 0; i < tt.failures; i++ {
+				result, err := throttler.Fail(context.Background(), "127.0.0.1", "user@foo.org")
+				if err != nil {
+					t.Fatalf("Fail() error = %v", err)
+				}
+				lockedOut = lockedOut || len(result.LockedOut) > 0
+			}
+			throttler.now = func() time.Time { return now.Add(tt.advance) }
+			got, err := throttler.Check(context.Background(), "127.0.0.1", "USER@foo.org")
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.wantWait {
+				t.Errorf("Check() = %v, want %v", got, tt.wantWait)
+			}
+			if lockedOut != tt.wantLockedOut {
+				t.Errorf("Fail() lockedOut = %v, want %v", lockedOut, tt.wantLockedOut)
+			}
+		})
+	}
+}
+
+func TestLoginThrottlerSucceedResetsEmail(t *testing.T) {
+	throttler := NewLoginThrottler(NewMemoryLoginAttemptStore())
+	for i := 0; i < EmailThrottlePolicy.FreeAttempts+1; i++ {
+		if _, err := throttler.Fail(context.Background(), "127.0.0.1", "user@foo.org"); err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+	}
+	if err := throttler.Succeed(context.Background(), "user@foo.org"); err != nil {
+		t.Fatalf("Succeed() error = %v", err)
+	}
+	got, err := throttler.Check(context.Background(), "127.0.0.1", "user@foo.org")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if got != 0 {
+		t.Errorf("Check() = %v, want 0", got)
+	}
+}
+
+func TestLoginThrottlerConcurrentFailures(t *testing.T) {
+	throttler := NewLoginThrottler(NewMemoryLoginAttemptStore())
+	var wg sync.WaitGroup
+	lockouts := make(chan []string, EmailThrottlePolicy.LockoutThreshold*2)
+	for i := 0; i < EmailThrottlePolicy.LockoutThreshold*2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := throttler.Fail(context.Background(), "127.0.0.1", "user@foo.org")
+			if err != nil {
+				t.Errorf("Fail() error = %v", err)
+			}
+			lockouts <- result.LockedOut
+		}()
+	}
+	wg.Wait()
+	close(lockouts)
+	emailLockouts := 0
+	for lockedOut := range lockouts {
+		emailLockouts += len(slices.DeleteFunc(lockedOut, func(key string) bool { return key != "email:user@foo.org" }))
+	}
+	if emailLockouts != 1 {
+		t.Errorf("Fail() reported %d lockouts of the email, want 1", emailLockouts)
+	}
+	attempt, err := throttler.store.GetLoginAttempt(context.Background(), "email:user@foo.org")
+	if err != nil || attempt.Failures != EmailThrottlePolicy.LockoutThreshold*2 {
+		t.Errorf("GetLoginAttempt() = %+v, %v, want %d failures", attempt, err, EmailThrottlePolicy.LockoutThreshold*2)
+	}
+}
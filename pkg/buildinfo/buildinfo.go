@@ -0,0 +1,49 @@
+package buildinfo
+
+import (
+	"cmp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// The build info set by the linker, e.g. with -ldflags "-X github.com/TheDonDope/wits-server/pkg/buildinfo.Version=v1.2.3",
+// see the build target of the Makefile. Without them, the build info of the Go toolchain is used.
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// Info is the build info of the binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	// Modified reports whether the working tree had uncommitted changes, as far as the Go toolchain knows it
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info of the binary. The values set by the linker take precedence over the version control
+// info, which the Go toolchain embeds when building a package of a repository.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = cmp.Or(info.Commit, setting.Value)
+			case "vcs.time":
+				info.BuildTime = cmp.Or(info.BuildTime, setting.Value)
+			case "vcs.modified":
+				info.Modified, _ = strconv.ParseBool(setting.Value)
+			}
+		}
+		info.Version = cmp.Or(info.Version, build.Main.Version)
+	}
+	if info.Version == "" || info.Version == "(devel)" {
+		info.Version = "dev"
+	}
+	return info
+}
@@ -0,0 +1,2 @@
+// Package buildinfo reports the version, commit and build time of the binary, which are embedded at compile time.
+package buildinfo // import "github.com/TheDonDope/wits-server/pkg/buildinfo"
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable with the path of the optional YAML (.yaml, .yml) or TOML (.toml) file.
+const FileEnv = "CONFIG_FILE"
+
+// DotEnvFile is the optional file with environment variables, which is read from the working directory.
+const DotEnvFile = ".env"
+
+// Config is the configuration of Wits. Every value is read from the environment variable of its env tag, or else
+// from the .env file, or else from the file of CONFIG_FILE, with the keys of its yaml and toml tags.
+type Config struct {
+	HTTP       HTTP       `yaml:"http" toml:"http"`
+	Log        Log        `yaml:"log" toml:"log"`
+	Database   Database   `yaml:"database" toml:"database"`
+	Auth       Auth       `yaml:"auth" toml:"auth"`
+	OIDC       OIDC       `yaml:"oidc" toml:"oidc"`
+	Supabase   Supabase   `yaml:"supabase" toml:"supabase"`
+	SMTP       SMTP       `yaml:"smtp" toml:"smtp"`
+	Encryption Encryption `yaml:"encryption" toml:"encryption"`
+}
+
+// HTTP is the configuration of the HTTP server.
+type HTTP struct {
+	// ListenAddr is the address the server listens on (format: <host>:<port>)
+	ListenAddr string `env:"HTTP_LISTEN_ADDR" yaml:"listen_addr" toml:"listen_addr"`
+	// TrustedProxies are the IP addresses or CIDR ranges of the reverse proxies, whose X-Forwarded-For header is
+	// trusted for the IP address of the client. Without them, the address of the connection is used.
+	TrustedProxies []string `env:"HTTP_TRUSTED_PROXIES" yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// Log is the configuration of the server and access logs.
+type Log struct {
+	// Level is one of DEBUG, INFO, WARN, ERROR or OFF
+	Level      string `env:"LOG_LEVEL" yaml:"level" toml:"level"`
+	Dir        string `env:"LOG_DIR" yaml:"dir" toml:"dir"`
+	File       string `env:"LOG_FILE" yaml:"file" toml:"file"`
+	AccessFile string `env:"ACCESS_LOG_FILE" yaml:"access_file" toml:"access_file"`
+}
+
+// Database is the configuration of the database and, for Postgres, of its connection pool.
+type Database struct {
+	// Type is local for a database managed by Wits, or remote for a database managed by Supabase
+	Type string `env:"DB_TYPE" yaml:"type" toml:"type"`
+	// Driver is postgres or sqlite, which stores the data of a local database in the file of Path
+	Driver string `env:"DB_DRIVER" yaml:"driver" toml:"driver"`
+	Path   string `env:"DB_PATH" yaml:"path" toml:"path"`
+	// Host is the host of the database, optionally with the port (format: <host>:<port>, default port: 5432)
+	Host     string `env:"DB_HOST" yaml:"host" toml:"host"`
+	User     string `env:"DB_USER" yaml:"user" toml:"user"`
+	Password Secret `env:"DB_PASSWORD" yaml:"password" toml:"password"`
+	Name     string `env:"DB_NAME" yaml:"name" toml:"name"`
+	// SSLMode is the TLS mode of libpq, one of disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode string `env:"DB_SSLMODE" yaml:"sslmode" toml:"sslmode"`
+	// SSLRootCert is the path of the CA certificates to verify the server with, for verify-ca and verify-full
+	SSLRootCert string `env:"DB_SSLROOTCERT" yaml:"sslrootcert" toml:"sslrootcert"`
+	// MaxOpenConns limits the open connections, 0 means unlimited
+	MaxOpenConns int `env:"DB_MAX_OPEN_CONNS" yaml:"max_open_conns" toml:"max_open_conns"`
+	// MaxIdleConns is the number of idle connections kept in the pool
+	MaxIdleConns int `env:"DB_MAX_IDLE_CONNS" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	// ConnMaxLifetime is the time after which connections are replaced, e.g. to follow a failover
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	// ConnMaxIdleTime is the time after which idle connections are closed
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	// StatementTimeout aborts statements running longer on the server, 0 disables it
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" yaml:"statement_timeout" toml:"statement_timeout"`
+	// ConnectTimeout is the timeout of establishing a single connection
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"connect_timeout" toml:"connect_timeout"`
+	// ConnectAttempts is the number of attempts to reach the database on startup, waiting longer after every failure
+	ConnectAttempts int `env:"DB_CONNECT_ATTEMPTS" yaml:"connect_attempts" toml:"connect_attempts"`
+}
+
+// Auth is the configuration of the identity providers, the tokens and the sessions.
+type Auth struct {
+	// Providers are the enabled identity providers, see Config.IdentityProviders
+	Providers []string `env:"AUTH_PROVIDERS" yaml:"providers" toml:"providers"`
+	// CallbackURL is the URL Supabase redirects to after the login with Google
+	CallbackURL string `env:"AUTH_CALLBACK_URL" yaml:"callback_url" toml:"callback_url"`
+	// JWTSecretKey signs the self-signed access tokens
+	JWTSecretKey Secret `env:"JWT_SECRET_KEY" yaml:"jwt_secret_key" toml:"jwt_secret_key"`
+	// JWTRefreshSecretKey signs the self-signed refresh tokens
+	JWTRefreshSecretKey Secret `env:"JWT_REFRESH_SECRET_KEY" yaml:"jwt_refresh_secret_key" toml:"jwt_refresh_secret_key"`
+	// SessionSecret signs the session cookies and the state of pending OpenID Connect logins
+	SessionSecret Secret `env:"SESSION_SECRET" yaml:"session_secret" toml:"session_secret"`
+	// SessionMaxAge is how long a session lasts after it has last been saved
+	SessionMaxAge time.Duration `env:"SESSION_MAX_AGE" yaml:"session_max_age" toml:"session_max_age"`
+	// SessionCookieSecure only sends the session cookie over HTTPS, which is only turned off for plain HTTP in development
+	SessionCookieSecure bool `env:"SESSION_COOKIE_SECURE" yaml:"session_cookie_secure" toml:"session_cookie_secure"`
+	// LoginThrottleStore is memory or postgres, which shares the failed login counters between replicas
+	LoginThrottleStore string `env:"LOGIN_THROTTLE_STORE" yaml:"login_throttle_store" toml:"login_throttle_store"`
+}
+
+// OIDC is the configuration of a generic OpenID Connect provider.
+type OIDC struct {
+	// ProviderName is the name of the provider, as shown on the login page
+	ProviderName string `env:"OIDC_PROVIDER_NAME" yaml:"provider_name" toml:"provider_name"`
+	IssuerURL    string `env:"OIDC_ISSUER_URL" yaml:"issuer_url" toml:"issuer_url"`
+	ClientID     string `env:"OIDC_CLIENT_ID" yaml:"client_id" toml:"client_id"`
+	ClientSecret Secret `env:"OIDC_CLIENT_SECRET" yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string `env:"OIDC_REDIRECT_URL" yaml:"redirect_url" toml:"redirect_url"`
+	// Scopes are the requested scopes, always including openid
+	Scopes []string `env:"OIDC_SCOPES" yaml:"scopes" toml:"scopes"`
+}
+
+// Supabase is the configuration of the Supabase client of a remote database.
+type Supabase struct {
+	URL    string `env:"SUPABASE_URL" yaml:"url" toml:"url"`
+	Secret Secret `env:"SUPABASE_SECRET" yaml:"secret" toml:"secret"`
+}
+
+// SMTP is the configuration of the SMTP server sending the emails, which are only logged without a host.
+type SMTP struct {
+	// Host is the address of the SMTP server (format: <host>:<port>)
+	Host     string `env:"SMTP_HOST" yaml:"host" toml:"host"`
+	From     string `env:"SMTP_FROM" yaml:"from" toml:"from"`
+	User     string `env:"SMTP_USER" yaml:"user" toml:"user"`
+	Password Secret `env:"SMTP_PASSWORD" yaml:"password" toml:"password"`
+}
+
+// Encryption is the configuration of the field encryption at rest, which is disabled without a master key.
+type Encryption struct {
+	// MasterKey is the base64 encoded 32 byte master key wrapping the data keys
+	MasterKey Secret `env:"ENCRYPTION_MASTER_KEY" yaml:"master_key" toml:"master_key"`
+	// PreviousMasterKeys only unwrap the data keys while rotating the master key
+	PreviousMasterKeys []Secret `env:"ENCRYPTION_PREVIOUS_MASTER_KEYS" yaml:"previous_master_keys" toml:"previous_master_keys"`
+}
+
+// Default returns the configuration used for everything, which is not configured otherwise.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTP{ListenAddr: "127.0.0.1:3000"},
+		Log:  Log{Level: "INFO", Dir: "log", File: "wits.log", AccessFile: "access.log"},
+		Database: Database{
+			Type:             "local",
+			Driver:           "postgres",
+			Path:             "wits.db",
+			SSLMode:          "disable",
+			MaxOpenConns:     25,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			StatementTimeout: 30 * time.Second,
+			ConnectTimeout:   5 * time.Second,
+			ConnectAttempts:  5,
+		},
+		Auth: Auth{SessionMaxAge: 30 * 24 * time.Hour, SessionCookieSecure: true, LoginThrottleStore: "memory"},
+		OIDC: OIDC{ProviderName: "SSO", Scopes: []string{"openid", "email", "profile"}},
+	}
+}
+
+// Load loads the configuration from the defaults, the file of CONFIG_FILE, the .env file and the environment, each
+// overriding the former. Both files are optional, and empty values count as unset.
+func Load() (*Config, error) {
+	slog.Info("💬 ⚙️  (pkg/config/config.go) Load()")
+	cfg, err := load(DotEnvFile, os.LookupEnv)
+	if err != nil {
+		slog.Error("🚨 ⚙️  (pkg/config/config.go) ❓❓❓❓ ⚙️  Loading configuration failed with", "error", err)
+		return nil, err
+	}
+	slog.Info("✅ ⚙️  (pkg/config/config.go) Load() -> ⚙️  Configuration has been loaded with", "config", cfg)
+	return cfg, nil
+}
+
+// load loads the configuration with the .env file at the path and the environment of the lookup.
+func load(dotEnvPath string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	dotEnv, err := godotenv.Read(dotEnvPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		dotEnv = map[string]string{}
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dotEnvPath, err)
+	}
+	lookup := func(name string) (string, bool) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			return v, true
+		}
+		v := dotEnv[name]
+		return v, v != ""
+	}
+
+	cfg := Default()
+	if path, ok := lookup(FileEnv); ok {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := readEnv(reflect.ValueOf(cfg).Elem(), lookup); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile reads the YAML or TOML file at the path into the configuration. Unknown keys are rejected, so typos do not
+// go unnoticed.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", FileEnv, err)
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.NewDecoder(f).Decode(c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("%s must be a .yaml, .yml or .toml file, got %s", FileEnv, path)
+	}
+	return nil
+}
+
+// readEnv sets the fields of the struct with an env tag from the variables of the lookup, descending into the nested
+// structs.
+func readEnv(v reflect.Value, lookup func(string) (string, bool)) error {
+	for i := range v.NumField() {
+		field, structField := v.Field(i), v.Type().Field(i)
+		name, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if field.Kind() == reflect.Struct {
+				if err := readEnv(field, lookup); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// durationType is the type of the duration fields, which are parsed like 30s or 5m.
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setField parses the value into the field. Lists are separated by commas or spaces.
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("must be a non-negative duration like 30s, got %q", value)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", value)
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("must be a non-negative number, got %q", value)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		items := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+		list := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		field.Set(list)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wits.yaml": "http:\n  listen_addr: 0.0.0.0:8080\ndatabase:\n  host: db.file\n  name: wits\n  statement_timeout: 10s\nauth:\n  providers: [local, oidc]\n",
+		"wits.toml": "[http]\nlisten_addr = \"0.0.0.0:8080\"\n[database]\nhost = \"db.file\"\nname = \"wits\"\nstatement_timeout = \"10s\"\n[auth]\nproviders = [\"local\", \"oidc\"]\n",
+		"typo.yaml": "database:\n  hots: db.file\n",
+		"typo.toml": "[database]\nhots = \"db.file\"\n",
+		"wits.json": "{}",
+		".env":      "DB_HOST=db.dotenv # overrides the file\nDB_USER=wits\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		dotEnv   string
+		env      map[string]string
+		wantHost string
+		wantUser string
+		wantErr  bool
+	}{
+		{"YAML file should be read", "wits.yaml", "missing.env", nil, "db.file", "", false},
+		{"TOML file should be read", "wits.toml", "missing.env", nil, "db.file", "", false},
+		{".env file should override the file", "wits.yaml", ".env", nil, "db.dotenv", "wits", false},
+		{"Environment should override the .env file", "wits.toml", ".env", map[string]string{"DB_HOST": "db.env"}, "db.env", "wits", false},
+		{"Empty environment should count as unset", "wits.toml", ".env", map[string]string{"DB_HOST": ""}, "db.dotenv", "wits", false},
+		{"Unknown YAML key should fail", "typo.yaml", "missing.env", nil, "", "", true},
+		{"Unknown TOML key should fail", "typo.toml", "missing.env", nil, "", "", true},
+		{"Unknown file format should fail", "wits.json", "missing.env", nil, "", "", true},
+		{"Invalid number should fail", "wits.yaml", "missing.env", map[string]string{"DB_MAX_OPEN_CONNS": "many"}, "", "", true},
+		{"Invalid boolean should fail", "wits.yaml", "missing.env", map[string]string{"SESSION_COOKIE_SECURE": "maybe"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{FileEnv: filepath.Join(dir, tt.file)}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			cfg, err := load(filepath.Join(dir, tt.dotEnv), func(name string) (string, bool) {
+				v, ok := env[name]
+				return v, ok
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.Database.Host != tt.wantHost || cfg.Database.User != tt.wantUser {
+				t.Errorf("load() database = %s@%s, want %s@%s", cfg.Database.User, cfg.Database.Host, tt.wantUser, tt.wantHost)
+			}
+			if cfg.HTTP.ListenAddr != "0.0.0.0:8080" || cfg.Database.Name != "wits" || cfg.Database.StatementTimeout != 10*time.Second || cfg.Database.ConnectAttempts != 5 {
+				t.Errorf("load() = %+v, want the values of the file on top of the defaults", cfg)
+			}
+			if !slices.Equal(cfg.IdentityProviders(), []string{"local", "oidc"}) {
+				t.Errorf("IdentityProviders() = %v, want [local oidc]", cfg.IdentityProviders())
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	strong := Secret(strings.Repeat("s", minSecretLength))
+	valid := func() *Config {
+		cfg := Default()
+		cfg.Database.Host, cfg.Database.User, cfg.Database.Name = "127.0.0.1:5432", "postgres", "wits"
+		cfg.Auth.SessionSecret = strong
+		cfg.Auth.JWTSecretKey = strong + "a"
+		cfg.Auth.JWTRefreshSecretKey = strong + "r"
+		return cfg
+	}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{"Valid configuration should pass", func(*Config) {}, nil},
+		{"Missing and weak secrets should all be reported", func(c *Config) {
+			c.Auth.SessionSecret = ""
+			c.Auth.JWTSecretKey = "foo"
+			c.Auth.JWTRefreshSecretKey = ""
+		}, []string{"SESSION_SECRET is missing", "JWT_SECRET_KEY is weak", "JWT_REFRESH_SECRET_KEY is missing"}},
+		{"Equal token secrets should fail", func(c *Config) {
+			c.Auth.JWTRefreshSecretKey = c.Auth.JWTSecretKey
+		}, []string{"JWT_REFRESH_SECRET_KEY must differ"}},
+		{"Supabase should need its client instead of token secrets", func(c *Config) {
+			c.Database.Type = "remote"
+			c.Auth.JWTSecretKey, c.Auth.JWTRefreshSecretKey = "", ""
+		}, []string{"SUPABASE_URL is missing", "SUPABASE_SECRET is missing"}},
+		{"OpenID Connect should need its client", func(c *Config) {
+			c.Auth.Providers = []string{"local", "oidc"}
+		}, []string{"OIDC_ISSUER_URL is missing", "OIDC_CLIENT_ID is missing", "OIDC_CLIENT_SECRET is missing"}},
+		{"Database problems should all be reported", func(c *Config) {
+			c.Database.Host, c.Database.SSLMode, c.Database.MaxIdleConns = "", "on", 100
+		}, []string{"DB_HOST is missing", "DB_SSLMODE must be one of", "DB_MAX_IDLE_CONNS (100)"}},
+		{"SQLite should only need a path", func(c *Config) {
+			c.Database = Database{Type: "local", Driver: "sqlite", Path: "wits.db"}
+		}, nil},
+		{"Trusted proxies should be IP addresses or CIDR ranges", func(c *Config) {
+			c.HTTP.TrustedProxies = []string{"10.0.0.1", "fd00::/8", "proxy.local"}
+		}, []string{`HTTP_TRUSTED_PROXIES must contain IP addresses or CIDR ranges, got "proxy.local"`}},
+		{"Sessions should last", func(c *Config) {
+			c.Auth.SessionMaxAge = 0
+		}, []string{"SESSION_MAX_AGE must be positive"}},
+		{"Invalid master key should fail", func(c *Config) {
+			c.Encryption.MasterKey = "c2hvcnQ="
+		}, []string{"ENCRYPTION_MASTER_KEY has 5 bytes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "db-password"
+	cfg.Auth.SessionSecret = "session-secret"
+	cfg.Encryption.PreviousMasterKeys = []Secret{"previous-key"}
+
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, printed := range []string{fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", cfg), fmt.Sprintf("%#v", cfg), string(encoded)} {
+		for _, secret := range []string{"db-password", "session-secret", "previous-key"} {
+			if strings.Contains(printed, secret) {
+				t.Errorf("printed configuration %s contains the secret %q", printed, secret)
+			}
+		}
+		if !strings.Contains(printed, redacted) {
+			t.Errorf("printed configuration %s does not contain %s", printed, redacted)
+		}
+	}
+	if cfg.Database.Password.Value() != "db-password" {
+		t.Errorf("Value() = %s, want the secret", cfg.Database.Password.Value())
+	}
+}
@@ -0,0 +1,3 @@
+// Package config provides the typed configuration of Wits, loaded from the environment, a .env file and an optional
+// YAML or TOML file.
+package config // import "github.com/TheDonDope/wits-server/pkg/config"
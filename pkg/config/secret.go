@@ -0,0 +1,38 @@
+package config
+
+import "log/slog"
+
+// redacted replaces the value of a secret in logs and printed configurations.
+const redacted = "[REDACTED]"
+
+// Secret is a configuration value like a password or a key, which is redacted when it is logged, printed or
+// marshalled. Its value is only returned by Value.
+type Secret string
+
+// Value returns the value of the secret.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String returns the redacted secret, or an empty string if it is not set.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString returns the redacted secret for the %#v verb.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// LogValue returns the redacted secret for slog.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalText returns the redacted secret, e.g. for JSON.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// minSecretLength is the minimum length of the secrets signing the tokens and sessions, which is 32 bytes of random
+// data, e.g. from: openssl rand -base64 32
+const minSecretLength = 32
+
+var (
+	dbTypes        = []string{"local", "remote"}
+	dbDrivers      = []string{"postgres", "sqlite"}
+	sslModes       = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logLevels      = []string{"DEBUG", "INFO", "WARN", "ERROR", "OFF"}
+	throttleStores = []string{"memory", "postgres"}
+)
+
+// IdentityProviders returns the enabled identity providers of AUTH_PROVIDERS. Without it, the providers of the
+// DB_TYPE are enabled: local passwords (and OpenID Connect, if OIDC_ISSUER_URL is set) for a local database, Supabase
+// and Google for a remote database.
+func (c *Config) IdentityProviders() []string {
+	if len(c.Auth.Providers) > 0 {
+		providers := make([]string, len(c.Auth.Providers))
+		for i, p := range c.Auth.Providers {
+			providers[i] = strings.ToLower(p)
+		}
+		return providers
+	}
+	switch c.Database.Type {
+	case "local":
+		if c.OIDC.IssuerURL != "" {
+			return []string{"local", "oidc"}
+		}
+		return []string{"local"}
+	case "remote":
+		return []string{"supabase", "google"}
+	}
+	return nil
+}
+
+// Validate checks the configuration of the server and reports all problems at once, e.g. every missing or weak
+// secret.
+func (c *Config) Validate() error {
+	providers := c.IdentityProviders()
+	usesSupabase := slices.Contains(providers, "supabase") || slices.Contains(providers, "google")
+	errs := []error{
+		required("HTTP_LISTEN_ADDR", c.HTTP.ListenAddr),
+		c.HTTP.Validate(),
+		oneOf("LOG_LEVEL", c.Log.Level, logLevels),
+		required("LOG_DIR", c.Log.Dir),
+		c.Database.Validate(),
+		oneOf("LOGIN_THROTTLE_STORE", c.Auth.LoginThrottleStore, throttleStores),
+		strongSecret("SESSION_SECRET", c.Auth.SessionSecret),
+		positive("SESSION_MAX_AGE", c.Auth.SessionMaxAge),
+		c.Encryption.Validate(),
+	}
+	// Without Supabase, the sessions carry self-signed tokens
+	if !usesSupabase {
+		errs = append(errs,
+			strongSecret("JWT_SECRET_KEY", c.Auth.JWTSecretKey),
+			strongSecret("JWT_REFRESH_SECRET_KEY", c.Auth.JWTRefreshSecretKey))
+		if c.Auth.JWTSecretKey != "" && c.Auth.JWTSecretKey == c.Auth.JWTRefreshSecretKey {
+			errs = append(errs, errors.New("JWT_REFRESH_SECRET_KEY must differ from JWT_SECRET_KEY"))
+		}
+	} else {
+		errs = append(errs, required("SUPABASE_URL", c.Supabase.URL), required("SUPABASE_SECRET", c.Supabase.Secret.Value()))
+	}
+	if slices.Contains(providers, "oidc") {
+		errs = append(errs,
+			required("OIDC_ISSUER_URL", c.OIDC.IssuerURL),
+			required("OIDC_CLIENT_ID", c.OIDC.ClientID),
+			required("OIDC_CLIENT_SECRET", c.OIDC.ClientSecret.Value()))
+	}
+	if c.SMTP.Host != "" {
+		errs = append(errs, required("SMTP_FROM (with SMTP_HOST)", c.SMTP.From))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that the trusted proxies are IP addresses or CIDR ranges.
+func (h HTTP) Validate() error {
+	_, err := h.TrustedProxyRanges()
+	return err
+}
+
+// TrustedProxyRanges returns the ranges of the trusted proxies, where a single IP address is a range of its own.
+func (h HTTP) TrustedProxyRanges() ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0, len(h.TrustedProxies))
+	for _, proxy := range h.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP_TRUSTED_PROXIES must contain IP addresses or CIDR ranges, got %q", proxy)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+// Validate checks the database type and driver, and for Postgres the connection, its TLS mode and the pool limits.
+func (d Database) Validate() error {
+	errs := []error{
+		oneOf("DB_TYPE", d.Type, dbTypes),
+		oneOf("DB_DRIVER", d.Driver, dbDrivers),
+	}
+	if d.Driver == "sqlite" {
+		if d.Type != "local" {
+			errs = append(errs, errors.New("DB_DRIVER=sqlite needs DB_TYPE=local, Supabase manages a remote database"))
+		}
+		return errors.Join(append(errs, required("DB_PATH", d.Path))...)
+	}
+	errs = append(errs,
+		required("DB_HOST", d.Host),
+		required("DB_USER", d.User),
+		required("DB_NAME", d.Name),
+		oneOf("DB_SSLMODE", d.SSLMode, sslModes))
+	if d.SSLRootCert != "" {
+		if _, err := os.Stat(d.SSLRootCert); err != nil {
+			errs = append(errs, fmt.Errorf("DB_SSLROOTCERT is not readable: %w", err))
+		}
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", d.MaxIdleConns, d.MaxOpenConns))
+	}
+	if d.ConnectAttempts < 1 {
+		errs = append(errs, fmt.Errorf("DB_CONNECT_ATTEMPTS must be at least 1, got %d", d.ConnectAttempts))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that the master keys are base64 encoded 32 byte keys.
+func (e Encryption) Validate() error {
+	var errs []error
+	if e.MasterKey != "" {
+		errs = append(errs, masterKey("ENCRYPTION_MASTER_KEY", e.MasterKey))
+	}
+	for _, key := range e.PreviousMasterKeys {
+		errs = append(errs, masterKey("ENCRYPTION_PREVIOUS_MASTER_KEYS", key))
+	}
+	return errors.Join(errs...)
+}
+
+// required returns an error if the value of the variable is missing.
+func required(name string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is missing", name)
+	}
+	return nil
+}
+
+// oneOf returns an error if the value of the variable is not one of the allowed values.
+func oneOf(name string, value string, allowed []string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value)
+	}
+	return nil
+}
+
+// positive returns an error if the duration of the variable is zero.
+func positive(name string, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, d)
+	}
+	return nil
+}
+
+// strongSecret returns an error if the secret is missing or too short to withstand guessing.
+func strongSecret(name string, secret Secret) error {
+	switch {
+	case secret == "":
+		return fmt.Errorf("%s is missing, generate one with: openssl rand -base64 32", name)
+	case len(secret) < minSecretLength:
+		return fmt.Errorf("%s is weak with %d characters, want at least %d, generate one with: openssl rand -base64 32", name, len(secret), minSecretLength)
+	}
+	return nil
+}
+
+// masterKey returns an error if the secret is no base64 encoded 32 byte key.
+func masterKey(name string, secret Secret) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret.Value()))
+	if err != nil {
+		return fmt.Errorf("%s is not base64 encoded", name)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("%s has %d bytes, want 32", name, len(key))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/admin"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler provides handlers for the admin area of the application.
+type AdminHandler struct {
+	deps
+}
+
+// NewAdminHandler creates a new AdminHandler using the repositories.
+func NewAdminHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *AdminHandler {
+	return &AdminHandler{deps: newDeps(repos, cfg, svc)}
+}
+
+// HandleGetAdmin responds to GET on the /admin route by rendering the users, the registration stats and the
+// statistics of the database connection pool.
+func (h AdminHandler) HandleGetAdmin(c echo.Context) error {
+	slog.Info("💬 👑 (pkg/handler/admin.go) HandleGetAdmin()")
+	users, err := h.repos.Users.GetAuthenticatedUsers(c.Request().Context())
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 📂 Listing users failed with", "error", err)
+		return err
+	}
+	stats, err := h.repos.Users.GetRegistrationStats(c.Request().Context())
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 📈 Counting registrations failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 👑 (pkg/handler/admin.go) HandleGetAdmin() -> 👑 Rendering admin area with", "users", len(users))
+	return render(c, admin.Index(users, stats, h.repos.Health.Stats()))
+}
+
+// HandleGetAudit responds to GET on the /admin/audit route by rendering the audit events matching the filter from
+// the query parameters.
+func (h AdminHandler) HandleGetAudit(c echo.Context) error {
+	slog.Info("💬 👑 (pkg/handler/admin.go) HandleGetAudit()")
+	filter := types.AuditFilter{
+		Action:    strings.TrimSpace(c.QueryParam("action")),
+		Email:     strings.TrimSpace(c.QueryParam("email")),
+		IPAddress: strings.TrimSpace(c.QueryParam("ip")),
+	}
+	if from, err := time.Parse(time.DateOnly, c.QueryParam("from")); err == nil {
+		filter.From = from
+	}
+	if to, err := time.Parse(time.DateOnly, c.QueryParam("to")); err == nil {
+		// The filter includes the whole last day
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	events, err := h.repos.AuditEvents.SearchAuditEvents(c.Request().Context(), filter)
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 🗒️  Searching audit log failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 👑 (pkg/handler/admin.go) HandleGetAudit() -> 🗒️  Rendering audit log with", "events", len(events))
+	return render(c, admin.Audit(admin.AuditParams{
+		Action: filter.Action,
+		Email:  filter.Email,
+		IP:     filter.IPAddress,
+		From:   c.QueryParam("from"),
+		To:     c.QueryParam("to"),
+	}, events))
+}
+
+// HandlePostUserRole responds to POST on the /admin/users/:id/role route by changing the role of the user.
+func (h AdminHandler) HandlePostUserRole(c echo.Context) error {
+	slog.Info("💬 👑 (pkg/handler/admin.go) HandlePostUserRole()")
+	role, err := types.ParseRole(c.FormValue("role"))
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 🎭 Parsing role failed with", "error", err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return h.updateAccount(c, types.AuditActionRoleChange, string(role), func(account *types.Account) {
+		account.Role = role
+	})
+}
+
+// HandlePostUserDisable responds to POST on the /admin/users/:id/disable route by disabling the account of the user
+// and logging them out everywhere.
+func (h AdminHandler) HandlePostUserDisable(c echo.Context) error {
+	slog.Info("💬 👑 (pkg/handler/admin.go) HandlePostUserDisable()")
+	return h.updateAccount(c, types.AuditActionAccountDisable, "", func(account *types.Account) {
+		account.DisabledAt = time.Now()
+	})
+}
+
+// HandlePostUserEnable responds to POST on the /admin/users/:id/enable route by enabling the account of the user
+// again.
+func (h AdminHandler) HandlePostUserEnable(c echo.Context) error {
+	slog.Info("💬 👑 (pkg/handler/admin.go) HandlePostUserEnable()")
+	return h.updateAccount(c, types.AuditActionAccountEnable, "", func(account *types.Account) {
+		account.DisabledAt = time.Time{}
+	})
+}
+
+// updateAccount applies the change to the account of the user from the :id parameter, records it in the audit log
+// and renders the row of the user. Admins cannot change their own account, so they cannot lock themselves out.
+func (h AdminHandler) updateAccount(c echo.Context, action string, details string, change func(*types.Account)) error {
+	actor := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 🎭 Parsing user id failed with", "error", err)
+		return c.String(http.StatusBadRequest, "invalid user id")
+	}
+	if id == actor.ID {
+		slog.Info("✅ 👑 (pkg/handler/admin.go) updateAccount() -> 🚫 Admins cannot change their own account")
+		return c.String(http.StatusForbidden, "you cannot change your own account")
+	}
+	user, err := h.repos.Users.GetAuthenticatedUserByID(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.String(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 📂 Finding user failed with", "error", err)
+		return err
+	}
+
+	account, err := h.repos.Accounts.GetAccountByUserID(c.Request().Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		account = types.Account{ID: uuid.New(), UserID: user.ID, Role: types.RolePatient}
+	} else if err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 📂 Finding account failed with", "error", err)
+		return err
+	}
+	change(&account)
+	if err := h.repos.Accounts.SaveAccount(c.Request().Context(), &account); err != nil {
+		slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 📂 Saving account failed with", "error", err)
+		return err
+	}
+	if action == types.AuditActionAccountDisable {
+		if err := h.repos.Sessions.DeleteSessionsByUserID(c.Request().Context(), user.ID); err != nil {
+			slog.Error("🚨 👑 (pkg/handler/admin.go) ❓❓❓❓ 🍪 Revoking sessions of disabled user failed with", "error", err)
+		}
+	}
+
+	event := types.AuditEvent{
+		Action:    action,
+		AccountID: user.ID,
+		Details:   user.Email,
+	}
+	if details != "" {
+		event.Details += " " + details
+	}
+	h.audit.Record(c, event)
+
+	user.Account = account
+	slog.Info("✅ 👑 (pkg/handler/admin.go) updateAccount() -> 🎭 Account has been changed with", "action", action, "email", user.Email)
+	return render(c, admin.UserRow(user))
+}
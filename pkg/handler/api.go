@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// APIPrefix is the path prefix of the JSON API, which authenticates every request with a bearer token instead of
+	// the session.
+	APIPrefix = "/api"
+	// problemContentType is the media type of the error responses of the API, see RFC 9457.
+	problemContentType = "application/problem+json"
+)
+
+// problem is the body of an error response of the API, see RFC 9457. Errors names the invalid fields of a request.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// validationError maps the invalid fields of a request to their problem. It is answered with 422 Unprocessable
+// Entity.
+type validationError map[string]string
+
+func (e validationError) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, message := range e {
+		fields = append(fields, field+": "+message)
+	}
+	slices.Sort(fields)
+	return "invalid request: " + strings.Join(fields, ", ")
+}
+
+// withoutEmpty returns the validation error without the fields which have no problem.
+func (e validationError) withoutEmpty() validationError {
+	for field, message := range e {
+		if message == "" {
+			delete(e, field)
+		}
+	}
+	return e
+}
+
+// listResponse is the body of a page of a listing of the API.
+type listResponse[R any] struct {
+	Data   []R `json:"data"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// listParams are the sort fields and filters, which a listing of the API allows.
+type listParams struct {
+	sort        []string
+	defaultSort string
+	filters     map[string]listFilter
+}
+
+// listFilter is a query parameter filtering a listing of the API. It allows one of its values, a time in RFC 3339,
+// the id of a record, or any value without either.
+type listFilter struct {
+	description string
+	values      []string
+	time        bool
+	id          bool
+}
+
+// WithProblems is a middleware for the API, which answers the errors of the handlers with a problem. Validation
+// errors answer with the invalid fields, unknown rows with 404 Not Found, changes of a stale version with 412
+// Precondition Failed, and unexpected errors with 500 Internal Server Error without their details.
+func WithProblems() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			if err == nil || c.Response().Committed {
+				return err
+			}
+			p := problem{Type: "about:blank", Instance: c.Request().URL.Path}
+			var invalid validationError
+			var httpErr *echo.HTTPError
+			switch {
+			case errors.As(err, &invalid):
+				p.Status, p.Detail, p.Errors = http.StatusUnprocessableEntity, "The request has invalid fields", invalid
+			case errors.As(err, &httpErr):
+				p.Status = httpErr.Code
+				if message := fmt.Sprint(httpErr.Message); message != http.StatusText(httpErr.Code) {
+					p.Detail = message
+				}
+			case errors.Is(err, sql.ErrNoRows):
+				p.Status = http.StatusNotFound
+			case errors.Is(err, storage.ErrVersionConflict):
+				p.Status, p.Detail = http.StatusPreconditionFailed, "the resource has been changed, fetch it again"
+			default:
+				slog.Error("🚨 🔌 (pkg/handler/api.go) ❓❓❓❓ 🛜 API request failed with", "error", err, "path", c.Request().URL.Path)
+				p.Status = http.StatusInternalServerError
+			}
+			p.Title = http.StatusText(p.Status)
+			body, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			return c.Blob(p.Status, problemContentType, body)
+		}
+	}
+}
+
+// WithBearer is a middleware for the API, which authenticates the request with the bearer token of the Authorization
+// header. The token is either a personal API token or a JWT token signed by the server, see HandlePostToken.
+func (m *Middleware) WithBearer() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			slog.Info("💬 🔌 (pkg/handler/api.go) WithBearer() -> next()", "path", c.Request().URL.Path)
+			bearer, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || bearer == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+			}
+			var user types.AuthenticatedUser
+			var err error
+			if strings.HasPrefix(bearer, types.APITokenPrefix) {
+				var token types.APIToken
+				user, token, err = m.authenticateAPIToken(c.Request().Context(), bearer)
+				if err == nil {
+					c.Set(types.APITokenContextKey, token)
+				}
+			} else {
+				user, err = m.authenticateJWT(c.Request().Context(), bearer)
+			}
+			if err != nil {
+				slog.Error("🚨 🔌 (pkg/handler/api.go) ❓❓❓❓ 🔑 Authenticating with bearer token failed with", "error", err)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired bearer token")
+			}
+
+			c.Set(types.UserContextKey, user)
+			c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), types.UserContextKey, user)))
+			slog.Info("✅ 🔌 (pkg/handler/api.go) WithBearer() -> next() -> 💃 User found for bearer token with", "id", user.ID)
+			return next(c)
+		}
+	}
+}
+
+// authenticateJWT returns the user of a JWT token signed with the secret key of the access tokens. Supabase signs
+// its own tokens, so without the secret key no JWT token is accepted. Tokens issued before the user has changed their
+// password are rejected. As the issue time is only stored in seconds, this includes tokens issued within the same
+// second after the change.
+func (d deps) authenticateJWT(ctx context.Context, bearer string) (types.AuthenticatedUser, error) {
+	if d.cfg.Auth.JWTSecretKey == "" {
+		return types.AuthenticatedUser{}, errors.New("JWT tokens are only accepted with self-signed tokens")
+	}
+	claims, err := auth.ParseToken(bearer, []byte(d.cfg.Auth.JWTSecretKey.Value()))
+	if err != nil {
+		return types.AuthenticatedUser{}, err
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return types.AuthenticatedUser{}, fmt.Errorf("JWT token has no user: %w", err)
+	}
+	user, err := d.activeUser(ctx, id)
+	if err != nil {
+		return types.AuthenticatedUser{}, err
+	}
+	if revokedAt := user.Account.TokensRevokedAt; !revokedAt.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.After(revokedAt.Truncate(time.Second))) {
+		return types.AuthenticatedUser{}, errors.New("JWT token has been revoked by a change of the password")
+	}
+	return user, nil
+}
+
+// APIAccess is a middleware for the API, which checks that the role of the user grants the permission and, for
+// requests with a personal API token, that the token has the scope of the resource. Reading requires the read scope
+// and changing requires the write scope.
+func APIAccess(resource string, permission types.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := getAuthenticatedUser(c)
+			if !user.Can(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing permission "+string(permission))
+			}
+			if token, ok := c.Get(types.APITokenContextKey).(types.APIToken); ok {
+				access := types.ScopeWrite
+				switch c.Request().Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+					access = types.ScopeRead
+				}
+				if scope := types.Scope(resource, access); !token.HasScope(scope) {
+					return echo.NewHTTPError(http.StatusForbidden, "API token is missing scope "+scope)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// decodeJSON decodes the JSON body of the request into v, rejecting unknown fields.
+func decodeJSON(c echo.Context, v any) error {
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	return nil
+}
+
+// parseListOptions returns the limit, offset, sort and filters of the query of a listing. The sort is a comma
+// separated list of fields, each descending with a leading minus, e.g. sort=-created_at.
+func parseListOptions(c echo.Context, params listParams) (types.ListOptions, error) {
+	opts := types.ListOptions{Limit: types.DefaultListLimit, Filters: map[string]string{}}
+	invalid := validationError{}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > types.MaxListLimit {
+			invalid["limit"] = fmt.Sprintf("must be a number from 1 to %d", types.MaxListLimit)
+		}
+		opts.Limit = n
+	}
+	if offset := c.QueryParam("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			invalid["offset"] = "must be a number from 0"
+		}
+		opts.Offset = n
+	}
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = params.defaultSort
+	}
+	for _, field := range strings.Split(sort, ",") {
+		name, desc := strings.CutPrefix(strings.TrimSpace(field), "-")
+		if !slices.Contains(params.sort, name) {
+			invalid["sort"] = "must be one of " + strings.Join(params.sort, ", ") + ", optionally with a leading minus"
+			continue
+		}
+		opts.Sort = append(opts.Sort, types.SortField{Field: name, Desc: desc})
+	}
+	for name, filter := range params.filters {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
+		}
+		if err := filter.check(value); err != nil {
+			invalid[name] = err.Error()
+		}
+		opts.Filters[name] = value
+	}
+	if len(invalid) > 0 {
+		return opts, invalid
+	}
+	return opts, nil
+}
+
+// check returns an error, if the filter does not allow the value.
+func (f listFilter) check(value string) error {
+	if len(f.values) > 0 && !slices.Contains(f.values, value) {
+		return errors.New("must be one of " + strings.Join(f.values, ", "))
+	}
+	if _, err := time.Parse(time.RFC3339, value); f.time && err != nil {
+		return errors.New("must be a time in RFC 3339, e.g. 2026-01-31T00:00:00Z")
+	}
+	if _, err := uuid.Parse(value); f.id && err != nil {
+		return errors.New("must be a UUID")
+	}
+	return nil
+}
+
+// writePage responds with a page of a listing, converting its items to their representation. The Link header points
+// to the previous and the next page.
+func writePage[T any, R any](c echo.Context, page types.Page[T], represent func(T) R) error {
+	data := make([]R, len(page.Items))
+	for i, item := range page.Items {
+		data[i] = represent(item)
+	}
+	var links []string
+	if page.Offset > 0 {
+		links = append(links, pageLink(c.Request().URL, page.Limit, max(0, page.Offset-page.Limit), "prev"))
+	}
+	if page.Offset+page.Limit < page.Total {
+		links = append(links, pageLink(c.Request().URL, page.Limit, page.Offset+page.Limit, "next"))
+	}
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
+	}
+	return c.JSON(http.StatusOK, listResponse[R]{Data: data, Total: page.Total, Limit: page.Limit, Offset: page.Offset})
+}
+
+// pageLink returns the link to the page of a listing at the offset, keeping the sort and filters of the query.
+func pageLink(u *url.URL, limit int, offset int, rel string) string {
+	query := u.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, query.Encode(), rel)
+}
+
+// etag returns the strong entity tag of the version of a resource, hashed from the parts identifying the version.
+func etag(parts ...any) string {
+	h := sha256.New()
+	for _, part := range parts {
+		if t, ok := part.(time.Time); ok {
+			part = t.UTC().Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(h, "%v\x00", part)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// writeWithETag responds with the representation of a resource and its entity tag, or with 304 Not Modified, if the
+// client has the current version already.
+func writeWithETag(c echo.Context, status int, tag string, representation any) error {
+	c.Response().Header().Set("ETag", tag)
+	if status == http.StatusOK && matchesETag(c.Request().Header.Get("If-None-Match"), tag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSON(status, representation)
+}
+
+// checkIfMatch guards changes of a resource against lost updates: The request has to send the entity tag of the
+// version it read in the If-Match header, which has to be the current one.
+func checkIfMatch(c echo.Context, current string) error {
+	ifMatch := c.Request().Header.Get("If-Match")
+	if ifMatch == "" {
+		return echo.NewHTTPError(http.StatusPreconditionRequired, "send the ETag of the resource in the If-Match header")
+	}
+	if !matchesETag(ifMatch, current) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "the resource has been changed, fetch it again")
+	}
+	return nil
+}
+
+// matchesETag reports whether the list of entity tags of an If-Match or If-None-Match header contains the tag.
+func matchesETag(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// optionalTime returns the time, or nil for the zero time, so it is left out of the representation.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// optionalID returns the id as a string, or an empty string for the nil id, so it is left out of the representation.
+func optionalID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	apiview "github.com/TheDonDope/wits-server/pkg/view/api"
+	"github.com/labstack/echo/v4"
+)
+
+// apiSpec describes the version 1 of the API for its OpenAPI document. Every operation may answer with a problem.
+var apiSpec = openapi.Spec{
+	Info: openapi.Info{
+		Title:       "Wits API",
+		Version:     "1",
+		Description: "The JSON API of Wits, e.g. for mobile apps and scripts. Errors are answered with problems of RFC 9457.",
+	},
+	ServerURL: APIPrefix + "/v1",
+	Tags: []openapi.Tag{
+		{Name: "auth", Description: "Access tokens"},
+		{Name: "account", Description: "The account of the user"},
+	},
+	Security: openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "An access token of POST /auth/token, or a personal API token starting with wits_",
+	},
+	Default:    openapi.Body{Description: "Error", ContentType: problemContentType, Type: problem{}},
+	TrimPrefix: "api",
+}
+
+var (
+	// ifNoneMatch is the header of the conditional reads of a resource.
+	ifNoneMatch = openapi.Parameter{Name: "If-None-Match", In: "header", Description: "The ETag of the version the client has, answered with 304 Not Modified while it is current", Schema: &openapi.Schema{Type: "string"}}
+	// ifMatch is the header of the changes of a resource, guarding against lost updates.
+	ifMatch = openapi.Parameter{Name: "If-Match", In: "header", Required: true, Description: "The ETag of the version the change is based on", Schema: &openapi.Schema{Type: "string"}}
+	// notModified is the response to a conditional read of the current version.
+	notModified = openapi.Body{Description: "The client has the current version", Headers: map[string]string{"ETag": "The version of the resource"}}
+)
+
+// apiRoutes describes the routes of the access tokens and the account of the version 1 of the API, see
+// reportShareRoutes, auditEventRoutes, productRoutes, inventoryRoutes, consumptionRoutes and symptomRatingRoutes for
+// the others. The contract test of the server fails, if they differ from the routes registered with Echo, or if a
+// response does not validate against the document.
+var apiRoutes = []openapi.Route{
+	{
+		Method: http.MethodPost, Path: "/auth/token", OperationID: "createToken", Tag: "auth", Public: true,
+		Summary:     "Exchange a login and password for an access token",
+		Description: "Only available with the local login. Failed attempts are throttled like the logins of the web app.",
+		Request:     tokenRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:              {Type: tokenResponse{}, Headers: map[string]string{"Cache-Control": "no-store"}},
+			http.StatusTooManyRequests: {Description: "Too many failed attempts", ContentType: problemContentType, Type: problem{}, Headers: map[string]string{"Retry-After": "The seconds until the next attempt"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/account", OperationID: "getAccount", Tag: "account",
+		Summary:    "Get the account of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiAccount{}, Headers: map[string]string{"ETag": "The version of the account"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+}
+
+// APIDocument returns the OpenAPI document of the version 1 of the API, which is generated once from the routes of
+// the account, the share links, the audit log and the tracked records.
+var APIDocument = sync.OnceValue(func() *openapi.Document {
+	spec := apiSpec
+	spec.Tags = append(slices.Clone(apiSpec.Tags), reportShareTag, auditEventTag, productTag, inventoryTag, consumptionTag, symptomRatingTag)
+	return spec.Generate(slices.Concat(apiRoutes, reportShareRoutes, auditEventRoutes, productRoutes, inventoryRoutes, consumptionRoutes, symptomRatingRoutes))
+})
+
+// parameters returns the query parameters of a listing, which page, sort and filter it.
+func (p listParams) parameters() []openapi.Parameter {
+	minLimit, maxLimit, minOffset := 1, types.MaxListLimit, 0
+	parameters := []openapi.Parameter{
+		{Name: "limit", In: "query", Description: "The number of items of the page", Schema: &openapi.Schema{Type: "integer", Minimum: &minLimit, Maximum: &maxLimit}},
+		{Name: "offset", In: "query", Description: "The number of items before the page", Schema: &openapi.Schema{Type: "integer", Minimum: &minOffset}},
+		{
+			Name: "sort", In: "query",
+			Description: "Comma separated fields of " + strings.Join(p.sort, ", ") + ", each descending with a leading minus. Defaults to " + p.defaultSort + ".",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+	}
+	names := make([]string, 0, len(p.filters))
+	for name := range p.filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		filter := p.filters[name]
+		schema := &openapi.Schema{Type: "string", Enum: filter.values}
+		switch {
+		case filter.time:
+			schema.Format = "date-time"
+		case filter.id:
+			schema.Format = "uuid"
+		}
+		parameters = append(parameters, openapi.Parameter{Name: name, In: "query", Description: filter.description, Schema: schema})
+	}
+	return parameters
+}
+
+// HandleGetOpenAPI responds to GET on the /api/openapi.json route with the OpenAPI document of the API.
+func (h APIHandler) HandleGetOpenAPI(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_openapi.go) HandleGetOpenAPI()")
+	return c.JSON(http.StatusOK, APIDocument())
+}
+
+// HandleGetAPIDocs responds to GET on the /api/docs route with the documentation of the API, rendered from its
+// OpenAPI document.
+func (h APIHandler) HandleGetAPIDocs(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_openapi.go) HandleGetAPIDocs()")
+	return render(c, apiview.Docs(APIDocument()))
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeUserRepository keeps the users and their accounts in memory.
+type fakeUserRepository struct {
+	storage.UserRepository
+	storage.AccountRepository
+	users map[uuid.UUID]types.AuthenticatedUser
+}
+
+func (f *fakeUserRepository) GetAuthenticatedUserByID(ctx context.Context, id uuid.UUID) (types.AuthenticatedUser, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return types.AuthenticatedUser{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeUserRepository) GetAccountByUserID(ctx context.Context, userID uuid.UUID) (types.Account, error) {
+	u, ok := f.users[userID]
+	if !ok {
+		return types.Account{}, sql.ErrNoRows
+	}
+	return u.Account, nil
+}
+
+func (f *fakeReportShareRepository) GetReportShareByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.ReportShare, error) {
+	for _, s := range f.shares {
+		if s.ID == id && s.OwnerID == ownerID {
+			return s, nil
+		}
+	}
+	return types.ReportShare{}, sql.ErrNoRows
+}
+
+func (f *fakeReportShareRepository) RevokeReportShareVersion(ctx context.Context, share types.ReportShare) error {
+	for hash, s := range f.shares {
+		if s.ID == share.ID && s.OwnerID == share.OwnerID {
+			if f.revokedConcurrently {
+				s.RevokedAt = time.Now()
+				f.shares[hash] = s
+			}
+			if s.Revoked() {
+				return storage.ErrVersionConflict
+			}
+			s.RevokedAt = time.Now()
+			f.shares[hash] = s
+		}
+	}
+	return nil
+}
+
+func TestWithProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+		wantErrors int
+	}{
+		{"Validation error should name the fields", validationError{"name": "is missing", "to": "is invalid"}, http.StatusUnprocessableEntity, "The request has invalid fields", 2},
+		{"HTTP error should keep its status and message", echo.NewHTTPError(http.StatusPreconditionFailed, "stale"), http.StatusPreconditionFailed, "stale", 0},
+		{"Unknown row should not be found", sql.ErrNoRows, http.StatusNotFound, "", 0},
+		{"Unexpected error should not leak", errors.New("connection refused"), http.StatusInternalServerError, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/report-shares", nil), rec)
+			next := func(c echo.Context) error { return tt.err }
+			if err := WithProblems()(next)(c); err != nil {
+				t.Fatalf("WithProblems() error = %v", err)
+			}
+			var p problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("WithProblems() body = %s, error = %v", rec.Body, err)
+			}
+			if rec.Code != tt.wantStatus || p.Status != tt.wantStatus || p.Detail != tt.wantDetail || len(p.Errors) != tt.wantErrors {
+				t.Errorf("WithProblems() = %v %+v, want %v with detail %q and %d errors", rec.Code, p, tt.wantStatus, tt.wantDetail, tt.wantErrors)
+			}
+			if got := rec.Header().Get(echo.HeaderContentType); got != problemContentType {
+				t.Errorf("WithProblems() Content-Type = %v, want %v", got, problemContentType)
+			}
+		})
+	}
+}
+
+func TestWithBearer(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecretKey = config.Secret(strings.Repeat("a", 32))
+	active := types.AuthenticatedUser{ID: uuid.New(), Email: "active@wits.example"}
+	disabled := types.AuthenticatedUser{ID: uuid.New(), Email: "disabled@wits.example", Account: types.Account{DisabledAt: time.Now()}}
+	revoked := types.AuthenticatedUser{ID: uuid.New(), Email: "revoked@wits.example", Account: types.Account{TokensRevokedAt: time.Now()}}
+	users := &fakeUserRepository{users: map[uuid.UUID]types.AuthenticatedUser{active.ID: active, disabled.ID: disabled, revoked.ID: revoked}}
+	m := NewMiddleware(&storage.Repositories{Users: users, Accounts: users}, cfg, Services{})
+
+	sign := func(user types.AuthenticatedUser, secret string) string {
+		token, err := auth.SignToken(user, []byte(secret))
+		if err != nil {
+			t.Fatalf("SignToken() error = %v", err)
+		}
+		return token
+	}
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"Token of an active user should pass", "Bearer " + sign(active, cfg.Auth.JWTSecretKey.Value()), http.StatusOK},
+		{"Missing token should fail", "", http.StatusUnauthorized},
+		{"Token with another secret should fail", "Bearer " + sign(active, strings.Repeat("b", 32)), http.StatusUnauthorized},
+		{"Token of a disabled user should fail", "Bearer " + sign(disabled, cfg.Auth.JWTSecretKey.Value()), http.StatusUnauthorized},
+		{"Token issued before the password was changed should fail", "Bearer " + sign(revoked, cfg.Auth.JWTSecretKey.Value()), http.StatusUnauthorized},
+		{"Token of an unknown user should fail", "Bearer " + sign(types.AuthenticatedUser{ID: uuid.New()}, cfg.Auth.JWTSecretKey.Value()), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/account", nil)
+			req.Header.Set(echo.HeaderAuthorization, tt.header)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			next := func(c echo.Context) error {
+				if user := getAuthenticatedUser(c); user.ID != active.ID || !user.LoggedIn {
+					t.Errorf("WithBearer() user = %+v, want the logged in %v", user, active.ID)
+				}
+				return c.NoContent(http.StatusOK)
+			}
+			if err := WithProblems()(m.WithBearer()(next))(c); err != nil {
+				t.Fatalf("WithBearer() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("WithBearer() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParseListOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantOptions types.ListOptions
+		wantInvalid []string
+	}{
+		{"Defaults should sort newest first", "", types.ListOptions{Limit: types.DefaultListLimit, Sort: []types.SortField{{Field: "created_at", Desc: true}}, Filters: map[string]string{}}, nil},
+		{"Query should page, sort and filter", "limit=5&offset=10&sort=from,-expires_at&status=revoked", types.ListOptions{Limit: 5, Offset: 10, Sort: []types.SortField{{Field: "from"}, {Field: "expires_at", Desc: true}}, Filters: map[string]string{"status": "revoked"}}, nil},
+		{"Invalid query should name every problem", "limit=1000&offset=-1&sort=name&status=deleted", types.ListOptions{}, []string{"limit", "offset", "sort", "status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/report-shares?"+tt.query, nil), httptest.NewRecorder())
+			opts, err := parseListOptions(c, reportShareListParams)
+			if len(tt.wantInvalid) > 0 {
+				var invalid validationError
+				if !errors.As(err, &invalid) || len(invalid) != len(tt.wantInvalid) {
+					t.Fatalf("parseListOptions() error = %v, want problems with %v", err, tt.wantInvalid)
+				}
+				for _, field := range tt.wantInvalid {
+					if invalid[field] == "" {
+						t.Errorf("parseListOptions() error = %v, want a problem with %s", err, field)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListOptions() error = %v", err)
+			}
+			got, _ := json.Marshal(opts)
+			want, _ := json.Marshal(tt.wantOptions)
+			if string(got) != string(want) {
+				t.Errorf("parseListOptions() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlePatchReportShare(t *testing.T) {
+	owner := types.AuthenticatedUser{ID: uuid.New(), LoggedIn: true}
+	share := types.ReportShare{ID: uuid.New(), OwnerID: owner.ID, Name: "Dr. Smith", ExpiresAt: time.Now().Add(time.Hour)}
+	current := reportShareETag(share)
+
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{"Missing If-Match should be required", "", http.StatusPreconditionRequired, false},
+		{"Stale If-Match should fail", etag("stale"), http.StatusPreconditionFailed, false},
+		{"Current If-Match should revoke the share", current, http.StatusOK, true},
+		{"Concurrent change should fail", current, http.StatusPreconditionFailed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := &fakeReportShareRepository{shares: map[string]types.ReportShare{"share": share}, revokedConcurrently: tt.wantStatus == http.StatusPreconditionFailed}
+			events := &fakeAuditRepository{}
+			h := NewAPIHandler(&storage.Repositories{ReportShares: shares, AuditEvents: events}, config.Default(), Services{})
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/report-shares/"+share.ID.String(), strings.NewReader(`{"revoked": true}`))
+			req.Header.Set("If-Match", tt.ifMatch)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(share.ID.String())
+			c.Set(types.UserContextKey, owner)
+			if err := WithProblems()(h.HandlePatchReportShare)(c); err != nil {
+				t.Fatalf("HandlePatchReportShare() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandlePatchReportShare() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if revoked := shares.shares["share"].Revoked() && !shares.revokedConcurrently; revoked != tt.wantRevoked || (len(events.events) == 1) != tt.wantRevoked {
+				t.Errorf("HandlePatchReportShare() revoked = %v with %d audit events, want %v", revoked, len(events.events), tt.wantRevoked)
+			}
+			if tt.wantRevoked && rec.Header().Get("ETag") == current {
+				t.Errorf("HandlePatchReportShare() ETag = %v, want a new version", rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
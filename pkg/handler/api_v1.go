@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// APIHandler provides the handlers of the version 1 of the JSON API at /api/v1, e.g. for mobile apps and scripts.
+// The requests act on the data of the user of the bearer token.
+type APIHandler struct {
+	deps
+}
+
+// NewAPIHandler creates a new APIHandler using the repositories and the configuration.
+func NewAPIHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *APIHandler {
+	return &APIHandler{deps: newDeps(repos, cfg, svc)}
+}
+
+// tokenRequest is the body of a request for an access token.
+type tokenRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// tokenResponse is the body of the response with an access token.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// apiAccount is the representation of the account of the user.
+type apiAccount struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HandlePostToken responds to POST on the /api/v1/auth/token route by exchanging the login name and password of a
+// user for an access token, which authenticates the requests to the API until it expires. Failed attempts are
+// throttled like the logins of the web app.
+func (h APIHandler) HandlePostToken(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1.go) HandlePostToken()")
+	var req tokenRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	user, wait, err := h.checkLocalLogin(c, strings.TrimSpace(req.Login), req.Password)
+	switch {
+	case errors.Is(err, errLoginThrottled):
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		return echo.NewHTTPError(http.StatusTooManyRequests, lockedOutMessage(wait))
+	case errors.Is(err, errAccountDisabled):
+		return echo.NewHTTPError(http.StatusForbidden, "account has been disabled")
+	case errors.Is(err, errInvalidCredentials):
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+	}
+
+	accessToken, err := auth.SignToken(user, []byte(h.cfg.Auth.JWTSecretKey.Value()))
+	if err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1.go) ❓❓❓❓ 🔒 Signing access token failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionLogin, ActorID: user.ID, Email: user.LoginName(), Details: "api"})
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1.go) HandlePostToken() -> 🔑 Access token has been issued for", "id", user.ID)
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, tokenResponse{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: int(auth.TokenLifetime.Seconds())})
+}
+
+// HandleGetAccount responds to GET on the /api/v1/account route with the account of the user.
+func (h APIHandler) HandleGetAccount(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1.go) HandleGetAccount()")
+	user := getAuthenticatedUser(c)
+	account := apiAccount{
+		ID:        user.ID,
+		Email:     user.Email,
+		Username:  user.Account.Username,
+		Role:      string(user.Role()),
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.Account.UpdatedAt,
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1.go) HandleGetAccount() -> 📦 Responding with account", "id", user.ID)
+	return writeWithETag(c, http.StatusOK, etag(user.ID, user.Email, user.UpdatedAt, account.Username, account.Role, user.Account.UpdatedAt), account)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// auditEventTag groups the routes of the audit log in the OpenAPI document. Like the share links, the audit log is
+// not a tracked resource of the account, but the security activity of the settings page.
+var auditEventTag = openapi.Tag{Name: "audit-events", Description: "The audit log of the user"}
+
+// auditEventRoutes describes the routes of the audit log for the OpenAPI document.
+var auditEventRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/audit-events", OperationID: "listAuditEvents", Tag: "audit-events",
+		Summary:    "List the audit events of the user",
+		Parameters: auditEventListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiAuditEvent]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+}
+
+// auditEventListParams are the sort fields and filters of the listing of the audit events.
+var auditEventListParams = listParams{
+	sort:        []string{"created_at", "action"},
+	defaultSort: "-created_at",
+	filters: map[string]listFilter{
+		"action":         {description: "Only the events whose action starts with the value, e.g. login"},
+		"created_after":  {description: "Only the events created at or after the time", time: true},
+		"created_before": {description: "Only the events created before the time", time: true},
+	},
+}
+
+// apiAuditEvent is the representation of an event of the audit log.
+type apiAuditEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Action    string    `json:"action"`
+	ActorID   string    `json:"actor_id,omitempty"`
+	AccountID string    `json:"account_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HandleGetAuditEvents responds to GET on the /api/v1/audit-events route with a page of the audit events, which the
+// user performed or which affected the account of the user, newest first unless sorted otherwise.
+func (h APIHandler) HandleGetAuditEvents(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_audit_events.go) HandleGetAuditEvents()")
+	opts, err := parseListOptions(c, auditEventListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.AuditEvents.ListAuditEventsByUserID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_audit_events.go) HandleGetAuditEvents() -> 📦 Responding with audit events", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representAuditEvent)
+}
+
+// representAuditEvent returns the representation of an event of the audit log.
+func representAuditEvent(e types.AuditEvent) apiAuditEvent {
+	return apiAuditEvent{
+		ID:        e.ID,
+		Action:    e.Action,
+		ActorID:   optionalID(e.ActorID),
+		AccountID: optionalID(e.AccountID),
+		Email:     e.Email,
+		IPAddress: e.IPAddress,
+		Details:   e.Details,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// consumptionTag groups the routes of the consumptions in the OpenAPI document.
+var consumptionTag = openapi.Tag{Name: "consumptions", Description: "The consumption history of the user"}
+
+// consumptionRoutes describes the routes of the consumptions for the OpenAPI document.
+var consumptionRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/consumptions", OperationID: "listConsumptions", Tag: "consumptions",
+		Summary:    "List the consumptions of the user",
+		Parameters: consumptionListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiConsumption]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/consumptions", OperationID: "createConsumption", Tag: "consumptions",
+		Summary: "Record a consumption of a product of the user",
+		Request: consumptionRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusCreated: {Type: apiConsumption{}, Headers: map[string]string{"Location": "The URL of the consumption", "ETag": "The version of the consumption"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/consumptions/:id", OperationID: "getConsumption", Tag: "consumptions",
+		Summary:    "Get a consumption of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiConsumption{}, Headers: map[string]string{"ETag": "The version of the consumption"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/consumptions/:id", OperationID: "updateConsumption", Tag: "consumptions",
+		Summary:    "Replace a consumption of the user",
+		Parameters: []openapi.Parameter{ifMatch},
+		Request:    consumptionRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: apiConsumption{}, Headers: map[string]string{"ETag": "The version of the consumption"}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/consumptions/:id", OperationID: "deleteConsumption", Tag: "consumptions",
+		Summary:    "Move a consumption of the user to the trash",
+		Parameters: []openapi.Parameter{ifMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusNoContent: {Description: "The consumption has been moved to the trash"},
+		},
+	},
+}
+
+// consumptionListParams are the sort fields and filters of the listing of the consumptions.
+var consumptionListParams = listParams{
+	sort:        []string{"consumed_at", "amount", "created_at"},
+	defaultSort: "-consumed_at",
+	filters: map[string]listFilter{
+		"product_id":      {description: "Only the consumptions of the product", id: true},
+		"method":          {description: "Only the consumptions of the method", values: []string{"vaporized", "smoked", "oral", "sublingual"}},
+		"consumed_after":  {description: "Only the consumptions at or after the time", time: true},
+		"consumed_before": {description: "Only the consumptions before the time", time: true},
+	},
+}
+
+// apiConsumption is the representation of a consumption. The amount is in the unit of the product.
+type apiConsumption struct {
+	ID         uuid.UUID `json:"id"`
+	ProductID  uuid.UUID `json:"product_id"`
+	Amount     float64   `json:"amount"`
+	Method     string    `json:"method" enum:"vaporized,smoked,oral,sublingual"`
+	ConsumedAt time.Time `json:"consumed_at"`
+	Notes      string    `json:"notes,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// consumptionRequest is the body of a request to create or replace a consumption.
+type consumptionRequest struct {
+	ProductID  uuid.UUID `json:"product_id"`
+	Amount     float64   `json:"amount"`
+	Method     string    `json:"method" enum:"vaporized,smoked,oral,sublingual"`
+	ConsumedAt time.Time `json:"consumed_at"`
+	Notes      string    `json:"notes,omitempty"`
+}
+
+// HandleGetConsumptions responds to GET on the /api/v1/consumptions route with a page of the consumptions of the
+// user, newest first unless sorted otherwise.
+func (h APIHandler) HandleGetConsumptions(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_consumptions.go) HandleGetConsumptions()")
+	opts, err := parseListOptions(c, consumptionListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.Consumptions.ListConsumptionsByOwnerID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_consumptions.go) HandleGetConsumptions() -> 📦 Responding with consumptions", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representConsumption)
+}
+
+// HandlePostConsumption responds to POST on the /api/v1/consumptions route by recording a consumption of a product
+// of the user.
+func (h APIHandler) HandlePostConsumption(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_consumptions.go) HandlePostConsumption()")
+	var req consumptionRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if err := h.validateConsumption(c, req); err != nil {
+		return err
+	}
+	user := getAuthenticatedUser(c)
+	created := &types.Consumption{OwnerID: user.ID}
+	req.apply(created)
+	if err := h.repos.Consumptions.CreateConsumption(c.Request().Context(), created); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_consumptions.go) ❓❓❓❓ 💨 Creating consumption failed with", "error", err)
+		return err
+	}
+	// The consumption is read again, as the database stores the times with less precision
+	consumption, err := h.repos.Consumptions.GetConsumptionByIDAndOwnerID(c.Request().Context(), created.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_consumptions.go) HandlePostConsumption() -> 💨 Consumption has been created with", "id", consumption.ID)
+	c.Response().Header().Set("Location", c.Request().URL.Path+"/"+consumption.ID.String())
+	return writeWithETag(c, http.StatusCreated, consumptionETag(consumption), representConsumption(consumption))
+}
+
+// HandleGetConsumption responds to GET on the /api/v1/consumptions/:id route with a consumption of the user.
+func (h APIHandler) HandleGetConsumption(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_consumptions.go) HandleGetConsumption()")
+	consumption, err := h.consumption(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_consumptions.go) HandleGetConsumption() -> 📦 Responding with consumption", "id", consumption.ID)
+	return writeWithETag(c, http.StatusOK, consumptionETag(consumption), representConsumption(consumption))
+}
+
+// HandlePutConsumption responds to PUT on the /api/v1/consumptions/:id route by replacing a consumption of the user.
+// The request has to send the ETag of the consumption in the If-Match header, and fails with 412 Precondition Failed
+// if the consumption is changed concurrently.
+func (h APIHandler) HandlePutConsumption(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_consumptions.go) HandlePutConsumption()")
+	consumption, err := h.consumption(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, consumptionETag(consumption)); err != nil {
+		return err
+	}
+	var req consumptionRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if err := h.validateConsumption(c, req); err != nil {
+		return err
+	}
+	req.apply(&consumption)
+	if err := h.repos.Consumptions.UpdateConsumptionVersion(c.Request().Context(), &consumption); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_consumptions.go) ❓❓❓❓ 💨 Updating consumption failed with", "error", err)
+		return err
+	}
+	if consumption, err = h.consumption(c); err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_consumptions.go) HandlePutConsumption() -> 💨 Consumption has been replaced with", "id", consumption.ID)
+	return writeWithETag(c, http.StatusOK, consumptionETag(consumption), representConsumption(consumption))
+}
+
+// HandleDeleteConsumption responds to DELETE on the /api/v1/consumptions/:id route by moving a consumption of the
+// user to the trash. The request has to send the ETag of the consumption in the If-Match header, and fails with 412
+// Precondition Failed if the consumption is changed concurrently.
+func (h APIHandler) HandleDeleteConsumption(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_consumptions.go) HandleDeleteConsumption()")
+	consumption, err := h.consumption(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, consumptionETag(consumption)); err != nil {
+		return err
+	}
+	if err := h.repos.Consumptions.DeleteConsumptionVersion(c.Request().Context(), consumption); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_consumptions.go) ❓❓❓❓ 🗑️  Deleting consumption failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_consumptions.go) HandleDeleteConsumption() -> 🗑️  Consumption has been moved to the trash with", "id", consumption.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// consumption returns the consumption of the :id parameter, as long as it belongs to the user.
+func (h APIHandler) consumption(c echo.Context) (types.Consumption, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return types.Consumption{}, echo.NewHTTPError(http.StatusNotFound, "unknown consumption id")
+	}
+	return h.repos.Consumptions.GetConsumptionByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+}
+
+// validateConsumption returns the invalid fields of the request as a validation error, or the error of looking up
+// its product.
+func (h APIHandler) validateConsumption(c echo.Context, r consumptionRequest) error {
+	invalid := validationError{}
+	problem, err := h.productProblem(c, r.ProductID)
+	if err != nil {
+		return err
+	}
+	invalid["product_id"] = problem
+	if r.Amount <= 0 {
+		invalid["amount"] = "must be positive"
+	}
+	if !slices.Contains(types.ConsumptionMethods, types.ConsumptionMethod(r.Method)) {
+		invalid["method"] = "must be one of vaporized, smoked, oral, sublingual"
+	}
+	if r.ConsumedAt.IsZero() {
+		invalid["consumed_at"] = "must be a time in RFC 3339, e.g. 2026-01-31T00:00:00Z"
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(r.Notes)) > maxNotesLength {
+		invalid["notes"] = "must be at most 1000 characters long"
+	}
+	if invalid = invalid.withoutEmpty(); len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// apply sets the fields of the consumption to the ones of the request.
+func (r consumptionRequest) apply(co *types.Consumption) {
+	co.ProductID = r.ProductID
+	co.Amount = r.Amount
+	co.Method = types.ConsumptionMethod(r.Method)
+	co.ConsumedAt = r.ConsumedAt
+	co.Notes = strings.TrimSpace(r.Notes)
+}
+
+// consumptionETag returns the entity tag of the version of a consumption, which every change gives a new UpdatedAt.
+func consumptionETag(co types.Consumption) string {
+	return etag(co.ID, co.UpdatedAt)
+}
+
+// representConsumption returns the representation of a consumption.
+func representConsumption(co types.Consumption) apiConsumption {
+	return apiConsumption{
+		ID:         co.ID,
+		ProductID:  co.ProductID,
+		Amount:     co.Amount,
+		Method:     string(co.Method),
+		ConsumedAt: co.ConsumedAt,
+		Notes:      co.Notes,
+		CreatedAt:  co.CreatedAt,
+		UpdatedAt:  co.UpdatedAt,
+	}
+}
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// inventoryTag groups the routes of the inventory in the OpenAPI document.
+var inventoryTag = openapi.Tag{Name: "inventory", Description: "The stock of the products of the user"}
+
+// inventoryRoutes describes the routes of the inventory for the OpenAPI document.
+var inventoryRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/inventory", OperationID: "listInventoryItems", Tag: "inventory",
+		Summary:    "List the inventory of the user",
+		Parameters: inventoryListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiInventoryItem]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/inventory", OperationID: "createInventoryItem", Tag: "inventory",
+		Summary: "Add a stock of a product to the inventory of the user",
+		Request: inventoryItemRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusCreated: {Type: apiInventoryItem{}, Headers: map[string]string{"Location": "The URL of the item", "ETag": "The version of the item"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/inventory/:id", OperationID: "getInventoryItem", Tag: "inventory",
+		Summary:    "Get an item of the inventory of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiInventoryItem{}, Headers: map[string]string{"ETag": "The version of the item"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/inventory/:id", OperationID: "updateInventoryItem", Tag: "inventory",
+		Summary:    "Replace an item of the inventory of the user, e.g. with the remaining amount",
+		Parameters: []openapi.Parameter{ifMatch},
+		Request:    inventoryItemRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: apiInventoryItem{}, Headers: map[string]string{"ETag": "The version of the item"}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/inventory/:id", OperationID: "deleteInventoryItem", Tag: "inventory",
+		Summary:    "Move an item of the inventory of the user to the trash",
+		Parameters: []openapi.Parameter{ifMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusNoContent: {Description: "The item has been moved to the trash"},
+		},
+	},
+}
+
+// inventoryListParams are the sort fields and filters of the listing of the inventory.
+var inventoryListParams = listParams{
+	sort:        []string{"acquired_at", "amount", "created_at"},
+	defaultSort: "-acquired_at",
+	filters: map[string]listFilter{
+		"product_id": {description: "Only the items of the product", id: true},
+	},
+}
+
+// apiInventoryItem is the representation of an item of the inventory. The amount is the remaining amount in the unit
+// of the product.
+type apiInventoryItem struct {
+	ID         uuid.UUID `json:"id"`
+	ProductID  uuid.UUID `json:"product_id"`
+	Amount     float64   `json:"amount"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// inventoryItemRequest is the body of a request to create or replace an item of the inventory.
+type inventoryItemRequest struct {
+	ProductID  uuid.UUID `json:"product_id"`
+	Amount     float64   `json:"amount"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// HandleGetInventoryItems responds to GET on the /api/v1/inventory route with a page of the inventory of the user,
+// most recently acquired first unless sorted otherwise.
+func (h APIHandler) HandleGetInventoryItems(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_inventory.go) HandleGetInventoryItems()")
+	opts, err := parseListOptions(c, inventoryListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.Inventory.ListInventoryItemsByOwnerID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_inventory.go) HandleGetInventoryItems() -> 📦 Responding with inventory items", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representInventoryItem)
+}
+
+// HandlePostInventoryItem responds to POST on the /api/v1/inventory route by adding a stock of a product of the user
+// to the inventory.
+func (h APIHandler) HandlePostInventoryItem(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_inventory.go) HandlePostInventoryItem()")
+	var req inventoryItemRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if err := h.validateInventoryItem(c, req); err != nil {
+		return err
+	}
+	user := getAuthenticatedUser(c)
+	created := &types.InventoryItem{OwnerID: user.ID}
+	req.apply(created)
+	if err := h.repos.Inventory.CreateInventoryItem(c.Request().Context(), created); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_inventory.go) ❓❓❓❓ 🫙 Creating inventory item failed with", "error", err)
+		return err
+	}
+	// The item is read again, as the database stores the times with less precision
+	item, err := h.repos.Inventory.GetInventoryItemByIDAndOwnerID(c.Request().Context(), created.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_inventory.go) HandlePostInventoryItem() -> 🫙 Inventory item has been created with", "id", item.ID)
+	c.Response().Header().Set("Location", c.Request().URL.Path+"/"+item.ID.String())
+	return writeWithETag(c, http.StatusCreated, inventoryItemETag(item), representInventoryItem(item))
+}
+
+// HandleGetInventoryItem responds to GET on the /api/v1/inventory/:id route with an item of the inventory of the
+// user.
+func (h APIHandler) HandleGetInventoryItem(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_inventory.go) HandleGetInventoryItem()")
+	item, err := h.inventoryItem(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_inventory.go) HandleGetInventoryItem() -> 📦 Responding with inventory item", "id", item.ID)
+	return writeWithETag(c, http.StatusOK, inventoryItemETag(item), representInventoryItem(item))
+}
+
+// HandlePutInventoryItem responds to PUT on the /api/v1/inventory/:id route by replacing an item of the inventory of
+// the user. The request has to send the ETag of the item in the If-Match header, and fails with 412 Precondition
+// Failed if the item is changed concurrently.
+func (h APIHandler) HandlePutInventoryItem(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_inventory.go) HandlePutInventoryItem()")
+	item, err := h.inventoryItem(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, inventoryItemETag(item)); err != nil {
+		return err
+	}
+	var req inventoryItemRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if err := h.validateInventoryItem(c, req); err != nil {
+		return err
+	}
+	req.apply(&item)
+	if err := h.repos.Inventory.UpdateInventoryItemVersion(c.Request().Context(), &item); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_inventory.go) ❓❓❓❓ 🫙 Updating inventory item failed with", "error", err)
+		return err
+	}
+	if item, err = h.inventoryItem(c); err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_inventory.go) HandlePutInventoryItem() -> 🫙 Inventory item has been replaced with", "id", item.ID)
+	return writeWithETag(c, http.StatusOK, inventoryItemETag(item), representInventoryItem(item))
+}
+
+// HandleDeleteInventoryItem responds to DELETE on the /api/v1/inventory/:id route by moving an item of the inventory
+// of the user to the trash. The request has to send the ETag of the item in the If-Match header, and fails with 412
+// Precondition Failed if the item is changed concurrently.
+func (h APIHandler) HandleDeleteInventoryItem(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_inventory.go) HandleDeleteInventoryItem()")
+	item, err := h.inventoryItem(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, inventoryItemETag(item)); err != nil {
+		return err
+	}
+	if err := h.repos.Inventory.DeleteInventoryItemVersion(c.Request().Context(), item); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_inventory.go) ❓❓❓❓ 🗑️  Deleting inventory item failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_inventory.go) HandleDeleteInventoryItem() -> 🗑️  Inventory item has been moved to the trash with", "id", item.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// inventoryItem returns the item of the inventory of the :id parameter, as long as it belongs to the user.
+func (h APIHandler) inventoryItem(c echo.Context) (types.InventoryItem, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return types.InventoryItem{}, echo.NewHTTPError(http.StatusNotFound, "unknown inventory item id")
+	}
+	return h.repos.Inventory.GetInventoryItemByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+}
+
+// validateInventoryItem returns the invalid fields of the request as a validation error, or the error of looking up
+// its product.
+func (h APIHandler) validateInventoryItem(c echo.Context, r inventoryItemRequest) error {
+	invalid := validationError{}
+	problem, err := h.productProblem(c, r.ProductID)
+	if err != nil {
+		return err
+	}
+	invalid["product_id"] = problem
+	if r.Amount < 0 {
+		invalid["amount"] = "must not be negative"
+	}
+	if r.AcquiredAt.IsZero() {
+		invalid["acquired_at"] = "must be a time in RFC 3339, e.g. 2026-01-31T00:00:00Z"
+	}
+	if invalid = invalid.withoutEmpty(); len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// apply sets the fields of the item to the ones of the request.
+func (r inventoryItemRequest) apply(i *types.InventoryItem) {
+	i.ProductID = r.ProductID
+	i.Amount = r.Amount
+	i.AcquiredAt = r.AcquiredAt
+}
+
+// inventoryItemETag returns the entity tag of the version of an item of the inventory, which every change gives a
+// new UpdatedAt.
+func inventoryItemETag(i types.InventoryItem) string {
+	return etag(i.ID, i.UpdatedAt)
+}
+
+// representInventoryItem returns the representation of an item of the inventory.
+func representInventoryItem(i types.InventoryItem) apiInventoryItem {
+	return apiInventoryItem{
+		ID:         i.ID,
+		ProductID:  i.ProductID,
+		Amount:     i.Amount,
+		AcquiredAt: i.AcquiredAt,
+		CreatedAt:  i.CreatedAt,
+		UpdatedAt:  i.UpdatedAt,
+	}
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxNameLength is the maximum number of characters of the names and symptoms of the tracked resources.
+	maxNameLength = 100
+	// maxNotesLength is the maximum number of characters of the notes of the tracked resources.
+	maxNotesLength = 1000
+)
+
+// productTag groups the routes of the products in the OpenAPI document.
+var productTag = openapi.Tag{Name: "products", Description: "The products of the user"}
+
+// productRoutes describes the routes of the products for the OpenAPI document.
+var productRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/products", OperationID: "listProducts", Tag: "products",
+		Summary:    "List the products of the user",
+		Parameters: productListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiProduct]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/products", OperationID: "createProduct", Tag: "products",
+		Summary: "Create a product of the user",
+		Request: productRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusCreated: {Type: apiProduct{}, Headers: map[string]string{"Location": "The URL of the product", "ETag": "The version of the product"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/products/:id", OperationID: "getProduct", Tag: "products",
+		Summary:    "Get a product of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiProduct{}, Headers: map[string]string{"ETag": "The version of the product"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/products/:id", OperationID: "updateProduct", Tag: "products",
+		Summary:    "Replace a product of the user",
+		Parameters: []openapi.Parameter{ifMatch},
+		Request:    productRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: apiProduct{}, Headers: map[string]string{"ETag": "The version of the product"}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/products/:id", OperationID: "deleteProduct", Tag: "products",
+		Summary:    "Move a product of the user to the trash",
+		Parameters: []openapi.Parameter{ifMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusNoContent: {Description: "The product has been moved to the trash"},
+		},
+	},
+}
+
+// productListParams are the sort fields and filters of the listing of the products.
+var productListParams = listParams{
+	sort:        []string{"name", "created_at"},
+	defaultSort: "name",
+	filters: map[string]listFilter{
+		"kind": {description: "Only the products of the kind", values: []string{"flower", "extract", "oil", "edible"}},
+	},
+}
+
+// apiProduct is the representation of a product. The amounts of the inventory and the consumptions of the product
+// are in its unit.
+type apiProduct struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind" enum:"flower,extract,oil,edible"`
+	Unit      string    `json:"unit" enum:"g,ml,pcs"`
+	THC       float64   `json:"thc"`
+	CBD       float64   `json:"cbd"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// productRequest is the body of a request to create or replace a product. THC and CBD are in percent.
+type productRequest struct {
+	Name string  `json:"name"`
+	Kind string  `json:"kind" enum:"flower,extract,oil,edible"`
+	THC  float64 `json:"thc,omitempty"`
+	CBD  float64 `json:"cbd,omitempty"`
+}
+
+// HandleGetProducts responds to GET on the /api/v1/products route with a page of the products of the user, sorted by
+// name unless sorted otherwise.
+func (h APIHandler) HandleGetProducts(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_products.go) HandleGetProducts()")
+	opts, err := parseListOptions(c, productListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.Products.ListProductsByOwnerID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_products.go) HandleGetProducts() -> 📦 Responding with products", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representProduct)
+}
+
+// HandlePostProduct responds to POST on the /api/v1/products route by creating a product of the user.
+func (h APIHandler) HandlePostProduct(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_products.go) HandlePostProduct()")
+	var req productRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if invalid := req.validate(); len(invalid) > 0 {
+		return invalid
+	}
+	user := getAuthenticatedUser(c)
+	created := &types.Product{OwnerID: user.ID}
+	req.apply(created)
+	if err := h.repos.Products.CreateProduct(c.Request().Context(), created); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_products.go) ❓❓❓❓ 🌿 Creating product failed with", "error", err)
+		return err
+	}
+	// The product is read again, as the database stores the times with less precision
+	product, err := h.repos.Products.GetProductByIDAndOwnerID(c.Request().Context(), created.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_products.go) HandlePostProduct() -> 🌿 Product has been created with", "id", product.ID)
+	c.Response().Header().Set("Location", c.Request().URL.Path+"/"+product.ID.String())
+	return writeWithETag(c, http.StatusCreated, productETag(product), representProduct(product))
+}
+
+// HandleGetProduct responds to GET on the /api/v1/products/:id route with a product of the user.
+func (h APIHandler) HandleGetProduct(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_products.go) HandleGetProduct()")
+	product, err := h.product(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_products.go) HandleGetProduct() -> 📦 Responding with product", "id", product.ID)
+	return writeWithETag(c, http.StatusOK, productETag(product), representProduct(product))
+}
+
+// HandlePutProduct responds to PUT on the /api/v1/products/:id route by replacing a product of the user. The request
+// has to send the ETag of the product in the If-Match header, and fails with 412 Precondition Failed if the product
+// is changed concurrently.
+func (h APIHandler) HandlePutProduct(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_products.go) HandlePutProduct()")
+	product, err := h.product(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, productETag(product)); err != nil {
+		return err
+	}
+	var req productRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if invalid := req.validate(); len(invalid) > 0 {
+		return invalid
+	}
+	req.apply(&product)
+	if err := h.repos.Products.UpdateProductVersion(c.Request().Context(), &product); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_products.go) ❓❓❓❓ 🌿 Updating product failed with", "error", err)
+		return err
+	}
+	if product, err = h.product(c); err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_products.go) HandlePutProduct() -> 🌿 Product has been replaced with", "id", product.ID)
+	return writeWithETag(c, http.StatusOK, productETag(product), representProduct(product))
+}
+
+// HandleDeleteProduct responds to DELETE on the /api/v1/products/:id route by moving a product of the user to the
+// trash. The request has to send the ETag of the product in the If-Match header, and fails with 412 Precondition
+// Failed if the product is changed concurrently.
+func (h APIHandler) HandleDeleteProduct(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_products.go) HandleDeleteProduct()")
+	product, err := h.product(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, productETag(product)); err != nil {
+		return err
+	}
+	if err := h.repos.Products.DeleteProductVersion(c.Request().Context(), product); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_products.go) ❓❓❓❓ 🗑️  Deleting product failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_products.go) HandleDeleteProduct() -> 🗑️  Product has been moved to the trash with", "id", product.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// product returns the product of the :id parameter, as long as it belongs to the user.
+func (h APIHandler) product(c echo.Context) (types.Product, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return types.Product{}, echo.NewHTTPError(http.StatusNotFound, "unknown product id")
+	}
+	return h.repos.Products.GetProductByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+}
+
+// productProblem returns the problem of the id of a product in a request, which has to be a product of the user.
+func (h APIHandler) productProblem(c echo.Context, id uuid.UUID) (string, error) {
+	_, err := h.repos.Products.GetProductByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "must be a product of the user", nil
+	}
+	return "", err
+}
+
+// validate returns the invalid fields of the request.
+func (r productRequest) validate() validationError {
+	invalid := validationError{}
+	if name := strings.TrimSpace(r.Name); name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		invalid["name"] = "must be 1 to 100 characters long"
+	}
+	if !slices.Contains(types.ProductKinds, types.ProductKind(r.Kind)) {
+		invalid["kind"] = "must be one of flower, extract, oil, edible"
+	}
+	if r.THC < 0 || r.THC > 100 {
+		invalid["thc"] = "must be a percentage from 0 to 100"
+	}
+	if r.CBD < 0 || r.CBD > 100 {
+		invalid["cbd"] = "must be a percentage from 0 to 100"
+	}
+	return invalid
+}
+
+// apply sets the fields of the product to the ones of the request.
+func (r productRequest) apply(p *types.Product) {
+	p.Name = strings.TrimSpace(r.Name)
+	p.Kind = types.ProductKind(r.Kind)
+	p.THC = r.THC
+	p.CBD = r.CBD
+}
+
+// productETag returns the entity tag of the version of a product, which every change gives a new UpdatedAt.
+func productETag(p types.Product) string {
+	return etag(p.ID, p.UpdatedAt)
+}
+
+// representProduct returns the representation of a product.
+func representProduct(p types.Product) apiProduct {
+	return apiProduct{
+		ID:        p.ID,
+		Name:      p.Name,
+		Kind:      string(p.Kind),
+		Unit:      p.Kind.Unit(),
+		THC:       p.THC,
+		CBD:       p.CBD,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
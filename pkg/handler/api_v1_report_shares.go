@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// reportShareTag groups the routes of the share links in the OpenAPI document. The share links are not a tracked
+// resource of the account, the API offers them like the settings page does, so scripts can create and revoke them.
+var reportShareTag = openapi.Tag{Name: "report-shares", Description: "Share links to the report of the user"}
+
+// reportShareRoutes describes the routes of the share links for the OpenAPI document.
+var reportShareRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/report-shares", OperationID: "listReportShares", Tag: "report-shares",
+		Summary:    "List the share links of the user",
+		Parameters: reportShareListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiReportShare]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/report-shares", OperationID: "createReportShare", Tag: "report-shares",
+		Summary:     "Create a share link to the report of the user",
+		Description: "The link is only part of this response.",
+		Request:     reportShareRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusCreated: {Type: apiReportShare{}, Headers: map[string]string{"Location": "The URL of the share", "ETag": "The version of the share"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/report-shares/:id", OperationID: "getReportShare", Tag: "report-shares",
+		Summary:    "Get a share link of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiReportShare{}, Headers: map[string]string{"ETag": "The version of the share"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+	{
+		Method: http.MethodPatch, Path: "/report-shares/:id", OperationID: "updateReportShare", Tag: "report-shares",
+		Summary:    "Revoke a share link of the user",
+		Parameters: []openapi.Parameter{ifMatch},
+		Request:    reportSharePatch{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: apiReportShare{}, Headers: map[string]string{"ETag": "The version of the share"}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/report-shares/:id", OperationID: "deleteReportShare", Tag: "report-shares",
+		Summary:    "Move a share link of the user to the trash",
+		Parameters: []openapi.Parameter{ifMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusNoContent: {Description: "The share has been moved to the trash"},
+		},
+	},
+}
+
+// reportShareListParams are the sort fields and filters of the listing of the share links.
+var reportShareListParams = listParams{
+	sort:        []string{"created_at", "expires_at", "from", "to"},
+	defaultSort: "-created_at",
+	filters: map[string]listFilter{
+		"status": {description: "Only the shares with the status", values: []string{"active", "revoked", "expired"}},
+	},
+}
+
+// apiReportShare is the representation of a share link to a report. The link is only part of the response to its
+// creation.
+type apiReportShare struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	From         string     `json:"from" format:"date"`
+	To           string     `json:"to" format:"date"`
+	Status       string     `json:"status" enum:"active,revoked,expired"`
+	Link         string     `json:"link,omitempty"`
+	Views        int        `json:"views"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// reportShareRequest is the body of a request to create a share link to a report.
+type reportShareRequest struct {
+	Name          string `json:"name"`
+	From          string `json:"from" format:"date"`
+	To            string `json:"to" format:"date"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+// reportSharePatch is the body of a request to change a share link to a report, which can only be revoked.
+type reportSharePatch struct {
+	Revoked *bool `json:"revoked"`
+}
+
+// HandleGetReportShares responds to GET on the /api/v1/report-shares route with a page of the share links of the
+// user, newest first unless sorted otherwise.
+func (h APIHandler) HandleGetReportShares(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_report_shares.go) HandleGetReportShares()")
+	opts, err := parseListOptions(c, reportShareListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.ReportShares.ListReportSharesByOwnerID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_report_shares.go) HandleGetReportShares() -> 📦 Responding with report shares", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representReportShare)
+}
+
+// HandlePostReportShare responds to POST on the /api/v1/report-shares route by creating a share link to the report
+// of the user. The link is only part of this response, as just the hash of its token is stored.
+func (h APIHandler) HandlePostReportShare(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_report_shares.go) HandlePostReportShare()")
+	var req reportShareRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	params := settings.ReportShareParams{Name: strings.TrimSpace(req.Name), From: req.From, To: req.To, ExpiresInDays: req.ExpiresInDays}
+	if errs, ok := validateReportShareParams(params); !ok {
+		return validationError{
+			"name":            errs.Name,
+			"from":            errs.From,
+			"to":              errs.To,
+			"expires_in_days": errs.ExpiresInDays,
+		}.withoutEmpty()
+	}
+	user := getAuthenticatedUser(c)
+	created, token, err := h.createReportShare(c, user.ID, params)
+	if err != nil {
+		return err
+	}
+	reportShare, err := h.repos.ReportShares.GetReportShareByIDAndOwnerID(c.Request().Context(), created.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	representation := representReportShare(reportShare)
+	representation.Link = shareLink(c, token)
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_report_shares.go) HandlePostReportShare() -> 🔗 Report share has been created with", "id", reportShare.ID)
+	c.Response().Header().Set("Location", c.Request().URL.Path+"/"+reportShare.ID.String())
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return writeWithETag(c, http.StatusCreated, reportShareETag(reportShare), representation)
+}
+
+// HandleGetReportShare responds to GET on the /api/v1/report-shares/:id route with a share link of the user.
+func (h APIHandler) HandleGetReportShare(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_report_shares.go) HandleGetReportShare()")
+	reportShare, err := h.reportShare(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_report_shares.go) HandleGetReportShare() -> 📦 Responding with report share", "id", reportShare.ID)
+	return writeWithETag(c, http.StatusOK, reportShareETag(reportShare), representReportShare(reportShare))
+}
+
+// HandlePatchReportShare responds to PATCH on the /api/v1/report-shares/:id route by revoking a share link of the
+// user. The request has to send the ETag of the share in the If-Match header, and fails with 412 Precondition Failed
+// if the share is changed concurrently.
+func (h APIHandler) HandlePatchReportShare(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_report_shares.go) HandlePatchReportShare()")
+	reportShare, err := h.reportShare(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, reportShareETag(reportShare)); err != nil {
+		return err
+	}
+	var patch reportSharePatch
+	if err := decodeJSON(c, &patch); err != nil {
+		return err
+	}
+	if patch.Revoked != nil && !*patch.Revoked && reportShare.Revoked() {
+		return validationError{"revoked": "a revoked share cannot be activated again"}
+	}
+	if patch.Revoked != nil && *patch.Revoked && !reportShare.Revoked() {
+		if err := h.repos.ReportShares.RevokeReportShareVersion(c.Request().Context(), reportShare); err != nil {
+			slog.Error("🚨 🔌 (pkg/handler/api_v1_report_shares.go) ❓❓❓❓ 🔗 Revoking report share failed with", "error", err)
+			return err
+		}
+		h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareRevoke, Details: reportShare.ID.String()})
+		if reportShare, err = h.reportShare(c); err != nil {
+			return err
+		}
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_report_shares.go) HandlePatchReportShare() -> 🔗 Report share has been changed with", "id", reportShare.ID)
+	return writeWithETag(c, http.StatusOK, reportShareETag(reportShare), representReportShare(reportShare))
+}
+
+// HandleDeleteReportShare responds to DELETE on the /api/v1/report-shares/:id route by moving a share link of the
+// user to the trash. The request has to send the ETag of the share in the If-Match header, and fails with 412
+// Precondition Failed if the share is changed concurrently.
+func (h APIHandler) HandleDeleteReportShare(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_report_shares.go) HandleDeleteReportShare()")
+	reportShare, err := h.reportShare(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, reportShareETag(reportShare)); err != nil {
+		return err
+	}
+	if err := h.repos.ReportShares.DeleteReportShareVersion(c.Request().Context(), reportShare); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_report_shares.go) ❓❓❓❓ 🗑️  Deleting report share failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareDelete, Details: reportShare.ID.String()})
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_report_shares.go) HandleDeleteReportShare() -> 🗑️  Report share has been moved to the trash with", "id", reportShare.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// reportShare returns the share link of the :id parameter, as long as it belongs to the user.
+func (h APIHandler) reportShare(c echo.Context) (types.ReportShare, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return types.ReportShare{}, echo.NewHTTPError(http.StatusNotFound, "unknown share id")
+	}
+	return h.repos.ReportShares.GetReportShareByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+}
+
+// reportShareETag returns the entity tag of the version of a share link. The views are not part of the version, so
+// viewing the report does not conflict with changing the share.
+func reportShareETag(s types.ReportShare) string {
+	return etag(s.ID, s.Name, s.From, s.To, s.ExpiresAt, s.RevokedAt, s.DeletedAt)
+}
+
+// representReportShare returns the representation of a share link to a report.
+func representReportShare(s types.ReportShare) apiReportShare {
+	status := "active"
+	switch {
+	case s.Revoked():
+		status = "revoked"
+	case s.Expired():
+		status = "expired"
+	}
+	return apiReportShare{
+		ID:           s.ID,
+		Name:         s.Name,
+		From:         s.From.Format(time.DateOnly),
+		To:           s.To.Format(time.DateOnly),
+		Status:       status,
+		Views:        s.Views,
+		LastViewedAt: optionalTime(s.LastViewedAt),
+		ExpiresAt:    s.ExpiresAt,
+		RevokedAt:    optionalTime(s.RevokedAt),
+		CreatedAt:    s.CreatedAt,
+	}
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheDonDope/wits-server/pkg/openapi"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// symptomRatingTag groups the routes of the ratings of symptoms in the OpenAPI document.
+var symptomRatingTag = openapi.Tag{Name: "symptoms", Description: "The ratings of the symptoms of the user"}
+
+// symptomRatingRoutes describes the routes of the ratings of symptoms for the OpenAPI document.
+var symptomRatingRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/symptoms", OperationID: "listSymptomRatings", Tag: "symptoms",
+		Summary:    "List the ratings of the symptoms of the user",
+		Parameters: symptomRatingListParams.parameters(),
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: listResponse[apiSymptomRating]{}, Headers: map[string]string{"Link": "The previous and the next page"}},
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/symptoms", OperationID: "createSymptomRating", Tag: "symptoms",
+		Summary: "Rate a symptom of the user",
+		Request: symptomRatingRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusCreated: {Type: apiSymptomRating{}, Headers: map[string]string{"Location": "The URL of the rating", "ETag": "The version of the rating"}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/symptoms/:id", OperationID: "getSymptomRating", Tag: "symptoms",
+		Summary:    "Get a rating of a symptom of the user",
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusOK:          {Type: apiSymptomRating{}, Headers: map[string]string{"ETag": "The version of the rating"}},
+			http.StatusNotModified: notModified,
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/symptoms/:id", OperationID: "updateSymptomRating", Tag: "symptoms",
+		Summary:    "Replace a rating of a symptom of the user",
+		Parameters: []openapi.Parameter{ifMatch},
+		Request:    symptomRatingRequest{},
+		Responses: map[int]openapi.Body{
+			http.StatusOK: {Type: apiSymptomRating{}, Headers: map[string]string{"ETag": "The version of the rating"}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/symptoms/:id", OperationID: "deleteSymptomRating", Tag: "symptoms",
+		Summary:    "Move a rating of a symptom of the user to the trash",
+		Parameters: []openapi.Parameter{ifMatch},
+		Responses: map[int]openapi.Body{
+			http.StatusNoContent: {Description: "The rating has been moved to the trash"},
+		},
+	},
+}
+
+// symptomRatingListParams are the sort fields and filters of the listing of the ratings of symptoms. The symptoms
+// are encrypted, so they can neither be sorted nor filtered by.
+var symptomRatingListParams = listParams{
+	sort:        []string{"rated_at", "severity", "created_at"},
+	defaultSort: "-rated_at",
+	filters: map[string]listFilter{
+		"min_severity": {description: "Only the ratings of at least the severity", values: []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+		"rated_after":  {description: "Only the ratings at or after the time", time: true},
+		"rated_before": {description: "Only the ratings before the time", time: true},
+	},
+}
+
+// apiSymptomRating is the representation of a rating of a symptom. The severity goes from 0 for none to 10 for the
+// worst.
+type apiSymptomRating struct {
+	ID        uuid.UUID `json:"id"`
+	Symptom   string    `json:"symptom"`
+	Severity  int       `json:"severity"`
+	RatedAt   time.Time `json:"rated_at"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// symptomRatingRequest is the body of a request to create or replace a rating of a symptom.
+type symptomRatingRequest struct {
+	Symptom  string    `json:"symptom"`
+	Severity int       `json:"severity"`
+	RatedAt  time.Time `json:"rated_at"`
+	Notes    string    `json:"notes,omitempty"`
+}
+
+// HandleGetSymptomRatings responds to GET on the /api/v1/symptoms route with a page of the ratings of the symptoms of
+// the user, newest first unless sorted otherwise.
+func (h APIHandler) HandleGetSymptomRatings(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleGetSymptomRatings()")
+	opts, err := parseListOptions(c, symptomRatingListParams)
+	if err != nil {
+		return err
+	}
+	page, err := h.repos.Symptoms.ListSymptomRatingsByOwnerID(c.Request().Context(), getAuthenticatedUser(c).ID, opts)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleGetSymptomRatings() -> 📦 Responding with symptom ratings", "count", len(page.Items), "total", page.Total)
+	return writePage(c, page, representSymptomRating)
+}
+
+// HandlePostSymptomRating responds to POST on the /api/v1/symptoms route by rating a symptom of the user.
+func (h APIHandler) HandlePostSymptomRating(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandlePostSymptomRating()")
+	var req symptomRatingRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if invalid := req.validate(); len(invalid) > 0 {
+		return invalid
+	}
+	user := getAuthenticatedUser(c)
+	created := &types.SymptomRating{OwnerID: user.ID}
+	req.apply(created)
+	if err := h.repos.Symptoms.CreateSymptomRating(c.Request().Context(), created); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_symptom_ratings.go) ❓❓❓❓ 🩺 Creating symptom rating failed with", "error", err)
+		return err
+	}
+	// The rating is read again, as the database stores the times with less precision
+	rating, err := h.repos.Symptoms.GetSymptomRatingByIDAndOwnerID(c.Request().Context(), created.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandlePostSymptomRating() -> 🩺 Symptom rating has been created with", "id", rating.ID)
+	c.Response().Header().Set("Location", c.Request().URL.Path+"/"+rating.ID.String())
+	return writeWithETag(c, http.StatusCreated, symptomRatingETag(rating), representSymptomRating(rating))
+}
+
+// HandleGetSymptomRating responds to GET on the /api/v1/symptoms/:id route with a rating of a symptom of the user.
+func (h APIHandler) HandleGetSymptomRating(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleGetSymptomRating()")
+	rating, err := h.symptomRating(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleGetSymptomRating() -> 📦 Responding with symptom rating", "id", rating.ID)
+	return writeWithETag(c, http.StatusOK, symptomRatingETag(rating), representSymptomRating(rating))
+}
+
+// HandlePutSymptomRating responds to PUT on the /api/v1/symptoms/:id route by replacing a rating of a symptom of the
+// user. The request has to send the ETag of the rating in the If-Match header, and fails with 412 Precondition Failed
+// if the rating is changed concurrently.
+func (h APIHandler) HandlePutSymptomRating(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandlePutSymptomRating()")
+	rating, err := h.symptomRating(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, symptomRatingETag(rating)); err != nil {
+		return err
+	}
+	var req symptomRatingRequest
+	if err := decodeJSON(c, &req); err != nil {
+		return err
+	}
+	if invalid := req.validate(); len(invalid) > 0 {
+		return invalid
+	}
+	req.apply(&rating)
+	if err := h.repos.Symptoms.UpdateSymptomRatingVersion(c.Request().Context(), &rating); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_symptom_ratings.go) ❓❓❓❓ 🩺 Updating symptom rating failed with", "error", err)
+		return err
+	}
+	if rating, err = h.symptomRating(c); err != nil {
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandlePutSymptomRating() -> 🩺 Symptom rating has been replaced with", "id", rating.ID)
+	return writeWithETag(c, http.StatusOK, symptomRatingETag(rating), representSymptomRating(rating))
+}
+
+// HandleDeleteSymptomRating responds to DELETE on the /api/v1/symptoms/:id route by moving a rating of a symptom of
+// the user to the trash. The request has to send the ETag of the rating in the If-Match header, and fails with 412
+// Precondition Failed if the rating is changed concurrently.
+func (h APIHandler) HandleDeleteSymptomRating(c echo.Context) error {
+	slog.Info("💬 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleDeleteSymptomRating()")
+	rating, err := h.symptomRating(c)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, symptomRatingETag(rating)); err != nil {
+		return err
+	}
+	if err := h.repos.Symptoms.DeleteSymptomRatingVersion(c.Request().Context(), rating); err != nil {
+		slog.Error("🚨 🔌 (pkg/handler/api_v1_symptom_ratings.go) ❓❓❓❓ 🗑️  Deleting symptom rating failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🔌 (pkg/handler/api_v1_symptom_ratings.go) HandleDeleteSymptomRating() -> 🗑️  Symptom rating has been moved to the trash with", "id", rating.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// symptomRating returns the rating of a symptom of the :id parameter, as long as it belongs to the user.
+func (h APIHandler) symptomRating(c echo.Context) (types.SymptomRating, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return types.SymptomRating{}, echo.NewHTTPError(http.StatusNotFound, "unknown symptom rating id")
+	}
+	return h.repos.Symptoms.GetSymptomRatingByIDAndOwnerID(c.Request().Context(), id, getAuthenticatedUser(c).ID)
+}
+
+// validate returns the invalid fields of the request.
+func (r symptomRatingRequest) validate() validationError {
+	invalid := validationError{}
+	if symptom := strings.TrimSpace(r.Symptom); symptom == "" || utf8.RuneCountInString(symptom) > maxNameLength {
+		invalid["symptom"] = "must be 1 to 100 characters long"
+	}
+	if r.Severity < 0 || r.Severity > types.MaxSeverity {
+		invalid["severity"] = "must be a number from 0 to " + strconv.Itoa(types.MaxSeverity)
+	}
+	if r.RatedAt.IsZero() {
+		invalid["rated_at"] = "must be a time in RFC 3339, e.g. 2026-01-31T00:00:00Z"
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(r.Notes)) > maxNotesLength {
+		invalid["notes"] = "must be at most 1000 characters long"
+	}
+	return invalid
+}
+
+// apply sets the fields of the rating to the ones of the request.
+func (r symptomRatingRequest) apply(sr *types.SymptomRating) {
+	sr.Symptom = strings.TrimSpace(r.Symptom)
+	sr.Severity = r.Severity
+	sr.RatedAt = r.RatedAt
+	sr.Notes = strings.TrimSpace(r.Notes)
+}
+
+// symptomRatingETag returns the entity tag of the version of a rating of a symptom, which every change gives a new
+// UpdatedAt.
+func symptomRatingETag(sr types.SymptomRating) string {
+	return etag(sr.ID, sr.UpdatedAt)
+}
+
+// representSymptomRating returns the representation of a rating of a symptom.
+func representSymptomRating(sr types.SymptomRating) apiSymptomRating {
+	return apiSymptomRating{
+		ID:        sr.ID,
+		Symptom:   sr.Symptom,
+		Severity:  sr.Severity,
+		RatedAt:   sr.RatedAt,
+		Notes:     sr.Notes,
+		CreatedAt: sr.CreatedAt,
+		UpdatedAt: sr.UpdatedAt,
+	}
+}
@@ -1,10 +1,9 @@
 package handler
 
 import (
-	"errors"
 	"log/slog"
-	"os"
 
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/TheDonDope/wits-server/pkg/view/auth"
@@ -35,52 +34,37 @@ type Verifier interface {
 	Verify(c echo.Context) error
 }
 
-// NewAuthenticator returns the correct Authenticator based on the DB_TYPE environment variable.
-func NewAuthenticator() (Authenticator, error) {
-	dbType := os.Getenv("DB_TYPE")
-	if dbType == storage.DBTypeLocal {
-		return &LocalAuthenticator{}, nil
-	} else if dbType == storage.DBTypeRemote {
-		return &SupabaseAuthenticator{}, nil
-	}
-	return nil, errors.New("DB_TYPE not set or invalid")
+// PasswordChanger is the interface that wraps the basic ChangePassword method.
+type PasswordChanger interface {
+	// ChangePassword changes the password of the logged in user
+	ChangePassword(c echo.Context) error
 }
 
-// NewRegistrator returns a new Registrator based on the DB_TYPE environment variable.
-func NewRegistrator() (Registrator, error) {
-	dbType := os.Getenv("DB_TYPE")
-	if dbType == storage.DBTypeLocal {
-		return &LocalRegistrator{}, nil
-	} else if dbType == storage.DBTypeRemote {
-		return &SupabaseRegistrator{}, nil
-	}
-	return nil, errors.New("DB_TYPE not set or invalid")
+// EmailChanger is the interface that wraps the ChangeEmail and ConfirmEmail methods.
+type EmailChanger interface {
+	// ChangeEmail starts the change of the email of the logged in user, sending a verification to the new address
+	ChangeEmail(c echo.Context) error
+	// ConfirmEmail applies the change of the email, once the new address has been verified
+	ConfirmEmail(c echo.Context) error
 }
 
 // AuthHandler provides handlers for the authentication routes of the application.
-// It is responsible for handling user login, registration, and logout.
+// It is responsible for handling user login, registration, and logout with the enabled identity providers.
 type AuthHandler struct {
-	auth     Authenticator
-	google   Authenticator
+	identity *IdentityRegistry
 	deauth   Deauthenticator
-	register Registrator
-	verify   Verifier
 }
 
-// NewAuthHandler creates a new AuthHandler with the given LoginService and RegisterService, depending on the database type.
-func NewAuthHandler() *AuthHandler {
-	auth, _ := NewAuthenticator()
-	google := &GoogleAuthenticator{}
-	deauth := &LocalDeauthenticator{}
-	register, _ := NewRegistrator()
-	verify := &SupabaseVerifier{}
-	return &AuthHandler{auth: auth, google: google, deauth: deauth, register: register, verify: verify}
+// NewAuthHandler creates a new AuthHandler for the identity providers of the registry.
+func NewAuthHandler(repos *storage.Repositories, cfg *config.Config, svc Services, identity *IdentityRegistry) *AuthHandler {
+	return &AuthHandler{identity: identity, deauth: &LocalDeauthenticator{deps: newDeps(repos, cfg, svc)}}
 }
 
-// HandleGetLogin responds to GET on the /login route by rendering the Login component.
+// HandleGetLogin responds to GET on the /login route by rendering the Login component with the enabled identity
+// providers.
 func (h AuthHandler) HandleGetLogin(c echo.Context) error {
 	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetLogin()")
-	return render(c, auth.Login())
+	return render(c, auth.Login(h.identity.LoginPage()))
 }
 
 // HandlePostLogin responds to POST on the /login route by trying to log in the user.
@@ -88,13 +72,32 @@ func (h AuthHandler) HandleGetLogin(c echo.Context) error {
 // Finally, the user is redirected to the dashboard.
 func (h AuthHandler) HandlePostLogin(c echo.Context) error {
 	slog.Info("💬 🔒 (pkg/handler/auth.go) HandlePostLogin()")
-	return h.auth.Login(c)
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	return h.identity.Password.Login(c)
+}
+
+// HandleGetLoginWithProvider responds to GET on the /login/provider/:provider route by redirecting the user to the
+// identity provider, e.g. Google or the OpenID Connect provider.
+func (h AuthHandler) HandleGetLoginWithProvider(c echo.Context) error {
+	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetLoginWithProvider()", "provider", c.Param("provider"))
+	provider, ok := h.identity.Redirect(c.Param("provider"))
+	if !ok {
+		return echo.ErrNotFound
+	}
+	return provider.Login(c)
 }
 
-// HandleGetLoginWithGoogle responds to GET on the /login/provider/google route by logging in the user with Google.
-func (h AuthHandler) HandleGetLoginWithGoogle(c echo.Context) error {
-	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetLoginWithGoogle()")
-	return h.google.Login(c)
+// HandleGetProviderCallback responds to GET on the /auth/:provider/callback route by logging in the user returning
+// from the identity provider.
+func (h AuthHandler) HandleGetProviderCallback(c echo.Context) error {
+	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetProviderCallback()", "provider", c.Param("provider"))
+	provider, ok := h.identity.Redirect(c.Param("provider"))
+	if !ok {
+		return echo.ErrNotFound
+	}
+	return provider.Verify(c)
 }
 
 // HandlePostLogout responds to POST on the /logout route by logging out the user.
@@ -103,10 +106,15 @@ func (h AuthHandler) HandlePostLogout(c echo.Context) error {
 	return h.deauth.Logout(c)
 }
 
-// HandleGetRegister responds to GET on the /register route by rendering the Register component.
+// HandleGetRegister responds to GET on the /register route by rendering the Register component. When local
+// passwords are enabled, users can register without an email with ?mode=pseudonymous.
 func (h AuthHandler) HandleGetRegister(c echo.Context) error {
 	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetRegister()")
-	return render(c, auth.Register())
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	params := auth.RegisterParams{Pseudonymous: h.identity.Pseudonymous && c.QueryParam("mode") == pseudonymousMode}
+	return render(c, auth.Register(params, h.identity.Pseudonymous))
 }
 
 // HandlePostRegister responds to POST on the /register route by trying to register the user.
@@ -114,13 +122,19 @@ func (h AuthHandler) HandleGetRegister(c echo.Context) error {
 // Afterwards, the JWT tokens are generated and set as cookies. Finally, the user is redirected to the dashboard.
 func (h AuthHandler) HandlePostRegister(c echo.Context) error {
 	slog.Info("💬 🔒 (pkg/handler/auth.go) HandlePostRegister()")
-	return h.register.Register(c)
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	return h.identity.Password.Register(c)
 }
 
-// HandleGetAuthCallback responds to GET on the /auth/callback route by verifying the user.
+// HandleGetAuthCallback responds to GET on the /auth/callback route by verifying the user returning from Supabase.
 func (h AuthHandler) HandleGetAuthCallback(c echo.Context) error {
 	slog.Info("💬 🔒 (pkg/handler/auth.go) HandleGetAuthCallback()")
-	return h.verify.Verify(c)
+	if h.identity.Verifier == nil {
+		return echo.ErrNotFound
+	}
+	return h.identity.Verifier.Verify(c)
 }
 
 // getAuthenticatedUser provides a shorthand function to get the authenticated user from the echo.Context.
@@ -3,22 +3,23 @@ package handler
 import (
 	"log/slog"
 	"net/http"
-	"os"
 
-	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/labstack/echo/v4"
 	"github.com/nedpals/supabase-go"
 )
 
-// GoogleAuthenticator is an interface for the user login, when using Google.
-type GoogleAuthenticator struct{}
+// GoogleAuthenticator is an interface for the user login with Google through Supabase. Returning users are verified
+// like all users of Supabase.
+type GoogleAuthenticator struct {
+	SupabaseVerifier
+}
 
 // Login logs in the user with their Google Credentials
 func (g GoogleAuthenticator) Login(c echo.Context) error {
 	slog.Info("💬 🛰️  (pkg/handler/auth_google.go) GoogleAuthenticator.Login()")
-	resp, err := storage.SupabaseClient.Auth.SignInWithProvider(supabase.ProviderSignInOptions{
+	resp, err := g.client.Auth.SignInWithProvider(supabase.ProviderSignInOptions{
 		Provider:   "google",
-		RedirectTo: os.Getenv("AUTH_CALLBACK_URL"),
+		RedirectTo: g.cfg.Auth.CallbackURL,
 	})
 	if err != nil {
 		return err
@@ -27,3 +28,9 @@ func (g GoogleAuthenticator) Login(c echo.Context) error {
 	slog.Info("✅ 🛰️  (pkg/handler/auth_google.go) RemoteAuthenticator.Login() -> 🔀 Redirecting to", "url", resp.URL[:10]+"...")
 	return c.Redirect(http.StatusSeeOther, resp.URL)
 }
+
+// Verify verifies the user returning from Google through Supabase.
+func (g GoogleAuthenticator) Verify(c echo.Context) error {
+	slog.Info("💬 🛰️  (pkg/handler/auth_google.go) GoogleAuthenticator.Verify()")
+	return g.SupabaseVerifier.Verify(c)
+}
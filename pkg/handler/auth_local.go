@@ -1,74 +1,217 @@
 package handler
 
 import (
-	"encoding/gob"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"os"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/TheDonDope/wits-server/pkg/auth"
 	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/TheDonDope/wits-server/pkg/types"
 	authview "github.com/TheDonDope/wits-server/pkg/view/auth"
+	settingsview "github.com/TheDonDope/wits-server/pkg/view/settings"
 	"github.com/google/uuid"
-	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// errLoginThrottled is returned when repeated failed logins lock out the login name or IP address.
+	errLoginThrottled = errors.New("(pkg/handler/auth_local.go) Login is throttled")
+	// errInvalidCredentials is returned when the login name or password is wrong.
+	errInvalidCredentials = errors.New("(pkg/handler/auth_local.go) Credentials are invalid")
+	// errAccountDisabled is returned when the account of the user has been disabled by an admin.
+	errAccountDisabled = errors.New("(pkg/handler/auth_local.go) Account has been disabled")
+)
+
+const (
+	// pseudonymousMode is the registration mode for users without an email.
+	pseudonymousMode = "pseudonymous"
+	// maxUsernameAttempts is the number of generated usernames tried before the registration gives up.
+	maxUsernameAttempts = 10
+)
+
 // LocalAuthenticator is an interface for the user login, when using a local database.
-type LocalAuthenticator struct{}
+type LocalAuthenticator struct {
+	deps
+}
 
 // Login logs in the user with the local database.
 func (l LocalAuthenticator) Login(c echo.Context) error {
 	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalAuthenticator.Login()")
 	email := c.FormValue("email")
 	password := c.FormValue("password")
-	user, userErr := storage.GetAuthenticatedUserByEmailAndPassword(email, password)
-	if userErr != nil {
-		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Checking if user exists failed with", "error", userErr)
+
+	authenticatedUser, wait, err := l.checkLocalLogin(c, email, password)
+	switch {
+	case errors.Is(err, errLoginThrottled):
+		slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalAuthenticator.Login() -> 🐢 Login is throttled for", "wait", wait)
 		return render(c, authview.LoginForm(email, password, authview.LoginErrors{
-			InvalidCredentials: "The credentials you have entered are invalid",
+			LockedOut: lockedOutMessage(wait),
 		}))
+	case errors.Is(err, errAccountDisabled):
+		slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalAuthenticator.Login() -> 🚫 Account of user has been disabled")
+		return render(c, authview.LoginForm(email, password, authview.LoginErrors{
+			InvalidCredentials: "Your account has been disabled. Please contact an administrator.",
+		}))
+	case errors.Is(err, errInvalidCredentials):
+		loginErrors := authview.LoginErrors{
+			InvalidCredentials: "The credentials you have entered are invalid",
+		}
+		if wait > 0 {
+			loginErrors.LockedOut = lockedOutMessage(wait)
+		}
+		return render(c, authview.LoginForm(email, password, loginErrors))
+	case err != nil:
+		return c.String(http.StatusInternalServerError, "logging in failed, please try again later")
 	}
 
-	authenticatedUser := types.AuthenticatedUser{
+	l.startLocalSession(c, authenticatedUser)
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionLogin, ActorID: authenticatedUser.ID, Email: authenticatedUser.LoginName()})
+
+	slog.Info("🆗 🏠 (pkg/handler/auth_local.go)  🔓 User has been logged in with local database")
+
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalAuthenticator.Login() -> 🔀 Redirecting to dashboard")
+	return hxRedirect(c, "/dashboard")
+}
+
+// checkLocalLogin checks the login name and password of a user in the local database, throttling repeated failures
+// per login name and IP address. It returns the logged in user, or errLoginThrottled, errInvalidCredentials or
+// errAccountDisabled together with the time the user has to wait before trying again. Any other error means the
+// account of the user could not be checked, so the login is refused.
+func (d deps) checkLocalLogin(c echo.Context, login string, password string) (types.AuthenticatedUser, time.Duration, error) {
+	ip := c.RealIP()
+	wait, err := d.Throttler.Check(c.Request().Context(), ip, login)
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Checking login throttle failed with", "error", err)
+	}
+	if wait > 0 {
+		return types.AuthenticatedUser{}, wait, errLoginThrottled
+	}
+
+	var user types.AuthenticatedUser
+	var userErr error
+	if strings.Contains(login, "@") {
+		user, userErr = d.repos.Users.GetAuthenticatedUserByEmail(c.Request().Context(), login)
+	} else {
+		user, userErr = d.repos.Users.GetAuthenticatedUserByUsername(c.Request().Context(), login)
+	}
+	if userErr == nil {
+		userErr = checkPassword(user, password)
+	}
+	if userErr != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Checking if user exists failed with", "error", userErr)
+		result, err := d.Throttler.Fail(c.Request().Context(), ip, login)
+		if err != nil {
+			slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Recording failed login failed with", "error", err)
+		}
+		d.audit.Record(c, types.AuditEvent{Action: types.AuditActionLoginFailure, Email: login})
+		for _, key := range result.LockedOut {
+			d.audit.Record(c, types.AuditEvent{Action: types.AuditActionLoginLockout, Email: login, Details: key})
+		}
+		return types.AuthenticatedUser{}, result.Wait, errInvalidCredentials
+	}
+
+	account, err := d.repos.Accounts.GetAccountByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Getting account of user failed with", "error", err)
+		return types.AuthenticatedUser{}, 0, err
+	}
+	if !account.DisabledAt.IsZero() {
+		return types.AuthenticatedUser{}, 0, errAccountDisabled
+	}
+
+	if err := d.Throttler.Succeed(c.Request().Context(), login); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Resetting login throttle failed with", "error", err)
+	}
+
+	return types.AuthenticatedUser{
 		ID:       user.ID,
 		Email:    user.Email,
 		LoggedIn: true,
+		Account:  account,
+	}, 0, nil
+}
+
+// checkCurrentPassword checks the current password of the logged in user with verify before a change of the password
+// or email. Failures are throttled together with the logins of the user, so the form can not be used to guess the
+// password instead. It returns errLoginThrottled or the error of verify together with the time the user has to wait
+// before trying again.
+func (d deps) checkCurrentPassword(c echo.Context, user types.AuthenticatedUser, verify func() error) (time.Duration, error) {
+	ip, login := c.RealIP(), user.LoginName()
+	wait, err := d.Throttler.Check(c.Request().Context(), ip, login)
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Checking login throttle failed with", "error", err)
+	}
+	if wait > 0 {
+		return wait, errLoginThrottled
+	}
+	if verifyErr := verify(); verifyErr != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Checking current password failed with", "error", verifyErr)
+		result, err := d.Throttler.Fail(c.Request().Context(), ip, login)
+		if err != nil {
+			slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Recording failed password check failed with", "error", err)
+		}
+		for _, key := range result.LockedOut {
+			d.audit.Record(c, types.AuditEvent{Action: types.AuditActionLoginLockout, Email: login, Details: key})
+		}
+		return result.Wait, verifyErr
+	}
+	if err := d.Throttler.Succeed(c.Request().Context(), login); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🐢 Resetting login throttle failed with", "error", err)
+	}
+	return 0, nil
+}
+
+// currentPasswordMessage returns the message shown for a failed check of the current password.
+func currentPasswordMessage(err error, wait time.Duration) string {
+	if errors.Is(err, errLoginThrottled) {
+		return lockedOutMessage(wait)
 	}
+	return "The current password is incorrect"
+}
 
+// startLocalSession generates self-signed JWT tokens for the user and stores them in the session, together with the
+// login name of the user.
+func (d deps) startLocalSession(c echo.Context, authenticatedUser types.AuthenticatedUser) {
 	// Generate JWT tokens and set cookies 'manually'
-	accessToken, err := auth.SignToken(authenticatedUser, []byte(os.Getenv("JWT_SECRET_KEY")))
+	accessToken, err := auth.SignToken(authenticatedUser, []byte(d.cfg.Auth.JWTSecretKey.Value()))
 	if err != nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Signing access token failed with", "error", err)
 	}
-	refreshToken, err := auth.SignToken(authenticatedUser, []byte(os.Getenv("JWT_REFRESH_SECRET_KEY")))
+	refreshToken, err := auth.SignToken(authenticatedUser, []byte(d.cfg.Auth.JWTRefreshSecretKey.Value()))
 	if err != nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Signing refresh token failed with", "error", err)
 	}
 
-	// Register uuid.UUID with gob
-	gob.Register(uuid.UUID{})
-
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), auth.WitsSessionName)
+	session := d.loginSession(c)
 	session.Values[auth.AccessTokenCookieName] = accessToken
 	session.Values[auth.RefreshTokenCookieName] = refreshToken
-	session.Values[types.UserContextKey] = authenticatedUser.Email
+	session.Values[types.UserContextKey] = authenticatedUser.LoginName()
 	session.Values[types.UserIdKey] = authenticatedUser.ID
 	cookieErr := session.Save(c.Request(), c.Response())
 	if cookieErr != nil {
-		slog.Error("🚨 🛰️  (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Saving session failed with", "error", cookieErr)
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Saving session failed with", "error", cookieErr)
 	}
+}
 
-	slog.Info("🆗 🏠 (pkg/handler/auth_local.go)  🔓 User has been logged in with local database")
-
-	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalAuthenticator.Login() -> 🔀 Redirecting to dashboard")
-	return hxRedirect(c, "/dashboard")
+// lockedOutMessage returns the message shown to a user who has to wait before logging in again.
+func lockedOutMessage(wait time.Duration) string {
+	wait = wait.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("Too many failed login attempts. Please try again in %s.", wait)
 }
 
 // LocalRegistrator is an interface for the user registration, when using a local database.
-type LocalRegistrator struct{}
+type LocalRegistrator struct {
+	deps
+}
 
 // Register logs in the user with the local database.
 func (l LocalRegistrator) Register(c echo.Context) error {
@@ -77,6 +220,7 @@ func (l LocalRegistrator) Register(c echo.Context) error {
 		Email:                c.FormValue("email"),
 		Password:             c.FormValue("password"),
 		PasswordConfirmation: c.FormValue("password-confirmation"),
+		Pseudonymous:         c.FormValue("mode") == pseudonymousMode,
 	}
 
 	if params.Password != params.PasswordConfirmation {
@@ -86,13 +230,17 @@ func (l LocalRegistrator) Register(c echo.Context) error {
 		}))
 	}
 
+	if params.Pseudonymous {
+		return l.registerPseudonymous(c, params)
+	}
+
 	// Check if user with email already exists
-	existingUser, err := storage.GetAuthenticatedUserByEmail(params.Email)
+	existingUser, err := l.repos.Users.GetAuthenticatedUserByEmail(c.Request().Context(), params.Email)
 	if err != nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Checking if user exists failed with", "error", err)
 	}
 
-	if existingUser != (types.AuthenticatedUser{}) {
+	if existingUser.ID != uuid.Nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 User with email already exists")
 		return render(c, authview.RegisterForm(params, authview.RegisterErrors{
 			InvalidCredentials: "User with email already exists",
@@ -102,6 +250,7 @@ func (l LocalRegistrator) Register(c echo.Context) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), 8)
 	if err != nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Hashing password failed with", "error", err)
+		return err
 	}
 
 	authenticatedUser := types.AuthenticatedUser{
@@ -109,56 +258,131 @@ func (l LocalRegistrator) Register(c echo.Context) error {
 		Email:    params.Email,
 		Password: string(hashedPassword),
 	}
-	account := types.Account{
+	authenticatedUser.Account = types.Account{
 		ID:     uuid.New(),
 		UserID: authenticatedUser.ID,
 	}
-	authenticatedUser.Account = account
-
-	if err := storage.CreateAuthenticatedUser(&authenticatedUser); err != nil {
+	err = l.repos.Tx.RunInTx(c.Request().Context(), func(ctx context.Context, repos *storage.Repositories) error {
+		return createUserWithAccount(ctx, repos, &authenticatedUser)
+	})
+	if err != nil {
 		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Creating user failed with", "error", err)
+		return err
 	}
 
 	authenticatedUser.LoggedIn = true
 
-	// Generate JWT tokens and set cookies 'manually'
-	accessToken, err := auth.SignToken(authenticatedUser, []byte(os.Getenv("JWT_SECRET_KEY")))
+	l.startLocalSession(c, authenticatedUser)
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionRegister, ActorID: authenticatedUser.ID, Email: authenticatedUser.Email})
+
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalRegistrator.Register() -> 🔀 User has been registered, redirecting to dashboard")
+	return hxRedirect(c, "/dashboard")
+}
+
+// registerPseudonymous registers a user without an email. The user logs in with a generated username and gets
+// recovery codes in place of a recovery email, which are only shown once.
+func (l LocalRegistrator) registerPseudonymous(c echo.Context, params authview.RegisterParams) error {
+	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalRegistrator.registerPseudonymous()")
+	if len(params.Password) < minPasswordLength {
+		return render(c, authview.RegisterForm(params, authview.RegisterErrors{
+			Password: fmt.Sprintf("The password must be at least %d characters long", minPasswordLength),
+		}))
+	}
+
+	username, err := l.uniqueUsername(c.Request().Context())
 	if err != nil {
-		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Signing access token failed with", "error", err)
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🥸 Generating username failed with", "error", err)
+		return err
 	}
-	refreshToken, err := auth.SignToken(authenticatedUser, []byte(os.Getenv("JWT_REFRESH_SECRET_KEY")))
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), 8)
 	if err != nil {
-		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Signing refresh token failed with", "error", err)
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Hashing password failed with", "error", err)
+		return err
+	}
+
+	authenticatedUser := types.AuthenticatedUser{
+		ID:       uuid.New(),
+		Password: string(hashedPassword),
+	}
+	authenticatedUser.Account = types.Account{
+		ID:       uuid.New(),
+		UserID:   authenticatedUser.ID,
+		Username: username,
+	}
+	var codes []string
+	err = l.repos.Tx.RunInTx(c.Request().Context(), func(ctx context.Context, repos *storage.Repositories) error {
+		if err := createUserWithAccount(ctx, repos, &authenticatedUser); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(ctx, repos.RecoveryCodes, authenticatedUser.ID)
+		return err
+	})
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Creating user with recovery codes failed with", "error", err)
+		return err
 	}
 
-	// Register uuid.UUID with gob
-	gob.Register(uuid.UUID{})
+	authenticatedUser.LoggedIn = true
+	l.startLocalSession(c, authenticatedUser)
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionRegister, ActorID: authenticatedUser.ID, Email: username, Details: "pseudonymous"})
 
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), auth.WitsSessionName)
-	session.Values[auth.AccessTokenCookieName] = accessToken
-	session.Values[auth.RefreshTokenCookieName] = refreshToken
-	session.Values[types.UserContextKey] = authenticatedUser.Email
-	session.Values[types.UserIdKey] = authenticatedUser.ID
-	cookieErr := session.Save(c.Request(), c.Response())
-	if cookieErr != nil {
-		slog.Error("🚨 🛰️  (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Saving session failed with", "error", cookieErr)
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalRegistrator.registerPseudonymous() -> 🥸 User has been registered with", "username", username)
+	return render(c, authview.PseudonymousRegisterSuccess(username, codes))
+}
+
+// uniqueUsername generates usernames until it finds one, which is not taken yet.
+func (l LocalRegistrator) uniqueUsername(ctx context.Context) (string, error) {
+	for range maxUsernameAttempts {
+		username, err := auth.GenerateUsername()
+		if err != nil {
+			return "", err
+		}
+		exists, err := l.repos.Accounts.ExistsAccountWithUsername(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
 	}
+	return "", fmt.Errorf("(pkg/handler/auth_local.go) No free username found after %d attempts", maxUsernameAttempts)
+}
 
-	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalRegistrator.Register() -> 🔀 User has been registered, redirecting to dashboard")
-	return hxRedirect(c, "/dashboard")
+// createUserWithAccount creates the user and the account of the user. It is meant to run in a transaction, so the
+// user is not created without the account.
+func createUserWithAccount(ctx context.Context, repos *storage.Repositories, user *types.AuthenticatedUser) error {
+	if err := repos.Users.CreateAuthenticatedUser(ctx, user); err != nil {
+		return err
+	}
+	return repos.Accounts.CreateAccount(ctx, &user.Account)
+}
+
+// replaceRecoveryCodes generates new recovery codes for the user, replacing the previous ones. Only the hashes of the
+// codes are stored.
+func replaceRecoveryCodes(ctx context.Context, recoveryCodes storage.RecoveryCodeRepository, userID uuid.UUID) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(types.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = storage.HashToken(code)
+	}
+	return codes, recoveryCodes.ReplaceRecoveryCodes(ctx, userID, hashes)
 }
 
 // LocalDeauthenticator is an struct for the user logout, when using a local database.
-type LocalDeauthenticator struct{}
+type LocalDeauthenticator struct {
+	deps
+}
 
 // Logout logs out the user with the local database.
 func (l LocalDeauthenticator) Logout(c echo.Context) error {
 	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalDeauthenticator.Logout()")
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionLogout})
 
 	// Clear cookies from gorilla/sessions store
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), auth.WitsSessionName)
+	session, _ := l.Sessions.Get(c.Request(), auth.WitsSessionName)
 	session.Options.MaxAge = -1
 	session.Options.Path = "/"
 	session.Values[auth.AccessTokenCookieName] = ""
@@ -174,3 +398,125 @@ func (l LocalDeauthenticator) Logout(c echo.Context) error {
 	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalDeauthenticator.Logout() -> 🔀 Redirecting to login")
 	return hxRedirect(c, "/login")
 }
+
+// LocalPasswordChanger is a struct for changing the password, when using a local database.
+type LocalPasswordChanger struct {
+	deps
+}
+
+// ChangePassword changes the password of the user in the local database after checking the current password.
+// All other sessions of the user are logged out and the personal API tokens are revoked.
+func (l LocalPasswordChanger) ChangePassword(c echo.Context) error {
+	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalPasswordChanger.ChangePassword()")
+	user := getAuthenticatedUser(c)
+	params := passwordParams(c)
+	if errs, ok := validatePasswordParams(params); !ok {
+		return render(c, settingsview.PasswordForm(params, errs))
+	}
+
+	if wait, err := l.checkCurrentPassword(c, user, func() error {
+		_, err := l.authenticateByID(c.Request().Context(), user.ID, params.CurrentPassword)
+		return err
+	}); err != nil {
+		return render(c, settingsview.PasswordForm(params, settingsview.PasswordErrors{
+			CurrentPassword: currentPasswordMessage(err, wait),
+		}))
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), 8)
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Hashing password failed with", "error", err)
+		return err
+	}
+	if err := l.repos.Users.UpdateAuthenticatedUserPassword(c.Request().Context(), user.ID, string(hashedPassword)); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🔒 Updating password failed with", "error", err)
+		return err
+	}
+	l.logoutOtherSessions(c, user)
+	l.revokeTokens(c, user)
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionPasswordChange})
+
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalPasswordChanger.ChangePassword() -> 🔑 Password has been changed")
+	return render(c, settingsview.PasswordForm(settingsview.PasswordParams{Success: true}, settingsview.PasswordErrors{}))
+}
+
+// LocalEmailChanger is a struct for changing the email, when using a local database.
+type LocalEmailChanger struct {
+	deps
+}
+
+// ChangeEmail checks the current password and sends a verification link to the new email address. The email is
+// only changed once the link has been opened.
+func (l LocalEmailChanger) ChangeEmail(c echo.Context) error {
+	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalEmailChanger.ChangeEmail()")
+	user := getAuthenticatedUser(c)
+	params := emailParams(c)
+	if errs, ok := validateEmailParams(user, params); !ok {
+		return render(c, settingsview.EmailForm(params, errs))
+	}
+
+	if existing, err := l.repos.Users.GetAuthenticatedUserByEmail(c.Request().Context(), params.Email); err == nil && existing.ID != uuid.Nil {
+		return render(c, settingsview.EmailForm(params, settingsview.EmailErrors{
+			Email: "The email address is already in use",
+		}))
+	}
+	if wait, err := l.checkCurrentPassword(c, user, func() error {
+		_, err := l.authenticateByID(c.Request().Context(), user.ID, params.CurrentPassword)
+		return err
+	}); err != nil {
+		return render(c, settingsview.EmailForm(params, settingsview.EmailErrors{
+			CurrentPassword: currentPasswordMessage(err, wait),
+		}))
+	}
+
+	token := randomToken()
+	change := types.EmailChange{
+		UserID:    user.ID,
+		NewEmail:  params.Email,
+		TokenHash: storage.HashToken(token),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	if err := l.repos.EmailChanges.CreateEmailChange(c.Request().Context(), &change); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Creating email change failed with", "error", err)
+		return err
+	}
+	link := fmt.Sprintf("%s://%s/email/confirm?token=%s", c.Scheme(), c.Request().Host, token)
+	body := fmt.Sprintf("Please open the following link within 24 hours to confirm your new email address for Wits:\n\n%s\n\nIf you did not request this change, you can ignore this email.", link)
+	if err := l.Mailer.Send(params.Email, "Confirm your new email address for Wits", body); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Sending verification email failed with", "error", err)
+		return err
+	}
+
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalEmailChanger.ChangeEmail() -> 📮 Verification email has been sent")
+	return render(c, settingsview.EmailForm(settingsview.EmailParams{Email: params.Email, Success: true}, settingsview.EmailErrors{}))
+}
+
+// ConfirmEmail applies a pending email change with the token from the verification link. All sessions of the user
+// are logged out, so the user logs in again with the new email.
+func (l LocalEmailChanger) ConfirmEmail(c echo.Context) error {
+	slog.Info("💬 🏠 (pkg/handler/auth_local.go) LocalEmailChanger.ConfirmEmail()")
+	change, err := l.repos.EmailChanges.GetEmailChangeByTokenHash(c.Request().Context(), storage.HashToken(c.QueryParam("token")))
+	if err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Finding email change failed with", "error", err)
+		return render(c, settingsview.EmailConfirmed(""))
+	}
+	if existing, err := l.repos.Users.GetAuthenticatedUserByEmail(c.Request().Context(), change.NewEmail); err == nil && existing.ID != uuid.Nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Email has been taken in the meantime")
+		return render(c, settingsview.EmailConfirmed(""))
+	}
+	if err := l.repos.Users.UpdateAuthenticatedUserEmail(c.Request().Context(), change.UserID, change.NewEmail); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Updating email failed with", "error", err)
+		return err
+	}
+	if err := l.repos.EmailChanges.DeleteEmailChangesByUserID(c.Request().Context(), change.UserID); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 📮 Deleting email changes failed with", "error", err)
+	}
+	if err := l.repos.Sessions.DeleteSessionsByUserID(c.Request().Context(), change.UserID); err != nil {
+		slog.Error("🚨 🏠 (pkg/handler/auth_local.go) ❓❓❓❓ 🍪 Revoking sessions failed with", "error", err)
+	}
+
+	l.audit.Record(c, types.AuditEvent{Action: types.AuditActionEmailChange, ActorID: change.UserID, Email: change.NewEmail})
+
+	slog.Info("✅ 🏠 (pkg/handler/auth_local.go) LocalEmailChanger.ConfirmEmail() -> 📮 Email has been changed")
+	return render(c, settingsview.EmailConfirmed(change.NewEmail))
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (f *fakeUserRepository) GetAuthenticatedUserByEmail(ctx context.Context, email string) (types.AuthenticatedUser, error) {
+	for _, u := range f.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return types.AuthenticatedUser{}, sql.ErrNoRows
+}
+
+func (f *fakeUserRepository) UpdateAuthenticatedUserPassword(ctx context.Context, id uuid.UUID, password string) error {
+	u := f.users[id]
+	u.Password = password
+	f.users[id] = u
+	return nil
+}
+
+func (f *fakeUserRepository) RevokeAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	u := f.users[userID]
+	u.Account.TokensRevokedAt = time.Now()
+	f.users[userID] = u
+	return nil
+}
+
+// fakeSessionRepository keeps the sessions in memory, keyed by the hash of their key.
+type fakeSessionRepository struct {
+	storage.SessionRepository
+	sessions map[string]types.Session
+}
+
+func (f *fakeSessionRepository) CreateSession(ctx context.Context, session *types.Session) error {
+	f.sessions[session.KeyHash] = *session
+	return nil
+}
+
+func (f *fakeSessionRepository) GetSessionByKeyHash(ctx context.Context, keyHash string) (types.Session, error) {
+	s, ok := f.sessions[keyHash]
+	if !ok {
+		return types.Session{}, sql.ErrNoRows
+	}
+	return s, nil
+}
+
+func (f *fakeSessionRepository) DeleteOtherSessionsByUserID(ctx context.Context, userID uuid.UUID, keyHash string) error {
+	for hash, s := range f.sessions {
+		if s.UserID == userID && hash != keyHash {
+			delete(f.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// fakeAPITokenRepository keeps the personal API tokens in memory.
+type fakeAPITokenRepository struct {
+	storage.APITokenRepository
+	tokens []types.APIToken
+}
+
+func (f *fakeAPITokenRepository) DeleteAPITokensByUserID(ctx context.Context, userID uuid.UUID) error {
+	f.tokens = slices.DeleteFunc(f.tokens, func(t types.APIToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func TestLocalPasswordChangerChangePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("current-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name            string
+		currentPassword string
+		priorFailures   int
+		wantMessage     string
+		wantChanged     bool
+	}{
+		{"Wrong current password should be rejected", "wrong-password", 0, "The current password is incorrect", false},
+		{"Correct current password should change the password", "current-password", 0, "", true},
+		{"Repeated wrong passwords should be throttled", "current-password", auth.EmailThrottlePolicy.LockoutThreshold, "Too many failed login attempts", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler := auth.NewLoginThrottler(auth.NewMemoryLoginAttemptStore())
+			for i := 0; i < tt.priorFailures; i++ {
+				if _, err := throttler.Fail(context.Background(), "192.0.2.1", "user@wits.example"); err != nil {
+					t.Fatalf("Fail() error = %v", err)
+				}
+			}
+			user := types.AuthenticatedUser{ID: uuid.New(), Email: "user@wits.example", Password: string(hash), LoggedIn: true}
+			users := &fakeUserRepository{users: map[uuid.UUID]types.AuthenticatedUser{user.ID: user}}
+			sessions := &fakeSessionRepository{sessions: map[string]types.Session{"other": {UserID: user.ID, KeyHash: "other"}}}
+			tokens := &fakeAPITokenRepository{tokens: []types.APIToken{{UserID: user.ID, Name: "script"}}}
+			cfg := config.Default()
+			cfg.Auth.SessionSecret = config.Secret(strings.Repeat("s", 32))
+			sessionStore := storage.NewPostgresStore(sessions, cfg.Auth, echo.ExtractIPDirect())
+			l := LocalPasswordChanger{deps: newDeps(&storage.Repositories{Users: users, Accounts: users, Sessions: sessions, APITokens: tokens, AuditEvents: &fakeAuditRepository{}}, cfg, Services{Sessions: sessionStore, Throttler: throttler})}
+
+			// The current session of the request is the one, which is kept
+			session, _ := sessionStore.New(httptest.NewRequest(http.MethodGet, "/", nil), auth.WitsSessionName)
+			session.Values[types.UserIdKey] = user.ID
+			saved := httptest.NewRecorder()
+			if err := sessionStore.Save(httptest.NewRequest(http.MethodGet, "/", nil), saved, session); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			form := url.Values{"current-password": {tt.currentPassword}, "new-password": {"new-password"}, "new-password-confirmation": {"new-password"}}
+			req := httptest.NewRequest(http.MethodPost, "/settings/password", strings.NewReader(form.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.AddCookie(saved.Result().Cookies()[0])
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.Set(types.UserContextKey, user)
+			if err := l.ChangePassword(c); err != nil {
+				t.Fatalf("ChangePassword() error = %v", err)
+			}
+
+			if tt.wantMessage != "" && !strings.Contains(rec.Body.String(), tt.wantMessage) {
+				t.Errorf("ChangePassword() body = %s, want it to contain %q", rec.Body, tt.wantMessage)
+			}
+			changed := bcrypt.CompareHashAndPassword([]byte(users.users[user.ID].Password), []byte("new-password")) == nil
+			if changed != tt.wantChanged {
+				t.Errorf("ChangePassword() changed the password = %v, want %v", changed, tt.wantChanged)
+			}
+			_, otherKept := sessions.sessions["other"]
+			if revoked := !otherKept && len(tokens.tokens) == 0 && !users.users[user.ID].Account.TokensRevokedAt.IsZero(); revoked != tt.wantChanged || len(sessions.sessions) == 0 {
+				t.Errorf("ChangePassword() left sessions %v and tokens %v, want the current session kept and the others and tokens revoked = %v", sessions.sessions, tokens.tokens, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestCheckLocalLoginThrottlesClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		wantKey        string
+	}{
+		{"Spoofed header should not change the throttle key", nil, "ip:203.0.113.7"},
+		{"Header of a trusted proxy should be the throttle key", []string{"203.0.113.0/24"}, "ip:198.51.100.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := auth.NewMemoryLoginAttemptStore()
+			users := &fakeUserRepository{users: map[uuid.UUID]types.AuthenticatedUser{}}
+			d := newDeps(&storage.Repositories{Users: users, Accounts: users, AuditEvents: &fakeAuditRepository{}}, config.Default(), Services{Throttler: auth.NewLoginThrottler(store)})
+
+			e := echo.New()
+			e.IPExtractor = NewIPExtractor(config.HTTP{TrustedProxies: tt.trustedProxies})
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = "203.0.113.7:1234"
+			req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.9")
+			req.Header.Set(echo.HeaderXRealIP, "198.51.100.9")
+			if _, _, err := d.checkLocalLogin(e.NewContext(req, httptest.NewRecorder()), "nobody@wits.example", "password"); !errors.Is(err, errInvalidCredentials) {
+				t.Fatalf("checkLocalLogin() error = %v, want %v", err, errInvalidCredentials)
+			}
+			if attempt, err := store.GetLoginAttempt(context.Background(), tt.wantKey); err != nil || attempt.Failures != 1 {
+				t.Errorf("GetLoginAttempt(%q) = %+v, %v, want 1 failure", tt.wantKey, attempt, err)
+			}
+		})
+	}
+}
+
+// failingAccountRepository fails to get any account, like an unreachable database.
+type failingAccountRepository struct {
+	storage.AccountRepository
+}
+
+func (f failingAccountRepository) GetAccountByUserID(ctx context.Context, userID uuid.UUID) (types.Account, error) {
+	return types.Account{}, errors.New("connection refused")
+}
+
+func TestCheckLocalLoginChecksAccount(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := types.AuthenticatedUser{ID: uuid.New(), Email: "active@wits.example", Password: string(hash)}
+	disabled := types.AuthenticatedUser{ID: uuid.New(), Email: "disabled@wits.example", Password: string(hash), Account: types.Account{DisabledAt: time.Now()}}
+	users := &fakeUserRepository{users: map[uuid.UUID]types.AuthenticatedUser{active.ID: active, disabled.ID: disabled}}
+	tests := []struct {
+		name     string
+		login    string
+		accounts storage.AccountRepository
+		wantErr  bool
+		wantIs   error
+	}{
+		{"Active account should log in", active.Email, users, false, nil},
+		{"Disabled account should not log in", disabled.Email, users, true, errAccountDisabled},
+		{"Failing account lookup should not log in", active.Email, failingAccountRepository{}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDeps(&storage.Repositories{Users: users, Accounts: tt.accounts, AuditEvents: &fakeAuditRepository{}}, config.Default(), Services{Throttler: auth.NewLoginThrottler(auth.NewMemoryLoginAttemptStore())})
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			user, _, err := d.checkLocalLogin(echo.New().NewContext(req, httptest.NewRecorder()), tt.login, "password")
+			if (err != nil) != tt.wantErr || (tt.wantIs != nil && !errors.Is(err, tt.wantIs)) {
+				t.Fatalf("checkLocalLogin() error = %v, want error %v (%v)", err, tt.wantErr, tt.wantIs)
+			}
+			if tt.wantErr && user.LoggedIn {
+				t.Errorf("checkLocalLogin() user = %+v, want no logged in user", user)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcFlowCookieName is the name of the cookie holding the state of a pending OpenID Connect login.
+	oidcFlowCookieName = "wits-oidc"
+	// oidcFlowMaxAge is how long a user may take to log in with the OpenID Connect provider.
+	oidcFlowMaxAge = 10 * time.Minute
+)
+
+// oidcFlow is the state of a pending login, kept in a signed cookie between the redirect to the provider and the
+// callback.
+type oidcFlow struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// oidcClaims are the claims of a validated ID token which are used to link the identity to a local user.
+type oidcClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// OIDCAuthenticator logs in users with a generic OpenID Connect provider, using the authorization code flow with
+// PKCE. Identities of the provider are linked to local users.
+type OIDCAuthenticator struct {
+	deps
+	Name     string
+	issuer   string
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	cookie   *securecookie.SecureCookie
+	secure   bool
+}
+
+// NewOIDCAuthenticator discovers the endpoints of the provider of the configuration and returns a new
+// OIDCAuthenticator for it, linking the identities to the users of the repositories.
+func NewOIDCAuthenticator(ctx context.Context, repos *storage.Repositories, c *config.Config, svc Services) (*OIDCAuthenticator, error) {
+	slog.Info("💬 🪪 (pkg/handler/auth_oidc.go) NewOIDCAuthenticator()", "issuer", c.OIDC.IssuerURL)
+	cfg := c.OIDC
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/handler/auth_oidc.go) ❓❓❓❓ 🔭 Discovering OpenID Connect provider failed with", "error", err)
+		return nil, err
+	}
+	scopes := cfg.Scopes
+	if !slices.Contains(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+	cookie := securecookie.New([]byte(c.Auth.SessionSecret.Value()), nil)
+	cookie.MaxAge(int(oidcFlowMaxAge.Seconds()))
+	slog.Info("✅ 🪪 (pkg/handler/auth_oidc.go) NewOIDCAuthenticator() -> 🔭 Discovered OpenID Connect provider", "name", cfg.ProviderName)
+	return &OIDCAuthenticator{
+		deps:   newDeps(repos, c, svc),
+		Name:   cfg.ProviderName,
+		issuer: cfg.IssuerURL,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret.Value(),
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		cookie:   cookie,
+		secure:   strings.HasPrefix(cfg.RedirectURL, "https://"),
+	}, nil
+}
+
+// Login redirects the user to the provider, remembering the state, nonce and PKCE verifier in a signed cookie.
+func (o *OIDCAuthenticator) Login(c echo.Context) error {
+	slog.Info("💬 🪪 (pkg/handler/auth_oidc.go) OIDCAuthenticator.Login()")
+	flow := oidcFlow{State: randomToken(), Nonce: randomToken(), Verifier: oauth2.GenerateVerifier()}
+	encoded, err := o.cookie.Encode(oidcFlowCookieName, flow)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/handler/auth_oidc.go) ❓❓❓❓ 🍪 Encoding login state failed with", "error", err)
+		return err
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcFlowCookieName,
+		Value:    encoded,
+		Path:     "/",
+		MaxAge:   int(oidcFlowMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	url := o.oauth2.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	slog.Info("✅ 🪪 (pkg/handler/auth_oidc.go) OIDCAuthenticator.Login() -> 🔀 Redirecting to provider", "name", o.Name)
+	return c.Redirect(http.StatusSeeOther, url)
+}
+
+// Verify handles the callback of the provider. It exchanges the authorization code, validates the ID token, links the
+// identity to a local user and starts the session, unless the account of the user has been disabled.
+func (o *OIDCAuthenticator) Verify(c echo.Context) error {
+	slog.Info("💬 🪪 (pkg/handler/auth_oidc.go) OIDCAuthenticator.Verify()")
+	claims, err := o.callbackClaims(c)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/handler/auth_oidc.go) ❓❓❓❓ 🔒 Validating OpenID Connect callback failed with", "error", err)
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	user, err := o.linkIdentity(c.Request().Context(), claims)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/handler/auth_oidc.go) ❓❓❓❓ 🔒 Linking OpenID Connect identity failed with", "error", err)
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	account, err := o.repos.Accounts.GetAccountByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		slog.Error("🚨 🪪 (pkg/handler/auth_oidc.go) ❓❓❓❓ 🔒 Getting account of user failed with", "error", err)
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+	if !account.DisabledAt.IsZero() {
+		slog.Info("✅ 🪪 (pkg/handler/auth_oidc.go) OIDCAuthenticator.Verify() -> 🚫 Account of user has been disabled")
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	authenticatedUser := types.AuthenticatedUser{
+		ID:       user.ID,
+		Email:    user.Email,
+		LoggedIn: true,
+		Account:  account,
+	}
+	o.startLocalSession(c, authenticatedUser)
+	o.audit.Record(c, types.AuditEvent{Action: types.AuditActionLogin, ActorID: user.ID, Email: user.Email, Details: o.Name})
+
+	slog.Info("🆗 🪪 (pkg/handler/auth_oidc.go)  🔓 User has been logged in with", "provider", o.Name, "email", user.Email)
+	slog.Info("✅ 🪪 (pkg/handler/auth_oidc.go) OIDCAuthenticator.Verify() -> 🔀 Redirecting to dashboard")
+	return c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+// callbackClaims checks the state of the callback against the pending login, exchanges the authorization code with
+// the PKCE verifier and returns the claims of the validated ID token.
+func (o *OIDCAuthenticator) callbackClaims(c echo.Context) (oidcClaims, error) {
+	var claims oidcClaims
+	cookie, err := c.Cookie(oidcFlowCookieName)
+	if err != nil {
+		return claims, errors.New("no pending login found")
+	}
+	// The pending login can only be used once
+	c.SetCookie(&http.Cookie{Name: oidcFlowCookieName, Path: "/", MaxAge: -1, HttpOnly: true, Secure: o.secure})
+
+	var flow oidcFlow
+	if err := o.cookie.Decode(oidcFlowCookieName, cookie.Value, &flow); err != nil {
+		return claims, fmt.Errorf("decoding pending login: %w", err)
+	}
+	query := c.Request().URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		return claims, fmt.Errorf("provider returned error: %s %s", providerErr, query.Get("error_description"))
+	}
+	if query.Get("state") != flow.State {
+		return claims, errors.New("state does not match pending login")
+	}
+
+	ctx := c.Request().Context()
+	token, err := o.oauth2.Exchange(ctx, query.Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return claims, fmt.Errorf("exchanging authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return claims, errors.New("token response does not contain an id_token")
+	}
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return claims, fmt.Errorf("verifying id_token: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return claims, errors.New("nonce does not match pending login")
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return claims, fmt.Errorf("parsing id_token claims: %w", err)
+	}
+	claims.Subject = idToken.Subject
+	return claims, nil
+}
+
+// linkIdentity returns the local user of the identity. Unknown identities are linked to the user with the same,
+// verified email, or to a newly created user.
+func (o *OIDCAuthenticator) linkIdentity(ctx context.Context, claims oidcClaims) (types.AuthenticatedUser, error) {
+	identity, err := o.repos.Identities.GetIdentityByIssuerAndSubject(ctx, o.issuer, claims.Subject)
+	if err == nil {
+		return o.repos.Users.GetAuthenticatedUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return types.AuthenticatedUser{}, err
+	}
+
+	if claims.Email == "" {
+		return types.AuthenticatedUser{}, errors.New("id_token does not contain an email")
+	}
+	user, err := o.repos.Users.GetAuthenticatedUserByEmail(ctx, claims.Email)
+	created := errors.Is(err, sql.ErrNoRows)
+	switch {
+	case err == nil && !claims.EmailVerified:
+		return types.AuthenticatedUser{}, errors.New("email of unverified identity belongs to an existing user")
+	case created:
+		user = types.AuthenticatedUser{
+			ID:    uuid.New(),
+			Email: claims.Email,
+		}
+		user.Account = types.Account{
+			ID:     uuid.New(),
+			UserID: user.ID,
+		}
+	case err != nil:
+		return types.AuthenticatedUser{}, err
+	}
+
+	identity = types.Identity{
+		UserID:  user.ID,
+		Issuer:  o.issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	// A new user is only kept together with the linked identity
+	err = o.repos.Tx.RunInTx(ctx, func(ctx context.Context, repos *storage.Repositories) error {
+		if created {
+			if err := createUserWithAccount(ctx, repos, &user); err != nil {
+				return err
+			}
+		}
+		return repos.Identities.CreateIdentity(ctx, &identity)
+	})
+	if err != nil {
+		return types.AuthenticatedUser{}, err
+	}
+	if created {
+		slog.Info("🆗 🪪 (pkg/handler/auth_oidc.go)  🐣 User has been created for identity with", "email", user.Email)
+	}
+	slog.Info("🆗 🪪 (pkg/handler/auth_oidc.go)  🔗 Identity has been linked to user with", "email", user.Email)
+	return user, nil
+}
+
+// randomToken returns a random, URL safe token.
+func randomToken() string {
+	return base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+}
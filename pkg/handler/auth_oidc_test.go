@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// standInIssuer is a minimal OpenID Connect provider, serving discovery, keys and a token endpoint which checks the
+// PKCE verifier of the authorization code it has handed out.
+type standInIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string
+	nonce     string
+	audience  string
+}
+
+func newStandInIssuer(t *testing.T) *standInIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuer := &standInIssuer{key: key, clientID: "wits"}
+	issuer.audience = issuer.clientID
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "valid-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != issuer.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     issuer.signIDToken(t),
+		})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (s *standInIssuer) signIDToken(t *testing.T) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: s.key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"iss":            s.server.URL,
+		"sub":            "subject-1",
+		"aud":            s.audience,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          s.nonce,
+		"email":          "sso@foo.org",
+		"email_verified": true,
+	})
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign id_token: %v", err)
+	}
+	token, _ := signed.CompactSerialize()
+	return token
+}
+
+// fakeIdentityRepository links every identity to the same user.
+type fakeIdentityRepository struct {
+	storage.IdentityRepository
+	userID uuid.UUID
+}
+
+func (f fakeIdentityRepository) GetIdentityByIssuerAndSubject(ctx context.Context, issuer string, subject string) (types.Identity, error) {
+	return types.Identity{Issuer: issuer, Subject: subject, UserID: f.userID}, nil
+}
+
+// newStandInOIDCAuthenticator returns an OIDCAuthenticator for the stand-in issuer using the repositories.
+func newStandInOIDCAuthenticator(t *testing.T, issuer *standInIssuer, repos *storage.Repositories) *OIDCAuthenticator {
+	t.Helper()
+	cfg := config.Default()
+	cfg.OIDC = config.OIDC{
+		ProviderName: "Stand-in",
+		IssuerURL:    issuer.server.URL,
+		ClientID:     issuer.clientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/auth/oidc/callback",
+		Scopes:       []string{"email"},
+	}
+	cfg.Auth.SessionSecret = "test-secret"
+	o, err := NewOIDCAuthenticator(context.Background(), repos, cfg, Services{})
+	if err != nil {
+		t.Fatalf("NewOIDCAuthenticator() error = %v", err)
+	}
+	return o
+}
+
+// oidcCallback starts the login, lets the stand-in issuer authorize the requested challenge and nonce, and returns
+// the request of the callback with the code, the state modified by state and the flow cookie.
+func oidcCallback(t *testing.T, e *echo.Echo, o *OIDCAuthenticator, issuer *standInIssuer, code string, state func(string) string) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := o.Login(e.NewContext(httptest.NewRequest(http.MethodGet, "/login/provider/oidc", nil), rec)); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	query := location.Query()
+	if query.Get("client_id") != issuer.clientID || query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid email" {
+		t.Fatalf("Login() redirected to unexpected URL %s", location)
+	}
+	issuer.challenge = query.Get("code_challenge")
+	issuer.nonce = query.Get("nonce")
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+url.Values{
+		"code":  {code},
+		"state": {state(query.Get("state"))},
+	}.Encode(), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	return callback
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        string
+		state       func(string) string
+		audience    string
+		wantErr     bool
+		wantSubject string
+	}{
+		{"Valid callback should return the claims", "valid-code", func(s string) string { return s }, "wits", false, "subject-1"},
+		{"Mismatching state should error", "valid-code", func(s string) string { return s + "x" }, "wits", true, ""},
+		{"Unknown code should error", "other-code", func(s string) string { return s }, "wits", true, ""},
+		{"Token for another client should error", "valid-code", func(s string) string { return s }, "other", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newStandInIssuer(t)
+			issuer.audience = tt.audience
+			o := newStandInOIDCAuthenticator(t, issuer, &storage.Repositories{})
+
+			e := echo.New()
+			callback := oidcCallback(t, e, o, issuer, tt.code, tt.state)
+			claims, err := o.callbackClaims(e.NewContext(callback, httptest.NewRecorder()))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("callbackClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if claims.Subject != tt.wantSubject {
+				t.Errorf("callbackClaims() subject = %v, want %v", claims.Subject, tt.wantSubject)
+			}
+			if !tt.wantErr && (claims.Email != "sso@foo.org" || !claims.EmailVerified) {
+				t.Errorf("callbackClaims() = %+v, want verified email sso@foo.org", claims)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthenticatorVerifyRejectsDisabledAccount(t *testing.T) {
+	issuer := newStandInIssuer(t)
+	user := types.AuthenticatedUser{ID: uuid.New(), Email: "sso@foo.org", Account: types.Account{DisabledAt: time.Now()}}
+	users := &fakeUserRepository{users: map[uuid.UUID]types.AuthenticatedUser{user.ID: user}}
+	auditEvents := &fakeAuditRepository{}
+	o := newStandInOIDCAuthenticator(t, issuer, &storage.Repositories{
+		Users:       users,
+		Accounts:    users,
+		Identities:  fakeIdentityRepository{userID: user.ID},
+		AuditEvents: auditEvents,
+	})
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	callback := oidcCallback(t, e, o, issuer, "valid-code", func(s string) string { return s })
+	if err := o.Verify(e.NewContext(callback, rec)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if location := rec.Header().Get("Location"); location != "/login" {
+		t.Errorf("Verify() redirected to %q, want /login", location)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == auth.WitsSessionName {
+			t.Errorf("Verify() set the session cookie %+v, want no session", cookie)
+		}
+	}
+	if len(auditEvents.events) != 0 {
+		t.Errorf("Verify() recorded %+v, want no login", auditEvents.events)
+	}
+}
@@ -1,23 +1,23 @@
 package handler
 
 import (
-	"encoding/gob"
 	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/TheDonDope/wits-server/pkg/auth"
-	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/TheDonDope/wits-server/pkg/types"
 	authview "github.com/TheDonDope/wits-server/pkg/view/auth"
+	settingsview "github.com/TheDonDope/wits-server/pkg/view/settings"
 	"github.com/google/uuid"
-	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
 	"github.com/nedpals/supabase-go"
 )
 
 // SupabaseAuthenticator is an interface for the user login, when using a remote Supabase database.
-type SupabaseAuthenticator struct{}
+type SupabaseAuthenticator struct {
+	deps
+	client *supabase.Client
+}
 
 // Login logs in the user with the remote Supabase database.
 func (s SupabaseAuthenticator) Login(c echo.Context) error {
@@ -28,9 +28,10 @@ func (s SupabaseAuthenticator) Login(c echo.Context) error {
 	}
 
 	// Call Supabase to sign in
-	resp, sessionErr := storage.SupabaseClient.Auth.SignIn(c.Request().Context(), credentials)
+	resp, sessionErr := s.client.Auth.SignIn(c.Request().Context(), credentials)
 	if sessionErr != nil {
 		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Signing user in with Supabase failed with", "error", sessionErr)
+		s.audit.Record(c, types.AuditEvent{Action: types.AuditActionLoginFailure, Email: credentials.Email})
 		return render(c, authview.LoginForm(credentials.Email, credentials.Password, authview.LoginErrors{
 			InvalidCredentials: "The credentials you have entered are invalid",
 		}))
@@ -43,11 +44,7 @@ func (s SupabaseAuthenticator) Login(c echo.Context) error {
 		LoggedIn: true,
 	}
 
-	// Register uuid.UUID with gob
-	gob.Register(uuid.UUID{})
-
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), auth.WitsSessionName)
+	session := s.loginSession(c)
 	session.Values[auth.AccessTokenCookieName] = resp.AccessToken
 	session.Values[auth.RefreshTokenCookieName] = resp.RefreshToken
 	session.Values[types.UserContextKey] = authenticatedUser.Email
@@ -56,13 +53,17 @@ func (s SupabaseAuthenticator) Login(c echo.Context) error {
 	if cookieErr != nil {
 		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Saving session failed with", "error", cookieErr)
 	}
+	s.audit.Record(c, types.AuditEvent{Action: types.AuditActionLogin, ActorID: authenticatedUser.ID, Email: authenticatedUser.Email})
 
 	slog.Info("✅ 🛰️  (pkg/handler/auth_supabase.go) SupabaseAuthenticator.Login() -> 🔀 Redirecting to dashboard")
 	return hxRedirect(c, "/dashboard")
 }
 
 // SupabaseRegistrator is an interface for the user registration, when using a remote Supabase database.
-type SupabaseRegistrator struct{}
+type SupabaseRegistrator struct {
+	deps
+	client *supabase.Client
+}
 
 // Register logs in the user with the remote Supabase database.
 func (s SupabaseRegistrator) Register(c echo.Context) error {
@@ -80,7 +81,7 @@ func (s SupabaseRegistrator) Register(c echo.Context) error {
 		}))
 	}
 	// Call Supabase to sign up
-	resp, err := storage.SupabaseClient.Auth.SignUp(c.Request().Context(), supabase.UserCredentials{Email: params.Email, Password: params.Password})
+	resp, err := s.client.Auth.SignUp(c.Request().Context(), supabase.UserCredentials{Email: params.Email, Password: params.Password})
 	if err != nil {
 		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Signing user up with Supabase failed with", "error", err)
 		return render(c, authview.RegisterForm(params, authview.RegisterErrors{
@@ -88,12 +89,16 @@ func (s SupabaseRegistrator) Register(c echo.Context) error {
 		}))
 	}
 	slog.Info("🆗 🛰️  (pkg/handler/auth_supabase.go)  🔓 User has been signed up with Supabase with", "email", resp.Email)
+	s.audit.Record(c, types.AuditEvent{Action: types.AuditActionRegister, ActorID: uuid.MustParse(resp.ID), Email: resp.Email})
 	slog.Info("✅ 🛰️  (pkg/handler/auth_supabase.go) SupabaseRegistrator.Register() -> 🔀 User has been registered, rendering success page")
 	return render(c, authview.RegisterSuccess(resp.Email))
 }
 
 // SupabaseVerifier is a struct for the user verification, when using a remote Supabase database.
-type SupabaseVerifier struct{}
+type SupabaseVerifier struct {
+	deps
+	client *supabase.Client
+}
 
 // Verify verifies the user with the remote Supabase database.
 func (s SupabaseVerifier) Verify(c echo.Context) error {
@@ -104,21 +109,17 @@ func (s SupabaseVerifier) Verify(c echo.Context) error {
 	}
 	slog.Info("🆗 🛰️  (pkg/handler/auth_supabase.go)  🔑 Parsed URL with access_token")
 
-	resp, err := storage.SupabaseClient.Auth.User(c.Request().Context(), accessToken)
+	resp, err := s.client.Auth.User(c.Request().Context(), accessToken)
 	if err != nil {
 		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Getting user from Supabase failed with", "error", err)
 		return nil
 	}
 	slog.Info("🆗 🛰️  (pkg/handler/auth_supabase.go)  🔓 User has been verified with", "email", resp.Email)
 
-	// Register uuid.UUID with gob
-	gob.Register(uuid.UUID{})
-
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-	session, _ := store.Get(c.Request(), auth.WitsSessionName)
+	session := s.loginSession(c)
 	session.Values[auth.AccessTokenCookieName] = accessToken
 	session.Values[types.UserContextKey] = resp.Email
-	session.Values[types.UserIdKey] = resp.ID
+	session.Values[types.UserIdKey] = uuid.MustParse(resp.ID)
 	cookieErr := session.Save(c.Request(), c.Response())
 	if cookieErr != nil {
 		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Saving session failed with", "error", cookieErr)
@@ -127,3 +128,95 @@ func (s SupabaseVerifier) Verify(c echo.Context) error {
 	slog.Info("✅ 🛰️ (pkg/handler/auth_supabase.go) SupabaseVerifier.Verify() -> 🔀 Redirecting to index")
 	return c.Redirect(http.StatusSeeOther, "/")
 }
+
+// SupabasePasswordChanger is a struct for changing the password, when using a remote Supabase database.
+type SupabasePasswordChanger struct {
+	deps
+	client *supabase.Client
+}
+
+// ChangePassword checks the current password by signing in with Supabase and changes the password with the fresh
+// access token. All other sessions of the user are logged out and the personal API tokens are revoked.
+func (s SupabasePasswordChanger) ChangePassword(c echo.Context) error {
+	slog.Info("💬 🛰️  (pkg/handler/auth_supabase.go) SupabasePasswordChanger.ChangePassword()")
+	user := getAuthenticatedUser(c)
+	params := passwordParams(c)
+	if errs, ok := validatePasswordParams(params); !ok {
+		return render(c, settingsview.PasswordForm(params, errs))
+	}
+
+	ctx := c.Request().Context()
+	var resp *supabase.AuthenticatedDetails
+	if wait, err := s.checkCurrentPassword(c, user, func() (err error) {
+		resp, err = s.client.Auth.SignIn(ctx, supabase.UserCredentials{Email: user.Email, Password: params.CurrentPassword})
+		return err
+	}); err != nil {
+		return render(c, settingsview.PasswordForm(params, settingsview.PasswordErrors{
+			CurrentPassword: currentPasswordMessage(err, wait),
+		}))
+	}
+	if _, err := s.client.Auth.UpdateUser(ctx, resp.AccessToken, map[string]interface{}{"password": params.NewPassword}); err != nil {
+		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Updating password with Supabase failed with", "error", err)
+		return render(c, settingsview.PasswordForm(params, settingsview.PasswordErrors{
+			NewPassword: err.Error(),
+		}))
+	}
+
+	session, _ := s.Sessions.Get(c.Request(), auth.WitsSessionName)
+	session.Values[auth.AccessTokenCookieName] = resp.AccessToken
+	session.Values[auth.RefreshTokenCookieName] = resp.RefreshToken
+	if err := session.Save(c.Request(), c.Response()); err != nil {
+		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 🔒 Saving session failed with", "error", err)
+	}
+	s.logoutOtherSessions(c, user)
+	s.revokeTokens(c, user)
+	s.audit.Record(c, types.AuditEvent{Action: types.AuditActionPasswordChange})
+
+	slog.Info("✅ 🛰️  (pkg/handler/auth_supabase.go) SupabasePasswordChanger.ChangePassword() -> 🔑 Password has been changed")
+	return render(c, settingsview.PasswordForm(settingsview.PasswordParams{Success: true}, settingsview.PasswordErrors{}))
+}
+
+// SupabaseEmailChanger is a struct for changing the email, when using a remote Supabase database.
+type SupabaseEmailChanger struct {
+	deps
+	client *supabase.Client
+}
+
+// ChangeEmail checks the current password by signing in with Supabase and requests the change of the email. Supabase
+// sends the verification to the new address and applies the change once it has been confirmed.
+func (s SupabaseEmailChanger) ChangeEmail(c echo.Context) error {
+	slog.Info("💬 🛰️  (pkg/handler/auth_supabase.go) SupabaseEmailChanger.ChangeEmail()")
+	user := getAuthenticatedUser(c)
+	params := emailParams(c)
+	if errs, ok := validateEmailParams(user, params); !ok {
+		return render(c, settingsview.EmailForm(params, errs))
+	}
+
+	ctx := c.Request().Context()
+	var resp *supabase.AuthenticatedDetails
+	if wait, err := s.checkCurrentPassword(c, user, func() (err error) {
+		resp, err = s.client.Auth.SignIn(ctx, supabase.UserCredentials{Email: user.Email, Password: params.CurrentPassword})
+		return err
+	}); err != nil {
+		return render(c, settingsview.EmailForm(params, settingsview.EmailErrors{
+			CurrentPassword: currentPasswordMessage(err, wait),
+		}))
+	}
+	if _, err := s.client.Auth.UpdateUser(ctx, resp.AccessToken, map[string]interface{}{"email": params.Email}); err != nil {
+		slog.Error("🚨 🛰️  (pkg/handler/auth_supabase.go) ❓❓❓❓ 📮 Updating email with Supabase failed with", "error", err)
+		return render(c, settingsview.EmailForm(params, settingsview.EmailErrors{
+			Email: err.Error(),
+		}))
+	}
+
+	s.audit.Record(c, types.AuditEvent{Action: types.AuditActionEmailChange, Details: "requested " + params.Email})
+
+	slog.Info("✅ 🛰️  (pkg/handler/auth_supabase.go) SupabaseEmailChanger.ChangeEmail() -> 📮 Email change has been requested")
+	return render(c, settingsview.EmailForm(settingsview.EmailParams{Email: params.Email, Success: true}, settingsview.EmailErrors{}))
+}
+
+// ConfirmEmail redirects to the settings, as Supabase confirms the new email itself.
+func (s SupabaseEmailChanger) ConfirmEmail(c echo.Context) error {
+	slog.Info("💬 🛰️  (pkg/handler/auth_supabase.go) SupabaseEmailChanger.ConfirmEmail()")
+	return c.Redirect(http.StatusSeeOther, "/settings")
+}
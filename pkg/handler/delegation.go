@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/delegation"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// DelegationHandler provides handlers for inviting delegates, e.g. caregivers or doctors, to an account and for
+// switching between the own and delegated accounts.
+type DelegationHandler struct {
+	deps
+}
+
+// NewDelegationHandler creates a new DelegationHandler using the repositories, and the mailer and session store of
+// the services.
+func NewDelegationHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *DelegationHandler {
+	return &DelegationHandler{deps: newDeps(repos, cfg, svc)}
+}
+
+// HandlePostDelegation responds to POST on the /settings/delegations route by inviting a delegate to the account of
+// the user. The invitation is sent to the email of the delegate.
+func (h DelegationHandler) HandlePostDelegation(c echo.Context) error {
+	slog.Info("💬 🤝 (pkg/handler/delegation.go) HandlePostDelegation()")
+	user := getAuthenticatedUser(c)
+	params := delegationParams(c)
+	if errs, ok := validateDelegationParams(user, params); !ok {
+		return h.renderDelegations(c, user, params, errs)
+	}
+
+	token := randomToken()
+	invitation := types.Delegation{
+		OwnerID:   user.ID,
+		Email:     params.Email,
+		Access:    types.Access(params.Access),
+		TokenHash: storage.HashToken(token),
+	}
+	if err := h.repos.Delegations.CreateDelegation(c.Request().Context(), &invitation); err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 📮 Creating invitation failed with", "error", err)
+		return err
+	}
+	link := fmt.Sprintf("%s://%s/delegations/accept?token=%s", c.Scheme(), c.Request().Host, token)
+	body := fmt.Sprintf("%s has invited you to access their Wits account (%s).\n\nPlease log in or register with this email address and open the following link to accept the invitation:\n\n%s\n\nIf you do not know %s, you can ignore this email.", user.LoginName(), accessLabel(invitation.Access), link, user.LoginName())
+	if err := h.Mailer.Send(params.Email, "Invitation to a Wits account", body); err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 📮 Sending invitation failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionDelegationInvite, Details: params.Email + " with " + params.Access + " access"})
+
+	slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegation() -> 📮 Invitation has been sent to", "email", params.Email)
+	return h.renderDelegations(c, user, settings.DelegationParams{Invited: params.Email}, settings.DelegationErrors{})
+}
+
+// HandlePostDelegationRevoke responds to POST on the /settings/delegations/:id/revoke route by ending a delegation.
+// Owners revoke the access of their delegates, delegates give up their access to the account of the owner.
+func (h DelegationHandler) HandlePostDelegationRevoke(c echo.Context) error {
+	slog.Info("💬 🤝 (pkg/handler/delegation.go) HandlePostDelegationRevoke()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 🤝 Parsing delegation id failed with", "error", err)
+		return c.String(http.StatusBadRequest, "invalid delegation id")
+	}
+	revoked, err := h.repos.Delegations.DeleteDelegationByIDAndUserID(c.Request().Context(), id, user.ID)
+	if err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 🤝 Revoking delegation failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionDelegationRevoke, AccountID: revoked.OwnerID, Details: revoked.Email})
+
+	slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegationRevoke() -> 🤝 Delegation has been revoked with", "id", id)
+	return h.renderDelegations(c, user, settings.DelegationParams{}, settings.DelegationErrors{})
+}
+
+// HandleGetDelegationAccept responds to GET on the /delegations/accept route by showing the invitation of the token.
+func (h DelegationHandler) HandleGetDelegationAccept(c echo.Context) error {
+	slog.Info("💬 🤝 (pkg/handler/delegation.go) HandleGetDelegationAccept()")
+	token := c.QueryParam("token")
+	invitation, err := h.repos.Delegations.GetPendingDelegationByTokenHash(c.Request().Context(), storage.HashToken(token))
+	if err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 📮 Finding invitation failed with", "error", err)
+		return render(c, delegation.Accept(types.Delegation{}, "", ""))
+	}
+	user := getAuthenticatedUser(c)
+	return render(c, delegation.Accept(invitation, token, invitationError(user, invitation)))
+}
+
+// HandlePostDelegationAccept responds to POST on the /delegations/accept route by accepting the invitation of the
+// token. Only the user with the invited email can accept it.
+func (h DelegationHandler) HandlePostDelegationAccept(c echo.Context) error {
+	slog.Info("💬 🤝 (pkg/handler/delegation.go) HandlePostDelegationAccept()")
+	user := getAuthenticatedUser(c)
+	invitation, err := h.repos.Delegations.GetPendingDelegationByTokenHash(c.Request().Context(), storage.HashToken(c.FormValue("token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return render(c, delegation.Accept(types.Delegation{}, "", ""))
+	}
+	if err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 📮 Finding invitation failed with", "error", err)
+		return err
+	}
+	if msg := invitationError(user, invitation); msg != "" {
+		slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegationAccept() -> 🚫 Invitation cannot be accepted", "reason", msg)
+		return render(c, delegation.Accept(invitation, c.FormValue("token"), msg))
+	}
+	if err := h.repos.Delegations.AcceptDelegation(c.Request().Context(), invitation.ID, user.ID); err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 🤝 Accepting invitation failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionDelegationAccept, AccountID: invitation.OwnerID, Details: "account of " + invitation.OwnerEmail})
+
+	slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegationAccept() -> 🔀 Invitation has been accepted, redirecting to settings")
+	return hxRedirect(c, "/settings")
+}
+
+// HandlePostDelegationSwitch responds to POST on the /delegations/switch route by switching to the delegated account
+// of the owner from the form, or back to the own account if no owner is given.
+func (h DelegationHandler) HandlePostDelegationSwitch(c echo.Context) error {
+	slog.Info("💬 🤝 (pkg/handler/delegation.go) HandlePostDelegationSwitch()")
+	user := getAuthenticatedUser(c)
+	session, _ := h.Sessions.Get(c.Request(), auth.WitsSessionName)
+	owner := c.FormValue("owner")
+	if owner == "" {
+		delete(session.Values, types.ActingAsKey)
+	} else {
+		ownerID, err := uuid.Parse(owner)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid account")
+		}
+		granted, err := h.repos.Delegations.GetAcceptedDelegation(c.Request().Context(), ownerID, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegationSwitch() -> 🚫 No delegation to the account found")
+			return c.String(http.StatusForbidden, "you have no access to this account")
+		}
+		if err != nil {
+			slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 🤝 Finding delegation failed with", "error", err)
+			return err
+		}
+		session.Values[types.ActingAsKey] = granted.OwnerID
+		h.audit.Record(c, types.AuditEvent{Action: types.AuditActionDelegationSwitch, AccountID: granted.OwnerID, Details: "account of " + granted.OwnerEmail})
+	}
+	if err := session.Save(c.Request(), c.Response()); err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/delegation.go) ❓❓❓❓ 🍪 Saving session failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🤝 (pkg/handler/delegation.go) HandlePostDelegationSwitch() -> 🔀 Account has been switched, redirecting to dashboard", "owner", owner)
+	return hxRedirect(c, "/dashboard")
+}
+
+// renderDelegations renders the delegations the user has granted and received.
+func (d deps) renderDelegations(c echo.Context, user types.AuthenticatedUser, params settings.DelegationParams, errs settings.DelegationErrors) error {
+	granted, err := d.repos.Delegations.GetDelegationsByOwnerID(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	received, err := d.repos.Delegations.GetAcceptedDelegationsByDelegateID(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	return render(c, settings.Delegations(granted, received, params, errs))
+}
+
+// invitationError returns why the user cannot accept the invitation, if they cannot.
+func invitationError(user types.AuthenticatedUser, invitation types.Delegation) string {
+	if invitation.OwnerID == user.ID {
+		return "You cannot accept an invitation to your own account."
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return "This invitation was sent to " + invitation.Email + ". Please log in with that email address to accept it."
+	}
+	return ""
+}
+
+// accessLabel returns the access level in words.
+func accessLabel(access types.Access) string {
+	if access == types.AccessReadWrite {
+		return "read and write access"
+	}
+	return "read-only access"
+}
+
+// delegationParams returns the parameters of the invite delegate form.
+func delegationParams(c echo.Context) settings.DelegationParams {
+	return settings.DelegationParams{
+		Email:  strings.TrimSpace(c.FormValue("email")),
+		Access: c.FormValue("access"),
+	}
+}
+
+// validateDelegationParams checks the email and access of the invite delegate form.
+func validateDelegationParams(user types.AuthenticatedUser, params settings.DelegationParams) (settings.DelegationErrors, bool) {
+	errs := settings.DelegationErrors{}
+	if _, err := netmail.ParseAddress(params.Email); err != nil {
+		errs.Email = "Please enter a valid email address"
+		return errs, false
+	}
+	if strings.EqualFold(params.Email, user.Email) {
+		errs.Email = "You cannot invite yourself"
+		return errs, false
+	}
+	if _, ok := types.ParseAccess(params.Access); !ok {
+		errs.Access = "Please select the access to grant"
+		return errs, false
+	}
+	return errs, true
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeDelegationRepository keeps the delegations in memory.
+type fakeDelegationRepository struct {
+	storage.DelegationRepository
+	delegations []types.Delegation
+}
+
+func (f *fakeDelegationRepository) GetPendingDelegationByTokenHash(ctx context.Context, tokenHash string) (types.Delegation, error) {
+	for _, d := range f.delegations {
+		if d.TokenHash == tokenHash && !d.Accepted() {
+			return d, nil
+		}
+	}
+	return types.Delegation{}, sql.ErrNoRows
+}
+
+func (f *fakeDelegationRepository) GetAcceptedDelegationsByDelegateID(ctx context.Context, delegateID uuid.UUID) ([]types.Delegation, error) {
+	var accepted []types.Delegation
+	for _, d := range f.delegations {
+		if d.DelegateID == delegateID && d.Accepted() {
+			accepted = append(accepted, d)
+		}
+	}
+	return accepted, nil
+}
+
+func (f *fakeDelegationRepository) AcceptDelegation(ctx context.Context, id uuid.UUID, delegateID uuid.UUID) error {
+	for i := range f.delegations {
+		if f.delegations[i].ID == id {
+			f.delegations[i].DelegateID = delegateID
+			f.delegations[i].AcceptedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func TestValidateDelegationParams(t *testing.T) {
+	user := types.AuthenticatedUser{Email: "owner@wits.example"}
+	tests := []struct {
+		name   string
+		params settings.DelegationParams
+		wantOk bool
+	}{
+		{"Valid invitation should pass", settings.DelegationParams{Email: "caregiver@wits.example", Access: string(types.AccessRead)}, true},
+		{"Read-write invitation should pass", settings.DelegationParams{Email: "caregiver@wits.example", Access: string(types.AccessReadWrite)}, true},
+		{"Invalid email should fail", settings.DelegationParams{Email: "caregiver", Access: string(types.AccessRead)}, false},
+		{"Own email should fail", settings.DelegationParams{Email: "Owner@wits.example", Access: string(types.AccessRead)}, false},
+		{"Unknown access should fail", settings.DelegationParams{Email: "caregiver@wits.example", Access: "admin"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := validateDelegationParams(user, tt.params); ok != tt.wantOk {
+				t.Errorf("validateDelegationParams() ok = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestHandlePostDelegationAccept(t *testing.T) {
+	owner := types.AuthenticatedUser{ID: uuid.New(), Email: "owner@wits.example", LoggedIn: true}
+	tests := []struct {
+		name         string
+		user         types.AuthenticatedUser
+		token        string
+		wantAccepted bool
+	}{
+		{"Invited user should accept the invitation", types.AuthenticatedUser{ID: uuid.New(), Email: "Caregiver@wits.example", LoggedIn: true}, "invitation", true},
+		{"Other user should not accept the invitation", types.AuthenticatedUser{ID: uuid.New(), Email: "other@wits.example", LoggedIn: true}, "invitation", false},
+		{"Owner should not accept their own invitation", owner, "invitation", false},
+		{"Unknown token should not accept the invitation", types.AuthenticatedUser{ID: uuid.New(), Email: "caregiver@wits.example", LoggedIn: true}, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invitation := types.Delegation{ID: uuid.New(), OwnerID: owner.ID, OwnerEmail: owner.Email, Email: "caregiver@wits.example", Access: types.AccessRead, TokenHash: storage.HashToken("invitation")}
+			delegations := &fakeDelegationRepository{delegations: []types.Delegation{invitation}}
+			audit := &fakeAuditRepository{}
+			h := NewDelegationHandler(&storage.Repositories{Delegations: delegations, AuditEvents: audit}, config.Default(), Services{})
+
+			form := url.Values{"token": {tt.token}}
+			req := httptest.NewRequest(http.MethodPost, "/delegations/accept", strings.NewReader(form.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			c.Set(types.UserContextKey, tt.user)
+			if err := h.HandlePostDelegationAccept(c); err != nil {
+				t.Fatalf("HandlePostDelegationAccept() error = %v", err)
+			}
+
+			accepted := delegations.delegations[0]
+			if accepted.Accepted() != tt.wantAccepted || (tt.wantAccepted && accepted.DelegateID != tt.user.ID) {
+				t.Errorf("HandlePostDelegationAccept() delegation = %+v, want accepted = %v by %v", accepted, tt.wantAccepted, tt.user.ID)
+			}
+			if recorded := len(audit.events) == 1 && audit.events[0].Action == types.AuditActionDelegationAccept; recorded != tt.wantAccepted {
+				t.Errorf("HandlePostDelegationAccept() audit events = %+v, want the acceptance recorded = %v", audit.events, tt.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestWithDelegation(t *testing.T) {
+	readOnly := &types.Delegation{OwnerID: uuid.New(), OwnerEmail: "owner@wits.example", Access: types.AccessRead}
+	readWrite := &types.Delegation{OwnerID: uuid.New(), OwnerEmail: "owner@wits.example", Access: types.AccessReadWrite}
+	tests := []struct {
+		name       string
+		method     string
+		actingAs   *types.Delegation
+		wantStatus int
+		wantAudit  bool
+	}{
+		{"Own account should pass without audit", http.MethodPost, nil, http.StatusOK, false},
+		{"Read-only delegation should read", http.MethodGet, readOnly, http.StatusOK, true},
+		{"Read-only delegation should not write", http.MethodPost, readOnly, http.StatusForbidden, true},
+		{"Read-write delegation should write", http.MethodPost, readWrite, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &fakeAuditRepository{}
+			m := NewMiddleware(&storage.Repositories{AuditEvents: audit}, config.Default(), Services{})
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(tt.method, "/dashboard", nil), rec)
+			c.Set(types.UserContextKey, types.AuthenticatedUser{ID: uuid.New(), Email: "caregiver@wits.example", LoggedIn: true, ActingAs: tt.actingAs})
+			next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			if err := m.WithDelegation()(next)(c); err != nil {
+				t.Fatalf("WithDelegation() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("WithDelegation() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if recorded := len(audit.events) == 1 && audit.events[0].Action == types.AuditActionDelegationAccess; recorded != tt.wantAudit {
+				t.Errorf("WithDelegation() audit events = %+v, want the access recorded = %v", audit.events, tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestApplyDelegations(t *testing.T) {
+	delegateID := uuid.New()
+	first := types.Delegation{ID: uuid.New(), OwnerID: uuid.New(), DelegateID: delegateID, AcceptedAt: time.Now()}
+	second := types.Delegation{ID: uuid.New(), OwnerID: uuid.New(), DelegateID: delegateID, AcceptedAt: time.Now()}
+	pending := types.Delegation{ID: uuid.New(), OwnerID: uuid.New(), Email: "caregiver@wits.example"}
+	tests := []struct {
+		name         string
+		role         types.Role
+		actingAs     any
+		wantActingAs *uuid.UUID
+	}{
+		{"Patient should stay on their own account", types.RolePatient, nil, nil},
+		{"Patient should act on the account they switched to", types.RolePatient, second.OwnerID, &second.OwnerID},
+		{"Switch to a pending delegation should be ignored", types.RolePatient, pending.OwnerID, nil},
+		{"Caregiver should act on the first delegated account", types.RoleCaregiver, nil, &first.OwnerID},
+		{"Caregiver should act on the account they switched to", types.RoleCaregiver, second.OwnerID, &second.OwnerID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegations := &fakeDelegationRepository{delegations: []types.Delegation{first, second, pending}}
+			d := newDeps(&storage.Repositories{Delegations: delegations}, config.Default(), Services{})
+			user := types.AuthenticatedUser{ID: delegateID, LoggedIn: true, Account: types.Account{Role: tt.role}}
+			if err := d.applyDelegations(context.Background(), tt.actingAs, &user); err != nil {
+				t.Fatalf("applyDelegations() error = %v", err)
+			}
+			if len(user.Delegations) != 2 {
+				t.Errorf("applyDelegations() delegations = %+v, want the 2 accepted ones", user.Delegations)
+			}
+			switch {
+			case tt.wantActingAs == nil && user.ActingAs != nil:
+				t.Errorf("applyDelegations() acting as = %v, want the own account", user.ActingAs.OwnerID)
+			case tt.wantActingAs != nil && (user.ActingAs == nil || user.ActingAs.OwnerID != *tt.wantActingAs):
+				t.Errorf("applyDelegations() acting as = %+v, want the account of %v", user.ActingAs, *tt.wantActingAs)
+			}
+		})
+	}
+}
@@ -1,13 +1,78 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 
+	"github.com/TheDonDope/wits-server/pkg/audit"
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/mail"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/a-h/templ"
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// errIncorrectPassword is returned when the password of a user does not match their password hash.
+var errIncorrectPassword = errors.New("(pkg/handler/handlers.go) Password is incorrect")
+
+// Services bundles the session store, the login throttler, the mailer and the identity configuration, which are
+// initialized once at startup and injected into the handlers and middlewares next to the repositories.
+type Services struct {
+	Sessions  *storage.PostgresStore
+	Throttler *auth.LoginThrottler
+	Mailer    mail.Mailer
+	Identity  auth.IdentityConfig
+}
+
+// deps bundles the repositories, the audit recorder, the configuration and the services, which are injected into the
+// handlers and identity providers through their constructors.
+type deps struct {
+	Services
+	repos *storage.Repositories
+	audit *audit.Recorder
+	cfg   *config.Config
+}
+
+// newDeps returns the dependencies of a handler using the repositories, the configuration and the services.
+func newDeps(repos *storage.Repositories, cfg *config.Config, svc Services) deps {
+	return deps{Services: svc, repos: repos, audit: audit.NewRecorder(repos.AuditEvents), cfg: cfg}
+}
+
+// authenticateByID returns the user with the id, if the password matches their password hash.
+func (d deps) authenticateByID(ctx context.Context, id uuid.UUID, password string) (types.AuthenticatedUser, error) {
+	user, err := d.repos.Users.GetAuthenticatedUserByID(ctx, id)
+	if err != nil {
+		return types.AuthenticatedUser{}, err
+	}
+	return user, checkPassword(user, password)
+}
+
+// checkPassword returns an error, if the password does not match the password hash of the user.
+func checkPassword(user types.AuthenticatedUser, password string) error {
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		slog.Error("🚨 🤝 (pkg/handler/handlers.go) ❓❓❓❓ 📖 Password is incorrect")
+		return errIncorrectPassword
+	}
+	return nil
+}
+
+// loginSession returns the session of the request for a login, which has been given a new key, so a session key
+// planted before the login is worthless. The caller stores the logged in user and saves it.
+func (d deps) loginSession(c echo.Context) *sessions.Session {
+	session, _ := d.Sessions.Get(c.Request(), auth.WitsSessionName)
+	if err := d.Sessions.Renew(c.Request(), session); err != nil {
+		slog.Error("🚨 🤝 (pkg/handler/handlers.go) ❓❓❓❓ 🍪 Renewing session failed with", "error", err)
+	}
+	return session
+}
+
 // render provides a shorthand function to render the template of a Templ component.
 func render(c echo.Context, component templ.Component) error {
 	return component.Render(c.Request().Context(), c.Response())
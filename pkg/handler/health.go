@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/buildinfo"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// LivenessPath is the path of the liveness probe, which only checks that the process answers.
+	LivenessPath = "/healthz"
+	// ReadinessPath is the path of the readiness probe, which checks the dependencies of the server.
+	ReadinessPath = "/readyz"
+	// VersionPath is the path of the build info of the server.
+	VersionPath = "/version"
+	// readinessTimeout bounds all checks of a readiness probe together.
+	readinessTimeout = 2 * time.Second
+)
+
+// HealthHandler provides the handlers of the health probes of Kubernetes and of the build info.
+type HealthHandler struct {
+	deps
+	client *http.Client
+}
+
+// NewHealthHandler creates a new HealthHandler using the repositories, the configuration and the services.
+func NewHealthHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *HealthHandler {
+	return &HealthHandler{deps: newDeps(repos, cfg, svc), client: &http.Client{Timeout: readinessTimeout}}
+}
+
+// healthResponse is the body of the responses of the probes. Checks maps the checks of the readiness probe to ok or
+// their error.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HandleGetHealthz responds to GET on the /healthz route, as long as the process is able to answer requests.
+func (h HealthHandler) HandleGetHealthz(c echo.Context) error {
+	slog.Debug("💬 🩺 (pkg/handler/health.go) HandleGetHealthz()")
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// HandleGetReadyz responds to GET on the /readyz route with the checks of the dependencies of the server: The
+// database has to answer, its migrations have to be at the version of the embedded ones, and Supabase has to be
+// reachable, if it is used. Any failing check answers with 503 Service Unavailable, so no traffic is routed to the
+// server.
+func (h HealthHandler) HandleGetReadyz(c echo.Context) error {
+	slog.Debug("💬 🩺 (pkg/handler/health.go) HandleGetReadyz()")
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database":   h.repos.Health.Ping,
+		"migrations": h.checkMigrations,
+	}
+	if h.Identity.UsesSupabase() {
+		checks["supabase"] = h.checkSupabase
+	}
+	response := healthResponse{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for name, check := range checks {
+		response.Checks[name] = "ok"
+		if err := check(ctx); err != nil {
+			slog.Error("🚨 🩺 (pkg/handler/health.go) ❓❓❓❓ 🩺 Readiness check failed with", "check", name, "error", err)
+			response.Checks[name] = err.Error()
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	slog.Debug("✅ 🩺 (pkg/handler/health.go) HandleGetReadyz() -> 🩺 Readiness has been checked with", "status", response.Status)
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(status, response)
+}
+
+// HandleGetVersion responds to GET on the /version route with the build info of the server.
+func (h HealthHandler) HandleGetVersion(c echo.Context) error {
+	slog.Info("💬 🩺 (pkg/handler/health.go) HandleGetVersion()")
+	return c.JSON(http.StatusOK, buildinfo.Get())
+}
+
+// checkMigrations checks that the last applied migration is the last embedded one, and has not failed halfway.
+func (h HealthHandler) checkMigrations(ctx context.Context) error {
+	want, err := migrations.LatestVersion(h.cfg.Database.Driver)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := h.repos.Health.MigrationVersion(ctx)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("no migration has been applied, want version %d", want)
+	case err != nil:
+		return err
+	case dirty:
+		return fmt.Errorf("migration %d has failed halfway", version)
+	case version != want:
+		return fmt.Errorf("migrations are at version %d, want %d", version, want)
+	}
+	return nil
+}
+
+// checkSupabase checks that the auth service of Supabase answers its health check.
+func (h HealthHandler) checkSupabase(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(h.cfg.Supabase.URL, "/")+"/auth/v1/health", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", h.cfg.Supabase.Secret.Value())
+	res, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("supabase answered with %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeHealthRepository answers the checks of the database with fixed results.
+type fakeHealthRepository struct {
+	storage.HealthRepository
+	pingErr    error
+	version    uint
+	dirty      bool
+	versionErr error
+}
+
+func (f fakeHealthRepository) Ping(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f fakeHealthRepository) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	return f.version, f.dirty, f.versionErr
+}
+
+func TestHandleGetReadyz(t *testing.T) {
+	latest, err := migrations.LatestVersion("postgres")
+	if err != nil {
+		t.Fatalf("LatestVersion() error = %v", err)
+	}
+	supabaseStatus := http.StatusOK
+	supabase := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/v1/health" || r.Header.Get("apikey") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(supabaseStatus)
+	}))
+	defer supabase.Close()
+
+	tests := []struct {
+		name           string
+		health         fakeHealthRepository
+		providers      []string
+		supabaseStatus int
+		wantStatus     int
+		wantFailing    []string
+	}{
+		{"Migrated database should be ready", fakeHealthRepository{version: latest}, []string{auth.ProviderLocal}, http.StatusOK, http.StatusOK, nil},
+		{"Unreachable database should not be ready", fakeHealthRepository{pingErr: errors.New("connection refused"), versionErr: errors.New("connection refused")}, []string{auth.ProviderLocal}, http.StatusOK, http.StatusServiceUnavailable, []string{"database", "migrations"}},
+		{"Unmigrated database should not be ready", fakeHealthRepository{versionErr: sql.ErrNoRows}, []string{auth.ProviderLocal}, http.StatusOK, http.StatusServiceUnavailable, []string{"migrations"}},
+		{"Outdated migrations should not be ready", fakeHealthRepository{version: latest - 1}, []string{auth.ProviderLocal}, http.StatusOK, http.StatusServiceUnavailable, []string{"migrations"}},
+		{"Dirty migration should not be ready", fakeHealthRepository{version: latest, dirty: true}, []string{auth.ProviderLocal}, http.StatusOK, http.StatusServiceUnavailable, []string{"migrations"}},
+		{"Reachable Supabase should be ready", fakeHealthRepository{version: latest}, []string{auth.ProviderSupabase}, http.StatusOK, http.StatusOK, nil},
+		{"Failing Supabase should not be ready", fakeHealthRepository{version: latest}, []string{auth.ProviderSupabase}, http.StatusBadGateway, http.StatusServiceUnavailable, []string{"supabase"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supabaseStatus = tt.supabaseStatus
+			cfg := config.Default()
+			cfg.Supabase = config.Supabase{URL: supabase.URL + "/", Secret: config.Secret("secret")}
+			h := NewHealthHandler(&storage.Repositories{Health: tt.health}, cfg, Services{Identity: auth.IdentityConfig{Providers: tt.providers}})
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, ReadinessPath, nil), rec)
+			if err := h.HandleGetReadyz(c); err != nil {
+				t.Fatalf("HandleGetReadyz() error = %v", err)
+			}
+			var got healthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("HandleGetReadyz() body = %s, error = %v", rec.Body, err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleGetReadyz() status = %v with %+v, want %v", rec.Code, got, tt.wantStatus)
+			}
+			for name, result := range got.Checks {
+				if failing := slices.Contains(tt.wantFailing, name); (result != "ok") != failing {
+					t.Errorf("HandleGetReadyz() check %s = %q, want failing %v", name, result, failing)
+				}
+			}
+		})
+	}
+}
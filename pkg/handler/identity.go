@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	authview "github.com/TheDonDope/wits-server/pkg/view/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/nedpals/supabase-go"
+)
+
+// PasswordProvider is an identity provider with which users log in with the login form. It also registers users and
+// changes their password and email.
+type PasswordProvider interface {
+	Authenticator
+	Registrator
+	PasswordChanger
+	EmailChanger
+}
+
+// RedirectProvider is an identity provider to which users are redirected to log in. Login redirects to the provider
+// and Verify handles the callback of the provider.
+type RedirectProvider interface {
+	Authenticator
+	Verifier
+}
+
+// LocalPasswordProvider logs in and registers users with a password stored in the local database.
+type LocalPasswordProvider struct {
+	LocalAuthenticator
+	LocalRegistrator
+	LocalPasswordChanger
+	LocalEmailChanger
+}
+
+// SupabasePasswordProvider logs in and registers users with their email and password at Supabase.
+type SupabasePasswordProvider struct {
+	SupabaseAuthenticator
+	SupabaseRegistrator
+	SupabasePasswordChanger
+	SupabaseEmailChanger
+}
+
+// IdentityRegistry holds the enabled identity providers, with which users log in.
+type IdentityRegistry struct {
+	// Password is the provider of the login form, or nil if users can only log in with a redirect provider
+	Password PasswordProvider
+	// Pseudonymous reports whether users can register without an email, which needs local passwords
+	Pseudonymous bool
+	// Verifier verifies users returning from Supabase, or is nil if Supabase is not used
+	Verifier  Verifier
+	redirects map[string]RedirectProvider
+	providers []authview.Provider
+}
+
+// NewIdentityRegistry creates the providers enabled in the identity configuration of the services, using the
+// repositories, the configuration, the services and, if Supabase is used, the Supabase client. A redirect provider
+// which cannot be created, e.g. because its issuer is unreachable, is left out and logged.
+func NewIdentityRegistry(ctx context.Context, repos *storage.Repositories, cfg *config.Config, svc Services, client *supabase.Client) *IdentityRegistry {
+	identity := svc.Identity
+	slog.Info("💬 🪪 (pkg/handler/identity.go) NewIdentityRegistry()", "providers", identity.Providers)
+	r := &IdentityRegistry{redirects: map[string]RedirectProvider{}}
+	d := newDeps(repos, cfg, svc)
+	verifier := SupabaseVerifier{deps: d, client: client}
+	for _, id := range identity.Providers {
+		switch id {
+		case auth.ProviderLocal:
+			r.Password = &LocalPasswordProvider{
+				LocalAuthenticator:   LocalAuthenticator{deps: d},
+				LocalRegistrator:     LocalRegistrator{deps: d},
+				LocalPasswordChanger: LocalPasswordChanger{deps: d},
+				LocalEmailChanger:    LocalEmailChanger{deps: d},
+			}
+			r.Pseudonymous = true
+		case auth.ProviderSupabase:
+			r.Password = &SupabasePasswordProvider{
+				SupabaseAuthenticator:   SupabaseAuthenticator{deps: d, client: client},
+				SupabaseRegistrator:     SupabaseRegistrator{deps: d, client: client},
+				SupabasePasswordChanger: SupabasePasswordChanger{deps: d, client: client},
+				SupabaseEmailChanger:    SupabaseEmailChanger{deps: d, client: client},
+			}
+		case auth.ProviderGoogle:
+			r.register(id, "Google", "fa-google", &GoogleAuthenticator{SupabaseVerifier: verifier})
+		case auth.ProviderOIDC:
+			oidcAuth, err := NewOIDCAuthenticator(ctx, repos, cfg, svc)
+			if err != nil {
+				slog.Error("🚨 🪪 (pkg/handler/identity.go) ❓❓❓❓ 🪪 OpenID Connect login is disabled, creating authenticator failed with", "error", err)
+				continue
+			}
+			r.register(id, cfg.OIDC.ProviderName, "fa-key", oidcAuth)
+		}
+	}
+	if identity.UsesSupabase() {
+		r.Verifier = &verifier
+	}
+	slog.Info("✅ 🪪 (pkg/handler/identity.go) NewIdentityRegistry() -> 🪪 Identity providers are ready", "passwordLogin", r.Password != nil, "redirects", len(r.redirects))
+	return r
+}
+
+// register adds a redirect provider, which is shown on the login page with the name and icon.
+func (r *IdentityRegistry) register(id string, name string, icon string, provider RedirectProvider) {
+	r.redirects[id] = provider
+	r.providers = append(r.providers, authview.Provider{ID: id, Name: name, Icon: icon})
+}
+
+// Redirect returns the enabled redirect provider with the id.
+func (r *IdentityRegistry) Redirect(id string) (RedirectProvider, bool) {
+	provider, ok := r.redirects[id]
+	return provider, ok
+}
+
+// LoginPage returns the login page, with the login form only if a password provider is enabled and a button for
+// every enabled redirect provider.
+func (r *IdentityRegistry) LoginPage() authview.LoginPage {
+	return authview.LoginPage{PasswordLogin: r.Password != nil, Providers: r.providers}
+}
+
+// requirePassword returns a not found error, if no password provider is enabled.
+func (r *IdentityRegistry) requirePassword() error {
+	if r.Password == nil {
+		return echo.ErrNotFound
+	}
+	return nil
+}
@@ -6,47 +6,103 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/TheDonDope/wits-server/pkg/storage"
 	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/google/uuid"
-	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
 )
 
+// apiTokenTouchInterval is the minimum time between two updates of the last used time of a personal API token.
+const apiTokenTouchInterval = time.Minute
+
 // HTTPErrorHandler will be executed when an HTTP request fails.
 func HTTPErrorHandler(err error, c echo.Context) {
 	slog.Error("🚨 🏧 (pkg/handler/middleware.go) ❓❓❓❓ 🛜 HTTP Request failed with", "error", err, "path", c.Request().URL.Path)
 }
 
+// NewIPExtractor returns how Echo extracts the IP address of the client, which the login throttle and the audit log
+// rely on. The X-Forwarded-For header is only trusted from the configured proxies, otherwise the address of the
+// connection is used, so a client can not spoof its address. The proxies have been checked by the validation of the
+// configuration.
+func NewIPExtractor(cfg config.HTTP) echo.IPExtractor {
+	ranges, _ := cfg.TrustedProxyRanges()
+	if len(ranges) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, r := range ranges {
+		options = append(options, echo.TrustIPRange(r))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// Middleware provides the middlewares, which load the user or record to the audit log with the repositories.
+type Middleware struct {
+	deps
+}
+
+// NewMiddleware creates a new Middleware using the repositories and the session store of the services.
+func NewMiddleware(repos *storage.Repositories, cfg *config.Config, svc Services) *Middleware {
+	return &Middleware{deps: newDeps(repos, cfg, svc)}
+}
+
 // WithUser is a middleware that sets the user in the request context.
-func WithUser() echo.MiddlewareFunc {
+func (m *Middleware) WithUser() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			if strings.Contains(c.Request().URL.Path, "/public") || strings.Contains(c.Request().URL.Path, "/favicon.ico") {
 				return next(c)
 			}
+			// The API authenticates every request with its bearer token instead of the session
+			if strings.HasPrefix(c.Request().URL.Path, APIPrefix+"/") {
+				return next(c)
+			}
+			// The probes of Kubernetes have no session, and must not depend on loading one
+			switch c.Request().URL.Path {
+			case LivenessPath, ReadinessPath, VersionPath:
+				return next(c)
+			}
 			slog.Info("💬 🏧 (pkg/handler/middleware.go) WithUser() -> next()", "path", c.Request().URL.Path)
 
 			// Get the authenticatedUser from the request context
 			var authenticatedUser types.AuthenticatedUser
-			store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
-			session, _ := store.Get(c.Request(), auth.WitsSessionName)
-			if session.Values[types.UserContextKey] != nil {
+			session, _ := m.Sessions.Get(c.Request(), auth.WitsSessionName)
+			if bearer, ok := bearerToken(c); ok {
+				user, token, err := m.authenticateAPIToken(c.Request().Context(), bearer)
+				if err != nil {
+					slog.Error("🚨 🏧 (pkg/handler/middleware.go) ❓❓❓❓ 🔑 Authenticating with API token failed with", "error", err)
+					return c.String(http.StatusUnauthorized, "invalid or expired API token")
+				}
+				slog.Info("🆗 🏧 (pkg/handler/middleware.go)  🔑 User found for API token with", "name", token.Name, "email", user.Email)
+				authenticatedUser = user
+				c.Set(types.APITokenContextKey, token)
+			} else if session.Values[types.UserContextKey] != nil {
 				slog.Info("🆗 🏧 (pkg/handler/middleware.go)  🍪 User found in session with", "name", types.UserContextKey, "value", session.Values[types.UserContextKey])
 				authenticatedUser = types.AuthenticatedUser{
 					ID:       session.Values[types.UserIdKey].(uuid.UUID),
-					Email:    session.Values[types.UserContextKey].(string),
 					LoggedIn: true,
 				}
-				account, err := storage.GetAccountByUserID(authenticatedUser.ID)
-				if !errors.Is(err, sql.ErrNoRows) {
+				// The session holds the login name, which is the generated username for pseudonymous users
+				if loginName := session.Values[types.UserContextKey].(string); strings.Contains(loginName, "@") {
+					authenticatedUser.Email = loginName
+				}
+				account, err := m.repos.Accounts.GetAccountByUserID(c.Request().Context(), authenticatedUser.ID)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
 					slog.Error("🚨 🏠 (pkg/handler/middleware.go) ❓❓❓❓ 🔒 Checking if account exists failed with", "error", err)
+					return c.String(http.StatusInternalServerError, "loading the account failed, please try again later")
 				}
 				authenticatedUser.Account = account
+				if authenticatedUser.Disabled() {
+					slog.Info("🆗 🏧 (pkg/handler/middleware.go)  🚫 Account of user has been disabled with", "email", authenticatedUser.Email)
+					authenticatedUser = types.AuthenticatedUser{}
+				} else if err := m.applyDelegations(c.Request().Context(), session.Values[types.ActingAsKey], &authenticatedUser); err != nil {
+					slog.Error("🚨 🏧 (pkg/handler/middleware.go) ❓❓❓❓ 🤝 Loading delegations failed with", "error", err)
+				}
 			}
 
 			// Set the user in the echo.Context
@@ -68,6 +124,87 @@ func WithUser() echo.MiddlewareFunc {
 	}
 }
 
+// WithScope is a middleware that checks if a request authenticated with a personal API token is allowed to access
+// the resource. Reading requires the read scope and changing requires the write scope of the resource. Requests of a
+// session are not restricted.
+func WithScope(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := c.Get(types.APITokenContextKey).(types.APIToken)
+			if !ok {
+				return next(c)
+			}
+			access := types.ScopeWrite
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				access = types.ScopeRead
+			}
+			scope := types.Scope(resource, access)
+			if !token.HasScope(scope) {
+				slog.Info("✅ 🏧 (pkg/handler/middleware.go) WithScope() -> 🚫 API token is missing scope", "scope", scope, "name", token.Name)
+				return c.String(http.StatusForbidden, "API token is missing scope "+scope)
+			}
+			return next(c)
+		}
+	}
+}
+
+// WithSession is a middleware that rejects requests authenticated with a personal API token, e.g. for managing the
+// tokens themselves.
+func WithSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := c.Get(types.APITokenContextKey).(types.APIToken); ok {
+				slog.Info("✅ 🏧 (pkg/handler/middleware.go) WithSession() -> 🚫 API token is not allowed", "path", c.Request().URL.Path)
+				return c.String(http.StatusForbidden, "API tokens are not allowed for this route")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequirePermission is a middleware that checks if the role of the user grants the permission.
+func RequirePermission(permission types.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := getAuthenticatedUser(c)
+			if !user.Can(permission) {
+				slog.Info("✅ 🏧 (pkg/handler/middleware.go) RequirePermission() -> 🚫 User is missing permission", "permission", permission, "role", user.Role(), "email", user.Email)
+				return c.String(http.StatusForbidden, "missing permission "+string(permission))
+			}
+			return next(c)
+		}
+	}
+}
+
+// WithDelegation is a middleware for the routes of account data, which a delegate may access after switching to a
+// delegated account. Every such request is recorded in the audit log, and changes are only allowed with read-write
+// access.
+func (m *Middleware) WithDelegation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := getAuthenticatedUser(c)
+			if user.ActingAs == nil {
+				return next(c)
+			}
+			method := c.Request().Method
+			m.audit.Record(c, types.AuditEvent{
+				Action:  types.AuditActionDelegationAccess,
+				Details: method + " " + c.Request().URL.Path + " on account of " + user.ActingAs.OwnerEmail,
+			})
+			switch method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if !user.ActingAs.CanWrite() {
+					slog.Info("✅ 🏧 (pkg/handler/middleware.go) WithDelegation() -> 🚫 Delegation is read-only", "owner", user.ActingAs.OwnerEmail)
+					return c.String(http.StatusForbidden, "you only have read access to this account")
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
 // WithAuth is a middleware that checks if the user is authenticated.
 func WithAuth() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -88,3 +225,78 @@ func WithAuth() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// bearerToken returns the personal API token from the Authorization header, if any.
+func bearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || !strings.HasPrefix(token, types.APITokenPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// authenticateAPIToken returns the user of an unexpired personal API token and records the usage of the token.
+func (d deps) authenticateAPIToken(ctx context.Context, bearer string) (types.AuthenticatedUser, types.APIToken, error) {
+	token, err := d.repos.APITokens.GetAPITokenByHash(ctx, storage.HashToken(bearer))
+	if err != nil {
+		return types.AuthenticatedUser{}, token, err
+	}
+	if token.Expired() {
+		return types.AuthenticatedUser{}, token, errors.New("API token has expired")
+	}
+	user, err := d.activeUser(ctx, token.UserID)
+	if err != nil {
+		return types.AuthenticatedUser{}, token, err
+	}
+	if time.Since(token.LastUsedAt) > apiTokenTouchInterval {
+		if err := d.repos.APITokens.TouchAPIToken(ctx, token.ID); err != nil {
+			slog.Error("🚨 🏧 (pkg/handler/middleware.go) ❓❓❓❓ 🔑 Updating last used time of API token failed with", "error", err)
+		}
+	}
+	return user, token, nil
+}
+
+// activeUser returns the logged in user with the id together with their account, unless it has been disabled.
+func (d deps) activeUser(ctx context.Context, id uuid.UUID) (types.AuthenticatedUser, error) {
+	user, err := d.repos.Users.GetAuthenticatedUserByID(ctx, id)
+	if err != nil {
+		return types.AuthenticatedUser{}, err
+	}
+	account, err := d.repos.Accounts.GetAccountByUserID(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return types.AuthenticatedUser{}, err
+	}
+	user.Account = account
+	if user.Disabled() {
+		return types.AuthenticatedUser{}, errors.New("account has been disabled")
+	}
+	user.LoggedIn = true
+	return user, nil
+}
+
+// applyDelegations loads the accepted delegations of other accounts to the user, and sets the delegation of the
+// account the user has switched to. A switch to a delegation which has since been revoked is ignored. Users whose role
+// has no own dashboard, i.e. caregivers, act on the first delegated account until they switch to another one.
+func (d deps) applyDelegations(ctx context.Context, actingAs any, user *types.AuthenticatedUser) error {
+	if !user.Role().Can(types.PermissionViewDelegated) {
+		return nil
+	}
+	delegations, err := d.repos.Delegations.GetAcceptedDelegationsByDelegateID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	user.Delegations = delegations
+	if ownerID, ok := actingAs.(uuid.UUID); ok {
+		for i := range delegations {
+			if delegations[i].OwnerID == ownerID {
+				user.ActingAs = &delegations[i]
+				return nil
+			}
+		}
+	}
+	if len(delegations) > 0 && !user.Role().Can(types.PermissionViewDashboard) {
+		user.ActingAs = &delegations[0]
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/labstack/echo/v4"
+)
+
+func TestWithScope(t *testing.T) {
+	readOnly := types.APIToken{Name: "read-only", Scopes: []string{types.Scope(types.ResourceDashboard, types.ScopeRead)}}
+	tests := []struct {
+		name       string
+		method     string
+		token      *types.APIToken
+		wantStatus int
+	}{
+		{"Session request should pass", http.MethodPost, nil, http.StatusOK},
+		{"Token with read scope should read", http.MethodGet, &readOnly, http.StatusOK},
+		{"Token with read scope should not write", http.MethodPost, &readOnly, http.StatusForbidden},
+		{"Token without scope should not read", http.MethodGet, &types.APIToken{Name: "none"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(tt.method, "/dashboard", nil), rec)
+			if tt.token != nil {
+				c.Set(types.APITokenContextKey, *tt.token)
+			}
+			next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			if err := WithScope(types.ResourceDashboard)(next)(c); err != nil {
+				t.Fatalf("WithScope() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("WithScope() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+		wantOk bool
+	}{
+		{"Personal API token should be returned", "Bearer wits_abc", "wits_abc", true},
+		{"Missing header should be ignored", "", "", false},
+		{"Other bearer tokens should be ignored", "Bearer eyJhbGciOi", "", false},
+		{"Other schemes should be ignored", "Basic d2l0czp3aXRz", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+			req.Header.Set(echo.HeaderAuthorization, tt.header)
+			got, ok := bearerToken(echo.New().NewContext(req, httptest.NewRecorder()))
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("bearerToken() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       types.AuthenticatedUser
+		wantStatus int
+	}{
+		{"Admin should manage users", types.AuthenticatedUser{LoggedIn: true, Account: types.Account{Role: types.RoleAdmin}}, http.StatusOK},
+		{"Patient should not manage users", types.AuthenticatedUser{LoggedIn: true, Account: types.Account{Role: types.RolePatient}}, http.StatusForbidden},
+		{"User without account should not manage users", types.AuthenticatedUser{LoggedIn: true}, http.StatusForbidden},
+		{"Anonymous user should not manage users", types.AuthenticatedUser{Account: types.Account{Role: types.RoleAdmin}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin", nil), rec)
+			c.Set(types.UserContextKey, tt.user)
+			next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			if err := RequirePermission(types.PermissionManageUsers)(next)(c); err != nil {
+				t.Fatalf("RequirePermission() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("RequirePermission() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
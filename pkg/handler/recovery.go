@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	authview "github.com/TheDonDope/wits-server/pkg/view/auth"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RecoveryHandler provides handlers for the recovery codes, with which pseudonymous users reset their password in
+// place of a recovery email. It is only available when using a local database.
+type RecoveryHandler struct {
+	deps
+}
+
+// NewRecoveryHandler creates a new RecoveryHandler using the repositories and the login throttler of the services.
+func NewRecoveryHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *RecoveryHandler {
+	return &RecoveryHandler{deps: newDeps(repos, cfg, svc)}
+}
+
+// HandleGetRecoverPassword responds to GET on the /recover-password route by rendering the reset password page.
+func (h RecoveryHandler) HandleGetRecoverPassword(c echo.Context) error {
+	slog.Info("💬 🛟 (pkg/handler/recovery.go) HandleGetRecoverPassword()")
+	return render(c, authview.RecoverPassword())
+}
+
+// HandlePostRecoverPassword responds to POST on the /recover-password route by resetting the password of the user
+// with a recovery code. The code can only be used once and all sessions of the user are logged out. Failed attempts
+// are throttled like failed logins.
+func (h RecoveryHandler) HandlePostRecoverPassword(c echo.Context) error {
+	slog.Info("💬 🛟 (pkg/handler/recovery.go) HandlePostRecoverPassword()")
+	params := authview.RecoverParams{
+		Username:                c.FormValue("username"),
+		RecoveryCode:            auth.NormalizeRecoveryCode(c.FormValue("recovery-code")),
+		NewPassword:             c.FormValue("new-password"),
+		NewPasswordConfirmation: c.FormValue("new-password-confirmation"),
+	}
+	if len(params.NewPassword) < minPasswordLength {
+		return render(c, authview.RecoverPasswordForm(params, authview.RecoverErrors{
+			NewPassword: fmt.Sprintf("The new password must be at least %d characters long", minPasswordLength),
+		}))
+	}
+	if params.NewPassword != params.NewPasswordConfirmation {
+		return render(c, authview.RecoverPasswordForm(params, authview.RecoverErrors{
+			NewPassword: "The passwords do not match",
+		}))
+	}
+
+	ip := c.RealIP()
+	wait, err := h.Throttler.Check(c.Request().Context(), ip, params.Username)
+	if err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🐢 Checking login throttle failed with", "error", err)
+	}
+	if wait > 0 {
+		slog.Info("✅ 🛟 (pkg/handler/recovery.go) HandlePostRecoverPassword() -> 🐢 Recovery is throttled for", "wait", wait)
+		return render(c, authview.RecoverPasswordForm(params, authview.RecoverErrors{LockedOut: lockedOutMessage(wait)}))
+	}
+
+	user, err := h.repos.Users.GetAuthenticatedUserByUsername(c.Request().Context(), params.Username)
+	if err == nil {
+		err = h.repos.RecoveryCodes.UseRecoveryCode(c.Request().Context(), user.ID, storage.HashToken(params.RecoveryCode))
+	}
+	if err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🛟 Checking recovery code failed with", "error", err)
+		recoverErrors := authview.RecoverErrors{InvalidCredentials: "The username or recovery code is invalid"}
+		result, err := h.Throttler.Fail(c.Request().Context(), ip, params.Username)
+		if err != nil {
+			slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🐢 Recording failed recovery failed with", "error", err)
+		}
+		h.audit.Record(c, types.AuditEvent{Action: types.AuditActionRecoveryFailure, Email: params.Username})
+		if result.Wait > 0 {
+			recoverErrors.LockedOut = lockedOutMessage(result.Wait)
+		}
+		return render(c, authview.RecoverPasswordForm(params, recoverErrors))
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), 8)
+	if err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🔒 Hashing password failed with", "error", err)
+		return err
+	}
+	if err := h.repos.Users.UpdateAuthenticatedUserPassword(c.Request().Context(), user.ID, string(hashedPassword)); err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🔒 Updating password failed with", "error", err)
+		return err
+	}
+	if err := h.repos.Sessions.DeleteSessionsByUserID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🍪 Revoking sessions failed with", "error", err)
+	}
+	h.revokeTokens(c, user)
+	if err := h.Throttler.Succeed(c.Request().Context(), params.Username); err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🐢 Resetting login throttle failed with", "error", err)
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionRecoveryCodeUse, ActorID: user.ID, Email: user.LoginName()})
+
+	slog.Info("✅ 🛟 (pkg/handler/recovery.go) HandlePostRecoverPassword() -> 🔑 Password has been reset with a recovery code")
+	return render(c, authview.RecoverPasswordForm(authview.RecoverParams{Success: true}, authview.RecoverErrors{}))
+}
+
+// HandlePostRecoveryCodes responds to POST on the /settings/recovery-codes route by replacing the recovery codes of
+// the user with new ones, after checking the current password. The new codes are only shown once.
+func (h RecoveryHandler) HandlePostRecoveryCodes(c echo.Context) error {
+	slog.Info("💬 🛟 (pkg/handler/recovery.go) HandlePostRecoveryCodes()")
+	user := getAuthenticatedUser(c)
+	if _, err := h.authenticateByID(c.Request().Context(), user.ID, c.FormValue("current-password")); err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🔒 Checking current password failed with", "error", err)
+		left, _ := h.repos.RecoveryCodes.CountUnusedRecoveryCodes(c.Request().Context(), user.ID)
+		return render(c, settings.RecoveryCodes(left, nil, "The current password is incorrect"))
+	}
+	codes, err := replaceRecoveryCodes(c.Request().Context(), h.repos.RecoveryCodes, user.ID)
+	if err != nil {
+		slog.Error("🚨 🛟 (pkg/handler/recovery.go) ❓❓❓❓ 🛟 Replacing recovery codes failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionRecoveryCodesCreate})
+
+	slog.Info("✅ 🛟 (pkg/handler/recovery.go) HandlePostRecoveryCodes() -> 🛟 Recovery codes have been replaced")
+	return render(c, settings.RecoveryCodes(len(codes), codes, ""))
+}
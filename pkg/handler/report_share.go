@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/TheDonDope/wits-server/pkg/view/share"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// reportShareExpiries are the number of days after which a share link to a report can expire.
+var reportShareExpiries = []int{1, 7, 30}
+
+// ReportShareHandler provides handlers for sharing reports with people without an account, e.g. a doctor, through
+// expiring links.
+type ReportShareHandler struct {
+	deps
+}
+
+// NewReportShareHandler creates a new ReportShareHandler using the repositories.
+func NewReportShareHandler(repos *storage.Repositories, cfg *config.Config, svc Services) *ReportShareHandler {
+	return &ReportShareHandler{deps: newDeps(repos, cfg, svc)}
+}
+
+// HandlePostReportShare responds to POST on the /settings/shares route by creating a share link to the report of
+// the user for a date range. The link is only shown once, as just the hash of its token is stored.
+func (h ReportShareHandler) HandlePostReportShare(c echo.Context) error {
+	slog.Info("💬 🔗 (pkg/handler/report_share.go) HandlePostReportShare()")
+	user := getAuthenticatedUser(c)
+	params := reportShareParams(c)
+	errs, ok := validateReportShareParams(params)
+	if ok {
+		reportShare, token, err := h.createReportShare(c, user.ID, params)
+		if err != nil {
+			return err
+		}
+		params = settings.ReportShareParams{Link: shareLink(c, token)}
+		slog.Info("🆗 🔗 (pkg/handler/report_share.go)  🔗 Report share has been created with", "name", reportShare.Name)
+	}
+
+	slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandlePostReportShare() -> 🔗 Rendering report shares")
+	return h.renderReportShares(c, user.ID, params, errs)
+}
+
+// HandlePostReportShareRevoke responds to POST on the /settings/shares/:id/revoke route by revoking a share link of
+// the user. The views of the share are kept.
+func (h ReportShareHandler) HandlePostReportShareRevoke(c echo.Context) error {
+	slog.Info("💬 🔗 (pkg/handler/report_share.go) HandlePostReportShareRevoke()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Parsing report share id failed with", "error", err)
+		return c.String(http.StatusBadRequest, "invalid share id")
+	}
+	if err := h.repos.ReportShares.RevokeReportShare(c.Request().Context(), id, user.ID); err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Revoking report share failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareRevoke, Details: id.String()})
+
+	slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandlePostReportShareRevoke() -> 🔗 Report share has been revoked with", "id", id)
+	return h.renderReportShares(c, user.ID, settings.ReportShareParams{}, settings.ReportShareErrors{})
+}
+
+// HandlePostReportShareDelete responds to POST on the /settings/shares/:id/delete route by moving a share link of the
+// user to the trash. The link stops working right away, but the share can be restored until the trash is purged.
+func (h ReportShareHandler) HandlePostReportShareDelete(c echo.Context) error {
+	slog.Info("💬 🔗 (pkg/handler/report_share.go) HandlePostReportShareDelete()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Parsing report share id failed with", "error", err)
+		return c.String(http.StatusBadRequest, "invalid share id")
+	}
+	if err := h.repos.ReportShares.DeleteReportShare(c.Request().Context(), id, user.ID); err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🗑️  Deleting report share failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareDelete, Details: id.String()})
+
+	slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandlePostReportShareDelete() -> 🗑️  Report share has been moved to the trash with", "id", id)
+	return h.renderReportShares(c, user.ID, settings.ReportShareParams{}, settings.ReportShareErrors{})
+}
+
+// HandlePostReportShareRestore responds to POST on the /settings/shares/:id/restore route by restoring a share link of
+// the user from the trash.
+func (h ReportShareHandler) HandlePostReportShareRestore(c echo.Context) error {
+	slog.Info("💬 🔗 (pkg/handler/report_share.go) HandlePostReportShareRestore()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Parsing report share id failed with", "error", err)
+		return c.String(http.StatusBadRequest, "invalid share id")
+	}
+	if err := h.repos.ReportShares.RestoreReportShare(c.Request().Context(), id, user.ID); err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🗑️  Restoring report share failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareRestore, Details: id.String()})
+
+	slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandlePostReportShareRestore() -> 🗑️  Report share has been restored with", "id", id)
+	return h.renderReportShares(c, user.ID, settings.ReportShareParams{}, settings.ReportShareErrors{})
+}
+
+// createReportShare creates a share link to the report of the owner for the validated parameters. It returns the
+// share together with its token, which is only known until the response, as just the hash of the token is stored.
+func (d deps) createReportShare(c echo.Context, ownerID uuid.UUID, params settings.ReportShareParams) (types.ReportShare, string, error) {
+	token := randomToken()
+	reportShare := types.ReportShare{
+		OwnerID:   ownerID,
+		Name:      params.Name,
+		TokenHash: storage.HashToken(token),
+		ExpiresAt: time.Now().AddDate(0, 0, params.ExpiresInDays),
+	}
+	reportShare.From, _ = time.Parse(time.DateOnly, params.From)
+	reportShare.To, _ = time.Parse(time.DateOnly, params.To)
+	if err := d.repos.ReportShares.CreateReportShare(c.Request().Context(), &reportShare); err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Creating report share failed with", "error", err)
+		return reportShare, "", err
+	}
+	d.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareCreate, Details: fmt.Sprintf("%s %s to %s", reportShare.ID, params.From, params.To)})
+	return reportShare, token, nil
+}
+
+// shareLink returns the link to the shared report with the token.
+func shareLink(c echo.Context, token string) string {
+	return fmt.Sprintf("%s://%s/share/%s", c.Scheme(), c.Request().Host, token)
+}
+
+// renderReportShares renders the share links of the owner together with the trash.
+func (h ReportShareHandler) renderReportShares(c echo.Context, ownerID uuid.UUID, params settings.ReportShareParams, errs settings.ReportShareErrors) error {
+	shares, err := h.repos.ReportShares.GetReportSharesByOwnerID(c.Request().Context(), ownerID)
+	if err != nil {
+		return err
+	}
+	trash, err := h.repos.ReportShares.GetDeletedReportSharesByOwnerID(c.Request().Context(), ownerID)
+	if err != nil {
+		return err
+	}
+	return render(c, settings.ReportShares(shares, trash, params, errs))
+}
+
+// HandleGetSharedReport responds to GET on the public /share/:token route by rendering the shared report. Every view
+// is logged for the owner. Expired, revoked and unknown links all render the same page, so the link tells nothing
+// about the share.
+func (h ReportShareHandler) HandleGetSharedReport(c echo.Context) error {
+	slog.Info("💬 🔗 (pkg/handler/report_share.go) HandleGetSharedReport()")
+	// The link is the only credential, so it must neither be cached nor leak through the referrer
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Referrer-Policy", "no-referrer")
+	c.Response().Header().Set("X-Robots-Tag", "noindex")
+
+	reportShare, err := h.repos.ReportShares.GetReportShareByTokenHash(c.Request().Context(), storage.HashToken(c.Param("token")))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Getting report share failed with", "error", err)
+		return err
+	}
+	if err != nil || !reportShare.Active() {
+		slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandleGetSharedReport() -> 🔗 Report share is unknown, expired or revoked")
+		c.Response().Status = http.StatusNotFound
+		return render(c, share.Unavailable())
+	}
+
+	view := types.ReportShareView{ShareID: reportShare.ID, IPAddress: c.RealIP(), UserAgent: c.Request().UserAgent()}
+	if err := h.repos.ReportShares.CreateReportShareView(c.Request().Context(), &view); err != nil {
+		slog.Error("🚨 🔗 (pkg/handler/report_share.go) ❓❓❓❓ 🔗 Logging report share view failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionReportShareView, AccountID: reportShare.OwnerID, Details: reportShare.ID.String()})
+
+	slog.Info("✅ 🔗 (pkg/handler/report_share.go) HandleGetSharedReport() -> 🔗 Rendering shared report with", "id", reportShare.ID)
+	return render(c, share.Report(reportShare))
+}
+
+// reportShareParams returns the parameters of the create share link form.
+func reportShareParams(c echo.Context) settings.ReportShareParams {
+	params := settings.ReportShareParams{
+		Name: strings.TrimSpace(c.FormValue("name")),
+		From: c.FormValue("from"),
+		To:   c.FormValue("to"),
+	}
+	params.ExpiresInDays, _ = strconv.Atoi(c.FormValue("expires-in-days"))
+	return params
+}
+
+// validateReportShareParams checks the name, date range and expiry of the create share link form.
+func validateReportShareParams(params settings.ReportShareParams) (settings.ReportShareErrors, bool) {
+	errs := settings.ReportShareErrors{}
+	if len(params.Name) == 0 {
+		errs.Name = "Please enter who the report is for"
+		return errs, false
+	}
+	from, err := time.Parse(time.DateOnly, params.From)
+	if err != nil {
+		errs.From = "Please enter a valid start date"
+		return errs, false
+	}
+	to, err := time.Parse(time.DateOnly, params.To)
+	if err != nil {
+		errs.To = "Please enter a valid end date"
+		return errs, false
+	}
+	if to.Before(from) {
+		errs.To = "The end date must not be before the start date"
+		return errs, false
+	}
+	if !slices.Contains(reportShareExpiries, params.ExpiresInDays) {
+		errs.ExpiresInDays = "Please select a valid expiry"
+		return errs, false
+	}
+	return errs, true
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeReportShareRepository keeps report shares and their views in memory. Methods which are not needed by the tests
+// are left to the embedded nil interface.
+type fakeReportShareRepository struct {
+	storage.ReportShareRepository
+	shares map[string]types.ReportShare
+	views  []types.ReportShareView
+	// revokedConcurrently revokes the share just before a revocation of its version
+	revokedConcurrently bool
+}
+
+func (f *fakeReportShareRepository) GetReportShareByTokenHash(ctx context.Context, tokenHash string) (types.ReportShare, error) {
+	s, ok := f.shares[tokenHash]
+	if !ok {
+		return types.ReportShare{}, sql.ErrNoRows
+	}
+	return s, nil
+}
+
+func (f *fakeReportShareRepository) CreateReportShareView(ctx context.Context, view *types.ReportShareView) error {
+	f.views = append(f.views, *view)
+	return nil
+}
+
+// fakeAuditRepository keeps the audit events in memory.
+type fakeAuditRepository struct {
+	storage.AuditRepository
+	events []types.AuditEvent
+}
+
+func (f *fakeAuditRepository) CreateAuditEvent(ctx context.Context, event *types.AuditEvent) error {
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func TestValidateReportShareParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params settings.ReportShareParams
+		wantOk bool
+	}{
+		{"Valid share should pass", settings.ReportShareParams{Name: "Dr. Smith", From: "2026-01-01", To: "2026-01-31", ExpiresInDays: 7}, true},
+		{"Single day should pass", settings.ReportShareParams{Name: "Dr. Smith", From: "2026-01-01", To: "2026-01-01", ExpiresInDays: 1}, true},
+		{"Missing name should fail", settings.ReportShareParams{From: "2026-01-01", To: "2026-01-31", ExpiresInDays: 7}, false},
+		{"Invalid date should fail", settings.ReportShareParams{Name: "Dr. Smith", From: "01.01.2026", To: "2026-01-31", ExpiresInDays: 7}, false},
+		{"End before start should fail", settings.ReportShareParams{Name: "Dr. Smith", From: "2026-01-31", To: "2026-01-01", ExpiresInDays: 7}, false},
+		{"Unlimited expiry should fail", settings.ReportShareParams{Name: "Dr. Smith", From: "2026-01-01", To: "2026-01-31", ExpiresInDays: 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := validateReportShareParams(tt.params); ok != tt.wantOk {
+				t.Errorf("validateReportShareParams() ok = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestHandleGetSharedReport(t *testing.T) {
+	t.Parallel()
+	active := types.ReportShare{ID: uuid.New(), OwnerID: uuid.New(), Name: "Dr. Smith", ExpiresAt: time.Now().Add(time.Hour)}
+	expired := types.ReportShare{ID: uuid.New(), OwnerID: uuid.New(), Name: "Dr. Jones", ExpiresAt: time.Now().Add(-time.Hour)}
+	revoked := types.ReportShare{ID: uuid.New(), OwnerID: uuid.New(), Name: "Dr. Brown", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: time.Now()}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantViews  int
+	}{
+		{"Active share should render the report", "active", http.StatusOK, 1},
+		{"Expired share should be unavailable", "expired", http.StatusNotFound, 0},
+		{"Revoked share should be unavailable", "revoked", http.StatusNotFound, 0},
+		{"Unknown share should be unavailable", "unknown", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			shares := &fakeReportShareRepository{shares: map[string]types.ReportShare{
+				storage.HashToken("active"):  active,
+				storage.HashToken("expired"): expired,
+				storage.HashToken("revoked"): revoked,
+			}}
+			events := &fakeAuditRepository{}
+			h := NewReportShareHandler(&storage.Repositories{ReportShares: shares, AuditEvents: events}, config.Default(), Services{})
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/share/"+tt.token, nil), rec)
+			c.SetParamNames("token")
+			c.SetParamValues(tt.token)
+			if err := h.HandleGetSharedReport(c); err != nil {
+				t.Fatalf("HandleGetSharedReport() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HandleGetSharedReport() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if len(shares.views) != tt.wantViews || len(events.events) != tt.wantViews {
+				t.Errorf("HandleGetSharedReport() views = %v, audit events = %v, want %v", len(shares.views), len(events.events), tt.wantViews)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("HandleGetSharedReport() Cache-Control = %v, want no-store", got)
+			}
+		})
+	}
+}
@@ -1,18 +1,320 @@
 package handler
 
 import (
+	"fmt"
 	"log/slog"
+	netmail "net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/TheDonDope/wits-server/pkg/auth"
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/storage"
+	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/TheDonDope/wits-server/pkg/view/settings"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// minPasswordLength is the minimum length of a new password.
+	minPasswordLength = 8
+	// securityActivityLimit is the number of audit events shown as security activity on the settings page.
+	securityActivityLimit = 25
+)
+
 // SettingsHandler provides handlers for the settings route of the application.
-type SettingsHandler struct{}
+type SettingsHandler struct {
+	deps
+	identity *IdentityRegistry
+}
+
+// NewSettingsHandler creates a new SettingsHandler, changing passwords and emails with the password provider of the
+// registry.
+func NewSettingsHandler(repos *storage.Repositories, cfg *config.Config, svc Services, identity *IdentityRegistry) *SettingsHandler {
+	return &SettingsHandler{deps: newDeps(repos, cfg, svc), identity: identity}
+}
 
 // HandleGetSettings responds to GET on the /settings route by rendering the settings page.
 func (h SettingsHandler) HandleGetSettings(c echo.Context) error {
 	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandleGetSettings()")
 	user := getAuthenticatedUser(c)
-	return render(c, settings.Index(user))
+	page := settings.Page{User: user}
+	var err error
+	if page.Sessions, err = h.getActiveSessions(c, user); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🍪 Listing active sessions failed with", "error", err)
+	}
+	if page.Tokens, err = h.repos.APITokens.GetAPITokensByUserID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Listing API tokens failed with", "error", err)
+	}
+	if page.Granted, err = h.repos.Delegations.GetDelegationsByOwnerID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🤝 Listing delegations failed with", "error", err)
+	}
+	if page.Received, err = h.repos.Delegations.GetAcceptedDelegationsByDelegateID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🤝 Listing delegated accounts failed with", "error", err)
+	}
+	if page.Shares, err = h.repos.ReportShares.GetReportSharesByOwnerID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔗 Listing report shares failed with", "error", err)
+	}
+	if page.Trash, err = h.repos.ReportShares.GetDeletedReportSharesByOwnerID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🗑️  Listing deleted report shares failed with", "error", err)
+	}
+	if page.User.Pseudonymous() {
+		if page.RecoveryCodesLeft, err = h.repos.RecoveryCodes.CountUnusedRecoveryCodes(c.Request().Context(), user.ID); err != nil {
+			slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🛟 Counting recovery codes failed with", "error", err)
+		}
+	}
+	if page.Activity, err = h.repos.AuditEvents.GetAuditEventsByUserID(c.Request().Context(), user.ID, securityActivityLimit); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🗒️  Listing security activity failed with", "error", err)
+	}
+	return render(c, settings.Index(page))
+}
+
+// HandlePostAPIToken responds to POST on the /settings/tokens route by creating a personal API token. The token is
+// only shown once, as just its hash is stored.
+func (h SettingsHandler) HandlePostAPIToken(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostAPIToken()")
+	user := getAuthenticatedUser(c)
+	params := apiTokenParams(c)
+	errs, ok := validateAPITokenParams(params)
+	if ok {
+		secret := types.APITokenPrefix + randomToken()
+		token := types.APIToken{
+			UserID:    user.ID,
+			Name:      params.Name,
+			TokenHash: storage.HashToken(secret),
+			Prefix:    secret[:len(types.APITokenPrefix)+6],
+			Scopes:    params.Scopes,
+		}
+		if params.ExpiresInDays > 0 {
+			token.ExpiresAt = time.Now().AddDate(0, 0, params.ExpiresInDays)
+		}
+		if err := h.repos.APITokens.CreateAPIToken(c.Request().Context(), &token); err != nil {
+			slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Creating API token failed with", "error", err)
+			return err
+		}
+		slog.Info("🆗 🛠️  (pkg/handler/settings.go)  🔑 API token has been created with", "name", token.Name, "scopes", token.Scopes)
+		h.audit.Record(c, types.AuditEvent{Action: types.AuditActionAPITokenCreate, Details: token.Name + " " + strings.Join(token.Scopes, " ")})
+		params = settings.APITokenParams{Secret: secret}
+	}
+	tokens, err := h.repos.APITokens.GetAPITokensByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🛠️  (pkg/handler/settings.go) HandlePostAPIToken() -> 🔑 Rendering API tokens")
+	return render(c, settings.APITokens(tokens, params, errs))
+}
+
+// HandlePostAPITokenRevoke responds to POST on the /settings/tokens/:id/revoke route by revoking a personal API token
+// of the user.
+func (h SettingsHandler) HandlePostAPITokenRevoke(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostAPITokenRevoke()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Parsing API token id failed with", "error", err)
+		return echo.ErrBadRequest
+	}
+	if err := h.repos.APITokens.DeleteAPITokenByIDAndUserID(c.Request().Context(), id, user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Revoking API token failed with", "error", err)
+		return err
+	}
+	h.audit.Record(c, types.AuditEvent{Action: types.AuditActionAPITokenRevoke, Details: id.String()})
+	tokens, err := h.repos.APITokens.GetAPITokensByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 🛠️  (pkg/handler/settings.go) HandlePostAPITokenRevoke() -> 🔑 API token has been revoked with", "id", id)
+	return render(c, settings.APITokens(tokens, settings.APITokenParams{}, settings.APITokenErrors{}))
+}
+
+// HandlePostPassword responds to POST on the /settings/password route by changing the password of the user.
+func (h SettingsHandler) HandlePostPassword(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostPassword()")
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	return h.identity.Password.ChangePassword(c)
+}
+
+// HandlePostEmail responds to POST on the /settings/email route by starting the change of the email of the user.
+func (h SettingsHandler) HandlePostEmail(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostEmail()")
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	return h.identity.Password.ChangeEmail(c)
+}
+
+// HandleGetConfirmEmail responds to GET on the /email/confirm route by applying the change of the email, once the
+// new address has been verified.
+func (h SettingsHandler) HandleGetConfirmEmail(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandleGetConfirmEmail()")
+	if err := h.identity.requirePassword(); err != nil {
+		return err
+	}
+	return h.identity.Password.ConfirmEmail(c)
+}
+
+// HandlePostSessionLogout responds to POST on the /settings/sessions/:id/logout route by revoking a single session
+// of the user. Revoking the current session logs the user out.
+func (h SettingsHandler) HandlePostSessionLogout(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostSessionLogout()")
+	user := getAuthenticatedUser(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🍪 Parsing session id failed with", "error", err)
+		return echo.ErrBadRequest
+	}
+	if err := h.repos.Sessions.DeleteSessionByIDAndUserID(c.Request().Context(), id, user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🍪 Revoking session failed with", "error", err)
+		return err
+	}
+	activeSessions, err := h.getActiveSessions(c, user)
+	if err != nil {
+		return err
+	}
+	if !containsCurrentSession(activeSessions) {
+		slog.Info("✅ 🛠️  (pkg/handler/settings.go) HandlePostSessionLogout() -> 🔀 Current session has been revoked, redirecting to login")
+		return hxRedirect(c, "/login")
+	}
+	slog.Info("✅ 🛠️  (pkg/handler/settings.go) HandlePostSessionLogout() -> 🍪 Session has been revoked with", "id", id)
+	return render(c, settings.SessionList(activeSessions))
+}
+
+// HandlePostSessionsLogout responds to POST on the /settings/sessions/logout route by revoking all sessions of the
+// user, logging them out everywhere.
+func (h SettingsHandler) HandlePostSessionsLogout(c echo.Context) error {
+	slog.Info("💬 🛠️  (pkg/handler/settings.go) HandlePostSessionsLogout()")
+	user := getAuthenticatedUser(c)
+	if err := h.repos.Sessions.DeleteSessionsByUserID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🍪 Revoking all sessions failed with", "error", err)
+		return err
+	}
+	slog.Info("✅ 🛠️  (pkg/handler/settings.go) HandlePostSessionsLogout() -> 🔀 All sessions have been revoked, redirecting to login")
+	return hxRedirect(c, "/login")
+}
+
+// getActiveSessions returns the active sessions of the user, marking the session of the current request.
+func (d deps) getActiveSessions(c echo.Context, user types.AuthenticatedUser) ([]types.Session, error) {
+	activeSessions, err := d.repos.Sessions.GetSessionsByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := d.Sessions.Get(c.Request(), auth.WitsSessionName)
+	currentHash := storage.HashToken(current.ID)
+	for i := range activeSessions {
+		activeSessions[i].Current = activeSessions[i].KeyHash == currentHash
+	}
+	return activeSessions, nil
+}
+
+// containsCurrentSession reports whether the session of the current request is part of the given sessions.
+func containsCurrentSession(activeSessions []types.Session) bool {
+	for _, s := range activeSessions {
+		if s.Current {
+			return true
+		}
+	}
+	return false
+}
+
+// logoutOtherSessions revokes all sessions of the user except the session of the current request.
+func (d deps) logoutOtherSessions(c echo.Context, user types.AuthenticatedUser) {
+	current, _ := d.Sessions.Get(c.Request(), auth.WitsSessionName)
+	if err := d.repos.Sessions.DeleteOtherSessionsByUserID(c.Request().Context(), user.ID, storage.HashToken(current.ID)); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🍪 Revoking other sessions failed with", "error", err)
+	}
+}
+
+// revokeTokens revokes all personal API tokens and access tokens of the user after a change of the password, as they
+// might have been obtained by whoever knew the old one.
+func (d deps) revokeTokens(c echo.Context, user types.AuthenticatedUser) {
+	if err := d.repos.APITokens.DeleteAPITokensByUserID(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Revoking API tokens failed with", "error", err)
+	}
+	if err := d.repos.Accounts.RevokeAccessTokens(c.Request().Context(), user.ID); err != nil {
+		slog.Error("🚨 🛠️  (pkg/handler/settings.go) ❓❓❓❓ 🔑 Revoking access tokens failed with", "error", err)
+	}
+}
+
+// passwordParams returns the parameters of the change password form.
+func passwordParams(c echo.Context) settings.PasswordParams {
+	return settings.PasswordParams{
+		CurrentPassword:         c.FormValue("current-password"),
+		NewPassword:             c.FormValue("new-password"),
+		NewPasswordConfirmation: c.FormValue("new-password-confirmation"),
+	}
+}
+
+// validatePasswordParams checks the new password of the change password form.
+func validatePasswordParams(params settings.PasswordParams) (settings.PasswordErrors, bool) {
+	errs := settings.PasswordErrors{}
+	if len(params.NewPassword) < minPasswordLength {
+		errs.NewPassword = fmt.Sprintf("The new password must be at least %d characters long", minPasswordLength)
+		return errs, false
+	}
+	if params.NewPassword != params.NewPasswordConfirmation {
+		errs.NewPasswordConfirmation = "The passwords do not match"
+		return errs, false
+	}
+	return errs, true
+}
+
+// emailParams returns the parameters of the change email form.
+func emailParams(c echo.Context) settings.EmailParams {
+	return settings.EmailParams{
+		Email:           strings.TrimSpace(c.FormValue("email")),
+		CurrentPassword: c.FormValue("current-password"),
+	}
+}
+
+// validateEmailParams checks the new email of the change email form.
+func validateEmailParams(user types.AuthenticatedUser, params settings.EmailParams) (settings.EmailErrors, bool) {
+	errs := settings.EmailErrors{}
+	if _, err := netmail.ParseAddress(params.Email); err != nil {
+		errs.Email = "Please enter a valid email address"
+		return errs, false
+	}
+	if strings.EqualFold(params.Email, user.Email) {
+		errs.Email = "This is already your email address"
+		return errs, false
+	}
+	return errs, true
+}
+
+// apiTokenParams returns the parameters of the create API token form.
+func apiTokenParams(c echo.Context) settings.APITokenParams {
+	params := settings.APITokenParams{Name: strings.TrimSpace(c.FormValue("name"))}
+	params.ExpiresInDays, _ = strconv.Atoi(c.FormValue("expires-in-days"))
+	form, _ := c.FormParams()
+	for _, resource := range types.APITokenResources {
+		for _, access := range []string{types.ScopeRead, types.ScopeWrite} {
+			scope := types.Scope(resource, access)
+			if slices.Contains(form["scopes"], scope) {
+				params.Scopes = append(params.Scopes, scope)
+			}
+		}
+	}
+	return params
+}
+
+// validateAPITokenParams checks the name and scopes of the create API token form.
+func validateAPITokenParams(params settings.APITokenParams) (settings.APITokenErrors, bool) {
+	errs := settings.APITokenErrors{}
+	if len(params.Name) == 0 {
+		errs.Name = "Please enter a name for the token"
+		return errs, false
+	}
+	if len(params.Scopes) == 0 {
+		errs.Scopes = "Please select at least one scope"
+		return errs, false
+	}
+	if params.ExpiresInDays < 0 {
+		errs.ExpiresInDays = "Please select a valid expiry"
+		return errs, false
+	}
+	return errs, true
 }
@@ -0,0 +1,2 @@
+// Package mail provides sending of emails, e.g. for the verification of email addresses.
+package mail // import "github.com/TheDonDope/wits-server/pkg/mail"
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+)
+
+// Mailer is the interface that wraps the basic Send method.
+type Mailer interface {
+	// Send sends a plain text email
+	Send(to string, subject string, body string) error
+}
+
+// InitMailer returns the mailer of the application. If the SMTP host is configured, emails are sent via SMTP,
+// otherwise they are only written to the log, which is sufficient for development.
+func InitMailer(cfg config.SMTP) (Mailer, error) {
+	slog.Info("💬 📮 (pkg/mail/mail.go) InitMailer()")
+	if cfg.Host == "" {
+		slog.Info("✅ 📮 (pkg/mail/mail.go) InitMailer() -> 🗒️  SMTP_HOST not set, writing emails to the log")
+		return LogMailer{}, nil
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("SMTP_FROM must be set when SMTP_HOST is set")
+	}
+	mailer := SMTPMailer{
+		Addr:     cfg.Host,
+		Username: cfg.User,
+		Password: cfg.Password.Value(),
+		From:     cfg.From,
+	}
+	slog.Info("✅ 📮 (pkg/mail/mail.go) InitMailer() -> 📮 Sending emails via SMTP with", "host", cfg.Host)
+	return mailer, nil
+}
+
+// SMTPMailer sends emails via an SMTP server.
+type SMTPMailer struct {
+	// Addr is the address of the SMTP server (format: `<host>:<port>`)
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send sends a plain text email via the SMTP server.
+func (m SMTPMailer) Send(to string, subject string, body string) error {
+	slog.Info("💬 📮 (pkg/mail/mail.go) SMTPMailer.Send()", "subject", subject)
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, strings.Split(m.Addr, ":")[0])
+	}
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		body,
+	}, "\r\n")
+	err := smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg))
+	slog.Info("✅ 📮 (pkg/mail/mail.go) SMTPMailer.Send() -> 📮 Email sending finished with", "error", err)
+	return err
+}
+
+// LogMailer writes emails to the log instead of sending them.
+type LogMailer struct{}
+
+// Send writes the email to the log.
+func (m LogMailer) Send(to string, subject string, body string) error {
+	slog.Info("📮 📮 (pkg/mail/mail.go) LogMailer.Send() -> 🗒️  Email would have been sent", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package openapi generates the OpenAPI 3.1 document of an API from the descriptions of its routes and the Go types of
+// their requests and responses.
+package openapi // import "github.com/TheDonDope/wits-server/pkg/openapi"
@@ -0,0 +1,172 @@
+package openapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// jsonContentType is the content type of the bodies, unless a body names another.
+	jsonContentType = "application/json"
+	// securitySchemeName is the name of the security scheme of the API.
+	securitySchemeName = "bearer"
+)
+
+// Spec describes an API, whose document is generated from its routes.
+type Spec struct {
+	Info Info
+	// ServerURL is the URL the paths of the routes are relative to, e.g. /api/v1
+	ServerURL string
+	Tags      []Tag
+	// Security is the scheme every route authenticates with, unless it is public
+	Security SecurityScheme
+	// Default is the response of every route for the statuses it does not describe, e.g. a problem
+	Default Body
+	// TrimPrefix is cut from the names of the Go types before they name the schemas, e.g. "api" of apiAccount
+	TrimPrefix string
+}
+
+// Route describes an operation of the API together with the Go types of its request and responses.
+type Route struct {
+	Method string
+	// Path is relative to the server URL with the parameters of Echo, e.g. /report-shares/:id
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tag         string
+	// Public routes do not need the security of the spec
+	Public bool
+	// Parameters are the query and header parameters, the path parameters are generated from the path
+	Parameters []Parameter
+	// Request is a value of the Go type of the JSON body of the request, or nil without a body
+	Request   any
+	Responses map[int]Body
+}
+
+// Body is a response of a route.
+type Body struct {
+	Description string
+	// ContentType is the content type of the body, which defaults to application/json
+	ContentType string
+	// Type is a value of the Go type of the body, or nil without content
+	Type any
+	// Headers maps the names of the headers of the response to their description
+	Headers map[string]string
+}
+
+// Generate returns the OpenAPI document of the API with the routes. It panics, if a Go type cannot be described,
+// e.g. a channel.
+func (s Spec) Generate(routes []Route) *Document {
+	schemas := newSchemas(s.TrimPrefix)
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.Info,
+		Tags:    s.Tags,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{securitySchemeName: s.Security},
+		},
+		Security: []map[string][]string{{securitySchemeName: {}}},
+	}
+	if s.ServerURL != "" {
+		doc.Servers = []Server{{URL: s.ServerURL}}
+	}
+	for _, route := range routes {
+		path, parameters := pathParameters(route.Path)
+		op := &Operation{
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Description: route.Description,
+			Parameters:  append(parameters, route.Parameters...),
+			Responses:   map[string]Response{"default": s.Default.response(schemas, "Error")},
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if route.Public {
+			op.Security = &[]map[string][]string{}
+		}
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{jsonContentType: {Schema: schemas.of(route.Request)}},
+			}
+		}
+		for status, body := range route.Responses {
+			op.Responses[strconv.Itoa(status)] = body.response(schemas, http.StatusText(status))
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+	doc.Components.Schemas = schemas.components
+	return doc
+}
+
+// response returns the response of the body, with its content described by the schema of its Go type. The fallback
+// describes the response, unless the body has a description.
+func (b Body) response(schemas *schemas, fallback string) Response {
+	r := Response{Description: b.Description}
+	if r.Description == "" {
+		r.Description = fallback
+	}
+	if len(b.Headers) > 0 {
+		r.Headers = map[string]Header{}
+		for name, description := range b.Headers {
+			r.Headers[name] = Header{Description: description, Schema: &Schema{Type: "string"}}
+		}
+	}
+	if b.Type != nil {
+		contentType := b.ContentType
+		if contentType == "" {
+			contentType = jsonContentType
+		}
+		r.Content = map[string]MediaType{contentType: {Schema: schemas.of(b.Type)}}
+	}
+	return r
+}
+
+// pathParameters returns the path of the document for a path of Echo, e.g. /report-shares/{id} for
+// /report-shares/:id, together with its required path parameters.
+func pathParameters(echoPath string) (string, []Parameter) {
+	segments := strings.Split(echoPath, "/")
+	var parameters []Parameter
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// Operation returns the operation of the method on the path of Echo, or nil if the document does not describe it.
+func (d *Document) Operation(method string, echoPath string) *Operation {
+	path, _ := pathParameters(strings.TrimPrefix(echoPath, d.serverURL()))
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// serverURL returns the URL the paths of the document are relative to.
+func (d *Document) serverURL() string {
+	if len(d.Servers) == 0 {
+		return ""
+	}
+	return d.Servers[0].URL
+}
+
+// Methods returns the HTTP methods of the operations, ordered like the Echo routes are usually registered.
+func (p PathItem) Methods() []string {
+	order := []string{"get", "post", "put", "patch", "delete", "head", "options"}
+	var methods []string
+	for method := range p {
+		methods = append(methods, method)
+	}
+	slices.SortFunc(methods, func(a, b string) int {
+		return slices.Index(order, a) - slices.Index(order, b)
+	})
+	return methods
+}
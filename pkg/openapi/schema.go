@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	uuidType          = reflect.TypeFor[uuid.UUID]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// schemas collects the schemas of the Go types. Every struct type becomes a component, which the other schemas
+// reference.
+type schemas struct {
+	trimPrefix string
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+// newSchemas returns an empty collection of schemas. The trim prefix is cut from the names of the Go types, before
+// they name the components.
+func newSchemas(trimPrefix string) *schemas {
+	return &schemas{trimPrefix: trimPrefix, components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema of the Go type of the value, which is a reference for structs. The properties of a struct
+// are named by their json tags, and are required unless they are omitted when empty. The format and enum tags of a
+// field refine its schema, e.g. `format:"date"` or `enum:"active,revoked"`.
+func (s *schemas) of(v any) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := s.schema(t.Elem())
+		if elem.Ref != "" {
+			return &Schema{AnyOf: []*Schema{elem, {Type: "null"}}}
+		}
+		elem.Type = []string{fmt.Sprint(elem.Type), "null"}
+		return elem
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// component adds the schema of the struct type to the components, unless it is there already, and returns its name.
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := s.name(t)
+	if _, taken := s.components[name]; taken {
+		panic(fmt.Sprintf("openapi: types %s and %s share the schema name %s", t, s.typeNamed(name), name))
+	}
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	// Register the component before its fields, so the struct may reference itself
+	s.names[t] = name
+	s.components[name] = schema
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		property, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if property == "-" {
+			continue
+		}
+		if property == "" {
+			property = field.Name
+		}
+		fieldSchema := s.schema(field.Type)
+		if format := field.Tag.Get("format"); format != "" {
+			fieldSchema.Format = format
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			fieldSchema.Enum = strings.Split(enum, ",")
+		}
+		schema.Properties[property] = fieldSchema
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, property)
+		}
+	}
+	return name
+}
+
+// name returns the name of the component of the struct type, which is its exported name without the trim prefix.
+// Generic types are prefixed with their type arguments, e.g. ReportShareListResponse for
+// listResponse[apiReportShare].
+func (s *schemas) name(t reflect.Type) string {
+	base, args, generic := strings.Cut(t.Name(), "[")
+	name := s.exported(base)
+	if generic {
+		var prefix string
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			prefix += s.exported(arg[strings.LastIndex(arg, ".")+1:])
+		}
+		name = prefix + name
+	}
+	return name
+}
+
+// exported returns the name of a Go type without the trim prefix and with an upper case first letter.
+func (s *schemas) exported(name string) string {
+	if rest, ok := strings.CutPrefix(name, s.trimPrefix); ok && s.trimPrefix != "" {
+		if r, _ := utf8.DecodeRuneInString(rest); unicode.IsUpper(r) {
+			name = rest
+		}
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// typeNamed returns the Go type of the component with the name.
+func (s *schemas) typeNamed(name string) reflect.Type {
+	for t, n := range s.names {
+		if n == name {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type apiItem struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Day       string     `json:"day" format:"date"`
+	Status    string     `json:"status" enum:"open,closed"`
+	Parent    *apiItem   `json:"parent"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Tags      []string   `json:"tags"`
+	internal  bool
+}
+
+type page[T any] struct {
+	Data []T `json:"data"`
+}
+
+func TestSchemasOf(t *testing.T) {
+	s := newSchemas("api")
+	got := s.of(page[apiItem]{})
+	if got.Ref != "#/components/schemas/ItemPage" {
+		t.Fatalf("of() = %+v, want a reference to ItemPage", got)
+	}
+
+	item, _ := json.Marshal(s.components["Item"])
+	want := `{"type":"object","properties":{` +
+		`"day":{"type":"string","format":"date"},` +
+		`"deleted_at":{"type":["string","null"],"format":"date-time"},` +
+		`"id":{"type":"string","format":"uuid"},` +
+		`"name":{"type":"string"},` +
+		`"parent":{"anyOf":[{"$ref":"#/components/schemas/Item"},{"type":"null"}]},` +
+		`"status":{"type":"string","enum":["open","closed"]},` +
+		`"tags":{"type":"array","items":{"type":"string"}}},` +
+		`"required":["id","day","status","parent","tags"],"additionalProperties":false}`
+	if string(item) != want {
+		t.Errorf("components[Item] = %s, want %s", item, want)
+	}
+}
+
+func TestSpecGenerate(t *testing.T) {
+	spec := Spec{Info: Info{Title: "Test", Version: "1"}, ServerURL: "/api/v1", TrimPrefix: "api"}
+	doc := spec.Generate([]Route{
+		{Method: "GET", Path: "/items/:id", OperationID: "getItem", Responses: map[int]Body{200: {Type: apiItem{}}}},
+		{Method: "POST", Path: "/login", OperationID: "login", Public: true, Request: apiItem{}},
+	})
+
+	op := doc.Operation("GET", "/api/v1/items/:id")
+	if op == nil || op.OperationID != "getItem" {
+		t.Fatalf("Operation() = %+v, want getItem", op)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+		t.Errorf("Operation().Parameters = %+v, want the required path parameter id", op.Parameters)
+	}
+	if op.Responses["200"].Description != "OK" || op.Security != nil {
+		t.Errorf("Operation() = %+v, want the OK response with the security of the document", op)
+	}
+	if login := doc.Operation("POST", "/api/v1/login"); login.Security == nil || len(*login.Security) != 0 || login.RequestBody == nil {
+		t.Errorf("Operation() = %+v, want a public operation with a request body", login)
+	}
+}
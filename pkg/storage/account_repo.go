@@ -3,24 +3,80 @@ package storage
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/TheDonDope/wits-server/pkg/types"
 	"github.com/google/uuid"
+	"github.com/uptrace/bun"
 )
 
+// BunAccountRepository is the AccountRepository backed by the accounts table.
+type BunAccountRepository struct {
+	db bun.IDB
+}
+
+// NewBunAccountRepository returns a new BunAccountRepository using the database connection.
+func NewBunAccountRepository(db bun.IDB) *BunAccountRepository {
+	return &BunAccountRepository{db: db}
+}
+
 // GetAccountByUserID retrieves an account by the user ID
-func GetAccountByUserID(userID uuid.UUID) (types.Account, error) {
+func (r *BunAccountRepository) GetAccountByUserID(ctx context.Context, userID uuid.UUID) (types.Account, error) {
 	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) GetAccountByUserID()")
 	var account types.Account
-	err := BunDB.NewSelect().Model(&account).Where("user_id = ?", userID).Scan(context.Background())
+	err := r.db.NewSelect().Model(&account).Where("user_id = ?", userID).Scan(ctx)
 	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) GetAccountByUserID() -> 📂 Account retrieval finished with", "error", err)
 	return account, err
 }
 
+// ExistsAccountWithUsername checks whether an account with the username exists, ignoring case
+func (r *BunAccountRepository) ExistsAccountWithUsername(ctx context.Context, username string) (bool, error) {
+	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) ExistsAccountWithUsername()")
+	exists, err := r.db.NewSelect().Model((*types.Account)(nil)).Where("lower(username) = lower(?)", username).Exists(ctx)
+	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) ExistsAccountWithUsername() -> 📂 Account lookup finished with", "exists", exists, "error", err)
+	return exists, err
+}
+
 // CreateAccount creates an account in the database
-func CreateAccount(account *types.Account) error {
+func (r *BunAccountRepository) CreateAccount(ctx context.Context, account *types.Account) error {
 	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) CreateAccount()")
-	_, err := BunDB.NewInsert().Model(account).Exec(context.Background())
+	_, err := r.db.NewInsert().Model(account).Exec(ctx)
 	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) CreateAccount() -> 📂 Account creation finished with", "error", err)
 	return err
 }
+
+// SaveAccount creates the account of a user or updates its role and disabled state, if the user already has one
+func (r *BunAccountRepository) SaveAccount(ctx context.Context, account *types.Account) error {
+	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) SaveAccount()")
+	account.UpdatedAt = time.Now()
+	_, err := r.db.NewInsert().Model(account).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("role = EXCLUDED.role").
+		Set("disabled_at = EXCLUDED.disabled_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Exec(ctx)
+	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) SaveAccount() -> 📂 Account saving finished with", "error", err)
+	return err
+}
+
+// CountAccountsByRole counts the accounts with the given role
+func (r *BunAccountRepository) CountAccountsByRole(ctx context.Context, role types.Role) (int, error) {
+	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) CountAccountsByRole()")
+	count, err := r.db.NewSelect().Model((*types.Account)(nil)).Where("role = ?", role).Count(ctx)
+	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) CountAccountsByRole() -> 📂 Account count finished with", "role", role, "count", count, "error", err)
+	return count, err
+}
+
+// RevokeAccessTokens rejects all access tokens of the user issued until now
+func (r *BunAccountRepository) RevokeAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	slog.Info("💬 🛰️  (pkg/storage/account_repo.go) RevokeAccessTokens()")
+	now := time.Now()
+	_, err := r.db.NewUpdate().Model((*types.Account)(nil)).
+		Set("tokens_revoked_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	slog.Info("✅ 🛰️  (pkg/storage/account_repo.go) RevokeAccessTokens() -> 📂 Access token revocation finished with", "error", err)
+	return err
+}
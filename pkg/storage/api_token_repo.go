@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunAPITokenRepository is the APITokenRepository backed by the api_tokens table.
+type BunAPITokenRepository struct {
+	db bun.IDB
+}
+
+// NewBunAPITokenRepository returns a new BunAPITokenRepository using the database connection.
+func NewBunAPITokenRepository(db bun.IDB) *BunAPITokenRepository {
+	return &BunAPITokenRepository{db: db}
+}
+
+// CreateAPIToken creates a personal API token in the database
+func (r *BunAPITokenRepository) CreateAPIToken(ctx context.Context, token *types.APIToken) error {
+	slog.Info("💬 💾 (pkg/storage/api_token_repo.go) CreateAPIToken()")
+	_, err := r.db.NewInsert().Model(token).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/api_token_repo.go) CreateAPIToken() -> 📂 API token creation finished with", "error", err)
+	return err
+}
+
+// GetAPITokenByHash retrieves a personal API token by the hash of the token
+func (r *BunAPITokenRepository) GetAPITokenByHash(ctx context.Context, tokenHash string) (types.APIToken, error) {
+	slog.Info("💬 💾 (pkg/storage/api_token_repo.go) GetAPITokenByHash()")
+	var token types.APIToken
+	err := r.db.NewSelect().Model(&token).Where("token_hash = ?", tokenHash).Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/api_token_repo.go) GetAPITokenByHash() -> 📂 API token retrieval finished with", "error", err)
+	return token, err
+}
+
+// GetAPITokensByUserID retrieves all personal API tokens of a user, newest first
+func (r *BunAPITokenRepository) GetAPITokensByUserID(ctx context.Context, userID uuid.UUID) ([]types.APIToken, error) {
+	slog.Info("💬 💾 (pkg/storage/api_token_repo.go) GetAPITokensByUserID()")
+	var tokens []types.APIToken
+	err := r.db.NewSelect().Model(&tokens).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/api_token_repo.go) GetAPITokensByUserID() -> 📂 API token retrieval finished with", "count", len(tokens), "error", err)
+	return tokens, err
+}
+
+// TouchAPIToken records that the personal API token has been used
+func (r *BunAPITokenRepository) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	slog.Debug("💬 💾 (pkg/storage/api_token_repo.go) TouchAPIToken()")
+	_, err := r.db.NewUpdate().Model((*types.APIToken)(nil)).
+		Set("last_used_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	slog.Debug("✅ 💾 (pkg/storage/api_token_repo.go) TouchAPIToken() -> 📂 API token touch finished with", "error", err)
+	return err
+}
+
+// DeleteAPITokenByIDAndUserID revokes a personal API token, as long as it belongs to the given user
+func (r *BunAPITokenRepository) DeleteAPITokenByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/api_token_repo.go) DeleteAPITokenByIDAndUserID()")
+	_, err := r.db.NewDelete().Model((*types.APIToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/api_token_repo.go) DeleteAPITokenByIDAndUserID() -> 📂 API token deletion finished with", "error", err)
+	return err
+}
+
+// DeleteAPITokensByUserID revokes all personal API tokens of a user
+func (r *BunAPITokenRepository) DeleteAPITokensByUserID(ctx context.Context, userID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/api_token_repo.go) DeleteAPITokensByUserID()")
+	_, err := r.db.NewDelete().Model((*types.APIToken)(nil)).Where("user_id = ?", userID).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/api_token_repo.go) DeleteAPITokensByUserID() -> 📂 API token deletion finished with", "error", err)
+	return err
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// auditedTables are the tables whose inserts, updates and deletes by bun queries are recorded in the audit log, e.g.
+// an admin changing the role of an account or disabling it. Logins and other security activity are recorded by the
+// handlers instead.
+var auditedTables = []string{"accounts"}
+
+// AuditHook is a bun.QueryHook which records every change of the audited tables in the audit log. The actor and
+// account are taken from the user in the context of the query, if there is one.
+type AuditHook struct{}
+
+// BeforeQuery is a no-op, changes are recorded after they succeeded.
+func (h AuditHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery records the successful change of an audited table.
+func (h AuditHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Err != nil || event.IQuery == nil {
+		return
+	}
+	var action string
+	switch event.Operation() {
+	case "INSERT":
+		action = "create"
+	case "UPDATE":
+		action = "update"
+	case "DELETE":
+		action = "delete"
+	default:
+		return
+	}
+	table := strings.Trim(event.IQuery.GetTableName(), `"`)
+	if !slices.Contains(auditedTables, table) {
+		return
+	}
+
+	var rows int64
+	if event.Result != nil {
+		rows, _ = event.Result.RowsAffected()
+	}
+	audit := &types.AuditEvent{
+		Action:  table + "." + action,
+		Details: fmt.Sprintf("%d row(s)", rows),
+	}
+	if user, ok := ctx.Value(types.UserContextKey).(types.AuthenticatedUser); ok && user.LoggedIn {
+		audit.ActorID = user.ID
+		audit.AccountID = user.OwnerID()
+		audit.Email = user.Email
+	}
+	if _, err := dbFromContext(ctx, event.DB).NewInsert().Model(audit).Exec(ctx); err != nil {
+		slog.Error("🚨 💾 (pkg/storage/audit_hook.go) ❓❓❓❓ 🗒️  Recording change of table failed with", "table", table, "error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestAuditHook(t *testing.T) {
+	actor := types.AuthenticatedUser{ID: uuid.New(), Email: "actor@foo.org", LoggedIn: true}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		query     func(db *bun.DB, ctx context.Context) error
+		expect    func(m sqlmock.Sqlmock)
+		wantAudit bool
+	}{
+		{
+			"Updating an audited table should be recorded with the actor",
+			context.WithValue(context.Background(), types.UserContextKey, actor),
+			func(db *bun.DB, ctx context.Context) error {
+				_, err := db.NewUpdate().Model((*types.Account)(nil)).Set("role = ?", types.RoleCaregiver).Where("user_id = ?", actor.ID).Exec(ctx)
+				return err
+			},
+			func(m sqlmock.Sqlmock) {
+				m.ExpectExec(regexp.QuoteMeta(`UPDATE "accounts"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+					WithArgs().
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+			},
+			true,
+		},
+		{
+			"Changing a table which is not audited should not be recorded",
+			context.Background(),
+			func(db *bun.DB, ctx context.Context) error {
+				_, err := db.NewDelete().Model((*types.Session)(nil)).Where("user_id = ?", actor.ID).Exec(ctx)
+				return err
+			},
+			func(m sqlmock.Sqlmock) {
+				m.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sessions"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqldb, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock db: %v", err)
+			}
+			defer sqldb.Close()
+			db := bun.NewDB(sqldb, pgdialect.New())
+			db.AddQueryHook(AuditHook{})
+
+			tt.expect(mock)
+			if err := tt.query(db, tt.ctx); err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("AuditHook recorded = %v, unmet expectations: %v", tt.wantAudit, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunAuditRepository is the AuditRepository backed by the audit_events table.
+type BunAuditRepository struct {
+	db bun.IDB
+}
+
+// NewBunAuditRepository returns a new BunAuditRepository using the database connection.
+func NewBunAuditRepository(db bun.IDB) *BunAuditRepository {
+	return &BunAuditRepository{db: db}
+}
+
+// defaultAuditLimit is the number of audit events returned, if no limit is given.
+const defaultAuditLimit = 100
+
+// CreateAuditEvent appends an event to the audit log
+func (r *BunAuditRepository) CreateAuditEvent(ctx context.Context, event *types.AuditEvent) error {
+	slog.Info("💬 💾 (pkg/storage/audit_repo.go) CreateAuditEvent()", "action", event.Action)
+	_, err := r.db.NewInsert().Model(event).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/audit_repo.go) CreateAuditEvent() -> 📂 Audit event creation finished with", "error", err)
+	return err
+}
+
+// GetAuditEventsByUserID retrieves the latest audit events which the user performed or which affected the account
+// of the user, newest first
+func (r *BunAuditRepository) GetAuditEventsByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]types.AuditEvent, error) {
+	slog.Info("💬 💾 (pkg/storage/audit_repo.go) GetAuditEventsByUserID()")
+	var events []types.AuditEvent
+	err := r.db.NewSelect().Model(&events).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("actor_id = ?", userID).WhereOr("account_id = ?", userID)
+		}).
+		Order("created_at DESC").
+		Limit(limit).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/audit_repo.go) GetAuditEventsByUserID() -> 📂 Audit event retrieval finished with", "count", len(events), "error", err)
+	return events, err
+}
+
+// SearchAuditEvents retrieves the audit events matching the filter, newest first
+func (r *BunAuditRepository) SearchAuditEvents(ctx context.Context, filter types.AuditFilter) ([]types.AuditEvent, error) {
+	slog.Info("💬 💾 (pkg/storage/audit_repo.go) SearchAuditEvents()", "filter", filter)
+	var events []types.AuditEvent
+	q := r.db.NewSelect().Model(&events)
+	if filter.Action != "" {
+		q = q.Where("action LIKE ?", filter.Action+"%")
+	}
+	if filter.Email != "" {
+		q = q.Where("lower(email) LIKE lower(?)", "%"+filter.Email+"%")
+	}
+	if filter.IPAddress != "" {
+		q = q.Where("ip_address = ?", filter.IPAddress)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("created_at < ?", filter.To)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	err := q.Order("created_at DESC").Limit(limit).Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/audit_repo.go) SearchAuditEvents() -> 📂 Audit event search finished with", "count", len(events), "error", err)
+	return events, err
+}
+
+// auditEventSortColumns are the columns by which the audit events can be sorted.
+var auditEventSortColumns = map[string]string{
+	"created_at": "created_at",
+	"action":     "action",
+}
+
+// ListAuditEventsByUserID retrieves a page of the audit events which the user performed or which affected the
+// account of the user. The action filter matches the prefix of the action, the created_after and created_before
+// filters the time of the event.
+func (r *BunAuditRepository) ListAuditEventsByUserID(ctx context.Context, userID uuid.UUID, opts types.ListOptions) (types.Page[types.AuditEvent], error) {
+	slog.Info("💬 💾 (pkg/storage/audit_repo.go) ListAuditEventsByUserID()", "options", opts)
+	var events []types.AuditEvent
+	q := r.db.NewSelect().Model(&events).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("actor_id = ?", userID).WhereOr("account_id = ?", userID)
+		})
+	if action := opts.Filters["action"]; action != "" {
+		q = q.Where("action LIKE ?", action+"%")
+	}
+	if after, ok := filterTime(opts, "created_after"); ok {
+		q = q.Where("created_at >= ?", after)
+	}
+	if before, ok := filterTime(opts, "created_before"); ok {
+		q = q.Where("created_at < ?", before)
+	}
+	page, err := listPage(ctx, q, &events, opts, auditEventSortColumns, "id")
+	slog.Info("✅ 💾 (pkg/storage/audit_repo.go) ListAuditEventsByUserID() -> 📂 Audit event listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
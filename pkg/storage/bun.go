@@ -1,18 +1,14 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
-	"log/slog"
-	"os"
-	"strings"
 
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/extra/bundebug"
-
-	_ "github.com/lib/pq" // Importing the postgres driver
+	"github.com/uptrace/bun/schema"
 )
 
 const (
@@ -22,47 +18,29 @@ const (
 	DBTypeRemote = "remote"
 )
 
-// BunDB is the global database connection
-var BunDB *bun.DB
+const (
+	// DBDriverPostgres is the driver of a Postgres database, which is the default
+	DBDriverPostgres = "postgres"
+	// DBDriverSQLite is the driver of a SQLite database file, e.g. for a single user or development
+	DBDriverSQLite = "sqlite"
+)
 
-// CreatePostgresDB creates a new database connection
-func CreatePostgresDB(dbname string, dbuser string, dbpassword string, dbhost string) (*sql.DB, error) {
-	slog.Info("💬 💾 (pkg/storage/bun.go) CreatePostgresDB()")
-	hostArr := strings.Split(dbhost, ":")
-	host := hostArr[0]
-	port := "5432"
-	if len(hostArr) > 1 {
-		port = hostArr[1]
+// NewBun opens the bun database connection with the driver of the configuration, which is passed on to the
+// repositories
+func NewBun(ctx context.Context, cfg config.Database) (*bun.DB, error) {
+	if cfg.Driver == DBDriverSQLite {
+		return NewBunWithSQLite(cfg.Path)
 	}
-	uri := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=disable", dbuser, dbpassword, dbname, host, port)
-	db, err := sql.Open("postgres", uri)
-	if err != nil {
-		slog.Info("🚨 💾 (pkg/storage/bun.go) ❓❓❓❓ 📂 Failed to create Postgresql db connection with", "error", err)
-		return nil, err
-	}
-	slog.Info("✅ 💾 (pkg/storage/bun.go) CreatePostgresDB() -> 📂 Successfully created Postgresql db connection with", "host", dbhost)
-	return db, nil
+	return NewBunWithPostgres(ctx, cfg)
 }
 
-// InitBunWithPostgres initializes the bun database connection
-func InitBunWithPostgres() error {
-	slog.Info("💬 💾 (pkg/storage/bun.go) InitBunWithPostgres()")
-	var (
-		host   = os.Getenv("DB_HOST")
-		user   = os.Getenv("DB_USER")
-		pass   = os.Getenv("DB_PASSWORD")
-		dbname = os.Getenv("DB_NAME")
-	)
-	db, err := CreatePostgresDB(dbname, user, pass, host)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := db.Ping(); err != nil {
-		slog.Info("🚨 💾 (pkg/storage/bun.go) ❓❓❓❓ 📂 Failed to ping Postgresql db with", "error", err)
-		return err
+// newBun wraps the database with bun for the dialect and adds the query hooks
+func newBun(db *sql.DB, d schema.Dialect) *bun.DB {
+	bunDB := bun.NewDB(db, d)
+	if d.Name() == dialect.SQLite {
+		useSQLiteUsersTable(bunDB)
 	}
-	BunDB = bun.NewDB(db, pgdialect.New())
-	BunDB.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
-	slog.Info("✅ 💾 (pkg/storage/bun.go) InitBunWithPostgres() -> 📂 Successfully initialized Bun with Postgres db")
-	return nil
+	bunDB.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+	bunDB.AddQueryHook(AuditHook{})
+	return bunDB
 }
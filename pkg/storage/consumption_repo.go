@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunConsumptionRepository is the ConsumptionRepository backed by the consumptions table. The notes are health data,
+// so they are encrypted with the keyring.
+type BunConsumptionRepository struct {
+	db      bun.IDB
+	keyring *Keyring
+}
+
+// NewBunConsumptionRepository returns a new BunConsumptionRepository using the database connection and keyring.
+func NewBunConsumptionRepository(db bun.IDB, keyring *Keyring) *BunConsumptionRepository {
+	return &BunConsumptionRepository{db: db, keyring: keyring}
+}
+
+// CreateConsumption creates a consumption in the database
+func (r *BunConsumptionRepository) CreateConsumption(ctx context.Context, consumption *types.Consumption) error {
+	slog.Info("💬 💾 (pkg/storage/consumption_repo.go) CreateConsumption()")
+	if consumption.CreatedAt.IsZero() {
+		consumption.CreatedAt = time.Now()
+	}
+	consumption.UpdatedAt = consumption.CreatedAt
+	if err := r.keyring.EncryptFields(ctx, consumption); err != nil {
+		return err
+	}
+	_, err := r.db.NewInsert().Model(consumption).Exec(ctx)
+	if decryptErr := r.keyring.DecryptFields(ctx, consumption); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/consumption_repo.go) CreateConsumption() -> 📂 Consumption creation finished with", "error", err)
+	return err
+}
+
+// consumptionSortColumns are the columns by which the consumptions can be sorted.
+var consumptionSortColumns = map[string]string{
+	"consumed_at": "co.consumed_at",
+	"amount":      "co.amount",
+	"created_at":  "co.created_at",
+}
+
+// ListConsumptionsByOwnerID retrieves a page of the consumptions of an owner. The filters narrow them down to a
+// product, a method and the time of the consumption.
+func (r *BunConsumptionRepository) ListConsumptionsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.Consumption], error) {
+	slog.Info("💬 💾 (pkg/storage/consumption_repo.go) ListConsumptionsByOwnerID()", "options", opts)
+	var consumptions []types.Consumption
+	q := r.db.NewSelect().Model(&consumptions).Where("co.owner_id = ?", ownerID)
+	if productID := opts.Filters["product_id"]; productID != "" {
+		q = q.Where("co.product_id = ?", productID)
+	}
+	if method := opts.Filters["method"]; method != "" {
+		q = q.Where("co.method = ?", method)
+	}
+	if after, ok := filterTime(opts, "consumed_after"); ok {
+		q = q.Where("co.consumed_at >= ?", after)
+	}
+	if before, ok := filterTime(opts, "consumed_before"); ok {
+		q = q.Where("co.consumed_at < ?", before)
+	}
+	page, err := listPage(ctx, q, &consumptions, opts, consumptionSortColumns, "co.id")
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &page.Items)
+	}
+	slog.Info("✅ 💾 (pkg/storage/consumption_repo.go) ListConsumptionsByOwnerID() -> 📂 Consumption listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
+
+// GetConsumptionByIDAndOwnerID retrieves a consumption, as long as it belongs to the given owner
+func (r *BunConsumptionRepository) GetConsumptionByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.Consumption, error) {
+	slog.Info("💬 💾 (pkg/storage/consumption_repo.go) GetConsumptionByIDAndOwnerID()")
+	var consumption types.Consumption
+	err := r.db.NewSelect().Model(&consumption).
+		Where("co.id = ?", id).
+		Where("co.owner_id = ?", ownerID).
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &consumption)
+	}
+	slog.Info("✅ 💾 (pkg/storage/consumption_repo.go) GetConsumptionByIDAndOwnerID() -> 📂 Consumption retrieval finished with", "error", err)
+	return consumption, err
+}
+
+// UpdateConsumptionVersion updates the version of a consumption, which has been read before with the UpdatedAt it
+// carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the consumption has been changed or
+// deleted since.
+func (r *BunConsumptionRepository) UpdateConsumptionVersion(ctx context.Context, consumption *types.Consumption) error {
+	slog.Info("💬 💾 (pkg/storage/consumption_repo.go) UpdateConsumptionVersion()")
+	readAt := consumption.UpdatedAt
+	consumption.UpdatedAt = time.Now()
+	if err := r.keyring.EncryptFields(ctx, consumption); err != nil {
+		return err
+	}
+	err := updateVersion(ctx, r.db, consumption, consumption.ID, consumption.OwnerID, readAt,
+		"product_id", "amount", "method", "consumed_at", "notes", "encrypted", "updated_at")
+	if decryptErr := r.keyring.DecryptFields(ctx, consumption); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/consumption_repo.go) UpdateConsumptionVersion() -> 📂 Consumption update finished with", "error", err)
+	return err
+}
+
+// DeleteConsumptionVersion moves the version of a consumption, which has been read before, to the trash, as long as
+// it belongs to its owner. It returns ErrVersionConflict, if the consumption has been changed or deleted since.
+func (r *BunConsumptionRepository) DeleteConsumptionVersion(ctx context.Context, consumption types.Consumption) error {
+	slog.Info("💬 💾 (pkg/storage/consumption_repo.go) DeleteConsumptionVersion()")
+	err := deleteVersion[types.Consumption](ctx, r.db, consumption.ID, consumption.OwnerID, consumption.UpdatedAt)
+	slog.Info("✅ 💾 (pkg/storage/consumption_repo.go) DeleteConsumptionVersion() -> 📂 Consumption deletion finished with", "error", err)
+	return err
+}
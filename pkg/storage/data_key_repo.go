@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunDataKeyRepository is the DataKeyRepository backed by the data_keys table. The keyring shares it between all
+// repositories, so it runs within the transaction in the context, if there is one, like the query hooks. Otherwise
+// the first data key of an account, created while encrypting within a transaction, would wait for the lock of that
+// transaction on SQLite.
+type BunDataKeyRepository struct {
+	db bun.IDB
+}
+
+// NewBunDataKeyRepository returns a new BunDataKeyRepository using the database connection.
+func NewBunDataKeyRepository(db bun.IDB) *BunDataKeyRepository {
+	return &BunDataKeyRepository{db: db}
+}
+
+// CreateDataKey creates a wrapped data key in the database
+func (r *BunDataKeyRepository) CreateDataKey(ctx context.Context, key *types.DataKey) error {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) CreateDataKey()")
+	_, err := dbFromContext(ctx, r.db).NewInsert().Model(key).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) CreateDataKey() -> 📂 Data key creation finished with", "error", err)
+	return err
+}
+
+// GetDataKey retrieves a data key by its id
+func (r *BunDataKeyRepository) GetDataKey(ctx context.Context, id uuid.UUID) (types.DataKey, error) {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) GetDataKey()")
+	var key types.DataKey
+	err := dbFromContext(ctx, r.db).NewSelect().Model(&key).Where("id = ?", id).Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) GetDataKey() -> 📂 Data key retrieval finished with", "error", err)
+	return key, err
+}
+
+// GetActiveDataKey retrieves the newest data key of an account, which has not been retired
+func (r *BunDataKeyRepository) GetActiveDataKey(ctx context.Context, accountID uuid.UUID) (types.DataKey, error) {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) GetActiveDataKey()")
+	var key types.DataKey
+	err := dbFromContext(ctx, r.db).NewSelect().Model(&key).
+		Where("account_id = ?", accountID).
+		Where("retired_at IS NULL").
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) GetActiveDataKey() -> 📂 Data key retrieval finished with", "error", err)
+	return key, err
+}
+
+// GetDataKeys retrieves all data keys, oldest first
+func (r *BunDataKeyRepository) GetDataKeys(ctx context.Context) ([]types.DataKey, error) {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) GetDataKeys()")
+	var keys []types.DataKey
+	err := dbFromContext(ctx, r.db).NewSelect().Model(&keys).Order("created_at").Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) GetDataKeys() -> 📂 Data keys retrieval finished with", "count", len(keys), "error", err)
+	return keys, err
+}
+
+// UpdateDataKey updates the wrapping and the retirement of a data key
+func (r *BunDataKeyRepository) UpdateDataKey(ctx context.Context, key *types.DataKey) error {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) UpdateDataKey()")
+	_, err := dbFromContext(ctx, r.db).NewUpdate().Model(key).
+		Column("master_key_id", "wrapped_key", "retired_at").
+		WherePK().
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) UpdateDataKey() -> 📂 Data key update finished with", "error", err)
+	return err
+}
+
+// DeleteRetiredDataKeys deletes all retired data keys, once no data is encrypted with them anymore
+func (r *BunDataKeyRepository) DeleteRetiredDataKeys(ctx context.Context) (int64, error) {
+	slog.Info("💬 💾 (pkg/storage/data_key_repo.go) DeleteRetiredDataKeys()")
+	res, err := dbFromContext(ctx, r.db).NewDelete().Model((*types.DataKey)(nil)).Where("retired_at IS NOT NULL").Exec(ctx)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	slog.Info("✅ 💾 (pkg/storage/data_key_repo.go) DeleteRetiredDataKeys() -> 📂 Data key deletion finished with", "count", n, "error", err)
+	return n, err
+}
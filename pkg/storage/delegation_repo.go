@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunDelegationRepository is the DelegationRepository backed by the delegations table.
+type BunDelegationRepository struct {
+	db bun.IDB
+}
+
+// NewBunDelegationRepository returns a new BunDelegationRepository using the database connection.
+func NewBunDelegationRepository(db bun.IDB) *BunDelegationRepository {
+	return &BunDelegationRepository{db: db}
+}
+
+// CreateDelegation creates the invitation of a delegate in the database, replacing an earlier delegation of the
+// owner to the same email
+func (r *BunDelegationRepository) CreateDelegation(ctx context.Context, delegation *types.Delegation) error {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) CreateDelegation()")
+	_, err := r.db.NewDelete().Model((*types.Delegation)(nil)).
+		Where("owner_id = ?", delegation.OwnerID).
+		Where("lower(email) = lower(?)", delegation.Email).
+		Exec(ctx)
+	if err == nil {
+		_, err = r.db.NewInsert().Model(delegation).Exec(ctx)
+	}
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) CreateDelegation() -> 📂 Delegation creation finished with", "error", err)
+	return err
+}
+
+// GetPendingDelegationByTokenHash retrieves a delegation, which has not been accepted yet, by the hash of its
+// invitation token
+func (r *BunDelegationRepository) GetPendingDelegationByTokenHash(ctx context.Context, tokenHash string) (types.Delegation, error) {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) GetPendingDelegationByTokenHash()")
+	var delegation types.Delegation
+	err := r.selectDelegationsWithOwnerEmail(&delegation).
+		Where("d.token_hash = ?", tokenHash).
+		Where("d.accepted_at IS NULL").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) GetPendingDelegationByTokenHash() -> 📂 Delegation retrieval finished with", "error", err)
+	return delegation, err
+}
+
+// GetAcceptedDelegation retrieves the accepted delegation of the owner to the delegate
+func (r *BunDelegationRepository) GetAcceptedDelegation(ctx context.Context, ownerID uuid.UUID, delegateID uuid.UUID) (types.Delegation, error) {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) GetAcceptedDelegation()")
+	var delegation types.Delegation
+	err := r.selectDelegationsWithOwnerEmail(&delegation).
+		Where("d.owner_id = ?", ownerID).
+		Where("d.delegate_id = ?", delegateID).
+		Where("d.accepted_at IS NOT NULL").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) GetAcceptedDelegation() -> 📂 Delegation retrieval finished with", "error", err)
+	return delegation, err
+}
+
+// GetDelegationsByOwnerID retrieves all delegations the owner has granted, including pending invitations
+func (r *BunDelegationRepository) GetDelegationsByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.Delegation, error) {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) GetDelegationsByOwnerID()")
+	var delegations []types.Delegation
+	err := r.selectDelegationsWithOwnerEmail(&delegations).
+		Where("d.owner_id = ?", ownerID).
+		Order("d.created_at DESC").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) GetDelegationsByOwnerID() -> 📂 Delegation retrieval finished with", "count", len(delegations), "error", err)
+	return delegations, err
+}
+
+// GetAcceptedDelegationsByDelegateID retrieves all accepted delegations of other accounts to the delegate
+func (r *BunDelegationRepository) GetAcceptedDelegationsByDelegateID(ctx context.Context, delegateID uuid.UUID) ([]types.Delegation, error) {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) GetAcceptedDelegationsByDelegateID()")
+	var delegations []types.Delegation
+	err := r.selectDelegationsWithOwnerEmail(&delegations).
+		Where("d.delegate_id = ?", delegateID).
+		Where("d.accepted_at IS NOT NULL").
+		Order("owner_email").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) GetAcceptedDelegationsByDelegateID() -> 📂 Delegation retrieval finished with", "count", len(delegations), "error", err)
+	return delegations, err
+}
+
+// AcceptDelegation activates the delegation for the delegate
+func (r *BunDelegationRepository) AcceptDelegation(ctx context.Context, id uuid.UUID, delegateID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) AcceptDelegation()")
+	_, err := r.db.NewUpdate().Model((*types.Delegation)(nil)).
+		Set("delegate_id = ?", delegateID).
+		Set("accepted_at = ?", time.Now()).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("accepted_at IS NULL").
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) AcceptDelegation() -> 📂 Delegation acceptance finished with", "error", err)
+	return err
+}
+
+// DeleteDelegationByIDAndUserID deletes a delegation, as long as the user is either its owner or its delegate
+func (r *BunDelegationRepository) DeleteDelegationByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (types.Delegation, error) {
+	slog.Info("💬 💾 (pkg/storage/delegation_repo.go) DeleteDelegationByIDAndUserID()")
+	var delegation types.Delegation
+	_, err := r.db.NewDelete().Model(&delegation).
+		Where("id = ?", id).
+		WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.Where("owner_id = ?", userID).WhereOr("delegate_id = ?", userID)
+		}).
+		Returning("*").
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/delegation_repo.go) DeleteDelegationByIDAndUserID() -> 📂 Delegation deletion finished with", "error", err)
+	return delegation, err
+}
+
+// selectDelegationsWithOwnerEmail selects delegations together with the current email of their owner, or the username
+// of pseudonymous owners
+func (r *BunDelegationRepository) selectDelegationsWithOwnerEmail(model any) *bun.SelectQuery {
+	return r.db.NewSelect().Model(model).
+		ColumnExpr("d.*").
+		ColumnExpr("coalesce(u.email, a.username) AS owner_email").
+		Join("JOIN ? AS u ON u.id = d.owner_id", usersTable(r.db)).
+		Join("LEFT JOIN accounts AS a ON a.user_id = d.owner_id")
+}
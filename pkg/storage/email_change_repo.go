@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunEmailChangeRepository is the EmailChangeRepository backed by the email_changes table.
+type BunEmailChangeRepository struct {
+	db bun.IDB
+}
+
+// NewBunEmailChangeRepository returns a new BunEmailChangeRepository using the database connection.
+func NewBunEmailChangeRepository(db bun.IDB) *BunEmailChangeRepository {
+	return &BunEmailChangeRepository{db: db}
+}
+
+// CreateEmailChange creates a pending email change in the database, replacing earlier pending changes of the user
+func (r *BunEmailChangeRepository) CreateEmailChange(ctx context.Context, change *types.EmailChange) error {
+	slog.Info("💬 💾 (pkg/storage/email_change_repo.go) CreateEmailChange()")
+	if err := r.DeleteEmailChangesByUserID(ctx, change.UserID); err != nil {
+		return err
+	}
+	_, err := r.db.NewInsert().Model(change).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/email_change_repo.go) CreateEmailChange() -> 📂 Email change creation finished with", "error", err)
+	return err
+}
+
+// GetEmailChangeByTokenHash retrieves an unexpired pending email change by the hash of its verification token
+func (r *BunEmailChangeRepository) GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (types.EmailChange, error) {
+	slog.Info("💬 💾 (pkg/storage/email_change_repo.go) GetEmailChangeByTokenHash()")
+	var change types.EmailChange
+	err := r.db.NewSelect().Model(&change).
+		Where("token_hash = ?", tokenHash).
+		Where("expires_at > ?", time.Now()).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/email_change_repo.go) GetEmailChangeByTokenHash() -> 📂 Email change retrieval finished with", "error", err)
+	return change, err
+}
+
+// DeleteEmailChangesByUserID deletes all pending email changes of a user
+func (r *BunEmailChangeRepository) DeleteEmailChangesByUserID(ctx context.Context, userID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/email_change_repo.go) DeleteEmailChangesByUserID()")
+	_, err := r.db.NewDelete().Model((*types.EmailChange)(nil)).Where("user_id = ?", userID).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/email_change_repo.go) DeleteEmailChangesByUserID() -> 📂 Email change deletion finished with", "error", err)
+	return err
+}
@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+)
+
+// encryptedPrefix starts the encrypted field values, which are stored as enc:v2:<data key id>:<base64 of nonce and
+// ciphertext>. Whether the values of a record are encrypted is not guessed from it, as the plain text values are
+// chosen by the users, but stored with the record.
+const encryptedPrefix = "enc:v2:"
+
+// encryptTag is the struct tag marking the fields of a model, which are encrypted (encrypt:"field"), the field holding
+// the id of the account whose data key encrypts them (encrypt:"owner"), the field holding the id of the record, which
+// the encrypted values are bound to (encrypt:"id"), and the bool field reporting whether they are encrypted
+// (encrypt:"state").
+const encryptTag = "encrypt"
+
+// ErrNoMasterKey is returned when encrypted data is read without ENCRYPTION_MASTER_KEY.
+var ErrNoMasterKey = errors.New("the data is encrypted, but ENCRYPTION_MASTER_KEY is not set")
+
+// MasterKey wraps the data keys. Its id is derived from the key, so the id stored with every data key tells which
+// master key wrapped it.
+type MasterKey struct {
+	ID  string
+	key []byte
+}
+
+// ParseMasterKey decodes a base64 encoded 256 bit master key.
+func ParseMasterKey(encoded string) (MasterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return MasterKey{}, fmt.Errorf("master key is not base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return MasterKey{}, fmt.Errorf("master key has %d bytes, want 32", len(key))
+	}
+	sum := sha256.Sum256(key)
+	return MasterKey{ID: hex.EncodeToString(sum[:8]), key: key}, nil
+}
+
+// GenerateMasterKey returns a new random base64 encoded master key for ENCRYPTION_MASTER_KEY.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Keyring encrypts the sensitive fields of models with AES-GCM, using a data key per account. The data keys are
+// stored wrapped by the master key and cached unwrapped in memory. A nil keyring leaves new values in plain text.
+type Keyring struct {
+	keys     DataKeyRepository
+	master   MasterKey
+	previous map[string]MasterKey
+
+	mu    sync.Mutex
+	cache map[uuid.UUID][]byte
+}
+
+// NewKeyring returns a keyring wrapping new data keys with the master key. The previous master keys only unwrap the
+// data keys, which have not been wrapped again with the current one yet.
+func NewKeyring(keys DataKeyRepository, master MasterKey, previous ...MasterKey) *Keyring {
+	k := &Keyring{keys: keys, master: master, previous: map[string]MasterKey{}, cache: map[uuid.UUID][]byte{}}
+	for _, p := range previous {
+		k.previous[p.ID] = p
+	}
+	return k
+}
+
+// NewKeyringFromConfig returns the keyring of the master key and the previous master keys of the configuration, or nil
+// if no master key is configured.
+func NewKeyringFromConfig(keys DataKeyRepository, cfg config.Encryption) (*Keyring, error) {
+	slog.Info("💬 🔐 (pkg/storage/encryption.go) NewKeyringFromConfig()")
+	if cfg.MasterKey == "" {
+		slog.Warn("🚨 🔐 (pkg/storage/encryption.go) ❓❓❓❓ 🔑 No ENCRYPTION_MASTER_KEY, sensitive data is stored in plain text")
+		return nil, nil
+	}
+	master, err := ParseMasterKey(cfg.MasterKey.Value())
+	if err != nil {
+		return nil, err
+	}
+	var previous []MasterKey
+	for _, p := range cfg.PreviousMasterKeys {
+		key, err := ParseMasterKey(p.Value())
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_PREVIOUS_MASTER_KEYS: %w", err)
+		}
+		previous = append(previous, key)
+	}
+	slog.Info("✅ 🔐 (pkg/storage/encryption.go) NewKeyringFromConfig() -> 🔑 Keyring has been created with", "masterKeyID", master.ID, "previous", len(previous))
+	return NewKeyring(keys, master, previous...), nil
+}
+
+// EncryptFields encrypts the fields tagged encrypt:"field" of the model, a pointer to a struct or a slice of structs,
+// with the active data key of the owner, creating one if the owner has none yet, and marks the records as encrypted.
+// Records which are marked already are left as they are. Records without an id get a new one, so the values are bound
+// to the id the record is stored with.
+func (k *Keyring) EncryptFields(ctx context.Context, model any) error {
+	if k == nil {
+		return nil
+	}
+	return eachEncryptedRecord(model, func(r encryptedRecord) error {
+		if r.state.Bool() {
+			return nil
+		}
+		if r.owner == uuid.Nil {
+			return fmt.Errorf("%s has no owner, whose data key encrypts it", r.name)
+		}
+		recordID, _ := r.id.Interface().(uuid.UUID)
+		if recordID == uuid.Nil {
+			recordID = uuid.New()
+			r.id.Set(reflect.ValueOf(recordID))
+		}
+		id, key, err := k.activeDataKey(ctx, r.owner)
+		if err != nil {
+			return err
+		}
+		for i, field := range r.fields {
+			if field.String() == "" {
+				continue
+			}
+			value, err := encrypt(key, id, fieldAAD(id, recordID, r.names[i]), field.String())
+			if err != nil {
+				return err
+			}
+			field.SetString(value)
+		}
+		r.state.SetBool(true)
+		return nil
+	})
+}
+
+// DecryptFields decrypts the fields tagged encrypt:"field" of the records of the model, a pointer to a struct or a
+// slice of structs, which are marked as encrypted, and marks them as plain text. Records in plain text, e.g. from
+// before encryption had been enabled, are kept as they are.
+func (k *Keyring) DecryptFields(ctx context.Context, model any) error {
+	return eachEncryptedRecord(model, func(r encryptedRecord) error {
+		if !r.state.Bool() {
+			return nil
+		}
+		if k == nil {
+			return ErrNoMasterKey
+		}
+		recordID, _ := r.id.Interface().(uuid.UUID)
+		for i, field := range r.fields {
+			if field.String() == "" {
+				continue
+			}
+			if !encrypted(field.String()) {
+				return fmt.Errorf("%s is marked as encrypted, but is not", r.names[i])
+			}
+			id, err := dataKeyID(field.String())
+			if err != nil {
+				return err
+			}
+			key, err := k.dataKey(ctx, id)
+			if err != nil {
+				return err
+			}
+			value, err := decrypt(key, fieldAAD(id, recordID, r.names[i]), r.names[i], field.String())
+			if err != nil {
+				return err
+			}
+			field.SetString(value)
+		}
+		r.state.SetBool(false)
+		return nil
+	})
+}
+
+// NewDataKey creates a new data key for the account, which becomes its active key.
+func (k *Keyring) NewDataKey(ctx context.Context, accountID uuid.UUID) (types.DataKey, error) {
+	slog.Info("💬 🔐 (pkg/storage/encryption.go) NewDataKey()", "accountID", accountID)
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return types.DataKey{}, err
+	}
+	dataKey := types.DataKey{ID: uuid.New(), AccountID: accountID}
+	if err := k.wrap(&dataKey, key); err != nil {
+		return types.DataKey{}, err
+	}
+	if err := k.keys.CreateDataKey(ctx, &dataKey); err != nil {
+		return types.DataKey{}, err
+	}
+	k.mu.Lock()
+	k.cache[dataKey.ID] = key
+	k.mu.Unlock()
+	slog.Info("✅ 🔐 (pkg/storage/encryption.go) NewDataKey() -> 🔑 Data key has been created with", "id", dataKey.ID)
+	return dataKey, nil
+}
+
+// Rewrap wraps all data keys with the current master key, which are still wrapped with a previous one, and returns
+// their number. Afterwards the previous master keys are no longer needed.
+func (k *Keyring) Rewrap(ctx context.Context) (int, error) {
+	slog.Info("💬 🔐 (pkg/storage/encryption.go) Rewrap()")
+	keys, err := k.keys.GetDataKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range keys {
+		if keys[i].MasterKeyID == k.master.ID {
+			continue
+		}
+		key, err := k.unwrap(keys[i])
+		if err != nil {
+			return n, err
+		}
+		if err := k.wrap(&keys[i], key); err != nil {
+			return n, err
+		}
+		if err := k.keys.UpdateDataKey(ctx, &keys[i]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	slog.Info("✅ 🔐 (pkg/storage/encryption.go) Rewrap() -> 🔑 Data keys have been wrapped with the current master key", "count", n)
+	return n, nil
+}
+
+// activeDataKey returns the id and the unwrapped active data key of the account, creating one if there is none.
+func (k *Keyring) activeDataKey(ctx context.Context, accountID uuid.UUID) (uuid.UUID, []byte, error) {
+	dataKey, err := k.keys.GetActiveDataKey(ctx, accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		dataKey, err = k.NewDataKey(ctx, accountID)
+		if err != nil {
+			// A concurrent request may have created the only active data key of the account first
+			if created, getErr := k.keys.GetActiveDataKey(ctx, accountID); getErr == nil {
+				dataKey, err = created, nil
+			}
+		}
+	}
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	key, err := k.dataKey(ctx, dataKey.ID)
+	return dataKey.ID, key, err
+}
+
+// dataKey returns the unwrapped data key with the id, from the cache if possible.
+func (k *Keyring) dataKey(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	k.mu.Lock()
+	key, ok := k.cache[id]
+	k.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	dataKey, err := k.keys.GetDataKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("data key %s: %w", id, err)
+	}
+	if key, err = k.unwrap(dataKey); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.cache[id] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// wrap encrypts the data key with the current master key, binding it to its id and account.
+func (k *Keyring) wrap(dataKey *types.DataKey, key []byte) error {
+	wrapped, err := seal(k.master.key, key, wrapAAD(*dataKey))
+	if err != nil {
+		return err
+	}
+	dataKey.MasterKeyID = k.master.ID
+	dataKey.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	return nil
+}
+
+// unwrap decrypts the data key with the master key it has been wrapped with.
+func (k *Keyring) unwrap(dataKey types.DataKey) ([]byte, error) {
+	master, ok := k.previous[dataKey.MasterKeyID]
+	if dataKey.MasterKeyID == k.master.ID {
+		master, ok = k.master, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("data key %s is wrapped with the unknown master key %s", dataKey.ID, dataKey.MasterKeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(dataKey.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(master.key, wrapped, wrapAAD(dataKey))
+}
+
+// wrapAAD is the additional data of a wrapped data key, so it cannot be moved to another account.
+func wrapAAD(dataKey types.DataKey) []byte {
+	return []byte(dataKey.ID.String() + ":" + dataKey.AccountID.String())
+}
+
+// fieldAAD is the additional data of an encrypted value, so it cannot be moved to another field or record.
+func fieldAAD(id uuid.UUID, recordID uuid.UUID, name string) []byte {
+	return []byte(id.String() + ":" + recordID.String() + ":" + name)
+}
+
+// encrypted reports whether the value has the format of an encrypted value.
+func encrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// encryptedPayload returns the data key id and the encoded ciphertext of the encrypted value.
+func encryptedPayload(value string) string {
+	return strings.TrimPrefix(value, encryptedPrefix)
+}
+
+// encrypt returns the encrypted value of the field with the data key of the id.
+func encrypt(key []byte, id uuid.UUID, aad []byte, value string) (string, error) {
+	sealed, err := seal(key, []byte(value), aad)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + id.String() + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt returns the plain text of the encrypted value of the field.
+func decrypt(key []byte, aad []byte, name string, value string) (string, error) {
+	_, encoded, _ := strings.Cut(encryptedPayload(value), ":")
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(key, sealed, aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", name, err)
+	}
+	return string(plain), nil
+}
+
+// dataKeyID returns the id of the data key, which encrypted the value.
+func dataKeyID(value string) (uuid.UUID, error) {
+	id, _, ok := strings.Cut(encryptedPayload(value), ":")
+	if !ok {
+		return uuid.Nil, errors.New("encrypted value has no data key id")
+	}
+	return uuid.Parse(id)
+}
+
+// seal encrypts the plain text with AES-GCM, prepending the random nonce.
+func seal(key []byte, plain []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plain)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+// open decrypts the sealed text of seal.
+func open(key []byte, sealed []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedRecord is a record with encrypted fields, together with its owner and its id and state fields.
+type encryptedRecord struct {
+	name   string
+	owner  uuid.UUID
+	id     reflect.Value
+	state  reflect.Value
+	names  []string
+	fields []reflect.Value
+}
+
+// eachEncryptedRecord calls fn for every record of the model, a pointer to a struct or a slice of structs, with string
+// fields tagged encrypt:"field". Such models need the id and state fields as well.
+func eachEncryptedRecord(model any, fn func(r encryptedRecord) error) error {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("model must be a pointer, got %T", model)
+	}
+	v = v.Elem()
+	structs := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		structs = structs[:0]
+		for i := range v.Len() {
+			structs = append(structs, v.Index(i))
+		}
+	}
+	for _, s := range structs {
+		if s.Kind() != reflect.Struct {
+			return fmt.Errorf("model must point to a struct or a slice of structs, got %T", model)
+		}
+		r := encryptedRecord{name: s.Type().Name()}
+		for i := range s.NumField() {
+			switch s.Type().Field(i).Tag.Get(encryptTag) {
+			case "owner":
+				r.owner, _ = s.Field(i).Interface().(uuid.UUID)
+			case "id":
+				r.id = s.Field(i)
+			case "state":
+				r.state = s.Field(i)
+			case "field":
+				r.names = append(r.names, s.Type().Field(i).Name)
+				r.fields = append(r.fields, s.Field(i))
+			}
+		}
+		if len(r.fields) == 0 {
+			continue
+		}
+		if !r.id.IsValid() {
+			return fmt.Errorf("%s has no record id, which binds its encrypted fields", r.name)
+		}
+		if r.state.Kind() != reflect.Bool {
+			return fmt.Errorf("%s has no state, which tells whether its fields are encrypted", r.name)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// rotationBatchSize is the number of rows re-encrypted at once.
+const rotationBatchSize = 100
+
+// encryptedModels are the models with `encrypt:"field"` fields, which RotateDataKeys re-encrypts with the new data
+// keys. The fields of a model missing here would stay encrypted with the retired keys, which are deleted at the end of
+// the rotation.
+var encryptedModels = []any{
+	(*types.ReportShare)(nil),
+	(*types.Consumption)(nil),
+	(*types.SymptomRating)(nil),
+	(*types.Prescription)(nil),
+}
+
+// RotateDataKeys replaces the data keys of all accounts with new ones and re-encrypts the encrypted fields of all
+// encrypted models with them, which encrypts remaining plain text values as well. The replaced keys are only deleted
+// at the end, so an interrupted rotation can simply be run again. It returns the number of re-encrypted rows.
+func (k *Keyring) RotateDataKeys(ctx context.Context, db bun.IDB) (int, error) {
+	slog.Info("💬 🔐 (pkg/storage/encryption_rotation.go) RotateDataKeys()")
+	keys, err := k.keys.GetDataKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for i := range keys {
+		if keys[i].Retired() {
+			continue
+		}
+		keys[i].RetiredAt = now
+		if err := k.keys.UpdateDataKey(ctx, &keys[i]); err != nil {
+			return 0, err
+		}
+	}
+	rows := 0
+	for _, model := range encryptedModels {
+		n, err := k.reencrypt(ctx, db, reflect.TypeOf(model).Elem())
+		rows += n
+		if err != nil {
+			return rows, err
+		}
+	}
+	deleted, err := k.keys.DeleteRetiredDataKeys(ctx)
+	if err != nil {
+		return rows, err
+	}
+	slog.Info("✅ 🔐 (pkg/storage/encryption_rotation.go) RotateDataKeys() -> 🔑 Data keys have been rotated with", "rows", rows, "deletedKeys", deleted)
+	return rows, nil
+}
+
+// reencrypt decrypts and encrypts the encrypted fields of all rows of the model type with the active data keys, and
+// stores them together with their state.
+func (k *Keyring) reencrypt(ctx context.Context, db bun.IDB, typ reflect.Type) (int, error) {
+	table := db.Dialect().Tables().Get(typ)
+	var columns []string
+	for i := range typ.NumField() {
+		if tag := typ.Field(i).Tag.Get(encryptTag); tag != "field" && tag != "state" {
+			continue
+		}
+		for _, f := range table.Fields {
+			if f.GoName == typ.Field(i).Name {
+				columns = append(columns, f.Name)
+			}
+		}
+	}
+	rows := 0
+	for offset := 0; ; offset += rotationBatchSize {
+		batch := reflect.New(reflect.SliceOf(typ))
+		q := db.NewSelect().Model(batch.Interface()).OrderExpr("?PKs").Limit(rotationBatchSize).Offset(offset)
+		if table.SoftDeleteField != nil {
+			// Records in the trash can still be restored
+			q = q.WhereAllWithDeleted()
+		}
+		if err := q.Scan(ctx); err != nil {
+			return rows, err
+		}
+		if batch.Elem().Len() == 0 {
+			return rows, nil
+		}
+		if err := k.DecryptFields(ctx, batch.Interface()); err != nil {
+			return rows, err
+		}
+		if err := k.EncryptFields(ctx, batch.Interface()); err != nil {
+			return rows, err
+		}
+		for i := range batch.Elem().Len() {
+			row := batch.Elem().Index(i).Addr().Interface()
+			u := db.NewUpdate().Model(row).Column(columns...).WherePK()
+			if table.SoftDeleteField != nil {
+				u = u.WhereAllWithDeleted()
+			}
+			if _, err := u.Exec(ctx); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		slog.Info("🆗 🔐 (pkg/storage/encryption_rotation.go)  🔑 Rows have been re-encrypted in", "table", table.Name, "rows", rows)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/uptrace/bun"
+)
+
+// migrationsTable is the table, in which golang-migrate records the applied version.
+const migrationsTable = "schema_migrations"
+
+// BunHealthRepository is the HealthRepository checking the bun database connection.
+type BunHealthRepository struct {
+	db bun.IDB
+}
+
+// NewBunHealthRepository returns a new BunHealthRepository using the database connection.
+func NewBunHealthRepository(db bun.IDB) *BunHealthRepository {
+	return &BunHealthRepository{db: db}
+}
+
+// Ping checks that the database answers a query
+func (r *BunHealthRepository) Ping(ctx context.Context) error {
+	slog.Debug("💬 💾 (pkg/storage/health_repo.go) Ping()")
+	var one int
+	err := r.db.NewSelect().ColumnExpr("1").Scan(ctx, &one)
+	slog.Debug("✅ 💾 (pkg/storage/health_repo.go) Ping() -> 📂 Ping finished with", "error", err)
+	return err
+}
+
+// MigrationVersion returns the version of the last migration applied to the database and whether it failed halfway,
+// as recorded by golang-migrate. Without any migration it returns sql.ErrNoRows.
+func (r *BunHealthRepository) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	slog.Debug("💬 💾 (pkg/storage/health_repo.go) MigrationVersion()")
+	var version int64
+	var dirty bool
+	err := r.db.NewSelect().Table(migrationsTable).Column("version", "dirty").Limit(1).Scan(ctx, &version, &dirty)
+	slog.Debug("✅ 💾 (pkg/storage/health_repo.go) MigrationVersion() -> 📂 Version has been read with", "version", version, "dirty", dirty, "error", err)
+	return uint(version), dirty, err
+}
+
+// Stats returns the statistics of the connection pool. Within a transaction there is no pool, so they are empty.
+func (r *BunHealthRepository) Stats() sql.DBStats {
+	if db, ok := r.db.(*bun.DB); ok {
+		return db.Stats()
+	}
+	return sql.DBStats{}
+}
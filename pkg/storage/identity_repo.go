@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// BunIdentityRepository is the IdentityRepository backed by the user_identities table.
+type BunIdentityRepository struct {
+	db bun.IDB
+}
+
+// NewBunIdentityRepository returns a new BunIdentityRepository using the database connection.
+func NewBunIdentityRepository(db bun.IDB) *BunIdentityRepository {
+	return &BunIdentityRepository{db: db}
+}
+
+// GetIdentityByIssuerAndSubject retrieves the identity of an OpenID Connect provider by its issuer and subject
+func (r *BunIdentityRepository) GetIdentityByIssuerAndSubject(ctx context.Context, issuer string, subject string) (types.Identity, error) {
+	slog.Info("💬 💾 (pkg/storage/identity_repo.go) GetIdentityByIssuerAndSubject()")
+	var identity types.Identity
+	err := r.db.NewSelect().Model(&identity).
+		Where("issuer = ?", issuer).
+		Where("subject = ?", subject).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/identity_repo.go) GetIdentityByIssuerAndSubject() -> 📂 Identity retrieval finished with", "error", err)
+	return identity, err
+}
+
+// CreateIdentity links an identity of an OpenID Connect provider to a user in the database
+func (r *BunIdentityRepository) CreateIdentity(ctx context.Context, identity *types.Identity) error {
+	slog.Info("💬 💾 (pkg/storage/identity_repo.go) CreateIdentity()")
+	_, err := r.db.NewInsert().Model(identity).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/identity_repo.go) CreateIdentity() -> 📂 Identity creation finished with", "error", err)
+	return err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunInventoryRepository is the InventoryRepository backed by the inventory_items table.
+type BunInventoryRepository struct {
+	db bun.IDB
+}
+
+// NewBunInventoryRepository returns a new BunInventoryRepository using the database connection.
+func NewBunInventoryRepository(db bun.IDB) *BunInventoryRepository {
+	return &BunInventoryRepository{db: db}
+}
+
+// CreateInventoryItem creates an item of the inventory in the database
+func (r *BunInventoryRepository) CreateInventoryItem(ctx context.Context, item *types.InventoryItem) error {
+	slog.Info("💬 💾 (pkg/storage/inventory_repo.go) CreateInventoryItem()")
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	item.UpdatedAt = item.CreatedAt
+	_, err := r.db.NewInsert().Model(item).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/inventory_repo.go) CreateInventoryItem() -> 📂 Inventory item creation finished with", "error", err)
+	return err
+}
+
+// inventorySortColumns are the columns by which the items of the inventory can be sorted.
+var inventorySortColumns = map[string]string{
+	"acquired_at": "ii.acquired_at",
+	"amount":      "ii.amount",
+	"created_at":  "ii.created_at",
+}
+
+// ListInventoryItemsByOwnerID retrieves a page of the inventory of an owner, optionally only the items of the
+// product_id filter
+func (r *BunInventoryRepository) ListInventoryItemsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.InventoryItem], error) {
+	slog.Info("💬 💾 (pkg/storage/inventory_repo.go) ListInventoryItemsByOwnerID()", "options", opts)
+	var items []types.InventoryItem
+	q := r.db.NewSelect().Model(&items).Where("ii.owner_id = ?", ownerID)
+	if productID := opts.Filters["product_id"]; productID != "" {
+		q = q.Where("ii.product_id = ?", productID)
+	}
+	page, err := listPage(ctx, q, &items, opts, inventorySortColumns, "ii.id")
+	slog.Info("✅ 💾 (pkg/storage/inventory_repo.go) ListInventoryItemsByOwnerID() -> 📂 Inventory listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
+
+// GetInventoryItemByIDAndOwnerID retrieves an item of the inventory, as long as it belongs to the given owner
+func (r *BunInventoryRepository) GetInventoryItemByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.InventoryItem, error) {
+	slog.Info("💬 💾 (pkg/storage/inventory_repo.go) GetInventoryItemByIDAndOwnerID()")
+	var item types.InventoryItem
+	err := r.db.NewSelect().Model(&item).
+		Where("ii.id = ?", id).
+		Where("ii.owner_id = ?", ownerID).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/inventory_repo.go) GetInventoryItemByIDAndOwnerID() -> 📂 Inventory item retrieval finished with", "error", err)
+	return item, err
+}
+
+// UpdateInventoryItemVersion updates the version of an item of the inventory, which has been read before with the
+// UpdatedAt it carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the item has been
+// changed or deleted since.
+func (r *BunInventoryRepository) UpdateInventoryItemVersion(ctx context.Context, item *types.InventoryItem) error {
+	slog.Info("💬 💾 (pkg/storage/inventory_repo.go) UpdateInventoryItemVersion()")
+	readAt := item.UpdatedAt
+	item.UpdatedAt = time.Now()
+	err := updateVersion(ctx, r.db, item, item.ID, item.OwnerID, readAt, "product_id", "amount", "acquired_at", "updated_at")
+	slog.Info("✅ 💾 (pkg/storage/inventory_repo.go) UpdateInventoryItemVersion() -> 📂 Inventory item update finished with", "error", err)
+	return err
+}
+
+// DeleteInventoryItemVersion moves the version of an item of the inventory, which has been read before, to the
+// trash, as long as it belongs to its owner. It returns ErrVersionConflict, if the item has been changed or deleted
+// since.
+func (r *BunInventoryRepository) DeleteInventoryItemVersion(ctx context.Context, item types.InventoryItem) error {
+	slog.Info("💬 💾 (pkg/storage/inventory_repo.go) DeleteInventoryItemVersion()")
+	err := deleteVersion[types.InventoryItem](ctx, r.db, item.ID, item.OwnerID, item.UpdatedAt)
+	slog.Info("✅ 💾 (pkg/storage/inventory_repo.go) DeleteInventoryItemVersion() -> 📂 Inventory item deletion finished with", "error", err)
+	return err
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// listPage sorts, limits and offsets the query with the list options and scans one page of the listing together
+// with the total number of matching rows. The columns map the sort fields of the resource to their columns, fields
+// without a column are ignored. The tiebreaker column keeps the order stable between pages.
+func listPage[T any](ctx context.Context, q *bun.SelectQuery, items *[]T, opts types.ListOptions, columns map[string]string, tiebreaker string) (types.Page[T], error) {
+	for _, s := range opts.Sort {
+		column, ok := columns[s.Field]
+		if !ok {
+			continue
+		}
+		if s.Desc {
+			q = q.OrderExpr("? DESC", bun.Ident(column))
+		} else {
+			q = q.OrderExpr("? ASC", bun.Ident(column))
+		}
+	}
+	limit := opts.Limit
+	if limit <= 0 || limit > types.MaxListLimit {
+		limit = types.DefaultListLimit
+	}
+	offset := max(0, opts.Offset)
+	total, err := q.OrderExpr("? ASC", bun.Ident(tiebreaker)).Limit(limit).Offset(offset).ScanAndCount(ctx)
+	if *items == nil {
+		*items = []T{}
+	}
+	return types.Page[T]{Items: *items, Total: total, Limit: limit, Offset: offset}, err
+}
+
+// filterTime returns the time of a filter in RFC 3339, which has been validated by the handler.
+func filterTime(opts types.ListOptions, name string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, opts.Filters[name])
+	return t, err == nil
+}
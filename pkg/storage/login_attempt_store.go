@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// PostgresLoginAttemptStore keeps the failed login counters in the login_attempts table, so they are shared by all
+// replicas of the server.
+type PostgresLoginAttemptStore struct {
+	db bun.IDB
+}
+
+// NewPostgresLoginAttemptStore returns a new PostgresLoginAttemptStore using the database connection.
+func NewPostgresLoginAttemptStore(db bun.IDB) *PostgresLoginAttemptStore {
+	return &PostgresLoginAttemptStore{db: db}
+}
+
+// GetLoginAttempt retrieves the login attempt for the key, returning an empty attempt if there is none
+func (s *PostgresLoginAttemptStore) GetLoginAttempt(ctx context.Context, key string) (types.LoginAttempt, error) {
+	slog.Debug("💬 💾 (pkg/storage/login_attempt_store.go) GetLoginAttempt()")
+	attempt := types.LoginAttempt{}
+	err := s.db.NewSelect().Model(&attempt).Where("key = ?", key).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.LoginAttempt{Key: key}, nil
+	}
+	slog.Debug("✅ 💾 (pkg/storage/login_attempt_store.go) GetLoginAttempt() -> 📂 Login attempt retrieval finished with", "error", err)
+	return attempt, err
+}
+
+// RecordLoginFailure counts the failed login with a single upsert, so concurrent failures of the replicas are never
+// lost. The counter starts over, if the lockout has expired or the last failure is too old. It returns the attempt
+// after the failure.
+func (s *PostgresLoginAttemptStore) RecordLoginFailure(ctx context.Context, failure types.LoginFailure) (types.LoginAttempt, error) {
+	slog.Debug("💬 💾 (pkg/storage/login_attempt_store.go) RecordLoginFailure()")
+	attempt := types.LoginAttempt{Key: failure.Key, Failures: 1, LastFailureAt: failure.At, UpdatedAt: failure.At}
+	if attempt.Failures >= failure.LockoutThreshold {
+		attempt.LockedUntil = failure.LockUntil
+	}
+	reset := bun.SafeQuery("(la.locked_until IS NOT NULL AND la.locked_until <= EXCLUDED.last_failure_at) OR la.last_failure_at IS NULL OR la.last_failure_at < ?", failure.ResetBefore)
+	_, err := s.db.NewInsert().Model(&attempt).
+		On("CONFLICT (key) DO UPDATE").
+		Set("failures = CASE WHEN ? THEN 1 ELSE la.failures + 1 END", reset).
+		Set("locked_until = CASE WHEN ? THEN EXCLUDED.locked_until WHEN la.locked_until IS NULL AND la.failures + 1 >= ? THEN ? ELSE la.locked_until END",
+			reset, failure.LockoutThreshold, failure.LockUntil).
+		Set("last_failure_at = EXCLUDED.last_failure_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Exec(ctx)
+	slog.Debug("✅ 💾 (pkg/storage/login_attempt_store.go) RecordLoginFailure() -> 📂 Login failure recording finished with", "error", err)
+	return attempt, err
+}
+
+// DeleteLoginAttempt deletes the login attempt for the key
+func (s *PostgresLoginAttemptStore) DeleteLoginAttempt(ctx context.Context, key string) error {
+	slog.Debug("💬 💾 (pkg/storage/login_attempt_store.go) DeleteLoginAttempt()")
+	_, err := s.db.NewDelete().Model((*types.LoginAttempt)(nil)).Where("key = ?", key).Exec(ctx)
+	slog.Debug("✅ 💾 (pkg/storage/login_attempt_store.go) DeleteLoginAttempt() -> 📂 Login attempt deletion finished with", "error", err)
+	return err
+}
@@ -0,0 +1,2 @@
+-- The extension is kept, as it is shared with other schemas, e.g. the ones managed by Supabase
+select 1;
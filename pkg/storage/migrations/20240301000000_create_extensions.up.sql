@@ -0,0 +1,2 @@
+-- Bun generates ids with uuid_generate_v4(), which is provided by the uuid-ossp extension
+create extension if not exists "uuid-ossp";
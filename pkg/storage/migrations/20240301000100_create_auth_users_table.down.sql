@@ -0,0 +1,12 @@
+-- The users table of Supabase, which stores an encrypted_password, must never be dropped
+do $$
+begin
+    if not exists (
+        select 1 from information_schema.columns
+        where table_schema = 'auth' and table_name = 'users' and column_name = 'encrypted_password'
+    ) then
+        drop table if exists auth.users cascade;
+        drop schema if exists auth cascade;
+    end if;
+end
+$$;
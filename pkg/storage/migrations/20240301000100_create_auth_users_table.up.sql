@@ -0,0 +1,11 @@
+-- With a remote database, Supabase manages the auth schema and its users table, so both are only created when
+-- using a local database
+create schema if not exists auth;
+
+create table if not exists auth.users (
+    id uuid primary key default uuid_generate_v4(),
+    email text not null unique,
+    password text not null default '',
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
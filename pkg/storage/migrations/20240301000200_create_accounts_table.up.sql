@@ -0,0 +1,7 @@
+create table if not exists accounts (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    username text not null default '',
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
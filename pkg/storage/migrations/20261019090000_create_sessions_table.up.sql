@@ -0,0 +1,14 @@
+create table if not exists sessions (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid references auth.users (id) on delete cascade,
+    key_hash text not null unique,
+    data text not null,
+    user_agent text not null default '',
+    ip_address text not null default '',
+    last_seen_at timestamptz not null default current_timestamp,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists sessions_user_id_idx on sessions (user_id);
@@ -0,0 +1,2 @@
+drop table if exists audit_events;
+drop table if exists login_attempts;
@@ -0,0 +1,18 @@
+create table if not exists login_attempts (
+    key text primary key,
+    failures integer not null default 0,
+    last_failure_at timestamptz,
+    locked_until timestamptz,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create table if not exists audit_events (
+    id uuid primary key default uuid_generate_v4(),
+    action text not null,
+    email text not null default '',
+    ip_address text not null default '',
+    details text not null default '',
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists audit_events_created_at_idx on audit_events (created_at);
@@ -0,0 +1,12 @@
+create table if not exists user_identities (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    issuer text not null,
+    subject text not null,
+    email text not null default '',
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp,
+    unique (issuer, subject)
+);
+
+create index if not exists user_identities_user_id_idx on user_identities (user_id);
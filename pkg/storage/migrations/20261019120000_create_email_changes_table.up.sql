@@ -0,0 +1,10 @@
+create table if not exists email_changes (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    new_email text not null,
+    token_hash text not null unique,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists email_changes_user_id_idx on email_changes (user_id);
@@ -0,0 +1,13 @@
+create table if not exists api_tokens (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    prefix text not null,
+    scopes text[] not null default '{}',
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists api_tokens_user_id_idx on api_tokens (user_id);
@@ -0,0 +1,13 @@
+create table if not exists accounts (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    username text not null default '',
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+alter table accounts add column if not exists role text not null default 'patient';
+alter table accounts add column if not exists disabled_at timestamptz;
+
+create unique index if not exists accounts_user_id_key on accounts (user_id);
+create index if not exists accounts_role_idx on accounts (role);
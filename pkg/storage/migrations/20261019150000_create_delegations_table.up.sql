@@ -0,0 +1,14 @@
+create table if not exists delegations (
+    id uuid primary key default uuid_generate_v4(),
+    owner_id uuid not null references auth.users (id) on delete cascade,
+    delegate_id uuid references auth.users (id) on delete cascade,
+    email text not null,
+    access text not null default 'read',
+    token_hash text not null unique,
+    accepted_at timestamptz,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists delegations_owner_id_idx on delegations (owner_id);
+create index if not exists delegations_delegate_id_idx on delegations (delegate_id);
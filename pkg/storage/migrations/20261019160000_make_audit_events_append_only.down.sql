@@ -0,0 +1,10 @@
+drop trigger if exists audit_events_append_only on audit_events;
+drop function if exists audit_events_append_only();
+
+drop index if exists audit_events_action_idx;
+drop index if exists audit_events_account_id_idx;
+drop index if exists audit_events_actor_id_idx;
+
+alter table audit_events drop column if exists user_agent;
+alter table audit_events drop column if exists account_id;
+alter table audit_events drop column if exists actor_id;
@@ -0,0 +1,18 @@
+alter table audit_events add column if not exists actor_id uuid;
+alter table audit_events add column if not exists account_id uuid;
+alter table audit_events add column if not exists user_agent text not null default '';
+
+create index if not exists audit_events_actor_id_idx on audit_events (actor_id);
+create index if not exists audit_events_account_id_idx on audit_events (account_id);
+create index if not exists audit_events_action_idx on audit_events (action);
+
+create or replace function audit_events_append_only() returns trigger as $$
+begin
+    raise exception 'audit_events is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists audit_events_append_only on audit_events;
+create trigger audit_events_append_only
+    before update or delete on audit_events
+    for each row execute function audit_events_append_only();
@@ -0,0 +1,2 @@
+drop table if exists report_share_views;
+drop table if exists report_shares;
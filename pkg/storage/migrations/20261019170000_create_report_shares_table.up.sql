@@ -0,0 +1,23 @@
+create table if not exists report_shares (
+    id uuid primary key default uuid_generate_v4(),
+    owner_id uuid not null references auth.users (id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    from_date date not null,
+    to_date date not null,
+    expires_at timestamptz not null,
+    revoked_at timestamptz,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists report_shares_owner_id_idx on report_shares (owner_id);
+
+create table if not exists report_share_views (
+    id uuid primary key default uuid_generate_v4(),
+    share_id uuid not null references report_shares (id) on delete cascade,
+    ip_address text not null default '',
+    user_agent text not null default '',
+    viewed_at timestamptz not null default current_timestamp
+);
+
+create index if not exists report_share_views_share_id_idx on report_share_views (share_id);
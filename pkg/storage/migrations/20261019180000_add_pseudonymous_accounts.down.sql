@@ -0,0 +1,15 @@
+drop table if exists recovery_codes;
+drop index if exists accounts_username_key;
+-- Pseudonymous users have no email, so the not null constraint can only be restored once they have been removed. The
+-- users table of Supabase, which stores an encrypted_password, is left as Supabase manages it
+do $$
+begin
+    if not exists (
+        select 1 from information_schema.columns
+        where table_schema = 'auth' and table_name = 'users' and column_name = 'encrypted_password'
+    ) then
+        delete from auth.users where email is null;
+        alter table auth.users alter column email set not null;
+    end if;
+end
+$$;
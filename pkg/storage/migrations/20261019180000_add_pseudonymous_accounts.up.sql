@@ -0,0 +1,24 @@
+-- Pseudonymous users register without an email and log in with the generated username of their account. The users
+-- table of Supabase, which stores an encrypted_password, is managed by Supabase and already allows a missing email
+do $$
+begin
+    if not exists (
+        select 1 from information_schema.columns
+        where table_schema = 'auth' and table_name = 'users' and column_name = 'encrypted_password'
+    ) then
+        alter table auth.users alter column email drop not null;
+    end if;
+end
+$$;
+
+create unique index if not exists accounts_username_key on accounts (lower(username)) where username <> '';
+
+create table if not exists recovery_codes (
+    id uuid primary key default uuid_generate_v4(),
+    user_id uuid not null references auth.users (id) on delete cascade,
+    code_hash text not null,
+    used_at timestamptz,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists recovery_codes_user_id_idx on recovery_codes (user_id);
@@ -0,0 +1,12 @@
+-- The data keys encrypt the sensitive fields of an account and are only stored wrapped by the master key. They are
+-- deleted together with the user, which leaves encrypted copies of the data, e.g. in backups, unreadable.
+create table if not exists data_keys (
+    id uuid primary key default uuid_generate_v4(),
+    account_id uuid not null references auth.users (id) on delete cascade,
+    master_key_id text not null,
+    wrapped_key text not null,
+    retired_at timestamptz,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists data_keys_account_id_idx on data_keys (account_id);
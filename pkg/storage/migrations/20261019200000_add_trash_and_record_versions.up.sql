@@ -0,0 +1,16 @@
+-- Deleted records are kept in the trash for 30 days, before they are purged
+alter table report_shares add column if not exists deleted_at timestamptz;
+
+create index if not exists report_shares_deleted_at_idx on report_shares (deleted_at) where deleted_at is not null;
+
+-- The previous versions of edited records as JSON, which are deleted together with the owner or the purged record
+create table if not exists record_versions (
+    id uuid primary key default uuid_generate_v4(),
+    table_name text not null,
+    record_id uuid not null,
+    owner_id uuid not null references auth.users (id) on delete cascade,
+    data jsonb not null,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists record_versions_record_idx on record_versions (table_name, record_id);
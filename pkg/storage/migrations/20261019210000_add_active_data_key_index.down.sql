@@ -0,0 +1 @@
+drop index if exists data_keys_active_account_id_idx;
@@ -0,0 +1,6 @@
+-- Whether the name of a share is encrypted is stored with it, instead of being guessed from the name, which the owner
+-- chooses. Names with the format of an encrypted value are marked as encrypted, a plain name of that format could not
+-- be read before either.
+alter table report_shares add column if not exists encrypted boolean not null default false;
+
+update report_shares set encrypted = true where substr(name, 1, 7) = 'enc:v2:';
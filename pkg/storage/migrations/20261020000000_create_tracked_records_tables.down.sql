@@ -0,0 +1,4 @@
+drop table if exists symptom_ratings;
+drop table if exists consumptions;
+drop table if exists inventory_items;
+drop table if exists products;
@@ -0,0 +1,18 @@
+-- The prescriptions of the users, a tracked record like the products. The doctors are encrypted, see the encrypted
+-- column of report_shares
+create table if not exists prescriptions (
+    id uuid primary key default uuid_generate_v4(),
+    owner_id uuid not null references auth.users (id) on delete cascade,
+    product_id uuid not null references products (id) on delete cascade,
+    doctor text not null default '',
+    amount double precision not null,
+    issued_at timestamptz not null,
+    expires_at timestamptz not null,
+    encrypted boolean not null default false,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp,
+    deleted_at timestamptz
+);
+
+create index if not exists prescriptions_owner_id_issued_at_idx on prescriptions (owner_id, issued_at);
+create index if not exists prescriptions_deleted_at_idx on prescriptions (deleted_at) where deleted_at is not null;
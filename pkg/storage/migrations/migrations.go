@@ -0,0 +1,55 @@
+// Package migrations embeds the versioned SQL migrations of the database, so the migrator and the tests run from any
+// working directory and inside the scratch container, which has no migration files on disk.
+package migrations // import "github.com/TheDonDope/wits-server/pkg/storage/migrations"
+
+import (
+	"embed"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// FS holds the up and down migrations, named <version>_<title>.<up|down>.sql. The migrations of Postgres are at the
+// top, the ones of SQLite in the sqlite directory, with the same versions.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
+
+// Dir returns the directory of the migrations for the database driver, relative to FS.
+func Dir(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite"
+	}
+	return "."
+}
+
+// NewSource returns the embedded migrations of the database driver as a source for golang-migrate.
+func NewSource(driver string) (source.Driver, error) {
+	slog.Info("💬 💾 (pkg/storage/migrations/migrations.go) NewSource()", "driver", driver)
+	return iofs.New(FS, Dir(driver))
+}
+
+// LatestVersion returns the version of the last embedded migration of the database driver, which a migrated database
+// is at.
+func LatestVersion(driver string) (uint, error) {
+	src, err := NewSource(driver)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	latest, err := src.First()
+	for err == nil {
+		var next uint
+		if next, err = src.Next(latest); err == nil {
+			latest = next
+		}
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return latest, nil
+}
@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	pattern := regexp.MustCompile(`^\d{14}_\w+\.(up|down)\.sql$`)
+	var migrations [][]string
+	for _, driver := range []string{"postgres", "sqlite"} {
+		names, err := fs.Glob(FS, path.Join(Dir(driver), "*.sql"))
+		if err != nil {
+			t.Fatalf("fs.Glob() error = %v", err)
+		}
+		if len(names) == 0 {
+			t.Fatalf("fs.Glob() found no embedded migrations for %v", driver)
+		}
+		for i, name := range names {
+			names[i] = path.Base(name)
+			if !pattern.MatchString(names[i]) {
+				t.Errorf("migration %v does not match %v", name, pattern)
+			}
+			up, ok := strings.CutSuffix(name, ".up.sql")
+			if !ok {
+				continue
+			}
+			if _, err := fs.Stat(FS, up+".down.sql"); err != nil {
+				t.Errorf("migration %v has no down migration", name)
+			}
+		}
+		migrations = append(migrations, names)
+	}
+	// Every version has to exist for both drivers, so the schemas do not drift apart
+	if !slices.Equal(migrations[0], migrations[1]) {
+		t.Errorf("migrations of postgres %v differ from the ones of sqlite %v", migrations[0], migrations[1])
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	for _, driver := range []string{"postgres", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			src, err := NewSource(driver)
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+			defer src.Close()
+
+			// The extensions and the auth schema are needed by all later migrations, so they have to come first
+			first, err := src.First()
+			if err != nil {
+				t.Fatalf("First() error = %v", err)
+			}
+			_, identifier, err := src.ReadUp(first)
+			if err != nil {
+				t.Fatalf("ReadUp() error = %v", err)
+			}
+			if identifier != "create_extensions" {
+				t.Errorf("First() = %v, want create_extensions", identifier)
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	ups, err := fs.Glob(FS, "*.up.sql")
+	if err != nil || len(ups) == 0 {
+		t.Fatalf("Glob() = %v, error = %v", ups, err)
+	}
+	want, _, _ := strings.Cut(ups[len(ups)-1], "_")
+	for _, driver := range []string{"postgres", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			got, err := LatestVersion(driver)
+			if err != nil {
+				t.Fatalf("LatestVersion() error = %v", err)
+			}
+			if strconv.FormatUint(uint64(got), 10) != want {
+				t.Errorf("LatestVersion() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+-- SQLite has no extensions. The ids default to a random version 4 UUID built from randomblob() instead of
+-- uuid_generate_v4(), so rows can be inserted by any SQLite client.
+select 1;
@@ -0,0 +1,9 @@
+-- SQLite has no auth schema, so the users table is named auth_users. The email is nullable from the start, as the
+-- column cannot be altered later on for pseudonymous users.
+create table if not exists auth_users (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    email text unique,
+    password text not null default '',
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
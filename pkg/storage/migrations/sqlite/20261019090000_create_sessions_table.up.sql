@@ -0,0 +1,14 @@
+create table if not exists sessions (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    user_id text references auth_users (id) on delete cascade,
+    key_hash text not null unique,
+    data text not null,
+    user_agent text not null default '',
+    ip_address text not null default '',
+    last_seen_at timestamp not null default current_timestamp,
+    expires_at timestamp not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create index if not exists sessions_user_id_idx on sessions (user_id);
@@ -0,0 +1,18 @@
+create table if not exists login_attempts (
+    key text primary key,
+    failures integer not null default 0,
+    last_failure_at timestamp,
+    locked_until timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create table if not exists audit_events (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    action text not null,
+    email text not null default '',
+    ip_address text not null default '',
+    details text not null default '',
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists audit_events_created_at_idx on audit_events (created_at);
@@ -0,0 +1,12 @@
+create table if not exists user_identities (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    user_id text not null references auth_users (id) on delete cascade,
+    issuer text not null,
+    subject text not null,
+    email text not null default '',
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    unique (issuer, subject)
+);
+
+create index if not exists user_identities_user_id_idx on user_identities (user_id);
@@ -0,0 +1,10 @@
+create table if not exists email_changes (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    user_id text not null references auth_users (id) on delete cascade,
+    new_email text not null,
+    token_hash text not null unique,
+    expires_at timestamp not null,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists email_changes_user_id_idx on email_changes (user_id);
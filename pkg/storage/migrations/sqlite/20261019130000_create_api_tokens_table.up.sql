@@ -0,0 +1,14 @@
+-- SQLite has no arrays, the scopes are stored as a JSON array instead
+create table if not exists api_tokens (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    user_id text not null references auth_users (id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    prefix text not null,
+    scopes text not null default '[]',
+    expires_at timestamp,
+    last_used_at timestamp,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists api_tokens_user_id_idx on api_tokens (user_id);
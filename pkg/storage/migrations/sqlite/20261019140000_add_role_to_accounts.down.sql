@@ -0,0 +1,5 @@
+drop index if exists accounts_role_idx;
+drop index if exists accounts_user_id_key;
+
+alter table accounts drop column disabled_at;
+alter table accounts drop column role;
@@ -0,0 +1,5 @@
+alter table accounts add column role text not null default 'patient';
+alter table accounts add column disabled_at timestamp;
+
+create unique index if not exists accounts_user_id_key on accounts (user_id);
+create index if not exists accounts_role_idx on accounts (role);
@@ -0,0 +1,14 @@
+create table if not exists delegations (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    delegate_id text references auth_users (id) on delete cascade,
+    email text not null,
+    access text not null default 'read',
+    token_hash text not null unique,
+    accepted_at timestamp,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create index if not exists delegations_owner_id_idx on delegations (owner_id);
+create index if not exists delegations_delegate_id_idx on delegations (delegate_id);
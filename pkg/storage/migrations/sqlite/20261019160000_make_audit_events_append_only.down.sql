@@ -0,0 +1,10 @@
+drop trigger if exists audit_events_append_only_delete;
+drop trigger if exists audit_events_append_only_update;
+
+drop index if exists audit_events_action_idx;
+drop index if exists audit_events_account_id_idx;
+drop index if exists audit_events_actor_id_idx;
+
+alter table audit_events drop column user_agent;
+alter table audit_events drop column account_id;
+alter table audit_events drop column actor_id;
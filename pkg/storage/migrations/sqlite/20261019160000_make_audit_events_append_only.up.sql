@@ -0,0 +1,19 @@
+alter table audit_events add column actor_id text;
+alter table audit_events add column account_id text;
+alter table audit_events add column user_agent text not null default '';
+
+create index if not exists audit_events_actor_id_idx on audit_events (actor_id);
+create index if not exists audit_events_account_id_idx on audit_events (account_id);
+create index if not exists audit_events_action_idx on audit_events (action);
+
+create trigger if not exists audit_events_append_only_update
+    before update on audit_events
+begin
+    select raise(abort, 'audit_events is append-only');
+end;
+
+create trigger if not exists audit_events_append_only_delete
+    before delete on audit_events
+begin
+    select raise(abort, 'audit_events is append-only');
+end;
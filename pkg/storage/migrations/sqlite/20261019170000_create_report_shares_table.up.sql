@@ -0,0 +1,23 @@
+create table if not exists report_shares (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    from_date date not null,
+    to_date date not null,
+    expires_at timestamp not null,
+    revoked_at timestamp,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists report_shares_owner_id_idx on report_shares (owner_id);
+
+create table if not exists report_share_views (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    share_id text not null references report_shares (id) on delete cascade,
+    ip_address text not null default '',
+    user_agent text not null default '',
+    viewed_at timestamp not null default current_timestamp
+);
+
+create index if not exists report_share_views_share_id_idx on report_share_views (share_id);
@@ -0,0 +1,2 @@
+drop table if exists recovery_codes;
+drop index if exists accounts_username_key;
@@ -0,0 +1,13 @@
+-- Pseudonymous users register without an email and log in with the generated username of their account. The email
+-- of auth_users is nullable since its creation.
+create unique index if not exists accounts_username_key on accounts (lower(username)) where username <> '';
+
+create table if not exists recovery_codes (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    user_id text not null references auth_users (id) on delete cascade,
+    code_hash text not null,
+    used_at timestamp,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists recovery_codes_user_id_idx on recovery_codes (user_id);
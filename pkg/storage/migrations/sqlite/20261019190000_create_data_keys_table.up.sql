@@ -0,0 +1,12 @@
+-- The data keys encrypt the sensitive fields of an account and are only stored wrapped by the master key. They are
+-- deleted together with the user, which leaves encrypted copies of the data, e.g. in backups, unreadable.
+create table if not exists data_keys (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    account_id text not null references auth_users (id) on delete cascade,
+    master_key_id text not null,
+    wrapped_key text not null,
+    retired_at timestamp,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists data_keys_account_id_idx on data_keys (account_id);
@@ -0,0 +1,5 @@
+drop table if exists record_versions;
+
+drop index if exists report_shares_deleted_at_idx;
+
+alter table report_shares drop column deleted_at;
@@ -0,0 +1,16 @@
+-- Deleted records are kept in the trash for 30 days, before they are purged
+alter table report_shares add column deleted_at timestamp;
+
+create index if not exists report_shares_deleted_at_idx on report_shares (deleted_at) where deleted_at is not null;
+
+-- The previous versions of edited records as JSON, which are deleted together with the owner or the purged record
+create table if not exists record_versions (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    table_name text not null,
+    record_id text not null,
+    owner_id text not null references auth_users (id) on delete cascade,
+    data text not null,
+    created_at timestamp not null default current_timestamp
+);
+
+create index if not exists record_versions_record_idx on record_versions (table_name, record_id);
@@ -0,0 +1,10 @@
+-- Every account has at most one active data key. Keys created concurrently before are retired, except for the newest
+-- one, so their values can still be decrypted until the next rotation.
+update data_keys set retired_at = current_timestamp
+where retired_at is null and exists (
+    select 1 from data_keys newer
+    where newer.account_id = data_keys.account_id and newer.retired_at is null
+      and (newer.created_at > data_keys.created_at or (newer.created_at = data_keys.created_at and newer.id > data_keys.id))
+);
+
+create unique index if not exists data_keys_active_account_id_idx on data_keys (account_id) where retired_at is null;
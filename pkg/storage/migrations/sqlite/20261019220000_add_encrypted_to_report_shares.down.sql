@@ -0,0 +1 @@
+alter table report_shares drop column encrypted;
@@ -0,0 +1,2 @@
+-- Access tokens issued before this time are rejected, it is set when the password of the user changes.
+alter table accounts add column tokens_revoked_at timestamp;
@@ -0,0 +1,65 @@
+-- The tracked records of the users. They are soft deleted into the trash, and their updated_at identifies the version
+-- of a record, which a change has to match.
+create table if not exists products (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    name text not null,
+    kind text not null,
+    thc real not null default 0,
+    cbd real not null default 0,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    deleted_at timestamp
+);
+
+create index if not exists products_owner_id_idx on products (owner_id);
+create index if not exists products_deleted_at_idx on products (deleted_at) where deleted_at is not null;
+
+create table if not exists inventory_items (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    product_id text not null references products (id) on delete cascade,
+    amount real not null,
+    acquired_at timestamp not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    deleted_at timestamp
+);
+
+create index if not exists inventory_items_owner_id_idx on inventory_items (owner_id);
+create index if not exists inventory_items_deleted_at_idx on inventory_items (deleted_at) where deleted_at is not null;
+
+-- The notes are encrypted, see the encrypted column of report_shares
+create table if not exists consumptions (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    product_id text not null references products (id) on delete cascade,
+    amount real not null,
+    method text not null,
+    consumed_at timestamp not null,
+    notes text not null default '',
+    encrypted boolean not null default false,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    deleted_at timestamp
+);
+
+create index if not exists consumptions_owner_id_consumed_at_idx on consumptions (owner_id, consumed_at);
+create index if not exists consumptions_deleted_at_idx on consumptions (deleted_at) where deleted_at is not null;
+
+-- The symptoms and notes are encrypted, see the encrypted column of report_shares
+create table if not exists symptom_ratings (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    symptom text not null,
+    severity integer not null check (severity between 0 and 10),
+    rated_at timestamp not null,
+    notes text not null default '',
+    encrypted boolean not null default false,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    deleted_at timestamp
+);
+
+create index if not exists symptom_ratings_owner_id_rated_at_idx on symptom_ratings (owner_id, rated_at);
+create index if not exists symptom_ratings_deleted_at_idx on symptom_ratings (deleted_at) where deleted_at is not null;
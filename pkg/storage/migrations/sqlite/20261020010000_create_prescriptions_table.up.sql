@@ -0,0 +1,18 @@
+-- The prescriptions of the users, a tracked record like the products. The doctors are encrypted, see the encrypted
+-- column of report_shares
+create table if not exists prescriptions (
+    id text primary key not null default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    owner_id text not null references auth_users (id) on delete cascade,
+    product_id text not null references products (id) on delete cascade,
+    doctor text not null default '',
+    amount real not null,
+    issued_at timestamp not null,
+    expires_at timestamp not null,
+    encrypted boolean not null default false,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    deleted_at timestamp
+);
+
+create index if not exists prescriptions_owner_id_issued_at_idx on prescriptions (owner_id, issued_at);
+create index if not exists prescriptions_deleted_at_idx on prescriptions (deleted_at) where deleted_at is not null;
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	_ "github.com/lib/pq" // Importing the postgres driver
+)
+
+const (
+	// maxConnectBackoff caps the doubling wait between the attempts to connect to the database on startup
+	maxConnectBackoff = 30 * time.Second
+	// firstConnectBackoff is the wait after the first failed attempt to connect to the database
+	firstConnectBackoff = time.Second
+)
+
+// postgresDSN returns the connection string of libpq for the configuration. The statement timeout is sent as a
+// run-time parameter of every connection.
+func postgresDSN(c config.Database) string {
+	host, port, found := strings.Cut(c.Host, ":")
+	if !found {
+		port = "5432"
+	}
+	params := []string{
+		"user=" + quoteDSNValue(c.User),
+		"password=" + quoteDSNValue(c.Password.Value()),
+		"dbname=" + quoteDSNValue(c.Name),
+		"host=" + quoteDSNValue(host),
+		"port=" + quoteDSNValue(port),
+		"sslmode=" + c.SSLMode,
+	}
+	if c.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteDSNValue(c.SSLRootCert))
+	}
+	if c.ConnectTimeout > 0 {
+		// libpq only supports whole seconds, anything below would disable the timeout
+		params = append(params, "connect_timeout="+strconv.Itoa(max(1, int(c.ConnectTimeout.Seconds()))))
+	}
+	if c.StatementTimeout > 0 {
+		params = append(params, "statement_timeout="+strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteDSNValue quotes a value of the connection string, so it may contain spaces and quotes.
+func quoteDSNValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// CreatePostgresDB creates a new database connection with the connection pool of the configuration
+func CreatePostgresDB(cfg config.Database) (*sql.DB, error) {
+	slog.Info("💬 💾 (pkg/storage/postgres.go) CreatePostgresDB()")
+	db, err := sql.Open("postgres", postgresDSN(cfg))
+	if err != nil {
+		slog.Error("🚨 💾 (pkg/storage/postgres.go) ❓❓❓❓ 📂 Failed to create Postgresql db connection with", "error", err)
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	slog.Info("✅ 💾 (pkg/storage/postgres.go) CreatePostgresDB() -> 📂 Successfully created Postgresql db connection with", "host", cfg.Host, "sslmode", cfg.SSLMode, "maxOpenConns", cfg.MaxOpenConns)
+	return db, nil
+}
+
+// NewBunWithPostgres opens the bun database connection with the configuration, which is passed on to the
+// repositories. It waits for the database to come up, e.g. when both are started together.
+func NewBunWithPostgres(ctx context.Context, cfg config.Database) (*bun.DB, error) {
+	slog.Info("💬 💾 (pkg/storage/postgres.go) NewBunWithPostgres()")
+	db, err := CreatePostgresDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := PingWithRetry(ctx, db, cfg.ConnectAttempts); err != nil {
+		db.Close()
+		return nil, err
+	}
+	bunDB := newBun(db, pgdialect.New())
+	slog.Info("✅ 💾 (pkg/storage/postgres.go) NewBunWithPostgres() -> 📂 Successfully initialized Bun with Postgres db")
+	return bunDB, nil
+}
+
+// PingWithRetry pings the database up to the given number of attempts, doubling the wait after every failure. It
+// returns the last error, if the database could not be reached, or the error of the context, once it is done.
+func PingWithRetry(ctx context.Context, db *sql.DB, attempts int) error {
+	wait := firstConnectBackoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		slog.Error("🚨 💾 (pkg/storage/postgres.go) ❓❓❓❓ 📂 Failed to ping Postgresql db with", "attempt", attempt, "of", attempts, "error", err)
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, maxConnectBackoff)
+	}
+	return fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+}
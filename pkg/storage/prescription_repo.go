@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunPrescriptionRepository is the PrescriptionRepository backed by the prescriptions table. The doctors are health
+// data, so they are encrypted with the keyring.
+type BunPrescriptionRepository struct {
+	db      bun.IDB
+	keyring *Keyring
+}
+
+// NewBunPrescriptionRepository returns a new BunPrescriptionRepository using the database connection and keyring.
+func NewBunPrescriptionRepository(db bun.IDB, keyring *Keyring) *BunPrescriptionRepository {
+	return &BunPrescriptionRepository{db: db, keyring: keyring}
+}
+
+// CreatePrescription creates a prescription in the database
+func (r *BunPrescriptionRepository) CreatePrescription(ctx context.Context, prescription *types.Prescription) error {
+	slog.Info("💬 💾 (pkg/storage/prescription_repo.go) CreatePrescription()")
+	if prescription.CreatedAt.IsZero() {
+		prescription.CreatedAt = time.Now()
+	}
+	prescription.UpdatedAt = prescription.CreatedAt
+	if err := r.keyring.EncryptFields(ctx, prescription); err != nil {
+		return err
+	}
+	_, err := r.db.NewInsert().Model(prescription).Exec(ctx)
+	if decryptErr := r.keyring.DecryptFields(ctx, prescription); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/prescription_repo.go) CreatePrescription() -> 📂 Prescription creation finished with", "error", err)
+	return err
+}
+
+// GetPrescriptionsByOwnerID retrieves the prescriptions of an owner, most recently issued first
+func (r *BunPrescriptionRepository) GetPrescriptionsByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.Prescription, error) {
+	slog.Info("💬 💾 (pkg/storage/prescription_repo.go) GetPrescriptionsByOwnerID()")
+	var prescriptions []types.Prescription
+	err := r.db.NewSelect().Model(&prescriptions).
+		Where("pr.owner_id = ?", ownerID).
+		Order("pr.issued_at DESC").
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &prescriptions)
+	}
+	slog.Info("✅ 💾 (pkg/storage/prescription_repo.go) GetPrescriptionsByOwnerID() -> 📂 Prescription retrieval finished with", "count", len(prescriptions), "error", err)
+	return prescriptions, err
+}
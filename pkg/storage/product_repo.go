@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunProductRepository is the ProductRepository backed by the products table.
+type BunProductRepository struct {
+	db bun.IDB
+}
+
+// NewBunProductRepository returns a new BunProductRepository using the database connection.
+func NewBunProductRepository(db bun.IDB) *BunProductRepository {
+	return &BunProductRepository{db: db}
+}
+
+// CreateProduct creates a product in the database
+func (r *BunProductRepository) CreateProduct(ctx context.Context, product *types.Product) error {
+	slog.Info("💬 💾 (pkg/storage/product_repo.go) CreateProduct()")
+	if product.CreatedAt.IsZero() {
+		product.CreatedAt = time.Now()
+	}
+	product.UpdatedAt = product.CreatedAt
+	_, err := r.db.NewInsert().Model(product).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/product_repo.go) CreateProduct() -> 📂 Product creation finished with", "error", err)
+	return err
+}
+
+// productSortColumns are the columns by which the products can be sorted.
+var productSortColumns = map[string]string{
+	"name":       "p.name",
+	"created_at": "p.created_at",
+}
+
+// ListProductsByOwnerID retrieves a page of the products of an owner, optionally only those of the kind filter
+func (r *BunProductRepository) ListProductsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.Product], error) {
+	slog.Info("💬 💾 (pkg/storage/product_repo.go) ListProductsByOwnerID()", "options", opts)
+	var products []types.Product
+	q := r.db.NewSelect().Model(&products).Where("p.owner_id = ?", ownerID)
+	if kind := opts.Filters["kind"]; kind != "" {
+		q = q.Where("p.kind = ?", kind)
+	}
+	page, err := listPage(ctx, q, &products, opts, productSortColumns, "p.id")
+	slog.Info("✅ 💾 (pkg/storage/product_repo.go) ListProductsByOwnerID() -> 📂 Product listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
+
+// GetProductByIDAndOwnerID retrieves a product, as long as it belongs to the given owner
+func (r *BunProductRepository) GetProductByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.Product, error) {
+	slog.Info("💬 💾 (pkg/storage/product_repo.go) GetProductByIDAndOwnerID()")
+	var product types.Product
+	err := r.db.NewSelect().Model(&product).
+		Where("p.id = ?", id).
+		Where("p.owner_id = ?", ownerID).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/product_repo.go) GetProductByIDAndOwnerID() -> 📂 Product retrieval finished with", "error", err)
+	return product, err
+}
+
+// UpdateProductVersion updates the version of a product, which has been read before with the UpdatedAt it carries,
+// as long as it belongs to its owner. It returns ErrVersionConflict, if the product has been changed or deleted since.
+func (r *BunProductRepository) UpdateProductVersion(ctx context.Context, product *types.Product) error {
+	slog.Info("💬 💾 (pkg/storage/product_repo.go) UpdateProductVersion()")
+	readAt := product.UpdatedAt
+	product.UpdatedAt = time.Now()
+	err := updateVersion(ctx, r.db, product, product.ID, product.OwnerID, readAt, "name", "kind", "thc", "cbd", "updated_at")
+	slog.Info("✅ 💾 (pkg/storage/product_repo.go) UpdateProductVersion() -> 📂 Product update finished with", "error", err)
+	return err
+}
+
+// DeleteProductVersion moves the version of a product, which has been read before, to the trash, as long as it
+// belongs to its owner. It returns ErrVersionConflict, if the product has been changed or deleted since.
+func (r *BunProductRepository) DeleteProductVersion(ctx context.Context, product types.Product) error {
+	slog.Info("💬 💾 (pkg/storage/product_repo.go) DeleteProductVersion()")
+	err := deleteVersion[types.Product](ctx, r.db, product.ID, product.OwnerID, product.UpdatedAt)
+	slog.Info("✅ 💾 (pkg/storage/product_repo.go) DeleteProductVersion() -> 📂 Product deletion finished with", "error", err)
+	return err
+}
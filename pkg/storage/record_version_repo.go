@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunRecordVersionRepository is the RecordVersionRepository backed by the record_versions table.
+type BunRecordVersionRepository struct {
+	db bun.IDB
+}
+
+// NewBunRecordVersionRepository returns a new BunRecordVersionRepository using the database connection.
+func NewBunRecordVersionRepository(db bun.IDB) *BunRecordVersionRepository {
+	return &BunRecordVersionRepository{db: db}
+}
+
+// GetRecordVersions retrieves the previous versions of a record of the owner, newest first
+func (r *BunRecordVersionRepository) GetRecordVersions(ctx context.Context, tableName string, recordID uuid.UUID, ownerID uuid.UUID) ([]types.RecordVersion, error) {
+	slog.Info("💬 💾 (pkg/storage/record_version_repo.go) GetRecordVersions()")
+	var versions []types.RecordVersion
+	err := r.db.NewSelect().Model(&versions).
+		Where("table_name = ?", tableName).
+		Where("record_id = ?", recordID).
+		Where("owner_id = ?", ownerID).
+		Order("created_at DESC").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/record_version_repo.go) GetRecordVersions() -> 📂 Record versions retrieval finished with", "count", len(versions), "error", err)
+	return versions, err
+}
+
+// saveVersions stores the current versions of the records of the owner, which the query selects, in the row history.
+// It has to run in the same transaction as the change of the records, and the records are stored as they are in the
+// database, so encrypted fields stay encrypted.
+func saveVersions[T any](ctx context.Context, db bun.IDB, ownerID uuid.UUID, query func(q *bun.SelectQuery) *bun.SelectQuery) error {
+	var records []T
+	table := db.Dialect().Tables().Get(reflect.TypeFor[T]())
+	q := query(db.NewSelect().Model(&records))
+	if table.SoftDeleteField != nil {
+		q = q.WhereAllWithDeleted()
+	}
+	if err := q.Scan(ctx); err != nil || len(records) == 0 {
+		return err
+	}
+	versions := make([]types.RecordVersion, len(records))
+	for i := range records {
+		data, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		versions[i] = types.RecordVersion{
+			TableName: table.Name,
+			RecordID:  table.PKs[0].Value(reflect.ValueOf(&records[i]).Elem()).Interface().(uuid.UUID),
+			OwnerID:   ownerID,
+			Data:      string(data),
+		}
+	}
+	_, err := db.NewInsert().Model(&versions).Exec(ctx)
+	return err
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunRecoveryCodeRepository is the RecoveryCodeRepository backed by the recovery_codes table.
+type BunRecoveryCodeRepository struct {
+	db bun.IDB
+}
+
+// NewBunRecoveryCodeRepository returns a new BunRecoveryCodeRepository using the database connection.
+func NewBunRecoveryCodeRepository(db bun.IDB) *BunRecoveryCodeRepository {
+	return &BunRecoveryCodeRepository{db: db}
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user with the given code hashes
+func (r *BunRecoveryCodeRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	slog.Info("💬 💾 (pkg/storage/recovery_code_repo.go) ReplaceRecoveryCodes()")
+	codes := make([]types.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = types.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	_, err := r.db.NewDelete().Model((*types.RecoveryCode)(nil)).Where("user_id = ?", userID).Exec(ctx)
+	if err == nil && len(codes) > 0 {
+		_, err = r.db.NewInsert().Model(&codes).Exec(ctx)
+	}
+	slog.Info("✅ 💾 (pkg/storage/recovery_code_repo.go) ReplaceRecoveryCodes() -> 📂 Recovery code replacement finished with", "count", len(codes), "error", err)
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It returns sql.ErrNoRows, if the user has no
+// unused recovery code with the hash.
+func (r *BunRecoveryCodeRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	slog.Info("💬 💾 (pkg/storage/recovery_code_repo.go) UseRecoveryCode()")
+	res, err := r.db.NewUpdate().Model((*types.RecoveryCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", codeHash).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	slog.Info("✅ 💾 (pkg/storage/recovery_code_repo.go) UseRecoveryCode() -> 📂 Recovery code use finished with", "error", err)
+	return err
+}
+
+// CountUnusedRecoveryCodes counts the recovery codes of a user, which have not been used yet
+func (r *BunRecoveryCodeRepository) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	slog.Info("💬 💾 (pkg/storage/recovery_code_repo.go) CountUnusedRecoveryCodes()")
+	count, err := r.db.NewSelect().Model((*types.RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Where("used_at IS NULL").
+		Count(ctx)
+	slog.Info("✅ 💾 (pkg/storage/recovery_code_repo.go) CountUnusedRecoveryCodes() -> 📂 Recovery code count finished with", "count", count, "error", err)
+	return count, err
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunReportShareRepository is the ReportShareRepository backed by the report_shares table. The names of the shares
+// often name the doctor of the owner, so they are encrypted with the keyring.
+type BunReportShareRepository struct {
+	db      bun.IDB
+	keyring *Keyring
+}
+
+// NewBunReportShareRepository returns a new BunReportShareRepository using the database connection and keyring.
+func NewBunReportShareRepository(db bun.IDB, keyring *Keyring) *BunReportShareRepository {
+	return &BunReportShareRepository{db: db, keyring: keyring}
+}
+
+// CreateReportShare creates a share link to a report in the database
+func (r *BunReportShareRepository) CreateReportShare(ctx context.Context, share *types.ReportShare) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) CreateReportShare()")
+	if err := r.keyring.EncryptFields(ctx, share); err != nil {
+		return err
+	}
+	_, err := r.db.NewInsert().Model(share).Exec(ctx)
+	if decryptErr := r.keyring.DecryptFields(ctx, share); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) CreateReportShare() -> 📂 Report share creation finished with", "error", err)
+	return err
+}
+
+// GetReportShareByTokenHash retrieves a share link to a report by the hash of its token
+func (r *BunReportShareRepository) GetReportShareByTokenHash(ctx context.Context, tokenHash string) (types.ReportShare, error) {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) GetReportShareByTokenHash()")
+	var share types.ReportShare
+	err := r.db.NewSelect().Model(&share).Where("token_hash = ?", tokenHash).Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &share)
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) GetReportShareByTokenHash() -> 📂 Report share retrieval finished with", "error", err)
+	return share, err
+}
+
+// GetReportSharesByOwnerID retrieves all share links of an owner together with their number of views, newest first
+func (r *BunReportShareRepository) GetReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.ReportShare, error) {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) GetReportSharesByOwnerID()")
+	var shares []types.ReportShare
+	err := r.selectReportShares(&shares).
+		Where("rs.owner_id = ?", ownerID).
+		Order("rs.created_at DESC").
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &shares)
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) GetReportSharesByOwnerID() -> 📂 Report share retrieval finished with", "count", len(shares), "error", err)
+	return shares, err
+}
+
+// reportShareSortColumns are the columns by which the share links can be sorted.
+var reportShareSortColumns = map[string]string{
+	"created_at": "rs.created_at",
+	"expires_at": "rs.expires_at",
+	"from":       "rs.from_date",
+	"to":         "rs.to_date",
+}
+
+// ListReportSharesByOwnerID retrieves a page of the share links of an owner together with their number of views.
+// The status filter narrows them down to active, revoked or expired shares.
+func (r *BunReportShareRepository) ListReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.ReportShare], error) {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) ListReportSharesByOwnerID()", "options", opts)
+	var shares []types.ReportShare
+	q := r.selectReportShares(&shares).Where("rs.owner_id = ?", ownerID)
+	switch opts.Filters["status"] {
+	case "active":
+		q = q.Where("rs.revoked_at IS NULL").Where("rs.expires_at > ?", time.Now())
+	case "revoked":
+		q = q.Where("rs.revoked_at IS NOT NULL")
+	case "expired":
+		q = q.Where("rs.revoked_at IS NULL").Where("rs.expires_at <= ?", time.Now())
+	}
+	page, err := listPage(ctx, q, &shares, opts, reportShareSortColumns, "rs.id")
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &page.Items)
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) ListReportSharesByOwnerID() -> 📂 Report share listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
+
+// GetReportShareByIDAndOwnerID retrieves a share link together with its number of views, as long as it belongs to
+// the given owner
+func (r *BunReportShareRepository) GetReportShareByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.ReportShare, error) {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) GetReportShareByIDAndOwnerID()")
+	var share types.ReportShare
+	err := r.selectReportShares(&share).
+		Where("rs.id = ?", id).
+		Where("rs.owner_id = ?", ownerID).
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &share)
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) GetReportShareByIDAndOwnerID() -> 📂 Report share retrieval finished with", "error", err)
+	return share, err
+}
+
+// selectReportShares selects the share links into the model together with their number of views.
+func (r *BunReportShareRepository) selectReportShares(model any) *bun.SelectQuery {
+	return r.db.NewSelect().Model(model).
+		ColumnExpr("rs.*").
+		ColumnExpr("(SELECT count(*) FROM report_share_views AS rsv WHERE rsv.share_id = rs.id) AS views").
+		ColumnExpr("(SELECT max(rsv.viewed_at) FROM report_share_views AS rsv WHERE rsv.share_id = rs.id) AS last_viewed_at")
+}
+
+// RevokeReportShare revokes a share link, as long as it belongs to the given owner. The share is kept for its views,
+// and its previous version in the row history.
+func (r *BunReportShareRepository) RevokeReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) RevokeReportShare()")
+	_, err := r.revokeReportShare(ctx, id, ownerID)
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) RevokeReportShare() -> 📂 Report share revocation finished with", "error", err)
+	return err
+}
+
+// RevokeReportShareVersion revokes the version of a share link, which has been read before, as long as it belongs to
+// its owner. It returns ErrVersionConflict, if the share has been revoked or deleted since.
+func (r *BunReportShareRepository) RevokeReportShareVersion(ctx context.Context, share types.ReportShare) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) RevokeReportShareVersion()")
+	n, err := r.revokeReportShare(ctx, share.ID, share.OwnerID)
+	if err == nil && n == 0 {
+		err = ErrVersionConflict
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) RevokeReportShareVersion() -> 📂 Report share revocation finished with", "error", err)
+	return err
+}
+
+// revokeReportShare revokes a share link, which has not been revoked yet, and returns the number of revoked shares.
+func (r *BunReportShareRepository) revokeReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (int64, error) {
+	var n int64
+	err := runInTx(ctx, r.db, func(ctx context.Context, tx bun.Tx) error {
+		unrevoked := func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", id).Where("owner_id = ?", ownerID).Where("revoked_at IS NULL")
+		}
+		if err := saveVersions[types.ReportShare](ctx, tx, ownerID, unrevoked); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model((*types.ReportShare)(nil)).
+			Set("revoked_at = ?", time.Now()).
+			Where("id = ?", id).
+			Where("owner_id = ?", ownerID).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// DeleteReportShare moves a share link to the trash, as long as it belongs to the given owner
+func (r *BunReportShareRepository) DeleteReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) DeleteReportShare()")
+	_, err := r.db.NewDelete().Model((*types.ReportShare)(nil)).
+		Where("id = ?", id).
+		Where("owner_id = ?", ownerID).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) DeleteReportShare() -> 📂 Report share deletion finished with", "error", err)
+	return err
+}
+
+// DeleteReportShareVersion moves the version of a share link, which has been read before, to the trash, as long as
+// it belongs to its owner. It returns ErrVersionConflict, if the share has been revoked or deleted since.
+func (r *BunReportShareRepository) DeleteReportShareVersion(ctx context.Context, share types.ReportShare) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) DeleteReportShareVersion()")
+	q := r.db.NewDelete().Model((*types.ReportShare)(nil)).
+		Where("id = ?", share.ID).
+		Where("owner_id = ?", share.OwnerID)
+	if share.Revoked() {
+		q = q.Where("revoked_at = ?", share.RevokedAt)
+	} else {
+		q = q.Where("revoked_at IS NULL")
+	}
+	res, err := q.Exec(ctx)
+	if err == nil {
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 0 {
+			err = ErrVersionConflict
+		}
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) DeleteReportShareVersion() -> 📂 Report share deletion finished with", "error", err)
+	return err
+}
+
+// GetDeletedReportSharesByOwnerID retrieves the share links of an owner in the trash, most recently deleted first
+func (r *BunReportShareRepository) GetDeletedReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.ReportShare, error) {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) GetDeletedReportSharesByOwnerID()")
+	var shares []types.ReportShare
+	err := r.db.NewSelect().Model(&shares).
+		WhereDeleted().
+		Where("owner_id = ?", ownerID).
+		Where("deleted_at > ?", time.Now().Add(-types.TrashRetention)).
+		Order("deleted_at DESC").
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &shares)
+	}
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) GetDeletedReportSharesByOwnerID() -> 📂 Deleted report share retrieval finished with", "count", len(shares), "error", err)
+	return shares, err
+}
+
+// RestoreReportShare restores a share link from the trash, as long as it belongs to the given owner
+func (r *BunReportShareRepository) RestoreReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) RestoreReportShare()")
+	_, err := r.db.NewUpdate().Model((*types.ReportShare)(nil)).
+		Set("deleted_at = NULL").
+		WhereDeleted().
+		Where("id = ?", id).
+		Where("owner_id = ?", ownerID).
+		Where("deleted_at > ?", time.Now().Add(-types.TrashRetention)).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) RestoreReportShare() -> 📂 Report share restoration finished with", "error", err)
+	return err
+}
+
+// CreateReportShareView records a view of a shared report in the database
+func (r *BunReportShareRepository) CreateReportShareView(ctx context.Context, view *types.ReportShareView) error {
+	slog.Info("💬 💾 (pkg/storage/report_share_repo.go) CreateReportShareView()")
+	_, err := r.db.NewInsert().Model(view).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/report_share_repo.go) CreateReportShareView() -> 📂 Report share view creation finished with", "error", err)
+	return err
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ErrVersionConflict is returned by the changes of a version of a record, which has been read before, if the record
+// has been changed since.
+var ErrVersionConflict = errors.New("the record has been changed since it has been read")
+
+// UserRepository is the interface for the storage of the authenticated users.
+type UserRepository interface {
+	// CreateAuthenticatedUser creates an authenticated user in the database
+	CreateAuthenticatedUser(ctx context.Context, user *types.AuthenticatedUser) error
+	// GetAuthenticatedUserByEmail retrieves an authenticated user by the email
+	GetAuthenticatedUserByEmail(ctx context.Context, email string) (types.AuthenticatedUser, error)
+	// GetAuthenticatedUserByUsername retrieves an authenticated user by the username of the account, ignoring case
+	GetAuthenticatedUserByUsername(ctx context.Context, username string) (types.AuthenticatedUser, error)
+	// GetAuthenticatedUserByID retrieves an authenticated user by the id
+	GetAuthenticatedUserByID(ctx context.Context, id uuid.UUID) (types.AuthenticatedUser, error)
+	// UpdateAuthenticatedUserPassword updates the password hash of an authenticated user
+	UpdateAuthenticatedUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	// UpdateAuthenticatedUserEmail updates the email of an authenticated user
+	UpdateAuthenticatedUserEmail(ctx context.Context, id uuid.UUID, email string) error
+	// GetAuthenticatedUsers retrieves all users with their accounts, newest first. The password hashes are not selected.
+	GetAuthenticatedUsers(ctx context.Context) ([]types.AuthenticatedUser, error)
+	// GetRegistrationStats counts the registered users, in total, recently and per month of the last year
+	GetRegistrationStats(ctx context.Context) (types.RegistrationStats, error)
+}
+
+// AccountRepository is the interface for the storage of the accounts of the users.
+type AccountRepository interface {
+	// GetAccountByUserID retrieves an account by the user ID
+	GetAccountByUserID(ctx context.Context, userID uuid.UUID) (types.Account, error)
+	// ExistsAccountWithUsername checks whether an account with the username exists, ignoring case
+	ExistsAccountWithUsername(ctx context.Context, username string) (bool, error)
+	// CreateAccount creates an account in the database
+	CreateAccount(ctx context.Context, account *types.Account) error
+	// SaveAccount creates the account of a user or updates its role and disabled state, if the user already has one
+	SaveAccount(ctx context.Context, account *types.Account) error
+	// CountAccountsByRole counts the accounts with the given role
+	CountAccountsByRole(ctx context.Context, role types.Role) (int, error)
+	// RevokeAccessTokens rejects all access tokens of the user issued until now
+	RevokeAccessTokens(ctx context.Context, userID uuid.UUID) error
+}
+
+// SessionRepository is the interface for the storage of the server-side sessions.
+type SessionRepository interface {
+	// CreateSession creates a session in the database
+	CreateSession(ctx context.Context, session *types.Session) error
+	// UpdateSession updates the data, owner, device and expiry of a session in the database
+	UpdateSession(ctx context.Context, session *types.Session) error
+	// TouchSession records that the session has been seen from the given device
+	TouchSession(ctx context.Context, id uuid.UUID, userAgent string, ipAddress string) error
+	// GetSessionByKeyHash retrieves an unexpired session by the hash of its key
+	GetSessionByKeyHash(ctx context.Context, keyHash string) (types.Session, error)
+	// GetSessionsByUserID retrieves all unexpired sessions of a user, most recently seen first
+	GetSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]types.Session, error)
+	// DeleteSessionByKeyHash deletes the session with the given key hash
+	DeleteSessionByKeyHash(ctx context.Context, keyHash string) error
+	// DeleteSessionByIDAndUserID deletes a single session, as long as it belongs to the given user
+	DeleteSessionByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// DeleteSessionsByUserID deletes all sessions of a user, logging them out everywhere
+	DeleteSessionsByUserID(ctx context.Context, userID uuid.UUID) error
+	// DeleteExpiredSessions deletes all sessions which are past their expiry
+	DeleteExpiredSessions(ctx context.Context) error
+	// DeleteOtherSessionsByUserID deletes all sessions of a user except the one with the given key hash
+	DeleteOtherSessionsByUserID(ctx context.Context, userID uuid.UUID, keyHash string) error
+}
+
+// APITokenRepository is the interface for the storage of the personal API tokens.
+type APITokenRepository interface {
+	// CreateAPIToken creates a personal API token in the database
+	CreateAPIToken(ctx context.Context, token *types.APIToken) error
+	// GetAPITokenByHash retrieves a personal API token by the hash of the token
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (types.APIToken, error)
+	// GetAPITokensByUserID retrieves all personal API tokens of a user, newest first
+	GetAPITokensByUserID(ctx context.Context, userID uuid.UUID) ([]types.APIToken, error)
+	// TouchAPIToken records that the personal API token has been used
+	TouchAPIToken(ctx context.Context, id uuid.UUID) error
+	// DeleteAPITokenByIDAndUserID revokes a personal API token, as long as it belongs to the given user
+	DeleteAPITokenByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// DeleteAPITokensByUserID revokes all personal API tokens of a user
+	DeleteAPITokensByUserID(ctx context.Context, userID uuid.UUID) error
+}
+
+// AuditRepository is the interface for the storage of the audit log.
+type AuditRepository interface {
+	// CreateAuditEvent appends an event to the audit log
+	CreateAuditEvent(ctx context.Context, event *types.AuditEvent) error
+	// GetAuditEventsByUserID retrieves the latest audit events which the user performed or which affected the account
+	// of the user, newest first
+	GetAuditEventsByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]types.AuditEvent, error)
+	// SearchAuditEvents retrieves the audit events matching the filter, newest first
+	SearchAuditEvents(ctx context.Context, filter types.AuditFilter) ([]types.AuditEvent, error)
+	// ListAuditEventsByUserID retrieves a page of the audit events which the user performed or which affected the
+	// account of the user. The action filter matches the prefix of the action, the created_after and created_before
+	// filters the time of the event.
+	ListAuditEventsByUserID(ctx context.Context, userID uuid.UUID, opts types.ListOptions) (types.Page[types.AuditEvent], error)
+}
+
+// DelegationRepository is the interface for the storage of the delegations of access to accounts.
+type DelegationRepository interface {
+	// CreateDelegation creates the invitation of a delegate in the database, replacing an earlier delegation of the
+	// owner to the same email
+	CreateDelegation(ctx context.Context, delegation *types.Delegation) error
+	// GetPendingDelegationByTokenHash retrieves a delegation, which has not been accepted yet, by the hash of its
+	// invitation token
+	GetPendingDelegationByTokenHash(ctx context.Context, tokenHash string) (types.Delegation, error)
+	// GetAcceptedDelegation retrieves the accepted delegation of the owner to the delegate
+	GetAcceptedDelegation(ctx context.Context, ownerID uuid.UUID, delegateID uuid.UUID) (types.Delegation, error)
+	// GetDelegationsByOwnerID retrieves all delegations the owner has granted, including pending invitations
+	GetDelegationsByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.Delegation, error)
+	// GetAcceptedDelegationsByDelegateID retrieves all accepted delegations of other accounts to the delegate
+	GetAcceptedDelegationsByDelegateID(ctx context.Context, delegateID uuid.UUID) ([]types.Delegation, error)
+	// AcceptDelegation activates the delegation for the delegate
+	AcceptDelegation(ctx context.Context, id uuid.UUID, delegateID uuid.UUID) error
+	// DeleteDelegationByIDAndUserID deletes a delegation, as long as the user is either its owner or its delegate
+	DeleteDelegationByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (types.Delegation, error)
+}
+
+// EmailChangeRepository is the interface for the storage of the pending email changes.
+type EmailChangeRepository interface {
+	// CreateEmailChange creates a pending email change in the database, replacing earlier pending changes of the user
+	CreateEmailChange(ctx context.Context, change *types.EmailChange) error
+	// GetEmailChangeByTokenHash retrieves an unexpired pending email change by the hash of its verification token
+	GetEmailChangeByTokenHash(ctx context.Context, tokenHash string) (types.EmailChange, error)
+	// DeleteEmailChangesByUserID deletes all pending email changes of a user
+	DeleteEmailChangesByUserID(ctx context.Context, userID uuid.UUID) error
+}
+
+// IdentityRepository is the interface for the storage of the identities of OpenID Connect providers.
+type IdentityRepository interface {
+	// GetIdentityByIssuerAndSubject retrieves the identity of an OpenID Connect provider by its issuer and subject
+	GetIdentityByIssuerAndSubject(ctx context.Context, issuer string, subject string) (types.Identity, error)
+	// CreateIdentity links an identity of an OpenID Connect provider to a user in the database
+	CreateIdentity(ctx context.Context, identity *types.Identity) error
+}
+
+// RecoveryCodeRepository is the interface for the storage of the recovery codes of pseudonymous users.
+type RecoveryCodeRepository interface {
+	// ReplaceRecoveryCodes replaces all recovery codes of a user with the given code hashes
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode marks an unused recovery code of a user as used. It returns sql.ErrNoRows, if the user has no
+	// unused recovery code with the hash.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	// CountUnusedRecoveryCodes counts the recovery codes of a user, which have not been used yet
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// ReportShareRepository is the interface for the storage of the share links to reports.
+type ReportShareRepository interface {
+	// CreateReportShare creates a share link to a report in the database
+	CreateReportShare(ctx context.Context, share *types.ReportShare) error
+	// GetReportShareByTokenHash retrieves a share link to a report by the hash of its token
+	GetReportShareByTokenHash(ctx context.Context, tokenHash string) (types.ReportShare, error)
+	// GetReportSharesByOwnerID retrieves all share links of an owner together with their number of views, newest first
+	GetReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.ReportShare, error)
+	// ListReportSharesByOwnerID retrieves a page of the share links of an owner together with their number of views.
+	// The status filter narrows them down to active, revoked or expired shares.
+	ListReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.ReportShare], error)
+	// GetReportShareByIDAndOwnerID retrieves a share link together with its number of views, as long as it belongs to
+	// the given owner
+	GetReportShareByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.ReportShare, error)
+	// RevokeReportShare revokes a share link, as long as it belongs to the given owner. The share is kept for its views,
+	// and its previous version in the row history.
+	RevokeReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error
+	// RevokeReportShareVersion revokes the version of a share link, which has been read before, as long as it belongs
+	// to its owner. It returns ErrVersionConflict, if the share has been revoked or deleted since.
+	RevokeReportShareVersion(ctx context.Context, share types.ReportShare) error
+	// DeleteReportShare moves a share link to the trash, as long as it belongs to the given owner
+	DeleteReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error
+	// DeleteReportShareVersion moves the version of a share link, which has been read before, to the trash, as long as
+	// it belongs to its owner. It returns ErrVersionConflict, if the share has been revoked or deleted since.
+	DeleteReportShareVersion(ctx context.Context, share types.ReportShare) error
+	// GetDeletedReportSharesByOwnerID retrieves the share links of an owner in the trash, most recently deleted first
+	GetDeletedReportSharesByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.ReportShare, error)
+	// RestoreReportShare restores a share link from the trash, as long as it belongs to the given owner
+	RestoreReportShare(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error
+	// CreateReportShareView records a view of a shared report in the database
+	CreateReportShareView(ctx context.Context, view *types.ReportShareView) error
+}
+
+// ProductRepository is the interface for the storage of the products of the users.
+type ProductRepository interface {
+	// CreateProduct creates a product in the database
+	CreateProduct(ctx context.Context, product *types.Product) error
+	// ListProductsByOwnerID retrieves a page of the products of an owner, optionally only those of the kind filter
+	ListProductsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.Product], error)
+	// GetProductByIDAndOwnerID retrieves a product, as long as it belongs to the given owner
+	GetProductByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.Product, error)
+	// UpdateProductVersion updates the version of a product, which has been read before with the UpdatedAt it
+	// carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the product has been changed or
+	// deleted since.
+	UpdateProductVersion(ctx context.Context, product *types.Product) error
+	// DeleteProductVersion moves the version of a product, which has been read before, to the trash, as long as it
+	// belongs to its owner. It returns ErrVersionConflict, if the product has been changed or deleted since.
+	DeleteProductVersion(ctx context.Context, product types.Product) error
+}
+
+// InventoryRepository is the interface for the storage of the inventories of the users.
+type InventoryRepository interface {
+	// CreateInventoryItem creates an item of the inventory in the database
+	CreateInventoryItem(ctx context.Context, item *types.InventoryItem) error
+	// ListInventoryItemsByOwnerID retrieves a page of the inventory of an owner, optionally only the items of the
+	// product_id filter
+	ListInventoryItemsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.InventoryItem], error)
+	// GetInventoryItemByIDAndOwnerID retrieves an item of the inventory, as long as it belongs to the given owner
+	GetInventoryItemByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.InventoryItem, error)
+	// UpdateInventoryItemVersion updates the version of an item of the inventory, which has been read before with
+	// the UpdatedAt it carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the item has
+	// been changed or deleted since.
+	UpdateInventoryItemVersion(ctx context.Context, item *types.InventoryItem) error
+	// DeleteInventoryItemVersion moves the version of an item of the inventory, which has been read before, to the
+	// trash, as long as it belongs to its owner. It returns ErrVersionConflict, if the item has been changed or
+	// deleted since.
+	DeleteInventoryItemVersion(ctx context.Context, item types.InventoryItem) error
+}
+
+// ConsumptionRepository is the interface for the storage of the consumption history of the users.
+type ConsumptionRepository interface {
+	// CreateConsumption creates a consumption in the database
+	CreateConsumption(ctx context.Context, consumption *types.Consumption) error
+	// ListConsumptionsByOwnerID retrieves a page of the consumptions of an owner. The filters narrow them down to a
+	// product, a method and the time of the consumption.
+	ListConsumptionsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.Consumption], error)
+	// GetConsumptionByIDAndOwnerID retrieves a consumption, as long as it belongs to the given owner
+	GetConsumptionByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.Consumption, error)
+	// UpdateConsumptionVersion updates the version of a consumption, which has been read before with the UpdatedAt
+	// it carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the consumption has been
+	// changed or deleted since.
+	UpdateConsumptionVersion(ctx context.Context, consumption *types.Consumption) error
+	// DeleteConsumptionVersion moves the version of a consumption, which has been read before, to the trash, as long
+	// as it belongs to its owner. It returns ErrVersionConflict, if the consumption has been changed or deleted since.
+	DeleteConsumptionVersion(ctx context.Context, consumption types.Consumption) error
+}
+
+// SymptomRatingRepository is the interface for the storage of the ratings of the symptoms of the users.
+type SymptomRatingRepository interface {
+	// CreateSymptomRating creates a rating of a symptom in the database
+	CreateSymptomRating(ctx context.Context, rating *types.SymptomRating) error
+	// ListSymptomRatingsByOwnerID retrieves a page of the ratings of symptoms of an owner. The filters narrow them
+	// down to a minimum severity and the time of the rating.
+	ListSymptomRatingsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.SymptomRating], error)
+	// GetSymptomRatingByIDAndOwnerID retrieves a rating of a symptom, as long as it belongs to the given owner
+	GetSymptomRatingByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.SymptomRating, error)
+	// UpdateSymptomRatingVersion updates the version of a rating of a symptom, which has been read before with the
+	// UpdatedAt it carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the rating has been
+	// changed or deleted since.
+	UpdateSymptomRatingVersion(ctx context.Context, rating *types.SymptomRating) error
+	// DeleteSymptomRatingVersion moves the version of a rating of a symptom, which has been read before, to the
+	// trash, as long as it belongs to its owner. It returns ErrVersionConflict, if the rating has been changed or
+	// deleted since.
+	DeleteSymptomRatingVersion(ctx context.Context, rating types.SymptomRating) error
+}
+
+// DataKeyRepository is the interface for the storage of the wrapped data keys of the accounts.
+type DataKeyRepository interface {
+	// CreateDataKey creates a wrapped data key in the database
+	CreateDataKey(ctx context.Context, key *types.DataKey) error
+	// GetDataKey retrieves a data key by its id
+	GetDataKey(ctx context.Context, id uuid.UUID) (types.DataKey, error)
+	// GetActiveDataKey retrieves the newest data key of an account, which has not been retired
+	GetActiveDataKey(ctx context.Context, accountID uuid.UUID) (types.DataKey, error)
+	// GetDataKeys retrieves all data keys, oldest first
+	GetDataKeys(ctx context.Context) ([]types.DataKey, error)
+	// UpdateDataKey updates the wrapping and the retirement of a data key
+	UpdateDataKey(ctx context.Context, key *types.DataKey) error
+	// DeleteRetiredDataKeys deletes all retired data keys, once no data is encrypted with them anymore
+	DeleteRetiredDataKeys(ctx context.Context) (int64, error)
+}
+
+// RecordVersionRepository is the interface for the row history of the edited records.
+type RecordVersionRepository interface {
+	// GetRecordVersions retrieves the previous versions of a record of the owner, newest first
+	GetRecordVersions(ctx context.Context, tableName string, recordID uuid.UUID, ownerID uuid.UUID) ([]types.RecordVersion, error)
+}
+
+// HealthRepository is the interface for checking the database connection.
+type HealthRepository interface {
+	// Ping checks that the database answers a query
+	Ping(ctx context.Context) error
+	// MigrationVersion returns the version of the last applied migration and whether it failed halfway
+	MigrationVersion(ctx context.Context) (uint, bool, error)
+	// Stats returns the statistics of the connection pool
+	Stats() sql.DBStats
+}
+
+// PrescriptionRepository is the interface for the storage of the prescriptions of the users.
+type PrescriptionRepository interface {
+	// CreatePrescription creates a prescription in the database
+	CreatePrescription(ctx context.Context, prescription *types.Prescription) error
+	// GetPrescriptionsByOwnerID retrieves the prescriptions of an owner, most recently issued first
+	GetPrescriptionsByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]types.Prescription, error)
+}
+
+// Repositories bundles the repositories, which are injected into the handlers and middlewares.
+type Repositories struct {
+	Users         UserRepository
+	Accounts      AccountRepository
+	Sessions      SessionRepository
+	APITokens     APITokenRepository
+	AuditEvents   AuditRepository
+	Delegations   DelegationRepository
+	EmailChanges  EmailChangeRepository
+	Identities    IdentityRepository
+	RecoveryCodes RecoveryCodeRepository
+	ReportShares  ReportShareRepository
+	Products      ProductRepository
+	Inventory     InventoryRepository
+	Consumptions  ConsumptionRepository
+	Symptoms      SymptomRatingRepository
+	Prescriptions PrescriptionRepository
+	DataKeys      DataKeyRepository
+	Versions      RecordVersionRepository
+	Health        HealthRepository
+	// Tx runs writes to multiple repositories atomically
+	Tx UnitOfWork
+}
+
+// NewBunRepositories returns the repositories backed by the bun database connection. The keyring encrypts the
+// sensitive fields, which are stored in plain text without one.
+func NewBunRepositories(db bun.IDB, keyring *Keyring) *Repositories {
+	return &Repositories{
+		Users:         NewBunUserRepository(db),
+		Accounts:      NewBunAccountRepository(db),
+		Sessions:      NewBunSessionRepository(db),
+		APITokens:     NewBunAPITokenRepository(db),
+		AuditEvents:   NewBunAuditRepository(db),
+		Delegations:   NewBunDelegationRepository(db),
+		EmailChanges:  NewBunEmailChangeRepository(db),
+		Identities:    NewBunIdentityRepository(db),
+		RecoveryCodes: NewBunRecoveryCodeRepository(db),
+		ReportShares:  NewBunReportShareRepository(db, keyring),
+		Products:      NewBunProductRepository(db),
+		Inventory:     NewBunInventoryRepository(db),
+		Consumptions:  NewBunConsumptionRepository(db, keyring),
+		Symptoms:      NewBunSymptomRatingRepository(db, keyring),
+		Prescriptions: NewBunPrescriptionRepository(db, keyring),
+		DataKeys:      NewBunDataKeyRepository(db),
+		Versions:      NewBunRecordVersionRepository(db),
+		Health:        NewBunHealthRepository(db),
+		Tx:            NewBunUnitOfWork(db, keyring),
+	}
+}
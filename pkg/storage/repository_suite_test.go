@@ -0,0 +1,707 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// testRepositories runs the same tests against the repositories of every database backend. The database has to be
+// migrated and empty.
+func testRepositories(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	keyring := NewKeyring(NewBunDataKeyRepository(db), testMasterKey(t, 1))
+	repos := NewBunRepositories(db, keyring)
+
+	owner := createTestUser(t, repos, "owner@wits.example", "")
+	delegate := createTestUser(t, repos, "delegate@wits.example", "")
+	pseudonym := createTestUser(t, repos, "", "calm-otter-0001")
+
+	t.Run("users", func(t *testing.T) {
+		user, err := repos.Users.GetAuthenticatedUserByEmail(ctx, owner.Email)
+		if err != nil {
+			t.Fatalf("GetAuthenticatedUserByEmail() error = %v", err)
+		}
+		if user.ID != owner.ID || user.Password != "hash" || user.CreatedAt.IsZero() {
+			t.Errorf("GetAuthenticatedUserByEmail() = %+v, want %+v", user, owner)
+		}
+		if _, err := repos.Users.GetAuthenticatedUserByEmail(ctx, "nobody@wits.example"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAuthenticatedUserByEmail() of an unknown email error = %v, want %v", err, sql.ErrNoRows)
+		}
+		user, err = repos.Users.GetAuthenticatedUserByUsername(ctx, "CALM-Otter-0001")
+		if err != nil {
+			t.Fatalf("GetAuthenticatedUserByUsername() error = %v", err)
+		}
+		if user.ID != pseudonym.ID || !user.Pseudonymous() {
+			t.Errorf("GetAuthenticatedUserByUsername() = %+v, want the pseudonymous %v", user, pseudonym.ID)
+		}
+
+		if err := repos.Users.UpdateAuthenticatedUserPassword(ctx, owner.ID, "new-hash"); err != nil {
+			t.Fatalf("UpdateAuthenticatedUserPassword() error = %v", err)
+		}
+		if err := repos.Users.UpdateAuthenticatedUserEmail(ctx, delegate.ID, "caregiver@wits.example"); err != nil {
+			t.Fatalf("UpdateAuthenticatedUserEmail() error = %v", err)
+		}
+		delegate.Email = "caregiver@wits.example"
+		user, err = repos.Users.GetAuthenticatedUserByID(ctx, owner.ID)
+		if err != nil || user.Password != "new-hash" {
+			t.Errorf("GetAuthenticatedUserByID() = %+v, %v, want the new password", user, err)
+		}
+
+		users, err := repos.Users.GetAuthenticatedUsers(ctx)
+		if err != nil {
+			t.Fatalf("GetAuthenticatedUsers() error = %v", err)
+		}
+		if len(users) != 3 || users[0].Password != "" || users[0].Account.UserID != users[0].ID {
+			t.Errorf("GetAuthenticatedUsers() = %+v, want 3 users with accounts and without passwords", users)
+		}
+
+		stats, err := repos.Users.GetRegistrationStats(ctx)
+		if err != nil {
+			t.Fatalf("GetRegistrationStats() error = %v", err)
+		}
+		if stats.Total != 3 || stats.Last7Days != 3 || len(stats.PerMonth) != 1 || stats.PerMonth[0].Count != 3 {
+			t.Errorf("GetRegistrationStats() = %+v, want 3 users registered this month", stats)
+		}
+	})
+
+	t.Run("accounts", func(t *testing.T) {
+		exists, err := repos.Accounts.ExistsAccountWithUsername(ctx, "Calm-Otter-0001")
+		if err != nil || !exists {
+			t.Errorf("ExistsAccountWithUsername() = %v, %v, want true", exists, err)
+		}
+		account, err := repos.Accounts.GetAccountByUserID(ctx, delegate.ID)
+		if err != nil {
+			t.Fatalf("GetAccountByUserID() error = %v", err)
+		}
+		account.Role = types.RoleCaregiver
+		if err := repos.Accounts.SaveAccount(ctx, &account); err != nil {
+			t.Fatalf("SaveAccount() error = %v", err)
+		}
+		for role, want := range map[types.Role]int{types.RolePatient: 2, types.RoleCaregiver: 1, types.RoleAdmin: 0} {
+			if n, err := repos.Accounts.CountAccountsByRole(ctx, role); err != nil || n != want {
+				t.Errorf("CountAccountsByRole(%v) = %v, %v, want %v", role, n, err, want)
+			}
+		}
+		if err := repos.Accounts.RevokeAccessTokens(ctx, delegate.ID); err != nil {
+			t.Fatalf("RevokeAccessTokens() error = %v", err)
+		}
+		if account, err := repos.Accounts.GetAccountByUserID(ctx, delegate.ID); err != nil || account.TokensRevokedAt.IsZero() || account.Role != types.RoleCaregiver {
+			t.Errorf("GetAccountByUserID() = %+v, %v, want revoked access tokens", account, err)
+		}
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		session := &types.Session{UserID: owner.ID, KeyHash: "key", Data: "data", ExpiresAt: time.Now().Add(time.Hour)}
+		other := &types.Session{UserID: owner.ID, KeyHash: "other", Data: "data", ExpiresAt: time.Now().Add(time.Hour)}
+		expired := &types.Session{UserID: owner.ID, KeyHash: "expired", Data: "data", ExpiresAt: time.Now().Add(-time.Hour)}
+		for _, s := range []*types.Session{session, other, expired} {
+			if err := repos.Sessions.CreateSession(ctx, s); err != nil {
+				t.Fatalf("CreateSession() error = %v", err)
+			}
+		}
+		session.Data = "changed"
+		if err := repos.Sessions.UpdateSession(ctx, session); err != nil {
+			t.Fatalf("UpdateSession() error = %v", err)
+		}
+		if err := repos.Sessions.TouchSession(ctx, session.ID, "curl/8.0", "192.0.2.1"); err != nil {
+			t.Fatalf("TouchSession() error = %v", err)
+		}
+		found, err := repos.Sessions.GetSessionByKeyHash(ctx, "key")
+		if err != nil || found.ID != session.ID || found.Data != "changed" || found.IPAddress != "192.0.2.1" {
+			t.Errorf("GetSessionByKeyHash() = %+v, %v, want the changed and touched session", found, err)
+		}
+		if _, err := repos.Sessions.GetSessionByKeyHash(ctx, "expired"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSessionByKeyHash() of an expired session error = %v, want %v", err, sql.ErrNoRows)
+		}
+		if err := repos.Sessions.DeleteExpiredSessions(ctx); err != nil {
+			t.Fatalf("DeleteExpiredSessions() error = %v", err)
+		}
+		if err := repos.Sessions.DeleteOtherSessionsByUserID(ctx, owner.ID, "key"); err != nil {
+			t.Fatalf("DeleteOtherSessionsByUserID() error = %v", err)
+		}
+		sessions, err := repos.Sessions.GetSessionsByUserID(ctx, owner.ID)
+		if err != nil || len(sessions) != 1 || sessions[0].ID != session.ID {
+			t.Errorf("GetSessionsByUserID() = %+v, %v, want only the current session", sessions, err)
+		}
+	})
+
+	t.Run("login attempts", func(t *testing.T) {
+		store := NewPostgresLoginAttemptStore(db)
+		now := time.Now().UTC().Truncate(time.Second)
+		fail := func(at time.Time) types.LoginAttempt {
+			attempt, err := store.RecordLoginFailure(ctx, types.LoginFailure{Key: "ip:192.0.2.1", At: at, ResetBefore: at.Add(-time.Hour), LockoutThreshold: 3, LockUntil: at.Add(time.Minute)})
+			if err != nil {
+				t.Fatalf("RecordLoginFailure() error = %v", err)
+			}
+			return attempt
+		}
+		for i := 1; i <= 2; i++ {
+			if attempt := fail(now); attempt.Failures != i || !attempt.LockedUntil.IsZero() {
+				t.Errorf("RecordLoginFailure() #%d = %+v, want %d failures without lockout", i, attempt, i)
+			}
+		}
+		if attempt := fail(now); attempt.Failures != 3 || !attempt.LockedUntil.Equal(now.Add(time.Minute)) {
+			t.Errorf("RecordLoginFailure() at the threshold = %+v, want a lockout until %v", attempt, now.Add(time.Minute))
+		}
+		if attempt := fail(now.Add(2 * time.Minute)); attempt.Failures != 1 || !attempt.LockedUntil.IsZero() {
+			t.Errorf("RecordLoginFailure() after the lockout = %+v, want the counter to start over", attempt)
+		}
+		if attempt := fail(now.Add(3 * time.Hour)); attempt.Failures != 1 {
+			t.Errorf("RecordLoginFailure() after the reset = %+v, want the counter to start over", attempt)
+		}
+		if err := store.DeleteLoginAttempt(ctx, "ip:192.0.2.1"); err != nil {
+			t.Fatalf("DeleteLoginAttempt() error = %v", err)
+		}
+		if attempt, err := store.GetLoginAttempt(ctx, "ip:192.0.2.1"); err != nil || attempt.Failures != 0 {
+			t.Errorf("GetLoginAttempt() after the deletion = %+v, %v, want no failures", attempt, err)
+		}
+	})
+
+	t.Run("api tokens", func(t *testing.T) {
+		scopes := []string{types.Scope(types.ResourceDashboard, types.ScopeRead), types.Scope(types.ResourceDashboard, types.ScopeWrite)}
+		token := &types.APIToken{UserID: owner.ID, Name: "test", TokenHash: "token", Prefix: "wits_abc", Scopes: scopes}
+		if err := repos.APITokens.CreateAPIToken(ctx, token); err != nil {
+			t.Fatalf("CreateAPIToken() error = %v", err)
+		}
+		if err := repos.APITokens.TouchAPIToken(ctx, token.ID); err != nil {
+			t.Fatalf("TouchAPIToken() error = %v", err)
+		}
+		found, err := repos.APITokens.GetAPITokenByHash(ctx, "token")
+		if err != nil || !slices.Equal(found.Scopes, scopes) || found.LastUsedAt.IsZero() {
+			t.Errorf("GetAPITokenByHash() = %+v, %v, want the used token with scopes %v", found, err, scopes)
+		}
+		if err := repos.APITokens.DeleteAPITokenByIDAndUserID(ctx, token.ID, delegate.ID); err != nil {
+			t.Fatalf("DeleteAPITokenByIDAndUserID() error = %v", err)
+		}
+		if tokens, err := repos.APITokens.GetAPITokensByUserID(ctx, owner.ID); err != nil || len(tokens) != 1 {
+			t.Errorf("GetAPITokensByUserID() = %+v, %v, want the token, which only its owner can delete", tokens, err)
+		}
+		if err := repos.APITokens.DeleteAPITokensByUserID(ctx, owner.ID); err != nil {
+			t.Fatalf("DeleteAPITokensByUserID() error = %v", err)
+		}
+		if tokens, err := repos.APITokens.GetAPITokensByUserID(ctx, owner.ID); err != nil || len(tokens) != 0 {
+			t.Errorf("GetAPITokensByUserID() after revoking all tokens = %+v, %v, want none", tokens, err)
+		}
+	})
+
+	t.Run("audit events", func(t *testing.T) {
+		event := &types.AuditEvent{Action: types.AuditActionLogin, ActorID: owner.ID, AccountID: owner.ID, Email: owner.Email, IPAddress: "192.0.2.1"}
+		if err := repos.AuditEvents.CreateAuditEvent(ctx, event); err != nil {
+			t.Fatalf("CreateAuditEvent() error = %v", err)
+		}
+		events, err := repos.AuditEvents.SearchAuditEvents(ctx, types.AuditFilter{Action: "login", Email: "OWNER@"})
+		if err != nil || len(events) != 1 || events[0].ID != event.ID {
+			t.Errorf("SearchAuditEvents() = %+v, %v, want the login", events, err)
+		}
+		if events, err := repos.AuditEvents.GetAuditEventsByUserID(ctx, owner.ID, 10); err != nil || len(events) == 0 {
+			t.Errorf("GetAuditEventsByUserID() = %+v, %v, want the login", events, err)
+		}
+		logout := &types.AuditEvent{Action: types.AuditActionLogout, ActorID: owner.ID, Email: owner.Email}
+		if err := repos.AuditEvents.CreateAuditEvent(ctx, logout); err != nil {
+			t.Fatalf("CreateAuditEvent() error = %v", err)
+		}
+		page, err := repos.AuditEvents.ListAuditEventsByUserID(ctx, owner.ID, types.ListOptions{
+			Limit:   1,
+			Sort:    []types.SortField{{Field: "action", Desc: true}},
+			Filters: map[string]string{"created_after": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+		})
+		if err != nil || page.Total != 2 || len(page.Items) != 1 || page.Items[0].ID != logout.ID {
+			t.Errorf("ListAuditEventsByUserID() = %+v, %v, want the logout first of 2 events", page, err)
+		}
+		page, err = repos.AuditEvents.ListAuditEventsByUserID(ctx, owner.ID, types.ListOptions{Offset: 1, Filters: map[string]string{"action": "login"}})
+		if err != nil || page.Total != 1 || len(page.Items) != 0 {
+			t.Errorf("ListAuditEventsByUserID() = %+v, %v, want an empty page of 1 login", page, err)
+		}
+		if _, err := db.NewDelete().Model((*types.AuditEvent)(nil)).Where("id = ?", event.ID).Exec(ctx); err == nil {
+			t.Error("deleting an audit event succeeded, want the audit log to be append-only")
+		}
+	})
+
+	t.Run("delegations", func(t *testing.T) {
+		delegation := &types.Delegation{OwnerID: owner.ID, Email: delegate.Email, Access: types.AccessRead, TokenHash: "invitation"}
+		if err := repos.Delegations.CreateDelegation(ctx, delegation); err != nil {
+			t.Fatalf("CreateDelegation() error = %v", err)
+		}
+		pending, err := repos.Delegations.GetPendingDelegationByTokenHash(ctx, "invitation")
+		if err != nil || pending.ID != delegation.ID || pending.OwnerEmail != owner.Email {
+			t.Errorf("GetPendingDelegationByTokenHash() = %+v, %v, want the invitation of %v", pending, err, owner.Email)
+		}
+		if err := repos.Delegations.AcceptDelegation(ctx, delegation.ID, delegate.ID); err != nil {
+			t.Fatalf("AcceptDelegation() error = %v", err)
+		}
+		if _, err := repos.Delegations.GetAcceptedDelegation(ctx, owner.ID, delegate.ID); err != nil {
+			t.Errorf("GetAcceptedDelegation() error = %v", err)
+		}
+		accepted, err := repos.Delegations.GetAcceptedDelegationsByDelegateID(ctx, delegate.ID)
+		if err != nil || len(accepted) != 1 || !accepted[0].Accepted() {
+			t.Errorf("GetAcceptedDelegationsByDelegateID() = %+v, %v, want the accepted delegation", accepted, err)
+		}
+		if _, err := repos.Delegations.DeleteDelegationByIDAndUserID(ctx, delegation.ID, delegate.ID); err != nil {
+			t.Fatalf("DeleteDelegationByIDAndUserID() error = %v", err)
+		}
+		if delegations, err := repos.Delegations.GetDelegationsByOwnerID(ctx, owner.ID); err != nil || len(delegations) != 0 {
+			t.Errorf("GetDelegationsByOwnerID() = %+v, %v, want none, as the delegate may end the delegation", delegations, err)
+		}
+	})
+
+	t.Run("email changes", func(t *testing.T) {
+		change := &types.EmailChange{UserID: owner.ID, NewEmail: "new@wits.example", TokenHash: "change", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.EmailChanges.CreateEmailChange(ctx, change); err != nil {
+			t.Fatalf("CreateEmailChange() error = %v", err)
+		}
+		if found, err := repos.EmailChanges.GetEmailChangeByTokenHash(ctx, "change"); err != nil || found.NewEmail != change.NewEmail {
+			t.Errorf("GetEmailChangeByTokenHash() = %+v, %v, want %+v", found, err, change)
+		}
+		if err := repos.EmailChanges.DeleteEmailChangesByUserID(ctx, owner.ID); err != nil {
+			t.Fatalf("DeleteEmailChangesByUserID() error = %v", err)
+		}
+		if _, err := repos.EmailChanges.GetEmailChangeByTokenHash(ctx, "change"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetEmailChangeByTokenHash() of a deleted change error = %v, want %v", err, sql.ErrNoRows)
+		}
+	})
+
+	t.Run("identities", func(t *testing.T) {
+		identity := &types.Identity{UserID: owner.ID, Issuer: "https://issuer.example", Subject: "42", Email: owner.Email}
+		if err := repos.Identities.CreateIdentity(ctx, identity); err != nil {
+			t.Fatalf("CreateIdentity() error = %v", err)
+		}
+		if found, err := repos.Identities.GetIdentityByIssuerAndSubject(ctx, identity.Issuer, "42"); err != nil || found.UserID != owner.ID {
+			t.Errorf("GetIdentityByIssuerAndSubject() = %+v, %v, want the identity of %v", found, err, owner.ID)
+		}
+	})
+
+	t.Run("recovery codes", func(t *testing.T) {
+		if err := repos.RecoveryCodes.ReplaceRecoveryCodes(ctx, pseudonym.ID, []string{"a", "b", "c"}); err != nil {
+			t.Fatalf("ReplaceRecoveryCodes() error = %v", err)
+		}
+		if err := repos.RecoveryCodes.UseRecoveryCode(ctx, pseudonym.ID, "b"); err != nil {
+			t.Fatalf("UseRecoveryCode() error = %v", err)
+		}
+		if err := repos.RecoveryCodes.UseRecoveryCode(ctx, pseudonym.ID, "b"); err == nil {
+			t.Error("UseRecoveryCode() of a used code succeeded, want an error")
+		}
+		if n, err := repos.RecoveryCodes.CountUnusedRecoveryCodes(ctx, pseudonym.ID); err != nil || n != 2 {
+			t.Errorf("CountUnusedRecoveryCodes() = %v, %v, want 2", n, err)
+		}
+	})
+
+	t.Run("report shares", func(t *testing.T) {
+		share := &types.ReportShare{
+			OwnerID:   owner.ID,
+			Name:      "doctor",
+			TokenHash: "share",
+			From:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:        time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		if err := repos.ReportShares.CreateReportShare(ctx, share); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		if err := repos.ReportShares.CreateReportShareView(ctx, &types.ReportShareView{ShareID: share.ID, IPAddress: "192.0.2.1"}); err != nil {
+			t.Fatalf("CreateReportShareView() error = %v", err)
+		}
+		found, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "share")
+		if err != nil || !found.From.Equal(share.From) || !found.Active() {
+			t.Errorf("GetReportShareByTokenHash() = %+v, %v, want the active share from %v", found, err, share.From)
+		}
+		if err := repos.ReportShares.RevokeReportShare(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("RevokeReportShare() error = %v", err)
+		}
+		shares, err := repos.ReportShares.GetReportSharesByOwnerID(ctx, owner.ID)
+		if err != nil || len(shares) != 1 || shares[0].Views != 1 || !shares[0].Revoked() {
+			t.Errorf("GetReportSharesByOwnerID() = %+v, %v, want the revoked share with 1 view", shares, err)
+		}
+		for status, want := range map[string]int{"revoked": 1, "active": 0} {
+			page, err := repos.ReportShares.ListReportSharesByOwnerID(ctx, owner.ID, types.ListOptions{Filters: map[string]string{"status": status}})
+			if err != nil || page.Total != want || len(page.Items) != want {
+				t.Errorf("ListReportSharesByOwnerID() of %s shares = %+v, %v, want %d", status, page, err, want)
+			}
+		}
+		found, err = repos.ReportShares.GetReportShareByIDAndOwnerID(ctx, share.ID, owner.ID)
+		if err != nil || found.Name != "doctor" || found.Views != 1 {
+			t.Errorf("GetReportShareByIDAndOwnerID() = %+v, %v, want the decrypted share with 1 view", found, err)
+		}
+		if _, err := repos.ReportShares.GetReportShareByIDAndOwnerID(ctx, share.ID, delegate.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetReportShareByIDAndOwnerID() of another owner error = %v, want %v", err, sql.ErrNoRows)
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		share := &types.ReportShare{OwnerID: owner.ID, Name: "dentist", TokenHash: "trash", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.ReportShares.CreateReportShare(ctx, share); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		if err := repos.ReportShares.RevokeReportShare(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("RevokeReportShare() error = %v", err)
+		}
+		versions, err := repos.Versions.GetRecordVersions(ctx, "report_shares", share.ID, owner.ID)
+		if err != nil || len(versions) != 1 || strings.Contains(versions[0].Data, "dentist") {
+			t.Errorf("GetRecordVersions() = %+v, %v, want the encrypted version before the revocation", versions, err)
+		}
+
+		if err := repos.ReportShares.DeleteReportShare(ctx, share.ID, delegate.ID); err != nil {
+			t.Fatalf("DeleteReportShare() of another owner error = %v", err)
+		}
+		if err := repos.ReportShares.DeleteReportShare(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("DeleteReportShare() error = %v", err)
+		}
+		if _, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "trash"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetReportShareByTokenHash() of a deleted share error = %v, want %v", err, sql.ErrNoRows)
+		}
+		trash, err := repos.ReportShares.GetDeletedReportSharesByOwnerID(ctx, owner.ID)
+		if err != nil || len(trash) != 1 || trash[0].Name != "dentist" || !trash[0].Revoked() {
+			t.Errorf("GetDeletedReportSharesByOwnerID() = %+v, %v, want the revoked share", trash, err)
+		}
+		if err := repos.ReportShares.RestoreReportShare(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("RestoreReportShare() error = %v", err)
+		}
+		if _, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "trash"); err != nil {
+			t.Errorf("GetReportShareByTokenHash() of a restored share error = %v", err)
+		}
+
+		if err := repos.ReportShares.DeleteReportShare(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("DeleteReportShare() error = %v", err)
+		}
+		if n, err := PurgeTrash(ctx, db, time.Now()); err != nil || n != 0 {
+			t.Errorf("PurgeTrash() within the retention = %d, %v, want 0", n, err)
+		}
+		if n, err := PurgeTrash(ctx, db, time.Now().Add(types.TrashRetention+time.Hour)); err != nil || n != 1 {
+			t.Errorf("PurgeTrash() after the retention = %d, %v, want 1", n, err)
+		}
+		if exists, err := db.NewSelect().Model((*types.ReportShare)(nil)).WhereAllWithDeleted().Where("id = ?", share.ID).Exists(ctx); err != nil || exists {
+			t.Errorf("purged share exists = %v, %v, want false", exists, err)
+		}
+		if versions, err := repos.Versions.GetRecordVersions(ctx, "report_shares", share.ID, owner.ID); err != nil || len(versions) != 0 {
+			t.Errorf("GetRecordVersions() of a purged share = %+v, %v, want none", versions, err)
+		}
+	})
+
+	t.Run("report share versions", func(t *testing.T) {
+		share := &types.ReportShare{OwnerID: owner.ID, Name: "nurse", TokenHash: "versions", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.ReportShares.CreateReportShare(ctx, share); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		read, err := repos.ReportShares.GetReportShareByIDAndOwnerID(ctx, share.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("GetReportShareByIDAndOwnerID() error = %v", err)
+		}
+		if err := repos.ReportShares.RevokeReportShareVersion(ctx, read); err != nil {
+			t.Fatalf("RevokeReportShareVersion() error = %v", err)
+		}
+		if err := repos.ReportShares.RevokeReportShareVersion(ctx, read); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("RevokeReportShareVersion() of a revoked share error = %v, want %v", err, ErrVersionConflict)
+		}
+		if err := repos.ReportShares.DeleteReportShareVersion(ctx, read); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("DeleteReportShareVersion() of a stale version error = %v, want %v", err, ErrVersionConflict)
+		}
+		if read, err = repos.ReportShares.GetReportShareByIDAndOwnerID(ctx, share.ID, owner.ID); err != nil {
+			t.Fatalf("GetReportShareByIDAndOwnerID() error = %v", err)
+		}
+		if err := repos.ReportShares.DeleteReportShareVersion(ctx, read); err != nil {
+			t.Errorf("DeleteReportShareVersion() of the current version error = %v", err)
+		}
+		if err := repos.ReportShares.DeleteReportShareVersion(ctx, read); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("DeleteReportShareVersion() of a deleted share error = %v, want %v", err, ErrVersionConflict)
+		}
+	})
+
+	t.Run("tracked records", func(t *testing.T) {
+		product := &types.Product{OwnerID: owner.ID, Name: "Bedrocan", Kind: types.ProductKindFlower, THC: 22}
+		oil := &types.Product{OwnerID: owner.ID, Name: "CBD oil", Kind: types.ProductKindOil, CBD: 10}
+		for _, p := range []*types.Product{product, oil} {
+			if err := repos.Products.CreateProduct(ctx, p); err != nil {
+				t.Fatalf("CreateProduct() error = %v", err)
+			}
+		}
+		products, err := repos.Products.ListProductsByOwnerID(ctx, owner.ID, types.ListOptions{Sort: []types.SortField{{Field: "name"}}, Filters: map[string]string{"kind": "oil"}})
+		if err != nil || products.Total != 1 || products.Items[0].ID != oil.ID {
+			t.Errorf("ListProductsByOwnerID() of oils = %+v, %v, want the oil", products, err)
+		}
+		if _, err := repos.Products.GetProductByIDAndOwnerID(ctx, product.ID, delegate.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetProductByIDAndOwnerID() of another owner error = %v, want %v", err, sql.ErrNoRows)
+		}
+		read, err := repos.Products.GetProductByIDAndOwnerID(ctx, product.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("GetProductByIDAndOwnerID() error = %v", err)
+		}
+		stale := read
+		read.THC = 19
+		if err := repos.Products.UpdateProductVersion(ctx, &read); err != nil {
+			t.Fatalf("UpdateProductVersion() error = %v", err)
+		}
+		if err := repos.Products.UpdateProductVersion(ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("UpdateProductVersion() of a stale version error = %v, want %v", err, ErrVersionConflict)
+		}
+		if found, err := repos.Products.GetProductByIDAndOwnerID(ctx, product.ID, owner.ID); err != nil || found.THC != 19 {
+			t.Errorf("GetProductByIDAndOwnerID() after the update = %+v, %v, want the new THC", found, err)
+		}
+		if versions, err := repos.Versions.GetRecordVersions(ctx, "products", product.ID, owner.ID); err != nil || len(versions) != 1 {
+			t.Errorf("GetRecordVersions() of the product = %+v, %v, want the version before the update", versions, err)
+		}
+
+		item := &types.InventoryItem{OwnerID: owner.ID, ProductID: product.ID, Amount: 10, AcquiredAt: time.Now().Add(-time.Hour)}
+		if err := repos.Inventory.CreateInventoryItem(ctx, item); err != nil {
+			t.Fatalf("CreateInventoryItem() error = %v", err)
+		}
+		items, err := repos.Inventory.ListInventoryItemsByOwnerID(ctx, owner.ID, types.ListOptions{Filters: map[string]string{"product_id": oil.ID.String()}})
+		if err != nil || items.Total != 0 {
+			t.Errorf("ListInventoryItemsByOwnerID() of the oil = %+v, %v, want none", items, err)
+		}
+		readItem, err := repos.Inventory.GetInventoryItemByIDAndOwnerID(ctx, item.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("GetInventoryItemByIDAndOwnerID() error = %v", err)
+		}
+		readItem.Amount = 8.5
+		if err := repos.Inventory.UpdateInventoryItemVersion(ctx, &readItem); err != nil {
+			t.Fatalf("UpdateInventoryItemVersion() error = %v", err)
+		}
+
+		consumption := &types.Consumption{OwnerID: owner.ID, ProductID: product.ID, Amount: 0.1, Method: types.ConsumptionMethodVaporized, ConsumedAt: time.Now().Add(-30 * time.Minute), Notes: "helped with the pain"}
+		if err := repos.Consumptions.CreateConsumption(ctx, consumption); err != nil {
+			t.Fatalf("CreateConsumption() error = %v", err)
+		}
+		var raw types.Consumption
+		if err := db.NewSelect().Model(&raw).Where("id = ?", consumption.ID).Scan(ctx); err != nil {
+			t.Fatalf("selecting the stored consumption error = %v", err)
+		}
+		if !raw.Encrypted || !strings.HasPrefix(raw.Notes, encryptedPrefix) {
+			t.Errorf("stored consumption = %+v, want the notes to be encrypted", raw)
+		}
+		consumptions, err := repos.Consumptions.ListConsumptionsByOwnerID(ctx, owner.ID, types.ListOptions{Filters: map[string]string{"method": "vaporized", "consumed_after": time.Now().Add(-time.Hour).Format(time.RFC3339)}})
+		if err != nil || consumptions.Total != 1 || consumptions.Items[0].Notes != "helped with the pain" {
+			t.Errorf("ListConsumptionsByOwnerID() = %+v, %v, want the decrypted consumption", consumptions, err)
+		}
+		readConsumption := consumptions.Items[0]
+		readConsumption.Notes = "helped a lot"
+		if err := repos.Consumptions.UpdateConsumptionVersion(ctx, &readConsumption); err != nil || readConsumption.Notes != "helped a lot" {
+			t.Fatalf("UpdateConsumptionVersion() = %+v, %v, want the decrypted notes", readConsumption, err)
+		}
+		if err := repos.Consumptions.DeleteConsumptionVersion(ctx, consumptions.Items[0]); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("DeleteConsumptionVersion() of a stale version error = %v, want %v", err, ErrVersionConflict)
+		}
+		if err := repos.Consumptions.DeleteConsumptionVersion(ctx, readConsumption); err != nil {
+			t.Errorf("DeleteConsumptionVersion() of the current version error = %v", err)
+		}
+		if _, err := repos.Consumptions.GetConsumptionByIDAndOwnerID(ctx, consumption.ID, owner.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetConsumptionByIDAndOwnerID() of a deleted consumption error = %v, want %v", err, sql.ErrNoRows)
+		}
+
+		for severity := range 3 {
+			rating := &types.SymptomRating{OwnerID: owner.ID, Symptom: "pain", Severity: 3 * severity, RatedAt: time.Now()}
+			if err := repos.Symptoms.CreateSymptomRating(ctx, rating); err != nil {
+				t.Fatalf("CreateSymptomRating() error = %v", err)
+			}
+		}
+		ratings, err := repos.Symptoms.ListSymptomRatingsByOwnerID(ctx, owner.ID, types.ListOptions{Sort: []types.SortField{{Field: "severity", Desc: true}}, Filters: map[string]string{"min_severity": "3"}})
+		if err != nil || ratings.Total != 2 || ratings.Items[0].Severity != 6 || ratings.Items[0].Symptom != "pain" {
+			t.Errorf("ListSymptomRatingsByOwnerID() = %+v, %v, want the two decrypted ratings from severity 3, worst first", ratings, err)
+		}
+		readRating := ratings.Items[0]
+		readRating.Severity = 5
+		if err := repos.Symptoms.UpdateSymptomRatingVersion(ctx, &readRating); err != nil {
+			t.Fatalf("UpdateSymptomRatingVersion() error = %v", err)
+		}
+		if found, err := repos.Symptoms.GetSymptomRatingByIDAndOwnerID(ctx, readRating.ID, owner.ID); err != nil || found.Severity != 5 || found.Symptom != "pain" {
+			t.Errorf("GetSymptomRatingByIDAndOwnerID() after the update = %+v, %v, want severity 5", found, err)
+		}
+		if err := repos.Symptoms.DeleteSymptomRatingVersion(ctx, readRating); err != nil {
+			t.Errorf("DeleteSymptomRatingVersion() error = %v", err)
+		}
+
+		for months := range 2 {
+			issuedAt := time.Now().AddDate(0, -months, 0)
+			prescription := &types.Prescription{OwnerID: owner.ID, ProductID: product.ID, Doctor: "Dr. Green", Amount: 30, IssuedAt: issuedAt, ExpiresAt: issuedAt.AddDate(0, 1, 0)}
+			if err := repos.Prescriptions.CreatePrescription(ctx, prescription); err != nil {
+				t.Fatalf("CreatePrescription() error = %v", err)
+			}
+		}
+		prescriptions, err := repos.Prescriptions.GetPrescriptionsByOwnerID(ctx, owner.ID)
+		if err != nil || len(prescriptions) != 2 || prescriptions[0].Doctor != "Dr. Green" || !prescriptions[0].IssuedAt.After(prescriptions[1].IssuedAt) {
+			t.Errorf("GetPrescriptionsByOwnerID() = %+v, %v, want the two decrypted prescriptions, latest first", prescriptions, err)
+		}
+	})
+
+	t.Run("encryption", func(t *testing.T) {
+		share := &types.ReportShare{OwnerID: delegate.ID, Name: "Dr. Who", TokenHash: "encrypted", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.ReportShares.CreateReportShare(ctx, share); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		if share.Name != "Dr. Who" {
+			t.Errorf("CreateReportShare() changed the name to %v", share.Name)
+		}
+		stored := func() string {
+			var name string
+			if err := db.NewSelect().Model((*types.ReportShare)(nil)).Column("name").Where("id = ?", share.ID).Scan(ctx, &name); err != nil {
+				t.Fatalf("selecting the stored name error = %v", err)
+			}
+			return name
+		}
+		before := stored()
+		if !strings.HasPrefix(before, encryptedPrefix) {
+			t.Errorf("stored name = %v, want it to be encrypted", before)
+		}
+
+		// The encrypted name is bound to its share and cannot be moved to another share of the owner
+		other := &types.ReportShare{OwnerID: delegate.ID, Name: "Dr. No", TokenHash: "moved", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.ReportShares.CreateReportShare(ctx, other); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		if _, err := db.NewUpdate().Model((*types.ReportShare)(nil)).Set("name = ?", before).Where("id = ?", other.ID).Exec(ctx); err != nil {
+			t.Fatalf("moving the encrypted name failed with %v", err)
+		}
+		if moved, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "moved"); err == nil {
+			t.Errorf("GetReportShareByTokenHash() of a moved name = %+v, want an error", moved)
+		}
+
+		if _, err := db.NewDelete().Model((*types.ReportShare)(nil)).Where("id = ?", other.ID).ForceDelete().Exec(ctx); err != nil {
+			t.Fatalf("deleting the moved share failed with %v", err)
+		}
+
+		// A name chosen like an encrypted value is encrypted as any other name
+		lookalike := &types.ReportShare{OwnerID: delegate.ID, Name: before, TokenHash: "lookalike", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repos.ReportShares.CreateReportShare(ctx, lookalike); err != nil {
+			t.Fatalf("CreateReportShare() error = %v", err)
+		}
+		var raw types.ReportShare
+		if err := db.NewSelect().Model(&raw).Where("id = ?", lookalike.ID).Scan(ctx); err != nil {
+			t.Fatalf("selecting the stored share error = %v", err)
+		}
+		if !raw.Encrypted || raw.Name == before {
+			t.Errorf("stored share = %+v, want the name to be encrypted", raw)
+		}
+		if found, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "lookalike"); err != nil || found.Name != before {
+			t.Errorf("GetReportShareByTokenHash() of a name like an encrypted value = %+v, %v, want the name %v", found, err, before)
+		}
+		if _, err := db.NewDelete().Model((*types.ReportShare)(nil)).Where("id = ?", lookalike.ID).ForceDelete().Exec(ctx); err != nil {
+			t.Fatalf("deleting the lookalike share failed with %v", err)
+		}
+
+		// Every account has only one active data key
+		active, err := NewBunDataKeyRepository(db).GetActiveDataKey(ctx, delegate.ID)
+		if err != nil {
+			t.Fatalf("GetActiveDataKey() error = %v", err)
+		}
+		duplicate := types.DataKey{ID: uuid.New(), AccountID: delegate.ID, MasterKeyID: active.MasterKeyID, WrappedKey: active.WrappedKey}
+		if err := NewBunDataKeyRepository(db).CreateDataKey(ctx, &duplicate); err == nil {
+			t.Errorf("CreateDataKey() of a second active data key error = nil, want a unique violation")
+		}
+
+		// Rotating the data keys re-encrypts the data, rewrapping with a new master key keeps the data as it is
+		if _, err := keyring.RotateDataKeys(ctx, db); err != nil {
+			t.Fatalf("RotateDataKeys() error = %v", err)
+		}
+		if after := stored(); after == before || !strings.HasPrefix(after, encryptedPrefix) {
+			t.Errorf("stored name after rotation = %v, want it to be encrypted with another key", after)
+		}
+		rotated := NewKeyring(NewBunDataKeyRepository(db), testMasterKey(t, 2), testMasterKey(t, 1))
+		if n, err := rotated.Rewrap(ctx); err != nil || n == 0 {
+			t.Fatalf("Rewrap() = %v, %v, want the data keys to be wrapped again", n, err)
+		}
+		repos := NewBunRepositories(db, NewKeyring(NewBunDataKeyRepository(db), testMasterKey(t, 2)))
+		found, err := repos.ReportShares.GetReportShareByTokenHash(ctx, "encrypted")
+		if err != nil || found.Name != "Dr. Who" {
+			t.Errorf("GetReportShareByTokenHash() with the new master key = %+v, %v, want the decrypted name", found, err)
+		}
+		if _, err := NewBunRepositories(db, nil).ReportShares.GetReportShareByTokenHash(ctx, "encrypted"); !errors.Is(err, ErrNoMasterKey) {
+			t.Errorf("GetReportShareByTokenHash() without a master key error = %v, want %v", err, ErrNoMasterKey)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		createUser := func(email string, result error) (types.AuthenticatedUser, error) {
+			user := types.AuthenticatedUser{Email: email, Password: "hash"}
+			err := repos.Tx.RunInTx(ctx, func(ctx context.Context, repos *Repositories) error {
+				if err := repos.Users.CreateAuthenticatedUser(ctx, &user); err != nil {
+					return err
+				}
+				user.Account = types.Account{UserID: user.ID}
+				if err := repos.Accounts.CreateAccount(ctx, &user.Account); err != nil {
+					return err
+				}
+				return result
+			})
+			return user, err
+		}
+
+		accountChanges := func() int {
+			events, err := repos.AuditEvents.SearchAuditEvents(ctx, types.AuditFilter{Action: "accounts.create", Limit: 1000})
+			if err != nil {
+				t.Fatalf("SearchAuditEvents() error = %v", err)
+			}
+			return len(events)
+		}
+		before := accountChanges()
+
+		failure := errors.New("failure")
+		if _, err := createUser("rollback@wits.test", failure); !errors.Is(err, failure) {
+			t.Fatalf("RunInTx() error = %v, want %v", err, failure)
+		}
+		if _, err := repos.Users.GetAuthenticatedUserByEmail(ctx, "rollback@wits.test"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAuthenticatedUserByEmail() after rollback error = %v, want %v", err, sql.ErrNoRows)
+		}
+		if got := accountChanges(); got != before {
+			t.Errorf("audit events of account creations after rollback = %d, want %d", got, before)
+		}
+
+		user, err := createUser("commit@wits.test", nil)
+		if err != nil {
+			t.Fatalf("RunInTx() error = %v", err)
+		}
+		if account, err := repos.Accounts.GetAccountByUserID(ctx, user.ID); err != nil || account.ID != user.Account.ID {
+			t.Errorf("GetAccountByUserID() after commit = %+v, %v, want the account %v", account, err, user.Account.ID)
+		}
+		if got := accountChanges(); got != before+1 {
+			t.Errorf("audit events of account creations after commit = %d, want %d", got, before+1)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		if err := repos.Health.Ping(ctx); err != nil {
+			t.Errorf("Ping() error = %v", err)
+		}
+		want, err := migrations.LatestVersion(db.Dialect().Name().String())
+		if err != nil {
+			t.Fatalf("LatestVersion() error = %v", err)
+		}
+		if version, dirty, err := repos.Health.MigrationVersion(ctx); err != nil || version != want || dirty {
+			t.Errorf("MigrationVersion() = %v, %v, %v, want %v, false", version, dirty, err, want)
+		}
+	})
+}
+
+// createTestUser creates a user with an account, which is pseudonymous if the username is given.
+func createTestUser(t *testing.T, repos *Repositories, email string, username string) types.AuthenticatedUser {
+	t.Helper()
+	ctx := context.Background()
+	user := types.AuthenticatedUser{Email: email, Password: "hash"}
+	if err := repos.Users.CreateAuthenticatedUser(ctx, &user); err != nil {
+		t.Fatalf("CreateAuthenticatedUser() error = %v", err)
+	}
+	user.Account = types.Account{UserID: user.ID, Username: username}
+	if err := repos.Accounts.CreateAccount(ctx, &user.Account); err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+	return user
+}
+
+// testMasterKey returns a fixed master key, which differs for every n.
+func testMasterKey(t *testing.T, n byte) MasterKey {
+	t.Helper()
+	key, err := ParseMasterKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{n}, 32)))
+	if err != nil {
+		t.Fatalf("ParseMasterKey() error = %v", err)
+	}
+	return key
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunSessionRepository is the SessionRepository backed by the sessions table.
+type BunSessionRepository struct {
+	db bun.IDB
+}
+
+// NewBunSessionRepository returns a new BunSessionRepository using the database connection.
+func NewBunSessionRepository(db bun.IDB) *BunSessionRepository {
+	return &BunSessionRepository{db: db}
+}
+
+// CreateSession creates a session in the database
+func (r *BunSessionRepository) CreateSession(ctx context.Context, session *types.Session) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) CreateSession()")
+	_, err := r.db.NewInsert().Model(session).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) CreateSession() -> 📂 Session creation finished with", "error", err)
+	return err
+}
+
+// UpdateSession updates the data, owner, device and expiry of a session in the database
+func (r *BunSessionRepository) UpdateSession(ctx context.Context, session *types.Session) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) UpdateSession()")
+	session.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().Model(session).
+		Column("user_id", "data", "user_agent", "ip_address", "last_seen_at", "expires_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) UpdateSession() -> 📂 Session update finished with", "error", err)
+	return err
+}
+
+// TouchSession records that the session has been seen from the given device
+func (r *BunSessionRepository) TouchSession(ctx context.Context, id uuid.UUID, userAgent string, ipAddress string) error {
+	slog.Debug("💬 💾 (pkg/storage/session_repo.go) TouchSession()")
+	_, err := r.db.NewUpdate().Model((*types.Session)(nil)).
+		Set("last_seen_at = ?", time.Now()).
+		Set("user_agent = ?", userAgent).
+		Set("ip_address = ?", ipAddress).
+		Where("id = ?", id).
+		Exec(ctx)
+	slog.Debug("✅ 💾 (pkg/storage/session_repo.go) TouchSession() -> 📂 Session touch finished with", "error", err)
+	return err
+}
+
+// GetSessionByKeyHash retrieves an unexpired session by the hash of its key
+func (r *BunSessionRepository) GetSessionByKeyHash(ctx context.Context, keyHash string) (types.Session, error) {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) GetSessionByKeyHash()")
+	var session types.Session
+	err := r.db.NewSelect().Model(&session).
+		Where("key_hash = ?", keyHash).
+		Where("expires_at > ?", time.Now()).
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) GetSessionByKeyHash() -> 📂 Session retrieval finished with", "error", err)
+	return session, err
+}
+
+// GetSessionsByUserID retrieves all unexpired sessions of a user, most recently seen first
+func (r *BunSessionRepository) GetSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]types.Session, error) {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) GetSessionsByUserID()")
+	var sessions []types.Session
+	err := r.db.NewSelect().Model(&sessions).
+		Where("user_id = ?", userID).
+		Where("expires_at > ?", time.Now()).
+		Order("last_seen_at DESC").
+		Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) GetSessionsByUserID() -> 📂 Session retrieval finished with", "count", len(sessions), "error", err)
+	return sessions, err
+}
+
+// DeleteSessionByKeyHash deletes the session with the given key hash
+func (r *BunSessionRepository) DeleteSessionByKeyHash(ctx context.Context, keyHash string) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) DeleteSessionByKeyHash()")
+	_, err := r.db.NewDelete().Model((*types.Session)(nil)).Where("key_hash = ?", keyHash).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) DeleteSessionByKeyHash() -> 📂 Session deletion finished with", "error", err)
+	return err
+}
+
+// DeleteSessionByIDAndUserID deletes a single session, as long as it belongs to the given user
+func (r *BunSessionRepository) DeleteSessionByIDAndUserID(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) DeleteSessionByIDAndUserID()")
+	_, err := r.db.NewDelete().Model((*types.Session)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) DeleteSessionByIDAndUserID() -> 📂 Session deletion finished with", "error", err)
+	return err
+}
+
+// DeleteSessionsByUserID deletes all sessions of a user, logging them out everywhere
+func (r *BunSessionRepository) DeleteSessionsByUserID(ctx context.Context, userID uuid.UUID) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) DeleteSessionsByUserID()")
+	_, err := r.db.NewDelete().Model((*types.Session)(nil)).Where("user_id = ?", userID).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) DeleteSessionsByUserID() -> 📂 Session deletion finished with", "error", err)
+	return err
+}
+
+// DeleteExpiredSessions deletes all sessions which are past their expiry
+func (r *BunSessionRepository) DeleteExpiredSessions(ctx context.Context) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) DeleteExpiredSessions()")
+	_, err := r.db.NewDelete().Model((*types.Session)(nil)).Where("expires_at <= ?", time.Now()).Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) DeleteExpiredSessions() -> 📂 Session cleanup finished with", "error", err)
+	return err
+}
+
+// DeleteOtherSessionsByUserID deletes all sessions of a user except the one with the given key hash
+func (r *BunSessionRepository) DeleteOtherSessionsByUserID(ctx context.Context, userID uuid.UUID, keyHash string) error {
+	slog.Info("💬 💾 (pkg/storage/session_repo.go) DeleteOtherSessionsByUserID()")
+	_, err := r.db.NewDelete().Model((*types.Session)(nil)).
+		Where("user_id = ?", userID).
+		Where("key_hash <> ?", keyHash).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/session_repo.go) DeleteOtherSessionsByUserID() -> 📂 Session deletion finished with", "error", err)
+	return err
+}
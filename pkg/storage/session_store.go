@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// sessionTouchInterval is the minimum time between two updates of the last seen time of a session.
+const sessionTouchInterval = time.Minute
+
+// PostgresStore is a sessions.Store which keeps the session values in the sessions table. The cookie only carries
+// the signed session key, so sessions can be listed and revoked on the server.
+type PostgresStore struct {
+	Codecs   []securecookie.Codec
+	Options  *sessions.Options // default configuration
+	sessions SessionRepository
+	clientIP func(*http.Request) string
+}
+
+func init() {
+	// Session values contain user ids, which need to be known to gob for encoding and decoding
+	gob.Register(uuid.UUID{})
+}
+
+// NewPostgresStore returns a new PostgresStore keeping the sessions in the repository, with the cookie options and
+// the secret signing the cookies of the configuration. The client IP of a session is taken from the request with
+// clientIP, which only trusts the headers of the configured proxies.
+func NewPostgresStore(sessionRepo SessionRepository, cfg config.Auth, clientIP func(*http.Request) string) *PostgresStore {
+	ps := &PostgresStore{
+		sessions: sessionRepo,
+		clientIP: clientIP,
+		Codecs:   securecookie.CodecsFromPairs([]byte(cfg.SessionSecret.Value())),
+		Options: &sessions.Options{
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+			Secure:   cfg.SessionCookieSecure,
+			HttpOnly: true,
+		},
+	}
+	ps.MaxAge(int(cfg.SessionMaxAge.Seconds()))
+	return ps
+}
+
+// InitSessionStore returns the session store with the configuration of the cookies and removes expired sessions.
+func InitSessionStore(ctx context.Context, sessionRepo SessionRepository, cfg config.Auth, clientIP func(*http.Request) string) (*PostgresStore, error) {
+	slog.Info("💬 💾 (pkg/storage/session_store.go) InitSessionStore()")
+	if err := sessionRepo.DeleteExpiredSessions(ctx); err != nil {
+		slog.Error("🚨 💾 (pkg/storage/session_store.go) ❓❓❓❓ 🍪 Removing expired sessions failed with", "error", err)
+		return nil, err
+	}
+	slog.Info("✅ 💾 (pkg/storage/session_store.go) InitSessionStore() -> 🍪 Using Postgres session store")
+	return NewPostgresStore(sessionRepo, cfg, clientIP), nil
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (s *PostgresStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the registry. If the request carries a cookie for
+// a known, unexpired session, its values are loaded and the last seen time and device of the session are updated.
+func (s *PostgresStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	c, errCookie := r.Cookie(name)
+	if errCookie != nil {
+		return session, nil
+	}
+	var key string
+	if err := securecookie.DecodeMulti(name, c.Value, &key, s.Codecs...); err != nil {
+		return session, err
+	}
+	record, err := s.sessions.GetSessionByKeyHash(r.Context(), HashToken(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		// The session has expired or was revoked, so the client starts over with a new one
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, record.Data, &session.Values, s.Codecs...); err != nil {
+		return session, err
+	}
+	session.ID = key
+	session.IsNew = false
+
+	if time.Since(record.LastSeenAt) > sessionTouchInterval {
+		if err := s.sessions.TouchSession(r.Context(), record.ID, r.UserAgent(), s.clientIP(r)); err != nil {
+			slog.Error("🚨 💾 (pkg/storage/session_store.go) ❓❓❓❓ 🍪 Updating last seen time of session failed with", "error", err)
+		}
+	}
+	return session, nil
+}
+
+// Save persists the session values and sets the session cookie. If the MaxAge of the session is <= 0, the session
+// is deleted from the database and the cookie is removed.
+func (s *PostgresStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.sessions.DeleteSessionByKeyHash(r.Context(), HashToken(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	record := types.Session{
+		UserID:     sessionUserID(session),
+		Data:       data,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  s.clientIP(r),
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+
+	existing := types.Session{}
+	if session.ID != "" {
+		existing, err = s.sessions.GetSessionByKeyHash(r.Context(), HashToken(session.ID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	if existing.ID != uuid.Nil {
+		record.ID = existing.ID
+		record.KeyHash = existing.KeyHash
+		err = s.sessions.UpdateSession(r.Context(), &record)
+	} else {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+		record.ID = uuid.New()
+		record.KeyHash = HashToken(session.ID)
+		err = s.sessions.CreateSession(r.Context(), &record)
+	}
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Renew deletes the stored session, so it gets a new key when it is saved next, keeping its values. It is called on
+// login, so a session key planted before the login (session fixation) is worthless afterwards.
+func (s *PostgresStore) Renew(r *http.Request, session *sessions.Session) error {
+	if session.ID != "" {
+		if err := s.sessions.DeleteSessionByKeyHash(r.Context(), HashToken(session.ID)); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	session.IsNew = true
+	return nil
+}
+
+// MaxAge sets the maximum age for the store and the underlying cookie implementation.
+func (s *PostgresStore) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// HashToken returns the SHA-256 hash of a secret token, e.g. a session key, as it is stored in the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionUserID returns the id of the user the session belongs to, if any.
+func sessionUserID(session *sessions.Session) uuid.UUID {
+	switch id := session.Values[types.UserIdKey].(type) {
+	case uuid.UUID:
+		return id
+	case string:
+		if parsed, err := uuid.Parse(id); err == nil {
+			return parsed
+		}
+	}
+	return uuid.Nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/config"
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/gorilla/sessions"
+)
+
+func TestPostgresStore(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteTestDB(t)
+	repos := NewBunRepositories(db, nil)
+	user := createTestUser(t, repos, "session@wits.example", "")
+	cfg := config.Default().Auth
+	cfg.SessionSecret = config.Secret(strings.Repeat("s", 32))
+	store := NewPostgresStore(repos.Sessions, cfg, func(r *http.Request) string { return "192.0.2.1" })
+
+	// save stores the values of a new session for the user and returns the session cookie
+	save := func(t *testing.T) *http.Cookie {
+		t.Helper()
+		session, err := store.New(httptest.NewRequest(http.MethodGet, "/", nil), "wits")
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		session.Values[types.UserIdKey] = user.ID
+		rec := httptest.NewRecorder()
+		if err := store.Save(httptest.NewRequest(http.MethodGet, "/", nil), rec, session); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || !cookies[0].Secure || !cookies[0].HttpOnly || cookies[0].MaxAge != int(cfg.SessionMaxAge.Seconds()) {
+			t.Fatalf("Save() cookies = %+v, want one secure session cookie with the configured max age", cookies)
+		}
+		return cookies[0]
+	}
+	// load returns the session of the request with the cookie
+	load := func(t *testing.T, cookie *http.Cookie) *sessions.Session {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		session, err := store.New(req, "wits")
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		return session
+	}
+
+	t.Run("save and load", func(t *testing.T) {
+		cookie := save(t)
+		if strings.Contains(cookie.Value, user.ID.String()) {
+			t.Errorf("Save() cookie = %s, want only the session key", cookie.Value)
+		}
+		session := load(t, cookie)
+		if session.IsNew || session.Values[types.UserIdKey] != user.ID {
+			t.Errorf("New() = %+v, want the saved session of %v", session, user.ID)
+		}
+		record, err := repos.Sessions.GetSessionByKeyHash(ctx, HashToken(session.ID))
+		if err != nil || record.UserID != user.ID || record.IPAddress != "192.0.2.1" {
+			t.Errorf("GetSessionByKeyHash() = %+v, %v, want the session of %v from the client IP", record, err, user.ID)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cookie := save(t)
+		session := load(t, cookie)
+		if _, err := db.NewUpdate().Model((*types.Session)(nil)).Set("expires_at = ?", time.Now().Add(-time.Minute)).Where("key_hash = ?", HashToken(session.ID)).Exec(ctx); err != nil {
+			t.Fatalf("expiring the session failed with %v", err)
+		}
+		if expired := load(t, cookie); !expired.IsNew || len(expired.Values) != 0 {
+			t.Errorf("New() of an expired session = %+v, want a new, empty session", expired)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		cookie := save(t)
+		session := load(t, cookie)
+		session.Options.MaxAge = -1
+		rec := httptest.NewRecorder()
+		if err := store.Save(httptest.NewRequest(http.MethodGet, "/", nil), rec, session); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+			t.Errorf("Save() of a revoked session cookies = %+v, want the cookie to be removed", cookies)
+		}
+		if session := load(t, cookie); !session.IsNew {
+			t.Errorf("New() of a revoked session = %+v, want a new session", session)
+		}
+	})
+
+	t.Run("renew", func(t *testing.T) {
+		cookie := save(t)
+		session := load(t, cookie)
+		if err := store.Renew(httptest.NewRequest(http.MethodGet, "/", nil), session); err != nil {
+			t.Fatalf("Renew() error = %v", err)
+		}
+		rec := httptest.NewRecorder()
+		if err := store.Save(httptest.NewRequest(http.MethodGet, "/", nil), rec, session); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if session := load(t, cookie); !session.IsNew {
+			t.Errorf("New() with the key before the renewal = %+v, want a new session", session)
+		}
+		if renewed := load(t, rec.Result().Cookies()[0]); renewed.IsNew || renewed.Values[types.UserIdKey] != user.ID {
+			t.Errorf("New() with the renewed key = %+v, want the values of the session", renewed)
+		}
+	})
+}
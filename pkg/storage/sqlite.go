@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"database/sql"
+	"log/slog"
+	"reflect"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+	_ "modernc.org/sqlite" // Importing the pure Go sqlite driver, which needs no cgo
+)
+
+// SQLiteUsersTable is the name of the users table in SQLite, which has no auth schema.
+const SQLiteUsersTable = "auth_users"
+
+// sqlitePragmas are run on every new connection. Foreign keys are off by default in SQLite, and waiting for locks
+// avoids failing writes while another connection writes.
+const sqlitePragmas = "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// CreateSQLiteDB opens the SQLite database file at the path, creating it if it does not exist
+func CreateSQLiteDB(path string) (*sql.DB, error) {
+	slog.Info("💬 💾 (pkg/storage/sqlite.go) CreateSQLiteDB()", "path", path)
+	db, err := sql.Open("sqlite", "file:"+path+sqlitePragmas)
+	if err != nil {
+		slog.Info("🚨 💾 (pkg/storage/sqlite.go) ❓❓❓❓ 📂 Failed to create SQLite db connection with", "error", err)
+		return nil, err
+	}
+	// SQLite allows a single writer only, and every connection to :memory: would open another empty database
+	db.SetMaxOpenConns(1)
+	slog.Info("✅ 💾 (pkg/storage/sqlite.go) CreateSQLiteDB() -> 📂 Successfully created SQLite db connection with", "path", path)
+	return db, nil
+}
+
+// NewBunWithSQLite opens the bun database connection to the SQLite database file at the path, which is passed on to
+// the repositories
+func NewBunWithSQLite(path string) (*bun.DB, error) {
+	slog.Info("💬 💾 (pkg/storage/sqlite.go) NewBunWithSQLite()")
+	db, err := CreateSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		slog.Info("🚨 💾 (pkg/storage/sqlite.go) ❓❓❓❓ 📂 Failed to ping SQLite db with", "error", err)
+		return nil, err
+	}
+	bunDB := newBun(db, sqlitedialect.New())
+	slog.Info("✅ 💾 (pkg/storage/sqlite.go) NewBunWithSQLite() -> 📂 Successfully initialized Bun with SQLite db")
+	return bunDB, nil
+}
+
+// useSQLiteUsersTable points the users model, which is mapped to auth.users, to the users table of SQLite. The table
+// definitions are cached per dialect, so this only affects the given database.
+func useSQLiteUsersTable(db *bun.DB) {
+	table := db.Table(reflect.TypeFor[types.AuthenticatedUser]())
+	table.Schema = ""
+	table.Name = SQLiteUsersTable
+	table.SQLName = schema.Safe(`"` + SQLiteUsersTable + `"`)
+	table.SQLNameForSelects = table.SQLName
+}
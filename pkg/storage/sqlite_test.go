@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TheDonDope/wits-server/pkg/storage/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// newSQLiteTestDB returns a migrated SQLite database in a temporary directory, which is closed after the test.
+func newSQLiteTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := CreateSQLiteDB(filepath.Join(t.TempDir(), "wits.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLiteDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		t.Fatalf("sqlite.WithInstance() error = %v", err)
+	}
+	src, err := migrations.NewSource(DBDriverSQLite)
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, DBDriverSQLite, driver)
+	if err != nil {
+		t.Fatalf("migrate.NewWithInstance() error = %v", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	return newBun(db, sqlitedialect.New())
+}
+
+func TestRepositoriesWithSQLite(t *testing.T) {
+	testRepositories(t, newSQLiteTestDB(t))
+}
@@ -2,20 +2,16 @@ package storage
 
 import (
 	"log/slog"
-	"os"
 
+	"github.com/TheDonDope/wits-server/pkg/config"
 	"github.com/nedpals/supabase-go"
 )
 
-// SupabaseClient is the supabase client for the application
-var SupabaseClient *supabase.Client
-
-// InitSupabaseClient initializes the supabase client.
-func InitSupabaseClient() error {
-	slog.Info("💬 🛰️  (pkg/storage/supabase.go) InitSupabaseClient()")
-	sbURL := os.Getenv("SUPABASE_URL")
-	sbSecret := os.Getenv("SUPABASE_SECRET")
-	SupabaseClient = supabase.CreateClient(sbURL, sbSecret)
-	slog.Info("✅ 🛰️  (pkg/storage/supabase.go) InitSupabaseClient() -> 📂 Using Supabase client with", "url", sbURL)
-	return nil
+// NewSupabaseClient creates the supabase client of the configuration, which is passed on to the Supabase identity
+// providers.
+func NewSupabaseClient(cfg config.Supabase) *supabase.Client {
+	slog.Info("💬 🛰️  (pkg/storage/supabase.go) NewSupabaseClient()")
+	client := supabase.CreateClient(cfg.URL, cfg.Secret.Value())
+	slog.Info("✅ 🛰️  (pkg/storage/supabase.go) NewSupabaseClient() -> 📂 Using Supabase client with", "url", cfg.URL)
+	return client
 }
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BunSymptomRatingRepository is the SymptomRatingRepository backed by the symptom_ratings table. The symptoms and
+// notes are health data, so they are encrypted with the keyring.
+type BunSymptomRatingRepository struct {
+	db      bun.IDB
+	keyring *Keyring
+}
+
+// NewBunSymptomRatingRepository returns a new BunSymptomRatingRepository using the database connection and keyring.
+func NewBunSymptomRatingRepository(db bun.IDB, keyring *Keyring) *BunSymptomRatingRepository {
+	return &BunSymptomRatingRepository{db: db, keyring: keyring}
+}
+
+// CreateSymptomRating creates a rating of a symptom in the database
+func (r *BunSymptomRatingRepository) CreateSymptomRating(ctx context.Context, rating *types.SymptomRating) error {
+	slog.Info("💬 💾 (pkg/storage/symptom_rating_repo.go) CreateSymptomRating()")
+	if rating.CreatedAt.IsZero() {
+		rating.CreatedAt = time.Now()
+	}
+	rating.UpdatedAt = rating.CreatedAt
+	if err := r.keyring.EncryptFields(ctx, rating); err != nil {
+		return err
+	}
+	_, err := r.db.NewInsert().Model(rating).Exec(ctx)
+	if decryptErr := r.keyring.DecryptFields(ctx, rating); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/symptom_rating_repo.go) CreateSymptomRating() -> 📂 Symptom rating creation finished with", "error", err)
+	return err
+}
+
+// symptomRatingSortColumns are the columns by which the ratings of symptoms can be sorted. The symptoms are
+// encrypted, so they cannot be sorted by.
+var symptomRatingSortColumns = map[string]string{
+	"rated_at":   "sr.rated_at",
+	"severity":   "sr.severity",
+	"created_at": "sr.created_at",
+}
+
+// ListSymptomRatingsByOwnerID retrieves a page of the ratings of symptoms of an owner. The filters narrow them down
+// to a minimum severity and the time of the rating.
+func (r *BunSymptomRatingRepository) ListSymptomRatingsByOwnerID(ctx context.Context, ownerID uuid.UUID, opts types.ListOptions) (types.Page[types.SymptomRating], error) {
+	slog.Info("💬 💾 (pkg/storage/symptom_rating_repo.go) ListSymptomRatingsByOwnerID()", "options", opts)
+	var ratings []types.SymptomRating
+	q := r.db.NewSelect().Model(&ratings).Where("sr.owner_id = ?", ownerID)
+	if severity, err := strconv.Atoi(opts.Filters["min_severity"]); err == nil {
+		q = q.Where("sr.severity >= ?", severity)
+	}
+	if after, ok := filterTime(opts, "rated_after"); ok {
+		q = q.Where("sr.rated_at >= ?", after)
+	}
+	if before, ok := filterTime(opts, "rated_before"); ok {
+		q = q.Where("sr.rated_at < ?", before)
+	}
+	page, err := listPage(ctx, q, &ratings, opts, symptomRatingSortColumns, "sr.id")
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &page.Items)
+	}
+	slog.Info("✅ 💾 (pkg/storage/symptom_rating_repo.go) ListSymptomRatingsByOwnerID() -> 📂 Symptom rating listing finished with", "count", len(page.Items), "total", page.Total, "error", err)
+	return page, err
+}
+
+// GetSymptomRatingByIDAndOwnerID retrieves a rating of a symptom, as long as it belongs to the given owner
+func (r *BunSymptomRatingRepository) GetSymptomRatingByIDAndOwnerID(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) (types.SymptomRating, error) {
+	slog.Info("💬 💾 (pkg/storage/symptom_rating_repo.go) GetSymptomRatingByIDAndOwnerID()")
+	var rating types.SymptomRating
+	err := r.db.NewSelect().Model(&rating).
+		Where("sr.id = ?", id).
+		Where("sr.owner_id = ?", ownerID).
+		Scan(ctx)
+	if err == nil {
+		err = r.keyring.DecryptFields(ctx, &rating)
+	}
+	slog.Info("✅ 💾 (pkg/storage/symptom_rating_repo.go) GetSymptomRatingByIDAndOwnerID() -> 📂 Symptom rating retrieval finished with", "error", err)
+	return rating, err
+}
+
+// UpdateSymptomRatingVersion updates the version of a rating of a symptom, which has been read before with the
+// UpdatedAt it carries, as long as it belongs to its owner. It returns ErrVersionConflict, if the rating has been
+// changed or deleted since.
+func (r *BunSymptomRatingRepository) UpdateSymptomRatingVersion(ctx context.Context, rating *types.SymptomRating) error {
+	slog.Info("💬 💾 (pkg/storage/symptom_rating_repo.go) UpdateSymptomRatingVersion()")
+	readAt := rating.UpdatedAt
+	rating.UpdatedAt = time.Now()
+	if err := r.keyring.EncryptFields(ctx, rating); err != nil {
+		return err
+	}
+	err := updateVersion(ctx, r.db, rating, rating.ID, rating.OwnerID, readAt,
+		"symptom", "severity", "rated_at", "notes", "encrypted", "updated_at")
+	if decryptErr := r.keyring.DecryptFields(ctx, rating); err == nil {
+		err = decryptErr
+	}
+	slog.Info("✅ 💾 (pkg/storage/symptom_rating_repo.go) UpdateSymptomRatingVersion() -> 📂 Symptom rating update finished with", "error", err)
+	return err
+}
+
+// DeleteSymptomRatingVersion moves the version of a rating of a symptom, which has been read before, to the trash,
+// as long as it belongs to its owner. It returns ErrVersionConflict, if the rating has been changed or deleted since.
+func (r *BunSymptomRatingRepository) DeleteSymptomRatingVersion(ctx context.Context, rating types.SymptomRating) error {
+	slog.Info("💬 💾 (pkg/storage/symptom_rating_repo.go) DeleteSymptomRatingVersion()")
+	err := deleteVersion[types.SymptomRating](ctx, r.db, rating.ID, rating.OwnerID, rating.UpdatedAt)
+	slog.Info("✅ 💾 (pkg/storage/symptom_rating_repo.go) DeleteSymptomRatingVersion() -> 📂 Symptom rating deletion finished with", "error", err)
+	return err
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// updateVersion updates the columns of a tracked record of the owner, as long as its updated_at is still the one it
+// has been read with. The previous version is kept in the row history. It returns ErrVersionConflict, if the record
+// has been changed or deleted since.
+func updateVersion[T any](ctx context.Context, db bun.IDB, record *T, id uuid.UUID, ownerID uuid.UUID, readAt time.Time, columns ...string) error {
+	return runInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		unchanged := func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", id).Where("owner_id = ?", ownerID).Where("updated_at = ?", readAt)
+		}
+		if err := saveVersions[T](ctx, tx, ownerID, unchanged); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(record).
+			Column(columns...).
+			Where("id = ?", id).
+			Where("owner_id = ?", ownerID).
+			Where("updated_at = ?", readAt).
+			Exec(ctx)
+		return checkVersion(res, err)
+	})
+}
+
+// deleteVersion moves a tracked record of the owner to the trash, as long as its updated_at is still the one it has
+// been read with. It returns ErrVersionConflict, if the record has been changed or deleted since.
+func deleteVersion[T any](ctx context.Context, db bun.IDB, id uuid.UUID, ownerID uuid.UUID, readAt time.Time) error {
+	res, err := db.NewDelete().Model((*T)(nil)).
+		Where("id = ?", id).
+		Where("owner_id = ?", ownerID).
+		Where("updated_at = ?", readAt).
+		Exec(ctx)
+	return checkVersion(res, err)
+}
+
+// checkVersion returns ErrVersionConflict, if the change of a version of a record has not affected any row.
+func checkVersion(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrVersionConflict
+	}
+	return err
+}
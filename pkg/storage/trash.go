@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/TheDonDope/wits-server/pkg/types"
+	"github.com/uptrace/bun"
+)
+
+// TrashPurgeInterval is how often the server purges the trash.
+const TrashPurgeInterval = time.Hour
+
+// trashedModels are the models, which are soft deleted into the trash with a `bun:",soft_delete"` field and purged
+// by PurgeTrash after the TrashRetention. Soft deleted records of a model missing here stay in the trash forever.
+var trashedModels = []any{
+	(*types.ReportShare)(nil),
+	(*types.Product)(nil),
+	(*types.InventoryItem)(nil),
+	(*types.Consumption)(nil),
+	(*types.SymptomRating)(nil),
+	(*types.Prescription)(nil),
+}
+
+// PurgeTrash permanently deletes the records of all trashed models, which have been in the trash for longer than the
+// TrashRetention, together with their row history. It returns the number of deleted records.
+func PurgeTrash(ctx context.Context, db bun.IDB, now time.Time) (int64, error) {
+	slog.Info("💬 🗑️  (pkg/storage/trash.go) PurgeTrash()")
+	before := now.Add(-types.TrashRetention)
+	var purged int64
+	for _, model := range trashedModels {
+		table := db.Dialect().Tables().Get(reflect.TypeOf(model).Elem())
+		err := runInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+			expired := tx.NewSelect().Model(model).Column(table.PKs[0].Name).WhereDeleted().Where("? < ?", bun.Ident(table.SoftDeleteField.Name), before)
+			if _, err := tx.NewDelete().Model((*types.RecordVersion)(nil)).
+				Where("table_name = ?", table.Name).
+				Where("record_id IN (?)", expired).
+				Exec(ctx); err != nil {
+				return err
+			}
+			res, err := tx.NewDelete().Model(model).WhereDeleted().Where("? < ?", bun.Ident(table.SoftDeleteField.Name), before).ForceDelete().Exec(ctx)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			purged += n
+			return err
+		})
+		if err != nil {
+			slog.Error("🚨 🗑️  (pkg/storage/trash.go) ❓❓❓❓ 🗑️  Purging the trash failed with", "table", table.Name, "error", err)
+			return purged, err
+		}
+	}
+	slog.Info("✅ 🗑️  (pkg/storage/trash.go) PurgeTrash() -> 🗑️  Trash has been purged with", "count", purged)
+	return purged, nil
+}
+
+// RunTrashPurge purges the trash right away and then every interval, until the context is done. Failures are only
+// logged, as the next run catches up.
+func RunTrashPurge(ctx context.Context, db bun.IDB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = PurgeTrash(ctx, db, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
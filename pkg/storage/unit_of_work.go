@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/uptrace/bun"
+)
+
+// UnitOfWork is the interface for writes spanning multiple repositories, which have to succeed or fail together.
+type UnitOfWork interface {
+	// RunInTx runs the function with repositories bound to a transaction. The transaction is committed, if the function
+	// returns nil, and rolled back on any error or panic. Nested calls run within a savepoint of the outer transaction.
+	// The function has to use the context it is given, which the query hooks need to write within the transaction.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, repos *Repositories) error) error
+}
+
+// txContextKey is the context key of the transaction, which a BunUnitOfWork runs.
+type txContextKey struct{}
+
+// dbFromContext returns the transaction in the context, if there is one, or else the database connection.
+func dbFromContext(ctx context.Context, db bun.IDB) bun.IDB {
+	if tx, ok := ctx.Value(txContextKey{}).(bun.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// runInTx runs the function in a transaction of the database connection, which is passed on in the context as well,
+// so the query hooks write within the transaction.
+func runInTx(ctx context.Context, db bun.IDB, fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx), tx)
+	})
+}
+
+// BunUnitOfWork is the UnitOfWork backed by transactions of the bun database connection.
+type BunUnitOfWork struct {
+	db      bun.IDB
+	keyring *Keyring
+}
+
+// NewBunUnitOfWork returns a new BunUnitOfWork using the database connection. The keyring is passed on to the
+// repositories of the transactions.
+func NewBunUnitOfWork(db bun.IDB, keyring *Keyring) *BunUnitOfWork {
+	return &BunUnitOfWork{db: db, keyring: keyring}
+}
+
+// RunInTx runs the function with repositories bound to a transaction
+func (u *BunUnitOfWork) RunInTx(ctx context.Context, fn func(ctx context.Context, repos *Repositories) error) error {
+	slog.Info("💬 💾 (pkg/storage/unit_of_work.go) RunInTx()")
+	err := runInTx(ctx, u.db, func(ctx context.Context, tx bun.Tx) error {
+		return fn(ctx, NewBunRepositories(tx, u.keyring))
+	})
+	slog.Info("✅ 💾 (pkg/storage/unit_of_work.go) RunInTx() -> 📂 Transaction finished with", "error", err)
+	return err
+}
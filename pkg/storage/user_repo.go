@@ -2,52 +2,157 @@ package storage
 
 import (
 	"context"
-	"database/sql"
-	"errors"
-	"fmt"
 	"log/slog"
+	"reflect"
+	"time"
 
 	"github.com/TheDonDope/wits-server/pkg/types"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
 )
 
+// BunUserRepository is the UserRepository backed by the auth.users table.
+type BunUserRepository struct {
+	db bun.IDB
+}
+
+// NewBunUserRepository returns a new BunUserRepository using the database connection.
+func NewBunUserRepository(db bun.IDB) *BunUserRepository {
+	return &BunUserRepository{db: db}
+}
+
 // CreateAuthenticatedUser creates an authenticated user in the database
-func CreateAuthenticatedUser(user *types.AuthenticatedUser) error {
+func (r *BunUserRepository) CreateAuthenticatedUser(ctx context.Context, user *types.AuthenticatedUser) error {
 	slog.Info("💬 💾 (pkg/storage/user_repo.go) CreateAuthenticatedUser()")
-	_, err := BunDB.NewInsert().Model(user).Exec(context.Background())
+	_, err := r.db.NewInsert().Model(user).Exec(ctx)
 	slog.Info("✅ 💾 (pkg/storage/user_repo.go) CreateAuthenticatedUser() -> 📂 Authenticated user creation finished with", "error", err)
 	return err
 }
 
-// GetAuthenticatedUserByEmailAndPassword retrieves an authenticated user by the email and password
-func GetAuthenticatedUserByEmailAndPassword(email string, password string) (types.AuthenticatedUser, error) {
-	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmailAndPassword()")
-	user, err := GetAuthenticatedUserByEmail(email)
+// GetAuthenticatedUserByEmail retrieves an authenticated user by the email
+func (r *BunUserRepository) GetAuthenticatedUserByEmail(ctx context.Context, email string) (types.AuthenticatedUser, error) {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmail()")
+	var user types.AuthenticatedUser
+	err := r.db.NewSelect().Model(&user).Where("email = ?", email).Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmail() -> 📂 Authenticated user retrieval finished with", "user", user, "error", err)
+	return user, err
+}
 
-	if errors.Is(err, sql.ErrNoRows) {
-		slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmailAndPassword() -> 📖 No user with email found, returning empty user")
-		return types.AuthenticatedUser{}, err
+// GetAuthenticatedUserByUsername retrieves an authenticated user by the username of the account, ignoring case
+func (r *BunUserRepository) GetAuthenticatedUserByUsername(ctx context.Context, username string) (types.AuthenticatedUser, error) {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByUsername()")
+	var user types.AuthenticatedUser
+	err := r.db.NewSelect().Model(&user).
+		Where("id = (?)", r.db.NewSelect().Model((*types.Account)(nil)).Column("user_id").Where("lower(username) = lower(?)", username)).
+		Scan(ctx)
+	if err == nil {
+		user.Account, err = NewBunAccountRepository(r.db).GetAccountByUserID(ctx, user.ID)
 	}
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByUsername() -> 📂 Authenticated user retrieval finished with", "error", err)
+	return user, err
+}
 
-	if err != nil {
-		slog.Error("🚨 💾 (pkg/storage/user_repo.go) ❓❓❓❓ 📖 Finding user by email failed with", "error", err)
-		return types.AuthenticatedUser{}, err
-	}
+// GetAuthenticatedUserByID retrieves an authenticated user by the id
+func (r *BunUserRepository) GetAuthenticatedUserByID(ctx context.Context, id uuid.UUID) (types.AuthenticatedUser, error) {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByID()")
+	var user types.AuthenticatedUser
+	err := r.db.NewSelect().Model(&user).Where("id = ?", id).Scan(ctx)
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByID() -> 📂 Authenticated user retrieval finished with", "error", err)
+	return user, err
+}
+
+// UpdateAuthenticatedUserPassword updates the password hash of an authenticated user
+func (r *BunUserRepository) UpdateAuthenticatedUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) UpdateAuthenticatedUserPassword()")
+	_, err := r.db.NewUpdate().Model((*types.AuthenticatedUser)(nil)).
+		Set("password = ?", hashedPassword).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) UpdateAuthenticatedUserPassword() -> 📂 Password update finished with", "error", err)
+	return err
+}
 
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
-		slog.Error("🚨 💾 (pkg/storage/user_repo.go) ❓❓❓❓ 📖 Password is incorrect")
-		return types.AuthenticatedUser{}, fmt.Errorf("(pkg/storage/user_repo.go) Password is incorrect")
+// UpdateAuthenticatedUserEmail updates the email of an authenticated user
+func (r *BunUserRepository) UpdateAuthenticatedUserEmail(ctx context.Context, id uuid.UUID, email string) error {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) UpdateAuthenticatedUserEmail()")
+	_, err := r.db.NewUpdate().Model((*types.AuthenticatedUser)(nil)).
+		Set("email = ?", email).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) UpdateAuthenticatedUserEmail() -> 📂 Email update finished with", "error", err)
+	return err
+}
+
+// GetAuthenticatedUsers retrieves all users with their accounts, newest first. The password hashes are not selected.
+func (r *BunUserRepository) GetAuthenticatedUsers(ctx context.Context) ([]types.AuthenticatedUser, error) {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUsers()")
+	var users []types.AuthenticatedUser
+	if err := r.db.NewSelect().Model(&users).ExcludeColumn("password").Order("created_at DESC").Scan(ctx); err != nil {
+		slog.Error("🚨 💾 (pkg/storage/user_repo.go) ❓❓❓❓ 📂 Authenticated users retrieval failed with", "error", err)
+		return nil, err
 	}
-	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmailAndPassword() -> Found user by email and password with", "email", user.Email)
-	return user, err
+	if len(users) == 0 {
+		return users, nil
+	}
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	var accounts []types.Account
+	if err := r.db.NewSelect().Model(&accounts).Where("user_id IN (?)", bun.In(ids)).Scan(ctx); err != nil {
+		slog.Error("🚨 💾 (pkg/storage/user_repo.go) ❓❓❓❓ 📂 Accounts retrieval failed with", "error", err)
+		return nil, err
+	}
+	accountsByUserID := make(map[uuid.UUID]types.Account, len(accounts))
+	for _, account := range accounts {
+		accountsByUserID[account.UserID] = account
+	}
+	for i := range users {
+		users[i].Account = accountsByUserID[users[i].ID]
+	}
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUsers() -> 📂 Authenticated users retrieval finished with", "count", len(users))
+	return users, nil
+}
 
+// GetRegistrationStats counts the registered users, in total, recently and per month of the last year
+func (r *BunUserRepository) GetRegistrationStats(ctx context.Context) (types.RegistrationStats, error) {
+	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetRegistrationStats()")
+	var stats types.RegistrationStats
+	var err error
+	users := func() *bun.SelectQuery { return r.db.NewSelect().Model((*types.AuthenticatedUser)(nil)) }
+	if stats.Total, err = users().Count(ctx); err != nil {
+		return stats, err
+	}
+	if stats.Last7Days, err = users().Where("created_at > ?", time.Now().AddDate(0, 0, -7)).Count(ctx); err != nil {
+		return stats, err
+	}
+	if stats.Last30Days, err = users().Where("created_at > ?", time.Now().AddDate(0, 0, -30)).Count(ctx); err != nil {
+		return stats, err
+	}
+	if stats.Disabled, err = r.db.NewSelect().Model((*types.Account)(nil)).Where("disabled_at IS NOT NULL").Count(ctx); err != nil {
+		return stats, err
+	}
+	month := "date_trunc('month', created_at)"
+	if r.db.Dialect().Name() == dialect.SQLite {
+		month = "date(created_at, 'start of month')"
+	}
+	err = users().
+		ColumnExpr(month+" AS month").
+		ColumnExpr("count(*) AS count").
+		Where("created_at > ?", time.Now().AddDate(-1, 0, 0)).
+		GroupExpr("month").
+		OrderExpr("month").
+		Scan(ctx, &stats.PerMonth)
+	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetRegistrationStats() -> 📂 Registration stats retrieval finished with", "total", stats.Total, "error", err)
+	return stats, err
 }
 
-// GetAuthenticatedUserByEmail retrieves an authenticated user by the email
-func GetAuthenticatedUserByEmail(email string) (types.AuthenticatedUser, error) {
-	slog.Info("💬 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmail()")
-	var user types.AuthenticatedUser
-	err := BunDB.NewSelect().Model(&user).Where("email = ?", email).Scan(context.Background())
-	slog.Info("✅ 💾 (pkg/storage/user_repo.go) GetAuthenticatedUserByEmail() -> 📂 Authenticated user retrieval finished with", "user", user, "error", err)
-	return user, err
+// usersTable returns the quoted name of the users table for raw joins, which is auth.users in Postgres, but has no
+// schema in SQLite
+func usersTable(db bun.IDB) schema.Safe {
+	return db.Dialect().Tables().Get(reflect.TypeFor[types.AuthenticatedUser]()).SQLName
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
 	"regexp"
@@ -25,8 +26,8 @@ func TestGetAuthenticatedUserByEmail(t *testing.T) {
 	}
 	defer db.Close()
 
-	// Set up the BunDB to use the mock database
-	BunDB = bun.NewDB(db, pgdialect.New())
+	// Set up the repository to use the mock database
+	repo := NewBunUserRepository(bun.NewDB(db, pgdialect.New()))
 
 	tests := []struct {
 		name           string
@@ -41,7 +42,7 @@ func TestGetAuthenticatedUserByEmail(t *testing.T) {
 			args{email: "unknown@foo.org"},
 			func(m *sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT \"u\".\"id\", \"u\".\"email\", \"u\".\"password\", \"u\".\"created_at\", \"u\".\"updated_at\", \"u\".\"account\" FROM \"auth\".\"users\" AS \"u\""),
+					regexp.QuoteMeta("SELECT \"u\".\"id\", \"u\".\"email\", \"u\".\"password\", \"u\".\"created_at\", \"u\".\"updated_at\" FROM \"auth\".\"users\" AS \"u\""),
 				).WillReturnError(sql.ErrNoRows)
 			},
 			types.AuthenticatedUser{},
@@ -53,7 +54,7 @@ func TestGetAuthenticatedUserByEmail(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockExpectFunc(&mock)
-			got, err := GetAuthenticatedUserByEmail(tt.args.email)
+			got, err := repo.GetAuthenticatedUserByEmail(context.Background(), tt.args.email)
 			if (err != nil) != tt.shouldErr {
 				t.Errorf("GetAuthenticatedUserByEmail() error = %v, wantErr = %v, shouldErr = %v", err, tt.wantErr, tt.shouldErr)
 			}
@@ -8,9 +8,12 @@ import (
 
 // Account is the type for the account of an authenticated user.
 type Account struct {
-	ID        uuid.UUID `bun:"type:uuid,default:uuid_generate_v4()"`
-	UserID    uuid.UUID
-	Username  string
-	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
-	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ID              uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID          uuid.UUID
+	Username        string
+	Role            Role      `bun:",nullzero,notnull,default:'patient'"`
+	DisabledAt      time.Time `bun:",nullzero"`
+	TokensRevokedAt time.Time `bun:",nullzero"`
+	CreatedAt       time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 }
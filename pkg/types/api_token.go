@@ -0,0 +1,77 @@
+package types
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// APITokenContextKey is the key used to store the personal API token of a request in the context.
+const APITokenContextKey = "wits-api-token"
+
+// APITokenPrefix is the prefix of all personal API tokens, making them recognizable e.g. for secret scanners.
+const APITokenPrefix = "wits_"
+
+const (
+	// ScopeRead grants reading a resource.
+	ScopeRead = "read"
+	// ScopeWrite grants changing a resource.
+	ScopeWrite = "write"
+)
+
+const (
+	// ResourceDashboard is the dashboard of the user.
+	ResourceDashboard = "dashboard"
+	// ResourceAccount is the account of the user.
+	ResourceAccount = "account"
+	// ResourceProducts are the products of the user.
+	ResourceProducts = "products"
+	// ResourceInventory is the inventory of the user.
+	ResourceInventory = "inventory"
+	// ResourceConsumptions are the consumptions of the user.
+	ResourceConsumptions = "consumptions"
+	// ResourceSymptoms are the ratings of the symptoms of the user.
+	ResourceSymptoms = "symptoms"
+	// ResourceReportShares are the share links to the reports of the user.
+	ResourceReportShares = "report_shares"
+	// ResourceAuditEvents are the audit events of the user.
+	ResourceAuditEvents = "audit_events"
+)
+
+// APITokenResources are the resources a personal API token can be scoped to.
+var APITokenResources = []string{
+	ResourceDashboard, ResourceAccount, ResourceProducts, ResourceInventory, ResourceConsumptions, ResourceSymptoms,
+	ResourceReportShares, ResourceAuditEvents,
+}
+
+// Scope returns the scope granting the access on the resource, e.g. "dashboard:read".
+func Scope(resource string, access string) string {
+	return resource + ":" + access
+}
+
+// APIToken is a personal access token with which scripts and integrations act on behalf of a user. Only the hash of
+// the token is stored.
+type APIToken struct {
+	bun.BaseModel `bun:"api_tokens,alias:at"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid"`
+	Name          string
+	TokenHash     string
+	Prefix        string
+	Scopes        []string  `bun:",array"`
+	ExpiresAt     time.Time `bun:",nullzero"`
+	LastUsedAt    time.Time `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// HasScope reports whether the token grants the given scope.
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Expired reports whether the token has an expiry which has passed.
+func (t APIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// AuditActionLogin is recorded when a user logs in.
+	AuditActionLogin = "login.success"
+	// AuditActionLoginFailure is recorded when a login fails.
+	AuditActionLoginFailure = "login.failure"
+	// AuditActionLoginLockout is recorded when repeated failed logins lock out an email or IP address.
+	AuditActionLoginLockout = "login.lockout"
+	// AuditActionLogout is recorded when a user logs out.
+	AuditActionLogout = "logout"
+	// AuditActionRegister is recorded when a user registers.
+	AuditActionRegister = "register"
+	// AuditActionPasswordChange is recorded when a user changes their password.
+	AuditActionPasswordChange = "password.change"
+	// AuditActionEmailChange is recorded when a user confirmed the change of their email.
+	AuditActionEmailChange = "email.change"
+	// AuditActionAPITokenCreate is recorded when a user creates a personal API token.
+	AuditActionAPITokenCreate = "api_token.create"
+	// AuditActionAPITokenRevoke is recorded when a user revokes a personal API token.
+	AuditActionAPITokenRevoke = "api_token.revoke"
+	// AuditActionRoleChange is recorded when an admin changes the role of a user.
+	AuditActionRoleChange = "account.role_change"
+	// AuditActionAccountDisable is recorded when an admin disables the account of a user.
+	AuditActionAccountDisable = "account.disable"
+	// AuditActionAccountEnable is recorded when an admin enables the account of a user again.
+	AuditActionAccountEnable = "account.enable"
+	// AuditActionDelegationInvite is recorded when an account owner invites a delegate.
+	AuditActionDelegationInvite = "delegation.invite"
+	// AuditActionDelegationAccept is recorded when an invited delegate accepts a delegation.
+	AuditActionDelegationAccept = "delegation.accept"
+	// AuditActionDelegationRevoke is recorded when the owner or the delegate ends a delegation.
+	AuditActionDelegationRevoke = "delegation.revoke"
+	// AuditActionDelegationSwitch is recorded when a delegate switches to a delegated account.
+	AuditActionDelegationSwitch = "delegation.switch"
+	// AuditActionDelegationAccess is recorded for every request of a delegate on a delegated account.
+	AuditActionDelegationAccess = "delegation.access"
+	// AuditActionRecoveryCodesCreate is recorded when a user generates new recovery codes.
+	AuditActionRecoveryCodesCreate = "recovery_codes.create"
+	// AuditActionRecoveryCodeUse is recorded when a user resets their password with a recovery code.
+	AuditActionRecoveryCodeUse = "recovery_code.use"
+	// AuditActionRecoveryFailure is recorded when resetting a password with a recovery code fails.
+	AuditActionRecoveryFailure = "recovery_code.failure"
+	// AuditActionReportShareCreate is recorded when an owner creates a share link to a report.
+	AuditActionReportShareCreate = "report_share.create"
+	// AuditActionReportShareRevoke is recorded when an owner revokes a share link to a report.
+	AuditActionReportShareRevoke = "report_share.revoke"
+	// AuditActionReportShareDelete is recorded when an owner moves a share link to a report to the trash.
+	AuditActionReportShareDelete = "report_share.delete"
+	// AuditActionReportShareRestore is recorded when an owner restores a share link to a report from the trash.
+	AuditActionReportShareRestore = "report_share.restore"
+	// AuditActionReportShareView is recorded for every view of a shared report.
+	AuditActionReportShareView = "report_share.view"
+)
+
+// AuditEvent is an entry in the append-only audit log. The actor is the user who performed the action, the account
+// is the user whose data has been affected, which differs for admins and delegates.
+type AuditEvent struct {
+	bun.BaseModel `bun:"audit_events,alias:ae"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	Action        string
+	ActorID       uuid.UUID `bun:"type:uuid,nullzero"`
+	AccountID     uuid.UUID `bun:"type:uuid,nullzero"`
+	Email         string
+	IPAddress     string
+	UserAgent     string
+	Details       string
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// AuditFilter narrows down the search of the audit log. Empty fields do not filter.
+type AuditFilter struct {
+	Action    string
+	Email     string
+	IPAddress string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ConsumptionMethod is the way a product has been consumed.
+type ConsumptionMethod string
+
+const (
+	// ConsumptionMethodVaporized is inhaling the vapor of a vaporizer.
+	ConsumptionMethodVaporized ConsumptionMethod = "vaporized"
+	// ConsumptionMethodSmoked is inhaling the smoke of a joint or pipe.
+	ConsumptionMethodSmoked ConsumptionMethod = "smoked"
+	// ConsumptionMethodOral is swallowing capsules, edibles or oil.
+	ConsumptionMethodOral ConsumptionMethod = "oral"
+	// ConsumptionMethodSublingual is taking drops of oil under the tongue.
+	ConsumptionMethodSublingual ConsumptionMethod = "sublingual"
+)
+
+// ConsumptionMethods are all ways of consumption, in the order in which they are offered.
+var ConsumptionMethods = []ConsumptionMethod{ConsumptionMethodVaporized, ConsumptionMethodSmoked, ConsumptionMethodOral, ConsumptionMethodSublingual}
+
+// Consumption is a dose of a product, which the owner has consumed. The amount is in the unit of the product. The
+// notes are health data, so they are encrypted at rest. Every change sets UpdatedAt, which identifies the version of
+// the consumption.
+type Consumption struct {
+	bun.BaseModel `bun:"consumptions,alias:co"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()" encrypt:"id"`
+	OwnerID       uuid.UUID `bun:"type:uuid" encrypt:"owner"`
+	ProductID     uuid.UUID `bun:"type:uuid"`
+	Amount        float64
+	Method        ConsumptionMethod
+	ConsumedAt    time.Time
+	Notes         string    `encrypt:"field"`
+	Encrypted     bool      `bun:",notnull" encrypt:"state"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// DataKey encrypts the sensitive fields of the data of an account. It is only stored wrapped, i.e. encrypted with the
+// master key, so the database alone does not reveal the data. Deleting the keys of an account makes its encrypted
+// data unreadable.
+type DataKey struct {
+	bun.BaseModel `bun:"data_keys,alias:dk"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	AccountID     uuid.UUID `bun:"type:uuid"`
+	// MasterKeyID identifies the master key the data key is wrapped with
+	MasterKeyID string
+	WrappedKey  string
+	// RetiredAt is set once a newer data key replaced the key, which is then only used for decryption
+	RetiredAt time.Time `bun:",nullzero"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Retired reports whether a newer data key replaced the key.
+func (k DataKey) Retired() bool {
+	return !k.RetiredAt.IsZero()
+}
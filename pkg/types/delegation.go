@@ -0,0 +1,57 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ActingAsKey is the key used to store the id of the account owner, whose account a delegate has switched to, in
+// the session.
+const ActingAsKey = "wits-acting-as"
+
+// Access is the level of access a delegation grants on the account of the owner.
+type Access string
+
+const (
+	// AccessRead grants viewing the data of the owner.
+	AccessRead Access = "read"
+	// AccessReadWrite grants viewing and changing the data of the owner.
+	AccessReadWrite Access = "read_write"
+)
+
+// Delegation grants another user, e.g. a caregiver or a doctor, access to the account of the owner. It starts as an
+// invitation to an email and becomes active once the invited user accepted it.
+type Delegation struct {
+	bun.BaseModel `bun:"delegations,alias:d"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	OwnerID       uuid.UUID `bun:"type:uuid"`
+	DelegateID    uuid.UUID `bun:"type:uuid,nullzero"`
+	Email         string
+	Access        Access
+	TokenHash     string
+	AcceptedAt    time.Time `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	OwnerEmail    string    `bun:",scanonly"`
+}
+
+// Accepted reports whether the invited user accepted the delegation.
+func (d Delegation) Accepted() bool {
+	return !d.AcceptedAt.IsZero()
+}
+
+// CanWrite reports whether the delegation grants changing the data of the owner.
+func (d Delegation) CanWrite() bool {
+	return d.Access == AccessReadWrite
+}
+
+// ParseAccess returns the access level with the given name.
+func ParseAccess(name string) (Access, bool) {
+	switch Access(name) {
+	case AccessRead, AccessReadWrite:
+		return Access(name), true
+	}
+	return "", false
+}
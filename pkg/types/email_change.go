@@ -0,0 +1,19 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// EmailChange is a pending change of the email of a user, which is applied once the new address has been verified.
+type EmailChange struct {
+	bun.BaseModel `bun:"email_changes,alias:ec"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid"`
+	NewEmail      string
+	TokenHash     string
+	ExpiresAt     time.Time `bun:",notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
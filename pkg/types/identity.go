@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Identity links an identity of an external OpenID Connect provider to a local user.
+type Identity struct {
+	bun.BaseModel `bun:"user_identities,alias:ui"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid"`
+	Issuer        string
+	Subject       string
+	Email         string
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
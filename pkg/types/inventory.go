@@ -0,0 +1,22 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// InventoryItem is a stock of a product, which the owner has acquired. The amount is the remaining amount in the unit
+// of the product. Every change sets UpdatedAt, which identifies the version of the item.
+type InventoryItem struct {
+	bun.BaseModel `bun:"inventory_items,alias:ii"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	OwnerID       uuid.UUID `bun:"type:uuid"`
+	ProductID     uuid.UUID `bun:"type:uuid"`
+	Amount        float64
+	AcquiredAt    time.Time
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+}
@@ -0,0 +1,31 @@
+package types
+
+const (
+	// DefaultListLimit is the number of items of a page, if no limit is given.
+	DefaultListLimit = 20
+	// MaxListLimit is the maximum number of items of a page.
+	MaxListLimit = 100
+)
+
+// SortField sorts a listing by a field, ascending unless Desc is set.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions pages, filters and sorts the listing of a resource. The fields are checked against the filters and sort
+// fields, which the resource allows, before they reach the repositories. Empty filters do not filter.
+type ListOptions struct {
+	Limit   int
+	Offset  int
+	Sort    []SortField
+	Filters map[string]string
+}
+
+// Page is a page of a listing together with the total number of items matching the filters.
+type Page[T any] struct {
+	Items  []T
+	Total  int
+	Limit  int
+	Offset int
+}
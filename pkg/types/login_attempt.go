@@ -0,0 +1,29 @@
+package types
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// LoginAttempt counts the failed login attempts for a throttling key, e.g. an IP address or an email.
+type LoginAttempt struct {
+	bun.BaseModel `bun:"login_attempts,alias:la"`
+	Key           string    `bun:",pk"`
+	Failures      int       `bun:",notnull"`
+	LastFailureAt time.Time `bun:",nullzero"`
+	LockedUntil   time.Time `bun:",nullzero"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// LoginFailure describes a failed login to be counted for a throttling key in a single step.
+type LoginFailure struct {
+	Key string
+	// At is the time of the failure
+	At time.Time
+	// ResetBefore starts the counter over, if the last failure was before it
+	ResetBefore time.Time
+	// LockoutThreshold is the number of failures which locks the key out until LockUntil
+	LockoutThreshold int
+	LockUntil        time.Time
+}
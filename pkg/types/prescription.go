@@ -0,0 +1,26 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Prescription is a prescription of a product to the owner, which allows them to get the amount of it until it
+// expires. The amount is in the unit of the product. The name of the doctor reveals the treatment, so it is encrypted
+// at rest.
+type Prescription struct {
+	bun.BaseModel `bun:"prescriptions,alias:pr"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()" encrypt:"id"`
+	OwnerID       uuid.UUID `bun:"type:uuid" encrypt:"owner"`
+	ProductID     uuid.UUID `bun:"type:uuid"`
+	Doctor        string    `encrypt:"field"`
+	Amount        float64
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+	Encrypted     bool      `bun:",notnull" encrypt:"state"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ProductKind is the kind of a product, which determines the unit of its amounts.
+type ProductKind string
+
+const (
+	// ProductKindFlower are dried flowers, tracked in grams.
+	ProductKindFlower ProductKind = "flower"
+	// ProductKindExtract are concentrates like resins, tracked in grams.
+	ProductKindExtract ProductKind = "extract"
+	// ProductKindOil are oils and tinctures, tracked in millilitres.
+	ProductKindOil ProductKind = "oil"
+	// ProductKindEdible are capsules and edibles, tracked in pieces.
+	ProductKindEdible ProductKind = "edible"
+)
+
+// ProductKinds are all kinds of products, in the order in which they are offered.
+var ProductKinds = []ProductKind{ProductKindFlower, ProductKindExtract, ProductKindOil, ProductKindEdible}
+
+// Unit returns the unit of the amounts of the products of the kind.
+func (k ProductKind) Unit() string {
+	switch k {
+	case ProductKindOil:
+		return "ml"
+	case ProductKindEdible:
+		return "pcs"
+	default:
+		return "g"
+	}
+}
+
+// Product is a cannabis product, which the owner keeps in their inventory and consumes. THC and CBD are its contents
+// in percent. Every change sets UpdatedAt, which identifies the version of the product.
+type Product struct {
+	bun.BaseModel `bun:"products,alias:p"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	OwnerID       uuid.UUID `bun:"type:uuid"`
+	Name          string
+	Kind          ProductKind
+	THC           float64
+	CBD           float64
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// TrashRetention is how long deleted records are kept in the trash, where they can be restored, before they are
+// deleted permanently.
+const TrashRetention = 30 * 24 * time.Hour
+
+// PurgeAt returns when a record deleted at the given time is deleted permanently.
+func PurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(TrashRetention)
+}
+
+// RecordVersion is a previous version of an edited record, which is stored as JSON in the row history before the
+// record is changed. Encrypted fields stay encrypted in the history.
+type RecordVersion struct {
+	bun.BaseModel `bun:"record_versions,alias:rv"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	TableName     string
+	RecordID      uuid.UUID `bun:"type:uuid"`
+	OwnerID       uuid.UUID `bun:"type:uuid"`
+	Data          string    `bun:"type:jsonb"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RecoveryCodeCount is the number of recovery codes generated for a user at once.
+const RecoveryCodeCount = 10
+
+// RecoveryCode is a single-use code with which a pseudonymous user resets their password, in place of a recovery
+// email. Only the hash of the code is stored.
+type RecoveryCode struct {
+	bun.BaseModel `bun:"recovery_codes,alias:rc"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid"`
+	CodeHash      string
+	UsedAt        time.Time `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
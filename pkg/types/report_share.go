@@ -0,0 +1,53 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ReportShare is an expiring, revocable, read-only link to the report of an owner for a date range, e.g. for a
+// doctor without an account. Only the hash of the link token is stored, and the name is encrypted at rest. Deleted
+// shares are kept in the trash for the TrashRetention.
+type ReportShare struct {
+	bun.BaseModel `bun:"report_shares,alias:rs"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()" encrypt:"id"`
+	OwnerID       uuid.UUID `bun:"type:uuid" encrypt:"owner"`
+	Name          string    `encrypt:"field"`
+	Encrypted     bool      `bun:",notnull" encrypt:"state"`
+	TokenHash     string
+	From          time.Time `bun:"from_date,type:date"`
+	To            time.Time `bun:"to_date,type:date"`
+	ExpiresAt     time.Time
+	RevokedAt     time.Time `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+	Views         int       `bun:",scanonly"`
+	LastViewedAt  time.Time `bun:",scanonly,nullzero"`
+}
+
+// Expired reports whether the expiry of the share has passed.
+func (s ReportShare) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// Revoked reports whether the owner has revoked the share.
+func (s ReportShare) Revoked() bool {
+	return !s.RevokedAt.IsZero()
+}
+
+// Active reports whether the share can still be viewed.
+func (s ReportShare) Active() bool {
+	return !s.Revoked() && !s.Expired()
+}
+
+// ReportShareView is a single view of a shared report.
+type ReportShareView struct {
+	bun.BaseModel `bun:"report_share_views,alias:rsv"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	ShareID       uuid.UUID `bun:"type:uuid"`
+	IPAddress     string
+	UserAgent     string
+	ViewedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Role is the role of a user, granting a set of permissions.
+type Role string
+
+const (
+	// RoleAdmin administrates the users of Wits.
+	RoleAdmin Role = "admin"
+	// RolePatient tracks their own data. It is the role of every new user.
+	RolePatient Role = "patient"
+	// RoleCaregiver looks after patients. Caregivers track no data of their own, they only view the accounts delegated
+	// to them.
+	RoleCaregiver Role = "caregiver"
+)
+
+// Roles are all roles, in the order in which they are offered.
+var Roles = []Role{RolePatient, RoleCaregiver, RoleAdmin}
+
+// Permission allows a user to perform an action.
+type Permission string
+
+const (
+	// PermissionViewDashboard allows viewing the own dashboard.
+	PermissionViewDashboard Permission = "dashboard.view"
+	// PermissionTrackData allows tracking the own products, inventory, consumptions and symptoms.
+	PermissionTrackData Permission = "data.track"
+	// PermissionViewDelegated allows switching to the accounts delegated to the user and viewing their dashboard.
+	PermissionViewDelegated Permission = "delegated.view"
+	// PermissionManageAccount allows changing the own settings, sessions and API tokens.
+	PermissionManageAccount Permission = "account.manage"
+	// PermissionManageUsers allows listing users, changing their roles and disabling them.
+	PermissionManageUsers Permission = "users.manage"
+	// PermissionViewStats allows viewing the registration statistics.
+	PermissionViewStats Permission = "stats.view"
+	// PermissionViewAudit allows searching the audit log of all users.
+	PermissionViewAudit Permission = "audit.view"
+)
+
+// RolePermissions are the permissions granted by each role.
+var RolePermissions = map[Role][]Permission{
+	RoleAdmin:     {PermissionViewDashboard, PermissionTrackData, PermissionViewDelegated, PermissionManageAccount, PermissionManageUsers, PermissionViewStats, PermissionViewAudit},
+	RolePatient:   {PermissionViewDashboard, PermissionTrackData, PermissionViewDelegated, PermissionManageAccount},
+	RoleCaregiver: {PermissionViewDelegated, PermissionManageAccount},
+}
+
+// ParseRole returns the role with the given name.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if !slices.Contains(Roles, role) {
+		return "", fmt.Errorf("unknown role %q", name)
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(permission Permission) bool {
+	return slices.Contains(RolePermissions[r], permission)
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRoleCan(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission Permission
+		want       map[Role]bool
+	}{
+		{"Only admins and patients should view their own dashboard", PermissionViewDashboard, map[Role]bool{RoleAdmin: true, RolePatient: true, RoleCaregiver: false}},
+		{"Only admins and patients should track their own data", PermissionTrackData, map[Role]bool{RoleAdmin: true, RolePatient: true, RoleCaregiver: false}},
+		{"Every role should view delegated accounts", PermissionViewDelegated, map[Role]bool{RoleAdmin: true, RolePatient: true, RoleCaregiver: true}},
+		{"Every role should manage their account", PermissionManageAccount, map[Role]bool{RoleAdmin: true, RolePatient: true, RoleCaregiver: true}},
+		{"Only admins should manage users", PermissionManageUsers, map[Role]bool{RoleAdmin: true, RolePatient: false, RoleCaregiver: false}},
+		{"Only admins should view the audit log", PermissionViewAudit, map[Role]bool{RoleAdmin: true, RolePatient: false, RoleCaregiver: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for role, want := range tt.want {
+				if got := role.Can(tt.permission); got != want {
+					t.Errorf("%s.Can(%s) = %v, want %v", role, tt.permission, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRolePermissionsDiffer(t *testing.T) {
+	for i, a := range Roles {
+		for _, b := range Roles[i+1:] {
+			same := len(RolePermissions[a]) == len(RolePermissions[b])
+			for _, permission := range RolePermissions[a] {
+				same = same && b.Can(permission)
+			}
+			if same {
+				t.Errorf("RolePermissions[%s] = RolePermissions[%s] = %v, want every role to grant different permissions", a, b, RolePermissions[a])
+			}
+		}
+	}
+}
+
+func TestAuthenticatedUserCanViewDashboard(t *testing.T) {
+	delegation := &Delegation{OwnerID: uuid.New(), Access: AccessRead}
+	tests := []struct {
+		name     string
+		role     Role
+		actingAs *Delegation
+		want     bool
+	}{
+		{"Patient should view their own dashboard", RolePatient, nil, true},
+		{"Caregiver should not view an own dashboard", RoleCaregiver, nil, false},
+		{"Caregiver should view the dashboard of a delegated account", RoleCaregiver, delegation, true},
+		{"Patient should view the dashboard of a delegated account", RolePatient, delegation, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := AuthenticatedUser{LoggedIn: true, Account: Account{Role: tt.role}, ActingAs: tt.actingAs}
+			if got := user.Can(PermissionViewDashboard); got != tt.want {
+				t.Errorf("Can(%s) = %v, want %v", PermissionViewDashboard, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Session is the server-side record of a login session, including the device it was created on.
+type Session struct {
+	bun.BaseModel `bun:"sessions,alias:s"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid,nullzero"`
+	KeyHash       string
+	Data          string
+	UserAgent     string
+	IPAddress     string
+	LastSeenAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt     time.Time `bun:",notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	Current       bool      `bun:"-"`
+}
+
+// Device returns a short, human readable description of the browser and operating system of the session.
+func (s Session) Device() string {
+	ua := s.UserAgent
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	os := "unknown device"
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+	return browser + " on " + os
+}
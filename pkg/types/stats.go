@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+// RegistrationStats are the statistics about the registrations of users, shown in the admin area.
+type RegistrationStats struct {
+	Total      int
+	Last7Days  int
+	Last30Days int
+	Disabled   int
+	PerMonth   []MonthlyRegistrations
+}
+
+// MonthlyRegistrations is the number of users registered in a month.
+type MonthlyRegistrations struct {
+	Month time.Time `bun:"month"`
+	Count int       `bun:"count"`
+}
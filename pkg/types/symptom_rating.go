@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// MaxSeverity is the severity of the worst symptoms, 0 stands for no symptoms.
+const MaxSeverity = 10
+
+// SymptomRating is the severity of a symptom of the owner at a time, e.g. to follow the effect of the consumptions.
+// The symptom and the notes are health data, so they are encrypted at rest. Every change sets UpdatedAt, which
+// identifies the version of the rating.
+type SymptomRating struct {
+	bun.BaseModel `bun:"symptom_ratings,alias:sr"`
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()" encrypt:"id"`
+	OwnerID       uuid.UUID `bun:"type:uuid" encrypt:"owner"`
+	Symptom       string    `encrypt:"field"`
+	Severity      int
+	RatedAt       time.Time
+	Notes         string    `encrypt:"field"`
+	Encrypted     bool      `bun:",notnull" encrypt:"state"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time `bun:",soft_delete,nullzero"`
+}
@@ -16,12 +16,65 @@ const UserIdKey = "wits-user-id"
 // AuthenticatedUser represents the wrapper for an authenticated user and their logged-in state, as well as embedding the account.
 type AuthenticatedUser struct {
 	bun.BaseModel `bun:"auth.users,alias:u"`
-	ID            uuid.UUID `bun:"type:uuid,default:uuid_generate_v4()"`
-	Email         string
+	ID            uuid.UUID `bun:"type:uuid,pk,default:uuid_generate_v4()"`
+	Email         string    `bun:",nullzero"`
 	Password      string
 	LoggedIn      bool      `bun:"-"`
 	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 	UpdatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 
-	Account Account
+	Account Account `bun:"-"`
+
+	// ActingAs is the delegation of the account the user has switched to, if any
+	ActingAs *Delegation `bun:"-"`
+	// Delegations are the accepted delegations of other accounts to the user
+	Delegations []Delegation `bun:"-"`
+}
+
+// Pseudonymous reports whether the user registered with a generated username instead of an email.
+func (u AuthenticatedUser) Pseudonymous() bool {
+	return u.Email == "" && u.Account.Username != ""
+}
+
+// LoginName returns the identifier the user logs in with, which is the email or, for pseudonymous users, the
+// generated username.
+func (u AuthenticatedUser) LoginName() string {
+	if u.Pseudonymous() {
+		return u.Account.Username
+	}
+	return u.Email
+}
+
+// Role returns the role of the user. Users without an account are patients.
+func (u AuthenticatedUser) Role() Role {
+	if u.Account.Role == "" {
+		return RolePatient
+	}
+	return u.Account.Role
+}
+
+// Can reports whether the role of the user grants the permission. On a delegated account the user has switched to,
+// the dashboard of the owner is viewed with the permission to view delegated accounts instead of the own one.
+func (u AuthenticatedUser) Can(permission Permission) bool {
+	if !u.LoggedIn {
+		return false
+	}
+	if u.ActingAs != nil && permission == PermissionViewDashboard {
+		return u.Role().Can(PermissionViewDelegated)
+	}
+	return u.Role().Can(permission)
+}
+
+// Disabled reports whether the account of the user has been disabled by an admin.
+func (u AuthenticatedUser) Disabled() bool {
+	return !u.Account.DisabledAt.IsZero()
+}
+
+// OwnerID returns the id of the user whose data is shown, which is the owner of the delegated account the user has
+// switched to, or the user themselves.
+func (u AuthenticatedUser) OwnerID() uuid.UUID {
+	if u.ActingAs != nil {
+		return u.ActingAs.OwnerID
+	}
+	return u.ID
 }